@@ -4,21 +4,22 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"os"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var completion = &cobra.Command{
-	Use:   "completion SHELL",
-	Short: "Output shell completion code",
+	Use:       "completion SHELL",
+	Short:     "Output shell completion code",
+	ValidArgs: []string{"bash", "zsh", "fish"},
 	Long: `Generates shell completion code.
 
-Auto completion supports both bash and zsh. Output is to STDOUT.
+Auto completion supports bash, zsh and fish. Output is to STDOUT.
 
 source <(kompose completion bash)
 source <(kompose completion zsh)
+kompose completion fish | source
 
 Will load the shell completion code.
 	`,
@@ -39,24 +40,35 @@ func Generate(cmd *cobra.Command, args []string) error {
 
 	// Check the passed in arguments
 	if len(args) == 0 {
-		return fmt.Errorf("Shell not specified. ex. kompose completion [bash|zsh]")
+		return fmt.Errorf("Shell not specified. ex. kompose completion [bash|zsh|fish]")
 	}
 	if len(args) > 1 {
-		return fmt.Errorf("Too many arguments. Expected only the shell type. ex. kompose completion [bash|zsh]")
+		return fmt.Errorf("Too many arguments. Expected only the shell type. ex. kompose completion [bash|zsh|fish]")
 	}
 	shell := args[0]
 
 	// Generate bash through cobra if selected
 	if shell == "bash" {
-		return cmd.Root().GenBashCompletion(os.Stdout)
+		return cmd.Root().GenBashCompletion(cmd.OutOrStdout())
 
 		// Generate zsh with the appropriate conversion as well as bash inclusion
 	} else if shell == "zsh" {
-		return runCompletionZsh(os.Stdout, cmd.Root())
+		return runCompletionZsh(cmd.OutOrStdout(), cmd.Root())
+
+		// Generate fish through cobra if selected
+	} else if shell == "fish" {
+		return cmd.Root().GenFishCompletion(cmd.OutOrStdout(), true)
 
 		// Else, return an error.
 	} else {
-		return fmt.Errorf("not a compatible shell, bash and zsh are only supported")
+		return fmt.Errorf("not a compatible shell, bash, zsh and fish are only supported")
+	}
+}
+
+// completeValues returns a completion function offering the given flag values
+func completeValues(values ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return values, cobra.ShellCompDirectiveNoFileComp
 	}
 }
 
@@ -0,0 +1,74 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// execute runs kompose with args and returns its output
+func execute(t *testing.T, args ...string) string {
+	t.Helper()
+	// the commands set the formatter of the standard logger
+	formatter := log.StandardLogger().Formatter
+	defer func() {
+		log.SetFormatter(formatter)
+		warningSummary = nil
+	}()
+
+	var out bytes.Buffer
+	RootCmd.SetOut(&out)
+	RootCmd.SetErr(ioutil.Discard)
+	RootCmd.SetArgs(args)
+	defer RootCmd.SetOut(nil)
+	defer RootCmd.SetErr(nil)
+	defer RootCmd.SetArgs(nil)
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestCompletion(t *testing.T) {
+	testCases := map[string]struct {
+		args     []string
+		expected []string
+	}{
+		"Bash": {[]string{"completion", "bash"}, []string{"# bash completion for kompose", "__start_kompose"}},
+		"Zsh":  {[]string{"completion", "zsh"}, []string{"__kompose_bash_source", "__start_kompose"}},
+		"Fish": {[]string{"completion", "fish"}, []string{"# fish completion for kompose", "complete -c kompose"}},
+		// the values of the flags are completed without files
+		"Flag values":                     {[]string{"__complete", "convert", "--format", ""}, []string{"yaml\njson\n:4\n"}},
+		"Flag values of the root command": {[]string{"__complete", "convert", "--provider", "o"}, []string{"kubernetes\nopenshift\n:4\n"}},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			out := execute(t, test.args...)
+			for _, expected := range test.expected {
+				if !strings.Contains(out, expected) {
+					t.Errorf("Expected the output of kompose %s to contain %q, got:\n%s", strings.Join(test.args, " "), expected, out)
+				}
+			}
+		})
+	}
+}
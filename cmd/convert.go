@@ -33,12 +33,14 @@ var (
 	ConvertBuildBranch           string
 	ConvertBuild                 string
 	ConvertVolumes               string
+	ConvertWindowsDrivePrefix    string
 	ConvertChart                 bool
 	ConvertDeployment            bool
 	ConvertDaemonSet             bool
 	ConvertReplicationController bool
 	ConvertYaml                  bool
 	ConvertJSON                  bool
+	ConvertFormat                string
 	ConvertStdout                bool
 	ConvertEmptyVols             bool
 	ConvertInsecureRepo          bool
@@ -46,8 +48,53 @@ var (
 	ConvertReplicas              int
 	ConvertController            string
 	ConvertPushImage             bool
+	ConvertPushRegistryUsername  string
+	ConvertPushRegistryPassword  string
+	ConvertPushRegistryToken     string
+	ConvertPushInsecureRegistry  bool
+	ConvertPushChart             string
+	ConvertPushPlainHTTP         bool
 	ConvertOpt                   kobject.ConvertOptions
 	ConvertYAMLIndent            int
+	ConvertDiff                  bool
+	ConvertMesh                  string
+	ConvertHostGatewayIP         string
+	ConvertChecksumAnnotations   bool
+	ConvertPruneUnused           bool
+	ConvertGroupBy               string
+	ConvertNamePorts             bool
+	ConvertDependsOnReadiness    bool
+	ConvertNamespacePerNetwork   bool
+	ConvertNameStrategy          string
+	ConvertKubectlCompatible     bool
+	ConvertKubeconfig            string
+	ConvertDiscover              bool
+	ConvertServer                string
+	ConvertToken                 string
+	ConvertCertificateAuthority  string
+	ConvertSmartDefaults         bool
+	ConvertZeroTrust             bool
+	ConvertProgress              bool
+	ConvertHostSockets           bool
+	ConvertPinCPUs               bool
+	ConvertMapping               string
+	ConvertExtraResources        string
+	ConvertFSGroupFromUser       bool
+	ConvertSplitServicePorts     bool
+	ConvertHeaderFile            string
+	ConvertRemoveStaleFiles      bool
+	ConvertPolicy                string
+	ConvertSingletonPDB          bool
+	ConvertSummaryFormat         string
+	ConvertLocalCluster          bool
+	ConvertLocalMountRoot        string
+	ConvertSCCBindings           bool
+	ConvertAutoIngress           string
+	ConvertPartOf                string
+	ConvertLegacyLabels          bool
+	ConvertPreserveSelectors     string
+	ConvertEnvExclude            []string
+	ConvertEnvMask               []string
 
 	UpBuild string
 
@@ -57,7 +104,7 @@ var (
 )
 
 var convertCmd = &cobra.Command{
-	Use:   "convert [file]",
+	Use:   "convert [--diff old-file new-file]",
 	Short: "Convert a Docker Compose file",
 	PreRun: func(cmd *cobra.Command, args []string) {
 
@@ -72,8 +119,10 @@ var convertCmd = &cobra.Command{
 			CreateChart:                 ConvertChart,
 			GenerateYaml:                ConvertYaml,
 			GenerateJSON:                ConvertJSON,
+			Format:                      strings.ToLower(ConvertFormat),
 			Replicas:                    ConvertReplicas,
 			InputFiles:                  GlobalFiles,
+			ProjectName:                 GlobalProjectName,
 			OutFile:                     ConvertOut,
 			Provider:                    GlobalProvider,
 			CreateD:                     ConvertDeployment,
@@ -83,9 +132,16 @@ var convertCmd = &cobra.Command{
 			BuildRepo:                   ConvertBuildRepo,
 			BuildBranch:                 ConvertBuildBranch,
 			PushImage:                   ConvertPushImage,
+			PushRegistryUsername:        ConvertPushRegistryUsername,
+			PushRegistryPassword:        ConvertPushRegistryPassword,
+			PushRegistryToken:           ConvertPushRegistryToken,
+			PushInsecureRegistry:        ConvertPushInsecureRegistry,
+			PushChart:                   ConvertPushChart,
+			PushPlainHTTP:               ConvertPushPlainHTTP,
 			CreateDeploymentConfig:      ConvertDeploymentConfig,
 			EmptyVols:                   ConvertEmptyVols,
 			Volumes:                     ConvertVolumes,
+			WindowsDrivePrefix:          ConvertWindowsDrivePrefix,
 			InsecureRepository:          ConvertInsecureRepo,
 			IsDeploymentFlag:            cmd.Flags().Lookup("deployment").Changed,
 			IsDaemonSetFlag:             cmd.Flags().Lookup("daemon-set").Changed,
@@ -95,14 +151,63 @@ var convertCmd = &cobra.Command{
 			IsDeploymentConfigFlag:      cmd.Flags().Lookup("deployment-config").Changed,
 			YAMLIndent:                  ConvertYAMLIndent,
 			WithKomposeAnnotation:       WithKomposeAnnotation,
+			Diff:                        ConvertDiff,
+			Mesh:                        ConvertMesh,
+			HostGatewayIP:               ConvertHostGatewayIP,
+			ChecksumAnnotations:         ConvertChecksumAnnotations,
+			PruneUnused:                 ConvertPruneUnused,
+			GroupBy:                     ConvertGroupBy,
+			NamePorts:                   ConvertNamePorts,
+			DependsOnReadiness:          ConvertDependsOnReadiness,
+			NamespacePerNetwork:         ConvertNamespacePerNetwork,
+			NameStrategy:                ConvertNameStrategy,
+			KubectlCompatible:           ConvertKubectlCompatible,
+			Kubeconfig:                  ConvertKubeconfig,
+			Discover:                    ConvertDiscover,
+			Server:                      ConvertServer,
+			Token:                       ConvertToken,
+			CertificateAuthority:        ConvertCertificateAuthority,
+			SmartDefaults:               ConvertSmartDefaults,
+			ZeroTrust:                   ConvertZeroTrust,
+			Progress:                    ConvertProgress,
+			HostSockets:                 ConvertHostSockets,
+			PinCPUs:                     ConvertPinCPUs,
+			Mapping:                     ConvertMapping,
+			ExtraResources:              ConvertExtraResources,
+			FSGroupFromUser:             ConvertFSGroupFromUser,
+			SplitServicePorts:           ConvertSplitServicePorts,
+			HeaderFile:                  ConvertHeaderFile,
+			RemoveStaleFiles:            ConvertRemoveStaleFiles,
+			Policy:                      ConvertPolicy,
+			SingletonPDB:                ConvertSingletonPDB,
+			SummaryFormat:               strings.ToLower(ConvertSummaryFormat),
+			SOPSAgeKeyFile:              GlobalSOPSAgeKeyFile,
+			LocalCluster:                ConvertLocalCluster,
+			LocalMountRoot:              ConvertLocalMountRoot,
+			SCCBindings:                 ConvertSCCBindings,
+			AutoIngress:                 ConvertAutoIngress,
+			PartOf:                      ConvertPartOf,
+			LegacyLabels:                ConvertLegacyLabels,
+			PreserveSelectors:           ConvertPreserveSelectors,
+			EnvExclude:                  ConvertEnvExclude,
+			EnvMask:                     ConvertEnvMask,
 		}
 
+		// Use the kompose config file for anything not set on the command line
+		app.ApplyConfig(GlobalConfig, cmd, &ConvertOpt)
+
 		// Validate before doing anything else. Use "bundle" if passed in.
 		app.ValidateFlags(GlobalBundle, args, cmd, &ConvertOpt)
-		app.ValidateComposeFile(&ConvertOpt)
+		if !ConvertOpt.Diff {
+			app.ValidateComposeFile(&ConvertOpt)
+		}
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 
+		if ConvertOpt.Diff {
+			app.Diff(ConvertOpt, args[0], args[1])
+			return
+		}
 		app.Convert(ConvertOpt)
 	},
 }
@@ -117,7 +222,8 @@ func init() {
 	convertCmd.Flags().BoolVar(&ConvertDaemonSet, "daemon-set", false, "Generate a Kubernetes daemonset object (deprecated, use --controller instead)")
 	convertCmd.Flags().BoolVarP(&ConvertDeployment, "deployment", "d", false, "Generate a Kubernetes deployment object (deprecated, use --controller instead)")
 	convertCmd.Flags().BoolVar(&ConvertReplicationController, "replication-controller", false, "Generate a Kubernetes replication controller object (deprecated, use --controller instead)")
-	convertCmd.Flags().StringVar(&ConvertController, "controller", "", `Set the output controller ("deployment"|"daemonSet"|"replicationController")`)
+	convertCmd.Flags().StringVar(&ConvertController, "controller", "", `Set the output controller ("deployment"|"daemonSet"|"replicationController"|"statefulSet"|"deploymentConfig"(OpenShift only))`)
+	convertCmd.RegisterFlagCompletionFunc("controller", completeValues("deployment", "daemonSet", "replicationController", "statefulSet", "deploymentConfig"))
 	convertCmd.Flags().MarkDeprecated("daemon-set", "use --controller")
 	convertCmd.Flags().MarkDeprecated("deployment", "use --controller")
 	convertCmd.Flags().MarkDeprecated("replication-controller", "use --controller")
@@ -127,27 +233,81 @@ func init() {
 	convertCmd.Flags().MarkHidden("deployment")
 
 	// OpenShift only
-	convertCmd.Flags().BoolVar(&ConvertDeploymentConfig, "deployment-config", true, "Generate an OpenShift deploymentconfig object")
+	convertCmd.Flags().BoolVar(&ConvertDeploymentConfig, "deployment-config", true, "Generate an OpenShift deploymentconfig object (deprecated, use --controller instead)")
 	convertCmd.Flags().BoolVar(&ConvertInsecureRepo, "insecure-repository", false, "Use an insecure Docker repository for OpenShift ImageStream")
 	convertCmd.Flags().StringVar(&ConvertBuildRepo, "build-repo", "", "Specify source repository for buildconfig (default remote origin)")
 	convertCmd.Flags().StringVar(&ConvertBuildBranch, "build-branch", "", "Specify repository branch to use for buildconfig (default master)")
+	convertCmd.Flags().BoolVar(&ConvertSCCBindings, "scc-bindings", false, "Run the pods needing a SecurityContextConstraints other than restricted with a ServiceAccount bound to it")
 	convertCmd.Flags().MarkDeprecated("deployment-config", "use --controller")
 	convertCmd.Flags().MarkHidden("deployment-config")
 	convertCmd.Flags().MarkHidden("insecure-repository")
 	convertCmd.Flags().MarkHidden("build-repo")
 	convertCmd.Flags().MarkHidden("build-branch")
+	convertCmd.Flags().MarkHidden("scc-bindings")
 
 	// Standard between the two
 	convertCmd.Flags().StringVar(&ConvertBuild, "build", "none", `Set the type of build ("local"|"build-config"(OpenShift only)|"none")`)
 	convertCmd.Flags().BoolVar(&ConvertPushImage, "push-image", true, "If we should push the docker image we built")
-	convertCmd.Flags().BoolVarP(&ConvertYaml, "yaml", "y", false, "Generate resource files into YAML format")
+	convertCmd.Flags().StringVar(&ConvertPushRegistryUsername, "push-registry-username", "", "User name to push the images with, instead of the credentials of the Docker config file")
+	convertCmd.Flags().StringVar(&ConvertPushRegistryPassword, "push-registry-password", "", "Password of --push-registry-username (default $KOMPOSE_PUSH_REGISTRY_PASSWORD)")
+	convertCmd.Flags().StringVar(&ConvertPushRegistryToken, "push-registry-token", "", "Registry token to push the images with, instead of the credentials of the Docker config file (default $KOMPOSE_PUSH_REGISTRY_TOKEN)")
+	convertCmd.Flags().BoolVar(&ConvertPushInsecureRegistry, "push-insecure-registry", false, "Push the images to a registry the Docker daemon reaches over plain HTTP or with an untrusted certificate, as set in its insecure-registries")
+	convertCmd.Flags().StringVar(&ConvertPushChart, "push", "", "Package the chart generated with --chart and push it to an OCI registry, e.g. oci://registry.example.com/charts")
+	convertCmd.Flags().BoolVar(&ConvertPushPlainHTTP, "push-plain-http", false, "Push the chart of --push to an OCI registry served over plain HTTP instead of HTTPS")
+	convertCmd.Flags().StringVar(&ConvertFormat, "format", "", `Format of the generated files and of stdout ("yaml"|"json") (default "yaml")`)
+	convertCmd.RegisterFlagCompletionFunc("format", completeValues("yaml", "json"))
+	convertCmd.Flags().BoolVarP(&ConvertYaml, "yaml", "y", false, "Generate resource files into YAML format (same as --format yaml)")
 	convertCmd.Flags().MarkDeprecated("yaml", "YAML is the default format now.")
 	convertCmd.Flags().MarkShorthandDeprecated("y", "YAML is the default format now.")
-	convertCmd.Flags().BoolVarP(&ConvertJSON, "json", "j", false, "Generate resource files into JSON format")
+	convertCmd.Flags().BoolVarP(&ConvertJSON, "json", "j", false, "Generate resource files into JSON format (same as --format json)")
 	convertCmd.Flags().BoolVar(&ConvertStdout, "stdout", false, "Print converted objects to stdout")
-	convertCmd.Flags().StringVarP(&ConvertOut, "out", "o", "", "Specify a file name or directory to save objects to (if path does not exist, a file will be created)")
+	convertCmd.Flags().StringVarP(&ConvertOut, "out", "o", "", "Specify a file name or directory to save objects to (if path does not exist, a file will be created, a .tar.gz or .tgz file name bundles the objects into an archive)")
+	convertCmd.Flags().BoolVar(&ConvertKubectlCompatible, "kubectl-compatible", false, "Print a multi-document YAML stream instead of a List, using the apiVersions served by the cluster of the kubeconfig if it is reachable")
+	convertCmd.Flags().BoolVar(&ConvertDiscover, "discover", false, "Use the apiVersions served by the cluster of the kubeconfig, keeping the default ones when it isn't reachable")
+	convertCmd.Flags().StringVar(&ConvertServer, "server", "", "API server of the cluster discovered by --discover and --kubectl-compatible, instead of the kubeconfig")
+	convertCmd.Flags().StringVar(&ConvertToken, "token", "", "Bearer token authenticating to --server (default $KOMPOSE_TOKEN)")
+	convertCmd.Flags().StringVar(&ConvertCertificateAuthority, "certificate-authority", "", "Certificate authority file verifying --server")
+	convertCmd.Flags().StringVar(&ConvertKubeconfig, "kubeconfig", "", "Kubeconfig of the cluster discovered by --discover and --kubectl-compatible (default $KUBECONFIG or ~/.kube/config)")
 	convertCmd.Flags().IntVar(&ConvertReplicas, "replicas", 1, "Specify the number of replicas in the generated resource spec")
+	convertCmd.Flags().StringVar(&ConvertNameStrategy, "name-strategy", "service", `Name the objects after the compose service ("service"), prefixed with the project name ("project") or by a template like "{{.Project}}-{{.Service}}"`)
+	convertCmd.RegisterFlagCompletionFunc("name-strategy", completeValues("service", "project"))
+	convertCmd.Flags().BoolVar(&ConvertNamespacePerNetwork, "namespace-per-network", false, "Place every service into a namespace named after its network (Kubernetes only)")
+	convertCmd.Flags().BoolVar(&ConvertDependsOnReadiness, "depends-on-readiness", false, "Generate readiness probes that wait for the services listed in depends_on, for services without a healthcheck")
+	convertCmd.Flags().StringArrayVar(&ConvertEnvExclude, "env-exclude", []string{}, `Leave out the environment variables matching the glob pattern, like "DOCKER_*" (can be repeated)`)
+	convertCmd.Flags().StringArrayVar(&ConvertEnvMask, "env-mask", []string{}, `Replace the values of the environment variables matching the glob pattern by placeholders like "${NAME}" (can be repeated)`)
+	convertCmd.Flags().StringVar(&ConvertPreserveSelectors, "preserve-selectors", "", "Keep the selectors of the controllers of the manifests of an earlier conversion in this file or directory, as they can't be changed in the cluster")
+	convertCmd.Flags().BoolVar(&ConvertLegacyLabels, "legacy-labels", false, "Label and select the objects with io.kompose.service instead of the recommended app.kubernetes.io labels, like kompose did before")
+	convertCmd.Flags().StringVar(&ConvertPartOf, "part-of", "", "Label every object with app.kubernetes.io/part-of set to this application and app.kubernetes.io/managed-by=kompose, e.g. for kubectl apply --prune -l")
+	convertCmd.Flags().BoolVar(&ConvertLocalCluster, "local-cluster", false, "Adapt the objects to a local cluster like kind or Docker Desktop: NodePort Services on ports derived from the published ports, bind mounts as hostPath volumes and built images never pulled")
+	convertCmd.Flags().StringVar(&ConvertLocalMountRoot, "local-mount-root", "", "Path the nodes of the --local-cluster mount the directory of the compose file at, e.g. with the extraMounts of kind (default the directory itself)")
+	convertCmd.Flags().StringVar(&ConvertAutoIngress, "auto-ingress", "", "Expose the services publishing a web port at <service>.<domain> of the given domain, unless they set kompose.service.expose")
+	convertCmd.Flags().BoolVar(&ConvertPinCPUs, "pin-cpus", false, "Request and limit as many whole CPUs as the cpuset of a service has, and its memory limit, so the pods get exclusive CPUs with the static CPU manager policy")
+	convertCmd.Flags().BoolVar(&ConvertHostSockets, "host-sockets", false, "Mount the bind mounted sockets of the host, like /var/run/docker.sock, from the node with hostPath volumes")
+	convertCmd.Flags().BoolVar(&ConvertProgress, "progress", false, "Report the progress of the conversion on stderr, service by service")
+	convertCmd.Flags().BoolVar(&ConvertZeroTrust, "zero-trust", false, "Deny the traffic between pods not sharing a network, and mount a TLS certificate issued by cert-manager into every service (Kubernetes only)")
+	convertCmd.Flags().BoolVar(&ConvertSmartDefaults, "smart-defaults", false, "Add the default port, data volume and probes of well-known images (nginx, postgres, mysql, redis, rabbitmq)")
+	convertCmd.Flags().BoolVar(&ConvertNamePorts, "name-ports", false, `Name every container port ("port-8080") and reference it by name from the Services`)
+	convertCmd.Flags().StringVar(&ConvertMesh, "mesh", "", `Inject the pods into a service mesh ("istio"|"linkerd")`)
+	convertCmd.RegisterFlagCompletionFunc("mesh", completeValues("istio", "linkerd"))
+	convertCmd.Flags().StringVar(&ConvertGroupBy, "group-by", "", `Write the controller, Services, Ingress, ConfigMaps and PersistentVolumeClaims of every service into one file named after it ("service")`)
+	convertCmd.RegisterFlagCompletionFunc("group-by", completeValues("service"))
+	convertCmd.Flags().StringVar(&ConvertMapping, "mapping", "", "Write the mapping of the compose services to the generated objects and their files to this JSON file, e.g. kompose-mapping.json")
+	convertCmd.Flags().StringVar(&ConvertHeaderFile, "header-file", "", "File whose lines are prepended as YAML comments to every generated file, e.g. a copyright or do-not-edit notice")
+	convertCmd.Flags().BoolVar(&ConvertSingletonPDB, "singleton-pdb", false, "Create a PodDisruptionBudget with maxUnavailable 0 for the services running a single pod that mounts PersistentVolumeClaims, so node drains don't evict it")
+	convertCmd.Flags().StringVar(&ConvertPolicy, "policy", "", "Directory of Rego policies of the kompose package the generated objects must satisfy, evaluated with opa; the conversion fails listing the violations of their deny rules")
+	convertCmd.Flags().BoolVar(&ConvertRemoveStaleFiles, "remove-stale-files", false, "Remove the files of the output directory named after the kind of their object, like web-deployment.yaml, that the conversion no longer generates")
+	convertCmd.Flags().StringVar(&ConvertSummaryFormat, "summary-format", "text", `Format of the summary of the services converted, objects generated, warnings and output size printed at the end ("text"|"json"|"none"); json is written to stdout`)
+	convertCmd.RegisterFlagCompletionFunc("summary-format", completeValues("text", "json", "none"))
+	convertCmd.Flags().StringVar(&ConvertExtraResources, "extra-resources", "", "Directory of YAML templates of objects to add for every service, rendered with the .Name, .Service, .Project, .Image, .Ports and .Labels of the service")
+	convertCmd.Flags().BoolVar(&ConvertSplitServicePorts, "split-service-ports", false, "Create a Service named <service>-<port> for every port of a service instead of one Service with all ports")
+	convertCmd.Flags().BoolVar(&ConvertFSGroupFromUser, "fs-group-from-user", false, "Set the fsGroup of the pods mounting PersistentVolumeClaims to the gid of the user of their service, e.g. 1000 for user: 1000:1000, so non-root containers can write to fresh volumes")
+	convertCmd.Flags().BoolVar(&ConvertChecksumAnnotations, "checksum-annotations", false, "Annotate the pod templates with a checksum of their ConfigMaps and Secrets, so a changed config rolls out the pods")
+	convertCmd.Flags().BoolVar(&ConvertPruneUnused, "prune-unused", false, "Leave out the objects of top-level volumes, networks, configs and secrets that no service uses")
+	convertCmd.Flags().StringVar(&ConvertHostGatewayIP, "host-gateway-ip", "", "IP that the hosts mapped to host-gateway in extra_hosts point to, e.g. the IP of the node")
+	convertCmd.Flags().BoolVar(&ConvertDiff, "diff", false, "Print the differences between the objects converted from the two compose files given as arguments")
 	convertCmd.Flags().StringVar(&ConvertVolumes, "volumes", "persistentVolumeClaim", `Volumes to be generated ("persistentVolumeClaim"|"emptyDir"|"hostPath" | "configMap")`)
+	convertCmd.RegisterFlagCompletionFunc("volumes", completeValues("persistentVolumeClaim", "emptyDir", "hostPath", "configMap"))
+	convertCmd.Flags().StringVar(&ConvertWindowsDrivePrefix, "windows-drive-prefix", "", `Translate Windows host paths of hostPath volumes below this directory, e.g. "/mnt" turns C:\data into /mnt/c/data`)
 
 	convertCmd.Flags().BoolVar(&WithKomposeAnnotation, "with-kompose-annotation", true, "Add kompose annotations to generated resource")
 
@@ -172,16 +332,13 @@ Available Commands:{{range .Commands}}{{if .IsAvailableCommand}}
   {{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{end}}{{ if .HasAvailableLocalFlags}}
 
 Kubernetes Flags:
-      --daemon-set               Generate a Kubernetes daemonset object (deprecated, use --controller instead)
-  -d, --deployment               Generate a Kubernetes deployment object (deprecated, use --controller instead)
   -c, --chart                    Create a Helm chart for converted objects
-      --replication-controller   Generate a Kubernetes replication controller object (deprecated, use --controller instead)
 
 OpenShift Flags:
       --build-branch             Specify repository branch to use for buildconfig (default is current branch name)
       --build-repo               Specify source repository for buildconfig (default is current branch's remote url)
-      --deployment-config        Generate an OpenShift deployment config object
       --insecure-repository      Specify to use insecure docker repository while generating Openshift image stream object
+      --scc-bindings             Run the pods needing a SecurityContextConstraints other than restricted with a ServiceAccount bound to it
 
 Flags:
 {{.LocalFlags.FlagUsages | trimRightSpace}}{{end}}{{ if .HasAvailableInheritedFlags}}
@@ -0,0 +1,59 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"github.com/kubernetes/kompose/pkg/app"
+	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// ExportOut is the compose file kompose export writes
+	ExportOut string
+
+	// ExportOpt holds the options of kompose export
+	ExportOpt kobject.ConvertOptions
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write the input files as a docker-compose file",
+	Long: `Loads the compose files or Podman quadlet units and writes them back as a
+single version 3 compose file, the way kompose understood them: the files are
+merged, the variables interpolated and the keys sorted, so the same input
+always gives the same file. Keys kompose doesn't convert are left out.`,
+	Example: `  kompose export -f docker-compose.yml -f docker-compose.prod.yml -o merged.yml
+  kompose export -f web.container -f db.container`,
+	Args: cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		ExportOpt = kobject.ConvertOptions{
+			InputFiles:     GlobalFiles,
+			OutFile:        ExportOut,
+			SOPSAgeKeyFile: GlobalSOPSAgeKeyFile,
+		}
+		app.ValidateComposeFile(&ExportOpt)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		app.Export(ExportOpt)
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringVarP(&ExportOut, "out", "o", "", "Compose file to write, stdout by default")
+	RootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/kubernetes/kompose/pkg/app"
+	"github.com/kubernetes/kompose/pkg/kobject"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// InitOpt holds the options used to load the compose file
+var InitOpt kobject.ConvertOptions
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Interactively create a kompose config file",
+	Long: `Walks through the conversion decisions (controller kind, volume type,
+service exposure and replica counts) and writes the answers into a kompose
+config file, which is used by 'kompose convert' for repeatable conversions.`,
+	Args: cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		InitOpt = kobject.ConvertOptions{
+			InputFiles:     GlobalFiles,
+			ProjectName:    GlobalProjectName,
+			Provider:       strings.ToLower(GlobalProvider),
+			SOPSAgeKeyFile: GlobalSOPSAgeKeyFile,
+		}
+		app.ValidateComposeFile(&InitOpt)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := app.Init(InitOpt, GlobalConfig, os.Stdin, os.Stdout); err != nil {
+			log.Fatalf("Unable to create config file: %s", err)
+		}
+		log.Infof("Config file %q created", GlobalConfig)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(initCmd)
+}
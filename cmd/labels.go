@@ -0,0 +1,66 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kubernetes/kompose/pkg/loader/compose"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// LabelsSchema prints the labels as JSON Schema
+var LabelsSchema bool
+
+var labelsCmd = &cobra.Command{
+	Use:   "labels",
+	Short: "List the kompose labels of compose files",
+	Long: `Lists the kompose.* labels of compose services and volumes that drive the
+conversion. With --schema the labels are printed as JSON Schema, which editors
+and linters can validate compose files against.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if LabelsSchema {
+			schema, err := compose.LabelSchema()
+			if err != nil {
+				log.Fatalf("Unable to create the label schema: %s", err)
+			}
+			fmt.Println(string(schema))
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "LABEL\tSCOPE\tDESCRIPTION")
+		for _, directive := range compose.LabelDirectives {
+			key := directive.Key
+			if directive.Prefix {
+				key += "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", key, strings.Join(directive.Scopes, ","), directive.Description)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	labelsCmd.Flags().BoolVar(&LabelsSchema, "schema", false, "Print the labels as JSON Schema")
+	RootCmd.AddCommand(labelsCmd)
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"github.com/kubernetes/kompose/pkg/app"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// MigrateInPlace writes the migrated manifests back instead of printing them
+	MigrateInPlace bool
+	// MigrateYAMLIndent is the indentation of the migrated YAML manifests
+	MigrateYAMLIndent int
+)
+
+var migrateControllersCmd = &cobra.Command{
+	Use:   "migrate-controllers FILE...",
+	Short: "Convert the ReplicationControllers of generated manifests to Deployments",
+	Long: `Reads manifests previously generated by kompose, as single objects, multi-document
+YAML or Lists, and converts their ReplicationControllers to the Deployments kompose
+generates today. The other objects are kept. The manifests are printed to stdout, or
+written back with --in-place, renaming files like web-replicationcontroller.yaml to
+web-deployment.yaml.`,
+	Example: `  kompose migrate-controllers web-replicationcontroller.yaml | kubectl apply -f -
+  kompose migrate-controllers --in-place k8s/*.yaml`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		app.MigrateControllers(args, MigrateInPlace, MigrateYAMLIndent)
+	},
+}
+
+func init() {
+	migrateControllersCmd.Flags().BoolVar(&MigrateInPlace, "in-place", false, "Write the migrated manifests back to the files")
+	migrateControllersCmd.Flags().IntVar(&MigrateYAMLIndent, "indent", 2, "Spaces length to indent generated yaml files")
+	RootCmd.AddCommand(migrateControllersCmd)
+}
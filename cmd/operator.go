@@ -0,0 +1,92 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kubernetes/kompose/pkg/app"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// OperatorNamespace is the namespace whose Kompositions kompose operator reconciles
+	OperatorNamespace string
+	// OperatorInterval is the time between the reconciliations of kompose operator
+	OperatorInterval time.Duration
+	// OperatorKubeconfig is the kubeconfig of the cluster kompose operator reconciles
+	OperatorKubeconfig string
+	// OperatorPrintCRD prints the CustomResourceDefinition of the Kompositions
+	OperatorPrintCRD bool
+	// OperatorAllowHostPath allows the Kompositions to run pods mounting hostPath volumes
+	OperatorAllowHostPath bool
+	// OperatorAllowPrivileged allows the Kompositions to run privileged pods
+	OperatorAllowPrivileged bool
+)
+
+var operatorCmd = &cobra.Command{
+	Use:   "operator",
+	Short: "Reconcile the Komposition custom resources of a cluster",
+	Long: `Watches the Komposition custom resources of the cluster, which hold a compose
+file and the options of its conversion, and applies the converted objects in the
+namespace of every Komposition. The objects are applied again every interval,
+undoing changes made to them, and the objects the compose file no longer
+generates are deleted, as are all the objects of a deleted Komposition.
+
+The options are the query parameters of kompose serve. Install the
+CustomResourceDefinition printed by --print-crd first. The operator uses the
+kubeconfig, or the service account of its pod, which must be allowed to manage
+the converted objects.
+
+The objects are applied with the permissions of the operator, not the ones of
+the author of the Komposition, so anyone allowed to create Kompositions can
+create the objects the operator may create. The compose files are interpolated
+without the environment of the operator, and the pods mounting hostPath volumes
+or running privileged containers are refused unless --allow-host-path or
+--allow-privileged allow them.`,
+	Example: `  kompose operator --print-crd | kubectl apply -f -
+  kompose operator --namespace apps --interval 30s`,
+	Args: cobra.NoArgs,
+	// the warnings of every conversion are printed right away
+	Annotations: map[string]string{annotationStreamWarnings: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		if OperatorPrintCRD {
+			fmt.Print(app.KompositionCRD)
+			return
+		}
+		cluster, err := app.OperatorCluster(OperatorKubeconfig)
+		if err != nil {
+			log.Fatalf("Unable to reach the cluster: %s", err)
+		}
+		permissions := app.OperatorPermissions{HostPath: OperatorAllowHostPath, Privileged: OperatorAllowPrivileged}
+		if err := app.RunOperator(cluster, OperatorNamespace, OperatorInterval, permissions); err != nil {
+			log.Fatalf("The operator failed: %s", err)
+		}
+	},
+}
+
+func init() {
+	operatorCmd.Flags().StringVarP(&OperatorNamespace, "namespace", "n", "", "Namespace whose Kompositions are reconciled (default all namespaces)")
+	operatorCmd.Flags().DurationVar(&OperatorInterval, "interval", time.Minute, "Time between the reconciliations")
+	operatorCmd.Flags().StringVar(&OperatorKubeconfig, "kubeconfig", "", "Kubeconfig of the cluster (default $KUBECONFIG or ~/.kube/config, else the service account of the pod)")
+	operatorCmd.Flags().BoolVar(&OperatorAllowHostPath, "allow-host-path", false, "Allow the Kompositions to run pods mounting hostPath volumes, like the ones of the volumes option hostPath")
+	operatorCmd.Flags().BoolVar(&OperatorAllowPrivileged, "allow-privileged", false, "Allow the Kompositions to run privileged containers, add capabilities and use the namespaces of the host")
+	operatorCmd.Flags().BoolVar(&OperatorPrintCRD, "print-crd", false, "Print the CustomResourceDefinition of the Kompositions and exit")
+	RootCmd.AddCommand(operatorCmd)
+}
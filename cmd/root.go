@@ -17,8 +17,11 @@ limitations under the License.
 package cmd
 
 import (
+	"fmt"
 	"strings"
+	"sync"
 
+	"github.com/kubernetes/kompose/pkg/app"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -37,6 +40,157 @@ func (errorOnWarningHook) Fire(entry *log.Entry) error {
 	return nil
 }
 
+// Logrus formatters
+
+// Formatter holding back warnings so repeated ones are printed once,
+// together with the services they were reported for. It also counts
+// the warnings by their category field for the summary of convert.
+type warningSummaryFormatter struct {
+	log.Formatter
+
+	mu         sync.Mutex
+	messages   []string
+	services   map[string][]string
+	counts     map[string]int
+	categories map[string]int
+	total      int
+}
+
+func newWarningSummaryFormatter(formatter log.Formatter) *warningSummaryFormatter {
+	return &warningSummaryFormatter{
+		Formatter:  formatter,
+		services:   map[string][]string{},
+		counts:     map[string]int{},
+		categories: map[string]int{},
+	}
+}
+
+func (f *warningSummaryFormatter) Format(entry *log.Entry) ([]byte, error) {
+	switch entry.Level {
+	case log.WarnLevel:
+		f.add(entry)
+		return nil, nil
+	case log.InfoLevel, log.DebugLevel, log.TraceLevel:
+		return f.Formatter.Format(entry)
+	}
+
+	// Print the pending warnings before an error, which most likely exits
+	summary, err := f.summary()
+	if err != nil {
+		return nil, err
+	}
+	data, err := f.Formatter.Format(entry)
+	return append(summary, data...), err
+}
+
+func (f *warningSummaryFormatter) add(entry *log.Entry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.counts[entry.Message]; !ok {
+		f.messages = append(f.messages, entry.Message)
+	}
+	f.counts[entry.Message]++
+	f.total++
+
+	category := "other"
+	if c, ok := entry.Data["category"]; ok {
+		category = fmt.Sprint(c)
+	}
+	f.categories[category]++
+
+	if service, ok := entry.Data["service"]; ok {
+		name := fmt.Sprint(service)
+		for _, s := range f.services[entry.Message] {
+			if s == name {
+				return
+			}
+		}
+		f.services[entry.Message] = append(f.services[entry.Message], name)
+	}
+}
+
+// summary formats the pending warnings and resets them
+func (f *warningSummaryFormatter) summary() ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var data []byte
+	for _, message := range f.messages {
+		services := f.services[message]
+		switch {
+		case len(services) == 1:
+			message = fmt.Sprintf("%s (service: %s)", message, services[0])
+		case len(services) > 1:
+			message = fmt.Sprintf("%s (services: %s)", message, strings.Join(services, ", "))
+		case f.counts[message] > 1:
+			message = fmt.Sprintf("%s (repeated %d times)", message, f.counts[message])
+		}
+
+		line, err := f.Formatter.Format(&log.Entry{Logger: log.StandardLogger(), Level: log.WarnLevel, Message: message})
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, line...)
+	}
+
+	f.messages = nil
+	f.services = map[string][]string{}
+	f.counts = map[string]int{}
+	return data, nil
+}
+
+// WarningCounts returns the number of warnings reported by category
+func (f *warningSummaryFormatter) WarningCounts() map[string]int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	counts := make(map[string]int, len(f.categories))
+	for category, count := range f.categories {
+		counts[category] = count
+	}
+	return counts
+}
+
+// PrintWarnings prints the pending warnings, e.g. before the outcome of a command
+func (f *warningSummaryFormatter) PrintWarnings() error {
+	data, err := f.summary()
+	if err != nil {
+		return err
+	}
+	_, err = log.StandardLogger().Out.Write(data)
+	return err
+}
+
+// Flush prints the pending warnings followed by the number of warnings reported
+func (f *warningSummaryFormatter) Flush() error {
+	data, err := f.summary()
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	total := f.total
+	f.mu.Unlock()
+	if total > 0 && log.IsLevelEnabled(log.InfoLevel) {
+		line, err := f.Formatter.Format(&log.Entry{Logger: log.StandardLogger(), Level: log.InfoLevel, Message: fmt.Sprintf("%d warning(s) reported", total)})
+		if err != nil {
+			return err
+		}
+		data = append(data, line...)
+	}
+
+	_, err = log.StandardLogger().Out.Write(data)
+	return err
+}
+
+// warningSummary is the formatter of the commands holding back their warnings, nil for the others
+var warningSummary *warningSummaryFormatter
+
+// annotationStreamWarnings marks the commands running until they are stopped, like kompose serve,
+// which print their warnings right away instead of holding them back until they end
+const annotationStreamWarnings = "kompose.io/stream-warnings"
+
 // TODO: comment
 var (
 	GlobalBundle           string
@@ -45,6 +199,9 @@ var (
 	GlobalSuppressWarnings bool
 	GlobalErrorOnWarning   bool
 	GlobalFiles            []string
+	GlobalConfig           string
+	GlobalProjectName      string
+	GlobalSOPSAgeKeyFile   string
 )
 
 // RootCmd root level flags and commands
@@ -66,7 +223,12 @@ var RootCmd = &cobra.Command{
 		formatter := new(log.TextFormatter)
 		formatter.DisableTimestamp = true
 		formatter.ForceColors = true
-		log.SetFormatter(formatter)
+		if cmd.Annotations[annotationStreamWarnings] == "true" {
+			log.SetFormatter(formatter)
+		} else {
+			warningSummary = newWarningSummaryFormatter(formatter)
+			log.SetFormatter(warningSummary)
+		}
 
 		// Set the appropriate suppress warnings and error on warning flags
 		if GlobalSuppressWarnings {
@@ -82,6 +244,15 @@ var RootCmd = &cobra.Command{
 			log.Fatalf("%s is an unsupported provider. Supported providers are: 'kubernetes', 'openshift'.", GlobalProvider)
 		}
 	},
+	// Print the deduplicated warnings once the command is done
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if warningSummary == nil {
+			return
+		}
+		if err := warningSummary.Flush(); err != nil {
+			log.Fatalf("Unable to print warnings: %s", err)
+		}
+	},
 }
 
 // Execute executes the root level command.
@@ -94,9 +265,13 @@ func init() {
 	RootCmd.PersistentFlags().BoolVarP(&GlobalVerbose, "verbose", "v", false, "verbose output")
 	RootCmd.PersistentFlags().BoolVar(&GlobalSuppressWarnings, "suppress-warnings", false, "Suppress all warnings")
 	RootCmd.PersistentFlags().BoolVar(&GlobalErrorOnWarning, "error-on-warning", false, "Treat any warning as an error")
-	RootCmd.PersistentFlags().StringArrayVarP(&GlobalFiles, "file", "f", []string{}, "Specify an alternative compose file")
+	RootCmd.PersistentFlags().StringArrayVarP(&GlobalFiles, "file", "f", []string{}, "Specify an alternative compose file (default: $COMPOSE_FILE)")
+	RootCmd.PersistentFlags().StringVar(&GlobalProjectName, "project-name", "", "Specify an alternative project name (default: $COMPOSE_PROJECT_NAME)")
 	RootCmd.PersistentFlags().StringVarP(&GlobalBundle, "bundle", "b", "", "Specify a Distributed Application Bundle (DAB) file")
 	RootCmd.PersistentFlags().StringVar(&GlobalProvider, "provider", "kubernetes", "Specify a provider. Kubernetes or OpenShift.")
+	RootCmd.RegisterFlagCompletionFunc("provider", completeValues("kubernetes", "openshift"))
+	RootCmd.PersistentFlags().StringVar(&GlobalSOPSAgeKeyFile, "sops-age-key-file", "", "Specify the age keys file the compose and env files encrypted with sops are decrypted with (default: $SOPS_AGE_KEY_FILE)")
+	RootCmd.PersistentFlags().StringVar(&GlobalConfig, "config", app.DefaultConfigFile, "Specify a kompose config file, as created by 'kompose init'")
 
 	// Mark DAB / bundle as deprecated, see issue: https://github.com/kubernetes/kompose/issues/390
 	// As DAB is still EXPERIMENTAL
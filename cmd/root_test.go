@@ -0,0 +1,147 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// newTestFormatter returns a warningSummaryFormatter without colors and timestamps, and the
+// buffer the standard logger writes to until the returned function restores it
+func newTestFormatter() (*warningSummaryFormatter, *bytes.Buffer, func()) {
+	out := &bytes.Buffer{}
+	previous := log.StandardLogger().Out
+	log.SetOutput(out)
+	formatter := newWarningSummaryFormatter(&log.TextFormatter{DisableTimestamp: true, DisableColors: true})
+	return formatter, out, func() { log.SetOutput(previous) }
+}
+
+// entry returns a log entry of level with message and fields
+func entry(level log.Level, message string, fields log.Fields) *log.Entry {
+	return &log.Entry{Logger: log.StandardLogger(), Level: level, Message: message, Data: fields}
+}
+
+func TestWarningSummaryFormatterFormat(t *testing.T) {
+	formatter, _, restore := newTestFormatter()
+	defer restore()
+
+	if data, err := formatter.Format(entry(log.WarnLevel, "held back", log.Fields{"service": "web"})); err != nil || len(data) != 0 {
+		t.Errorf("Expected the warning to be held back, got %q, %v", data, err)
+	}
+	if data, err := formatter.Format(entry(log.InfoLevel, "printed", log.Fields{})); err != nil || !strings.Contains(string(data), `msg=printed`) || strings.Contains(string(data), "held back") {
+		t.Errorf("Expected the info message alone, got %q, %v", data, err)
+	}
+
+	// the pending warnings come before an error, which most likely exits
+	data, err := formatter.Format(entry(log.ErrorLevel, "failed", log.Fields{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `msg="held back (service: web)"`) || !strings.Contains(lines[1], "msg=failed") {
+		t.Errorf("Expected the pending warning followed by the error, got %q", data)
+	}
+	if data, _ := formatter.summary(); len(data) != 0 {
+		t.Errorf("Expected no pending warning after the error, got %q", data)
+	}
+}
+
+func TestWarningSummaryFormatterSummary(t *testing.T) {
+	formatter, _, restore := newTestFormatter()
+	defer restore()
+
+	warnings := []*log.Entry{
+		entry(log.WarnLevel, "unsupported key", log.Fields{"service": "web", "category": "unsupported"}),
+		entry(log.WarnLevel, "no ports", log.Fields{"category": "networking"}),
+		entry(log.WarnLevel, "unsupported key", log.Fields{"service": "db", "category": "unsupported"}),
+		entry(log.WarnLevel, "no ports", log.Fields{"category": "networking"}),
+		entry(log.WarnLevel, "unsupported key", log.Fields{"service": "web", "category": "unsupported"}),
+		entry(log.WarnLevel, "untagged", log.Fields{}),
+	}
+	for _, warning := range warnings {
+		formatter.Format(warning)
+	}
+
+	data, err := formatter.summary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		`msg="unsupported key (services: web, db)"`,
+		`msg="no ports (repeated 2 times)"`,
+		`msg=untagged`,
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d warnings in the order they were first reported, got %q", len(expected), data)
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "level=warning") || !strings.Contains(line, expected[i]) {
+			t.Errorf("Expected the warning %s, got %s", expected[i], line)
+		}
+	}
+
+	counts := map[string]int{"unsupported": 3, "networking": 2, "other": 1}
+	if got := formatter.WarningCounts(); !reflect.DeepEqual(got, counts) {
+		t.Errorf("Expected the warning counts %v, got %v", counts, got)
+	}
+	// the summary resets the pending warnings, not the counts
+	if data, _ := formatter.summary(); len(data) != 0 {
+		t.Errorf("Expected no pending warning, got %q", data)
+	}
+	if got := formatter.WarningCounts(); !reflect.DeepEqual(got, counts) {
+		t.Errorf("Expected the warning counts to be kept, got %v", got)
+	}
+}
+
+func TestWarningSummaryFormatterFlush(t *testing.T) {
+	formatter, out, restore := newTestFormatter()
+	defer restore()
+
+	formatter.Format(entry(log.WarnLevel, "first", log.Fields{"service": "web"}))
+	if err := formatter.PrintWarnings(); err != nil {
+		t.Fatal(err)
+	}
+	if printed := out.String(); !strings.Contains(printed, `msg="first (service: web)"`) || strings.Contains(printed, "reported") {
+		t.Errorf("Expected the pending warning without the total, got %q", printed)
+	}
+
+	out.Reset()
+	formatter.Format(entry(log.WarnLevel, "second", log.Fields{}))
+	if err := formatter.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "msg=second") || !strings.Contains(lines[1], `msg="2 warning(s) reported"`) {
+		t.Errorf("Expected the pending warning followed by the total of the command, got %q", out.String())
+	}
+
+	// nothing is reported without warnings
+	formatter, out, restore = newTestFormatter()
+	defer restore()
+	if err := formatter.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no output without warnings, got %q", out.String())
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"github.com/kubernetes/kompose/pkg/app"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// ServeAddress is the address kompose serve listens on
+	ServeAddress string
+	// ServeCacheSize is the number of conversions kompose serve caches
+	ServeCacheSize int
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve conversions over HTTP",
+	Long: `Serves conversions of compose files over HTTP. POST a compose file to /convert
+to receive the manifests, or the tarball of a Helm chart with ?chart=true. The
+query sets the provider, controller, volumes (except configMap), replicas,
+project-name, name-strategy, format (or the older json), kubectl-compatible,
+smart-defaults, legacy-labels and chart options like the flags of kompose
+convert. The last conversions are cached by the hash of their compose file and
+options.
+
+The compose files are converted in an empty directory of the server and
+interpolated without its environment variables. The compose files that would
+read the files or variables of the server are refused: include, env_file,
+extends with a file, the file and environment of the top-level secrets and
+configs, and the kompose.volume.tls label.`,
+	Example: `  kompose serve --address :8080
+  curl --data-binary @docker-compose.yaml 'localhost:8080/convert?controller=statefulset'`,
+	Args: cobra.NoArgs,
+	// the warnings of every conversion are printed right away
+	Annotations: map[string]string{annotationStreamWarnings: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := app.Serve(ServeAddress, ServeCacheSize); err != nil {
+			log.Fatalf("Unable to serve conversions: %s", err)
+		}
+	},
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&ServeAddress, "address", ":8080", "Address to listen on")
+	serveCmd.Flags().IntVar(&ServeCacheSize, "cache-size", 64, "Number of conversions to cache by the hash of their compose file and options, 0 disables the cache")
+	RootCmd.AddCommand(serveCmd)
+}
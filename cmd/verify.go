@@ -0,0 +1,45 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"github.com/kubernetes/kompose/pkg/app"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify PATH...",
+	Short: "Check that generated manifests are consistent",
+	Long: `Reads manifests previously generated by kompose, as files, directories or Helm
+chart tarballs, and checks that they are consistent with each other: the
+ConfigMaps, Secrets and PersistentVolumeClaims the pods use exist, the Services
+select pods, the Ingresses, Routes and HorizontalPodAutoscalers refer to existing
+objects and ports, and the probes use ports of their containers. It reads no
+cluster, so it catches the mistakes of hand edits before the manifests are
+applied, also where no cluster can be reached.`,
+	Example: `  kompose verify k8s/
+  kompose verify web-deployment.yaml web-service.yaml web-env-configmap.yaml
+  kompose verify myapp-0.0.1.tgz`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		app.Verify(args)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(verifyCmd)
+}
@@ -17,7 +17,15 @@ limitations under the License.
 package app
 
 import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -26,9 +34,16 @@ import (
 
 	"github.com/kubernetes/kompose/pkg/kobject"
 	"github.com/kubernetes/kompose/pkg/loader"
+	"github.com/kubernetes/kompose/pkg/loader/compose"
+	"github.com/kubernetes/kompose/pkg/loader/quadlet"
 	"github.com/kubernetes/kompose/pkg/transformer"
 	"github.com/kubernetes/kompose/pkg/transformer/kubernetes"
 	"github.com/kubernetes/kompose/pkg/transformer/openshift"
+	"github.com/kubernetes/kompose/pkg/utils/discovery"
+	"github.com/kubernetes/kompose/pkg/utils/oci"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 var (
@@ -38,6 +53,8 @@ var (
 		"docker-compose.yaml",
 		"container-compose.yml",
 		"container-compose.yaml",
+		"podman-compose.yml",
+		"podman-compose.yaml",
 	}
 )
 
@@ -50,10 +67,75 @@ const (
 	DefaultProvider = ProviderKubernetes
 )
 
-var inputFormat = "compose"
+const (
+	// FormatYAML writes the objects in YAML, the default format
+	FormatYAML = "yaml"
+	// FormatJSON writes the objects in JSON
+	FormatJSON = "json"
+)
+
+// inputFormat returns the format of the input files: quadlet for Podman quadlet unit files, else
+// compose. DAB bundles are no longer supported.
+func inputFormat(files []string) (string, error) {
+	units := 0
+	for _, file := range files {
+		if quadlet.IsUnitFile(file) {
+			units++
+		}
+	}
+	switch units {
+	case 0:
+		return "compose", nil
+	case len(files):
+		return "quadlet", nil
+	}
+	return "", errors.New("compose files and quadlet unit files can't be converted together")
+}
 
-// ValidateFlags validates all command line flags
+// controllerProviders maps the values of --controller to the provider generating them
+var controllerProviders = map[string]string{
+	kubernetes.DeploymentController:       ProviderKubernetes,
+	kubernetes.DaemonSetController:        ProviderKubernetes,
+	kubernetes.ReplicationController:      ProviderKubernetes,
+	kubernetes.StatefulSetController:      ProviderKubernetes,
+	kubernetes.DeploymentConfigController: ProviderOpenshift,
+}
+
+// controllerAliases are the deprecated boolean flags standing for a --controller value
+var controllerAliases = []struct {
+	flag       string
+	controller string
+}{
+	{"deployment", kubernetes.DeploymentController},
+	{"daemon-set", kubernetes.DaemonSetController},
+	{"replication-controller", kubernetes.ReplicationController},
+	{"deployment-config", kubernetes.DeploymentConfigController},
+}
+
+// controllers returns the sorted values of --controller
+func controllers() []string {
+	var names []string
+	for name := range controllerProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateFlags validates all command line flags and reports all the violations found at once
 func ValidateFlags(bundle string, args []string, cmd *cobra.Command, opt *kobject.ConvertOptions) {
+	violations := validateFlags(bundle, args, cmd, opt)
+	if len(violations) == 1 {
+		log.Fatalf("Error: %s", violations[0])
+	} else if len(violations) > 1 {
+		log.Fatalf("Found %d errors in the options:\n  - %s", len(violations), strings.Join(violations, "\n  - "))
+	}
+}
+
+// validateFlags resolves the aliases of the flags of cmd into opt, and returns the violations of
+// the flags and options found
+func validateFlags(bundle string, args []string, cmd *cobra.Command, opt *kobject.ConvertOptions) []string {
+	var violations []string
 
 	// Check to see if the "file" has changed from the default flag value
 	isFileSet := cmd.Flags().Lookup("file").Changed
@@ -68,91 +150,292 @@ func ValidateFlags(bundle string, args []string, cmd *cobra.Command, opt *kobjec
 	log.Debugf("Checking validation of provider: %s", provider)
 
 	// OpenShift specific flags
-	deploymentConfig := cmd.Flags().Lookup("deployment-config").Changed
 	buildRepo := cmd.Flags().Lookup("build-repo").Changed
 	buildBranch := cmd.Flags().Lookup("build-branch").Changed
+	sccBindings := cmd.Flags().Lookup("scc-bindings").Changed
 
 	// Kubernetes specific flags
 	chart := cmd.Flags().Lookup("chart").Changed
-	daemonSet := cmd.Flags().Lookup("daemon-set").Changed
-	replicationController := cmd.Flags().Lookup("replication-controller").Changed
-	deployment := cmd.Flags().Lookup("deployment").Changed
-
-	// Get the controller
-	controller := opt.Controller
-	log.Debugf("Checking validation of controller: %s", controller)
+	pushChart := cmd.Flags().Lookup("push").Changed
+	namespacePerNetwork := cmd.Flags().Lookup("namespace-per-network").Changed
+	zeroTrust := cmd.Flags().Lookup("zero-trust").Changed
+	localCluster := cmd.Flags().Lookup("local-cluster").Changed
+
+	// Get the controller, the deprecated controller flags are aliases of --controller
+	controllerFlag := "--controller=" + opt.Controller
+	for _, alias := range controllerAliases {
+		flag := cmd.Flags().Lookup(alias.flag)
+		if !flag.Changed || flag.Value.String() != "true" {
+			continue
+		}
+		if opt.Controller != "" && opt.Controller != alias.controller {
+			violations = append(violations, fmt.Sprintf("--%s and %s can't be set at the same time, use --controller", alias.flag, controllerFlag))
+			continue
+		}
+		opt.Controller = alias.controller
+		controllerFlag = "--" + alias.flag
+	}
+	log.Debugf("Checking validation of controller: %s", opt.Controller)
+
+	// --yaml and --json are aliases of --format
+	formatFlag := "--format=" + opt.Format
+	for _, format := range []string{FormatYAML, FormatJSON} {
+		flag := cmd.Flags().Lookup(format)
+		if !flag.Changed || flag.Value.String() != "true" {
+			continue
+		}
+		if opt.Format != "" && opt.Format != format {
+			violations = append(violations, fmt.Sprintf("--%s and %s can't be set at the same time", format, formatFlag))
+			continue
+		}
+		opt.Format = format
+		formatFlag = "--" + format
+	}
+	opt.GenerateYaml = opt.Format == FormatYAML
+	opt.GenerateJSON = opt.Format == FormatJSON
+
+	if opt.Controller != "" {
+		controllerProvider, ok := controllerProviders[opt.Controller]
+		if !ok {
+			violations = append(violations, fmt.Sprintf("unknown controller %q, the supported controllers are %s", opt.Controller, strings.Join(controllers(), ", ")))
+		} else if controllerProvider != provider {
+			violations = append(violations, fmt.Sprintf("%s is not supported by the %s provider", controllerFlag, provider))
+		}
+	}
 
 	// Check validations against provider flags
 	switch {
 	case provider == ProviderOpenshift:
 		if chart {
-			log.Fatalf("--chart, -c is a Kubernetes only flag")
+			violations = append(violations, "--chart, -c is a Kubernetes only flag")
 		}
-		if daemonSet {
-			log.Fatalf("--daemon-set is a Kubernetes only flag")
+		if pushChart {
+			violations = append(violations, "--push is a Kubernetes only flag")
 		}
-		if replicationController {
-			log.Fatalf("--replication-controller is a Kubernetes only flag")
+		if namespacePerNetwork {
+			violations = append(violations, "--namespace-per-network is a Kubernetes only flag")
 		}
-		if deployment {
-			log.Fatalf("--deployment, -d is a Kubernetes only flag")
+		if zeroTrust {
+			violations = append(violations, "--zero-trust is a Kubernetes only flag")
 		}
-		if controller == "daemonset" || controller == "replicationcontroller" || controller == "deployment" {
-			log.Fatalf("--controller= daemonset, replicationcontroller or deployment is a Kubernetes only flag")
+		if localCluster {
+			violations = append(violations, "--local-cluster is a Kubernetes only flag")
 		}
 	case provider == ProviderKubernetes:
-		if deploymentConfig {
-			log.Fatalf("--deployment-config is an OpenShift only flag")
-		}
 		if buildRepo {
-			log.Fatalf("--build-repo is an Openshift only flag")
+			violations = append(violations, "--build-repo is an Openshift only flag")
 		}
 		if buildBranch {
-			log.Fatalf("--build-branch is an Openshift only flag")
+			violations = append(violations, "--build-branch is an Openshift only flag")
 		}
-		if controller == "deploymentconfig" {
-			log.Fatalf("--controller=deploymentConfig is an OpenShift only flag")
+		if sccBindings {
+			violations = append(violations, "--scc-bindings is an Openshift only flag")
 		}
 	}
 
-	// Standard checks regardless of provider
+	if len(bundle) > 0 {
+		log.Fatalf("DAB / bundle (--bundle | -b) is no longer supported. See issue: https://github.com/kubernetes/kompose/issues/390")
+		opt.InputFiles = []string{bundle}
+	}
+
+	if len(bundle) > 0 && isFileSet {
+		violations = append(violations, "'compose' file and 'dab' file cannot be specified at the same time")
+	}
+
+	if opt.Diff {
+		if len(args) != 2 {
+			violations = append(violations, "--diff requires the old and the new compose file as arguments")
+		}
+		if isFileSet {
+			violations = append(violations, "--diff and --file can't be set at the same time")
+		}
+	} else if len(args) != 0 {
+		violations = append(violations, "unknown argument(s): "+strings.Join(args, ","))
+	}
+
+	// CI machines pass the secrets by environment rather than on the command line
+	if opt.PushRegistryPassword == "" {
+		opt.PushRegistryPassword = os.Getenv("KOMPOSE_PUSH_REGISTRY_PASSWORD")
+	}
+	if opt.PushRegistryToken == "" {
+		opt.PushRegistryToken = os.Getenv("KOMPOSE_PUSH_REGISTRY_TOKEN")
+	}
+	if opt.Token == "" {
+		opt.Token = os.Getenv("KOMPOSE_TOKEN")
+	}
+	if opt.PushRegistryPassword != "" && opt.PushRegistryUsername == "" && cmd.Flags().Lookup("push-registry-password").Changed {
+		violations = append(violations, "--push-registry-password requires --push-registry-username")
+	}
+
+	return append(violations, validateOptions(*opt)...)
+}
+
+// validateOptions checks the options against each other, regardless of the flags they come from,
+// and returns the violations found
+func validateOptions(opt kobject.ConvertOptions) []string {
+	var violations []string
+
 	if len(opt.OutFile) != 0 && opt.ToStdout {
-		log.Fatalf("Error: --out and --stdout can't be set at the same time")
+		violations = append(violations, "--out and --stdout can't be set at the same time")
 	}
 
 	if opt.CreateChart && opt.ToStdout {
-		log.Fatalf("Error: chart cannot be generated when --stdout is specified")
+		violations = append(violations, "chart cannot be generated when --stdout is specified, use --out to choose the directory of the chart")
 	}
 
-	if opt.Replicas < 0 {
-		log.Fatalf("Error: --replicas cannot be negative")
+	if opt.PushChart != "" {
+		if !opt.CreateChart {
+			violations = append(violations, "--push requires --chart")
+		}
+		if _, _, err := oci.ParseReference(opt.PushChart); err != nil {
+			violations = append(violations, fmt.Sprintf("--push: %v", err))
+		}
+	}
+	if opt.PushPlainHTTP && opt.PushChart == "" {
+		violations = append(violations, "--push-plain-http requires --push")
 	}
 
-	if len(bundle) > 0 {
-		inputFormat = "bundle"
-		log.Fatalf("DAB / bundle (--bundle | -b) is no longer supported. See issue: https://github.com/kubernetes/kompose/issues/390")
-		opt.InputFiles = []string{bundle}
+	if opt.Replicas < 0 {
+		violations = append(violations, "--replicas cannot be negative")
 	}
 
-	if len(bundle) > 0 && isFileSet {
-		log.Fatalf("Error: 'compose' file and 'dab' file cannot be specified at the same time")
+	// a DaemonSet runs one pod per node, its number of pods can't be set
+	if opt.IsReplicaSetFlag && (opt.Controller == kubernetes.DaemonSetController || opt.CreateDS) {
+		violations = append(violations, "--replicas can't be used with a DaemonSet controller, which runs one pod per node")
 	}
 
-	if len(args) != 0 {
-		log.Fatal("Unknown Argument(s): ", strings.Join(args, ","))
+	if opt.Diff && (len(opt.OutFile) != 0 || opt.ToStdout || opt.CreateChart) {
+		violations = append(violations, "--diff can't be used with --out, --stdout or --chart")
 	}
 
 	if opt.GenerateJSON && opt.GenerateYaml {
-		log.Fatalf("YAML and JSON format cannot be provided at the same time")
+		violations = append(violations, "YAML and JSON format cannot be provided at the same time")
+	}
+
+	if opt.Format != "" && opt.Format != FormatYAML && opt.Format != FormatJSON {
+		violations = append(violations, fmt.Sprintf("unknown format %s, possible values are: yaml and json", opt.Format))
 	}
 
 	if opt.Volumes != "persistentVolumeClaim" && opt.Volumes != "emptyDir" && opt.Volumes != "hostPath" && opt.Volumes != "configMap" {
-		log.Fatal("Unknown Volume type: ", opt.Volumes, ", possible values are: persistentVolumeClaim, configMap and emptyDir")
+		violations = append(violations, fmt.Sprintf("unknown volume type %s, possible values are: persistentVolumeClaim, configMap and emptyDir", opt.Volumes))
+	}
+
+	if !transformer.IsValidNameStrategy(opt.NameStrategy) {
+		violations = append(violations, fmt.Sprintf("unknown name strategy %s, possible values are: service, project or a template", opt.NameStrategy))
+	}
+
+	// a malformed pattern would match no variable
+	for _, flag := range []struct {
+		name     string
+		patterns []string
+	}{{"--env-exclude", opt.EnvExclude}, {"--env-mask", opt.EnvMask}} {
+		for _, pattern := range flag.patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				violations = append(violations, fmt.Sprintf("invalid %s pattern %s: %v", flag.name, pattern, err))
+			}
+		}
+	}
+
+	if opt.HeaderFile != "" && opt.GenerateJSON {
+		violations = append(violations, "--header-file can't be used with --format json, JSON files can't hold comments")
+	}
+
+	if opt.KubectlCompatible && opt.GenerateJSON {
+		violations = append(violations, "--kubectl-compatible and --format json can't be set at the same time")
+	}
+
+	if opt.Kubeconfig != "" && !opt.KubectlCompatible && !opt.Discover {
+		violations = append(violations, "--kubeconfig requires --discover or --kubectl-compatible")
+	}
+
+	if opt.Server != "" {
+		if !opt.KubectlCompatible && !opt.Discover {
+			violations = append(violations, "--server requires --discover or --kubectl-compatible")
+		}
+		if opt.Kubeconfig != "" {
+			violations = append(violations, "--server and --kubeconfig can't be set at the same time")
+		}
+	} else if opt.Token != "" || opt.CertificateAuthority != "" {
+		violations = append(violations, "--token and --certificate-authority require --server")
+	}
+
+	if opt.PushRegistryUsername != "" && opt.PushRegistryPassword == "" && opt.PushRegistryToken == "" {
+		violations = append(violations, "--push-registry-username requires --push-registry-password or --push-registry-token")
+	}
+
+	if opt.GroupBy != "" && opt.GroupBy != kubernetes.GroupByService {
+		violations = append(violations, fmt.Sprintf("unknown --group-by value %s, possible value is: service", opt.GroupBy))
+	}
+
+	if opt.HostGatewayIP != "" && net.ParseIP(opt.HostGatewayIP) == nil {
+		violations = append(violations, "invalid --host-gateway-ip: "+opt.HostGatewayIP)
+	}
+
+	if opt.LocalMountRoot != "" {
+		if !opt.LocalCluster {
+			violations = append(violations, "--local-mount-root requires --local-cluster")
+		}
+		if !path.IsAbs(opt.LocalMountRoot) {
+			violations = append(violations, "--local-mount-root must be an absolute path of the nodes: "+opt.LocalMountRoot)
+		}
+	}
+
+	if opt.Mapping != "" && opt.Diff {
+		violations = append(violations, "--mapping can't be used with --diff")
+	}
+
+	if opt.Policy != "" {
+		if info, err := os.Stat(opt.Policy); err != nil || !info.IsDir() {
+			violations = append(violations, "--policy must be a directory of Rego policies: "+opt.Policy)
+		}
 	}
+
+	if opt.RemoveStaleFiles && (opt.ToStdout || opt.Diff) {
+		violations = append(violations, "--remove-stale-files requires the files to be written to a directory")
+	}
+
+	switch opt.SummaryFormat {
+	case "", SummaryText, SummaryNone:
+	case SummaryJSON:
+		if opt.ToStdout {
+			violations = append(violations, "--summary-format json writes the summary to stdout, it can't be used with --stdout")
+		}
+	default:
+		violations = append(violations, fmt.Sprintf("unknown --summary-format value %s, possible values are: text, json, none", opt.SummaryFormat))
+	}
+
+	if opt.PreserveSelectors != "" && opt.Diff {
+		violations = append(violations, "--preserve-selectors can't be used with --diff")
+	}
+
+	if opt.PartOf != "" {
+		if errs := validation.IsValidLabelValue(opt.PartOf); len(errs) > 0 {
+			violations = append(violations, fmt.Sprintf("invalid --part-of %s: %s", opt.PartOf, strings.Join(errs, ", ")))
+		}
+	}
+
+	if opt.AutoIngress != "" {
+		if errs := validation.IsDNS1123Subdomain(opt.AutoIngress); len(errs) > 0 {
+			violations = append(violations, fmt.Sprintf("invalid --auto-ingress domain %s: %s", opt.AutoIngress, strings.Join(errs, ", ")))
+		}
+	}
+
+	if opt.Mesh != "" && opt.Mesh != kubernetes.MeshIstio && opt.Mesh != kubernetes.MeshLinkerd {
+		violations = append(violations, fmt.Sprintf("unknown mesh %s, possible values are: istio and linkerd", opt.Mesh))
+	}
+	return violations
 }
 
 // ValidateComposeFile validates the compose file provided for conversion
 func ValidateComposeFile(opt *kobject.ConvertOptions) {
+	if len(opt.ProjectName) == 0 {
+		opt.ProjectName = os.Getenv("COMPOSE_PROJECT_NAME")
+	}
+
+	if len(opt.InputFiles) == 0 {
+		opt.InputFiles = composeFilesFromEnv()
+	}
+
 	if len(opt.InputFiles) == 0 {
 		for _, name := range DefaultComposeFiles {
 			_, err := os.Stat(name)
@@ -168,84 +451,348 @@ func ValidateComposeFile(opt *kobject.ConvertOptions) {
 	}
 }
 
-func validateControllers(opt *kobject.ConvertOptions) {
+// composeFilesFromEnv returns the compose files listed in COMPOSE_FILE, separated by
+// COMPOSE_PATH_SEPARATOR or by the OS path list separator (":" or ";" on Windows)
+func composeFilesFromEnv() []string {
+	composeFile := os.Getenv("COMPOSE_FILE")
+	if len(composeFile) == 0 {
+		return nil
+	}
 
-	singleOutput := len(opt.OutFile) != 0 || opt.OutFile == "-" || opt.ToStdout
+	separator := os.Getenv("COMPOSE_PATH_SEPARATOR")
+	if len(separator) == 0 {
+		separator = string(os.PathListSeparator)
+	}
+
+	var files []string
+	for _, file := range strings.Split(composeFile, separator) {
+		if len(file) != 0 {
+			files = append(files, file)
+		}
+	}
+	log.Debugf("Using compose files from COMPOSE_FILE: %s", strings.Join(files, ", "))
+	return files
+}
+
+// validateControllers picks the default controller of the provider, ValidateFlags already
+// made sure that a single controller has been set
+func validateControllers(opt *kobject.ConvertOptions) {
 	if opt.Provider == ProviderKubernetes {
 		// create deployment by default if no controller has been set
 		if !opt.CreateD && !opt.CreateDS && !opt.CreateRC && opt.Controller == "" {
 			opt.CreateD = true
 		}
-		if singleOutput {
-			count := 0
-			if opt.CreateD {
-				count++
-			}
-			if opt.CreateDS {
-				count++
-			}
-			if opt.CreateRC {
-				count++
-			}
-			if count > 1 {
-				log.Fatalf("Error: only one kind of Kubernetes resource can be generated when --out or --stdout is specified")
+	} else if opt.Provider == ProviderOpenshift {
+		// create deploymentconfig by default if no controller has been set
+		opt.CreateDeploymentConfig = true
+	}
+}
+
+// Convert transforms docker compose or dab file to k8s objects
+func Convert(opt kobject.ConvertOptions) {
+	objects, komposeObject, err := convertObjects(&opt)
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+
+	if opt.KubectlCompatible || opt.Discover {
+		start := time.Now()
+		objects = adaptToCluster(objects, opt)
+		log.Debugf("Discovered the cluster in %s", time.Since(start))
+	}
+
+	if opt.Policy != "" {
+		checkPolicies(objects, opt.Policy)
+	}
+	printWarnings()
+
+	// Print output
+	start := time.Now()
+	written, err := kubernetes.PrintListSize(objects, opt)
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+	log.Debugf("Serialized %d objects in %s", len(objects), time.Since(start))
+
+	summary := newSummary(len(komposeObject.ServiceConfigs), objects, warningCounts(), written)
+	if err := printSummary(summary, opt.SummaryFormat); err != nil {
+		log.Fatalf("Unable to print the summary: %s", err)
+	}
+}
+
+// checkPolicies exits with an error listing the violations of the Rego policies of dir by the objects
+func checkPolicies(objects []runtime.Object, dir string) {
+	start := time.Now()
+	unstructuredObjects, err := kubernetes.ToUnstructured(objects)
+	if err != nil {
+		log.Fatalf("Unable to convert the objects for the policies: %s", err)
+	}
+	violations, err := kubernetes.EvaluatePolicies(unstructuredObjects, dir)
+	if err != nil {
+		log.Fatalf("Unable to evaluate the policies: %s", err)
+	}
+	if len(violations) > 0 {
+		log.Fatalf("Found %d policy violations in %d objects:\n  - %s", len(violations), len(objects), strings.Join(violations, "\n  - "))
+	}
+	log.Debugf("Checked %d objects against the policies of %s in %s", len(objects), dir, time.Since(start))
+}
+
+// Transform converts the compose files of opt to the objects of the provider without writing them,
+// for programs embedding kompose, which marshal them with kubernetes.MarshalList. Unlike Convert it
+// returns the invalid options as an error instead of exiting. It keeps no state between calls and
+// neither changes the working directory nor writes files, so conversions can run concurrently. The
+// options hold the values of the flags of kompose convert, the provider, volume type and name
+// strategy default to theirs when empty.
+//
+// The file system can't be injected: the compose files, env_files, configs and extended files are
+// read from the file system of the process, partly by docker/cli and libcompose, which read the
+// env_files and extended files themselves. Programs converting files they hold in memory write
+// them into a directory of their own for every conversion first, like kompose serve does.
+func Transform(opt kobject.ConvertOptions) ([]runtime.Object, error) {
+	if opt.Provider == "" {
+		opt.Provider = DefaultProvider
+	}
+	if opt.Volumes == "" {
+		opt.Volumes = "persistentVolumeClaim"
+	}
+	if opt.NameStrategy == "" {
+		opt.NameStrategy = transformer.NameStrategyService
+	}
+	if violations := validateOptions(opt); len(violations) > 0 {
+		return nil, fmt.Errorf("found %d errors in the options:\n  - %s", len(violations), strings.Join(violations, "\n  - "))
+	}
+	objects, _, err := convertObjects(&opt)
+	return objects, err
+}
+
+// convertObjects loads and transforms the compose files, and prepares opt for printing the
+// objects: it chooses the default controller and maps the services for --mapping
+func convertObjects(opt *kobject.ConvertOptions) ([]runtime.Object, kobject.KomposeObject, error) {
+	validateControllers(opt)
+
+	objects, komposeObject, err := transform(*opt)
+	if err != nil {
+		return nil, kobject.KomposeObject{}, err
+	}
+
+	if opt.Mapping != "" {
+		opt.ServiceNames = map[string]string{}
+		for name, service := range komposeObject.ServiceConfigs {
+			if service.ComposeName != "" {
+				opt.ServiceNames[name] = service.ComposeName
 			}
 		}
+	}
 
-	} else if opt.Provider == ProviderOpenshift {
-		// create deploymentconfig by default if no controller has been set
-		if !opt.CreateDeploymentConfig {
-			opt.CreateDeploymentConfig = true
+	if opt.PreserveSelectors != "" {
+		selectors, err := kubernetes.LoadSelectors(opt.PreserveSelectors)
+		if err != nil {
+			return nil, kobject.KomposeObject{}, err
+		}
+		k := kubernetes.Kubernetes{Opt: *opt}
+		preserved, err := k.PreserveSelectors(objects, selectors)
+		if err != nil {
+			return nil, kobject.KomposeObject{}, err
+		}
+		log.Infof("Preserved the selectors of %d controllers of %s", preserved, opt.PreserveSelectors)
+	}
+	return objects, komposeObject, nil
+}
+
+// adaptToCluster switches the objects to the apiVersions served by the cluster of the kubeconfig,
+// keeping the default apiVersions when there is no kubeconfig or the cluster isn't reachable
+func adaptToCluster(objects []runtime.Object, opt kobject.ConvertOptions) []runtime.Object {
+	var cluster *discovery.Cluster
+	var err error
+	path := discovery.KubeconfigPath(opt.Kubeconfig)
+	switch {
+	case opt.Server != "":
+		cluster, err = discovery.NewTokenCluster(opt.Server, opt.Token, opt.CertificateAuthority)
+		if err != nil {
+			log.WithField("category", "cluster").Warnf("Unable to use the server %s, keeping the default apiVersions: %s", opt.Server, err)
+			return objects
+		}
+	case path != "":
+		cluster, err = discovery.NewCluster(path)
+		if err != nil {
+			log.WithField("category", "cluster").Warnf("Unable to use the kubeconfig %s, keeping the default apiVersions: %s", path, err)
+			return objects
+		}
+	case discovery.InCluster():
+		cluster, err = discovery.NewInCluster()
+		if err != nil {
+			log.WithField("category", "cluster").Warnf("Unable to use the service account of the pod, keeping the default apiVersions: %s", err)
+			return objects
+		}
+	default:
+		if opt.Discover {
+			log.WithField("category", "cluster").Warnf("No kubeconfig found to discover the cluster, keeping the default apiVersions")
+		}
+		return objects
+	}
+	served, err := cluster.ServedGroupVersions()
+	if err != nil {
+		log.WithField("category", "cluster").Warnf("Unable to reach the cluster %s, keeping the default apiVersions: %s", cluster.Server, err)
+		return objects
+	}
+	return kubernetes.AdaptAPIVersions(objects, served)
+}
+
+// MigrateControllers converts the ReplicationControllers of previously generated manifest files
+// to Deployments, printing the manifests to stdout or, with inPlace, writing them back. Files
+// named after a ReplicationController, like web-replicationcontroller.yaml, are renamed after the
+// Deployment.
+func MigrateControllers(files []string, inPlace bool, indent int) {
+	var documents [][]byte
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Fatalf("Unable to read %s: %s", file, err)
+		}
+		jsonFormat := inPlace && filepath.Ext(file) == ".json"
+		migrated, count, err := kubernetes.MigrateControllers(data, jsonFormat, indent)
+		if err != nil {
+			log.Fatalf("Unable to migrate %s: %s", file, err)
 		}
-		if singleOutput {
-			count := 0
-			if opt.CreateDeploymentConfig {
-				count++
-			}
-			// Add more controllers here once they are available in OpenShift
-			// if opt.foo {count++}
 
-			if count > 1 {
-				log.Fatalf("Error: only one kind of OpenShift resource can be generated when --out or --stdout is specified")
+		if !inPlace {
+			if len(migrated) > 0 {
+				documents = append(documents, migrated)
 			}
+			continue
 		}
+		if count == 0 {
+			log.Debugf("%s holds no ReplicationController", file)
+			continue
+		}
+		target := file
+		if base := strings.TrimSuffix(file, filepath.Ext(file)); strings.HasSuffix(base, "-replicationcontroller") {
+			target = strings.TrimSuffix(base, "-replicationcontroller") + "-deployment" + filepath.Ext(file)
+		}
+		if err := ioutil.WriteFile(target, migrated, 0644); err != nil {
+			log.Fatalf("Unable to write %s: %s", target, err)
+		}
+		if target != file {
+			if err := os.Remove(file); err != nil {
+				log.Fatalf("Unable to remove %s: %s", file, err)
+			}
+			log.Infof("%s is migrated to %s", file, target)
+		} else {
+			log.Infof("%s is migrated", file)
+		}
+	}
+	if !inPlace {
+		os.Stdout.Write(bytes.Join(documents, []byte("---\n")))
 	}
 }
 
-// Convert transforms docker compose or dab file to k8s objects
-func Convert(opt kobject.ConvertOptions) {
+// Diff converts two docker compose files and prints the differences between the resulting objects
+func Diff(opt kobject.ConvertOptions, oldFile string, newFile string) {
 
 	validateControllers(&opt)
 
-	// loader parses input from file into komposeObject.
-	l, err := loader.GetLoader(inputFormat)
+	opt.InputFiles = []string{oldFile}
+	oldObjects, _, err := transform(opt)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatalf("Unable to convert %s: %s", oldFile, err)
 	}
 
-	komposeObject := kobject.KomposeObject{
-		ServiceConfigs: make(map[string]kobject.ServiceConfig),
+	opt.InputFiles = []string{newFile}
+	newObjects, _, err := transform(opt)
+	if err != nil {
+		log.Fatalf("Unable to convert %s: %s", newFile, err)
 	}
-	komposeObject, err = l.LoadFile(opt.InputFiles)
+
+	changed, err := kubernetes.PrintDiff(oldObjects, newObjects, opt, os.Stdout)
 	if err != nil {
 		log.Fatalf(err.Error())
 	}
+	if changed == 0 {
+		log.Info("No differences found")
+	}
+}
 
-	// Get a transformer that maps komposeObject to provider's primitives
-	t := getTransformer(opt)
+// Export loads the compose files or quadlet units of opt and writes them back as a version 3
+// compose file to opt.OutFile, or to stdout when it is empty
+func Export(opt kobject.ConvertOptions) {
+	format, err := inputFormat(opt.InputFiles)
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+	l, err := loader.GetLoader(format, opt)
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+	komposeObject, err := l.LoadFile(opt.InputFiles)
+	if err != nil {
+		log.Fatalf("Unable to load %s: %s", strings.Join(opt.InputFiles, ", "), err)
+	}
+	data, err := compose.Marshal(komposeObject)
+	if err != nil {
+		log.Fatalf("Unable to export the compose file: %s", err)
+	}
 
-	// Do the transformation
-	objects, err := t.Transform(komposeObject, opt)
+	if opt.OutFile == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := ioutil.WriteFile(opt.OutFile, data, 0644); err != nil {
+		log.Fatalf("Unable to write %s: %s", opt.OutFile, err)
+	}
+	log.Infof("Compose file %q created", opt.OutFile)
+}
 
+// transform loads the input files and maps them to the provider's objects
+func transform(opt kobject.ConvertOptions) ([]runtime.Object, kobject.KomposeObject, error) {
+	// loader parses input from file into komposeObject.
+	format, err := inputFormat(opt.InputFiles)
 	if err != nil {
-		log.Fatalf(err.Error())
+		return nil, kobject.KomposeObject{}, err
+	}
+	l, err := loader.GetLoader(format, opt)
+	if err != nil {
+		return nil, kobject.KomposeObject{}, err
 	}
 
-	// Print output
-	err = kubernetes.PrintList(objects, opt)
+	start := time.Now()
+	komposeObject, err := l.LoadFile(opt.InputFiles)
 	if err != nil {
-		log.Fatalf(err.Error())
+		return nil, kobject.KomposeObject{}, err
+	}
+	log.Debugf("Parsed %d services in %s", len(komposeObject.ServiceConfigs), time.Since(start))
+
+	transformer.ReportUnused(&komposeObject, opt.PruneUnused)
+
+	applyServiceOverrides(&komposeObject, opt.ServiceOverrides)
+
+	if opt.SmartDefaults {
+		kubernetes.ApplyImageDefaults(&komposeObject)
+	}
+
+	if opt.Provider == ProviderKubernetes {
+		if err := kubernetes.ValidateServices(komposeObject, opt); err != nil {
+			return nil, kobject.KomposeObject{}, err
+		}
+	}
+
+	if err := transformer.RenameServices(&komposeObject, opt); err != nil {
+		return nil, kobject.KomposeObject{}, err
+	}
+	for _, reference := range kubernetes.BrokenServiceReferences(komposeObject, opt) {
+		log.WithFields(log.Fields{"service": reference.Service, "category": "networking"}).Warn(reference.Message)
+	}
+
+	// Get a transformer that maps komposeObject to provider's primitives
+	t := getTransformer(opt)
+
+	// Do the transformation
+	start = time.Now()
+	objects, err := t.Transform(komposeObject, opt)
+	if err != nil {
+		return nil, kobject.KomposeObject{}, err
 	}
+	log.Debugf("Transformed %d services into %d objects in %s", len(komposeObject.ServiceConfigs), len(objects), time.Since(start))
+	return objects, komposeObject, nil
 }
 
 // Convenience method to return the appropriate Transformer based on
@@ -0,0 +1,276 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/testutils"
+	"github.com/kubernetes/kompose/pkg/transformer"
+	"github.com/kubernetes/kompose/pkg/transformer/kubernetes"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// TestTransformConcurrently runs conversions of different compose files and options in parallel,
+// which must give the same manifests as running them one after the other. Run with -race, like
+// make test-unit does, it also checks that the conversions share no mutable state.
+func TestTransformConcurrently(t *testing.T) {
+	dir := testutils.CreateLocalDirectory(t)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"v1.yaml": `web:
+  image: nginx
+  ports:
+    - "80:80"
+  environment:
+    - MODE=v1
+`,
+		"v3.yaml": `version: "3.5"
+services:
+  web:
+    image: nginx
+    ports:
+      - "8080:80"
+    env_file: web.env
+    configs:
+      - source: site
+        target: /etc/nginx/conf.d/site.conf
+  db:
+    image: postgres
+    volumes:
+      - data:/var/lib/postgresql/data
+volumes:
+  data:
+configs:
+  site:
+    file: ./site.conf
+`,
+		"web.env":   "MODE=v3\n",
+		"site.conf": "server {}\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var options []kobject.ConvertOptions
+	for _, file := range []string{"v1.yaml", "v3.yaml"} {
+		for _, provider := range []string{ProviderKubernetes, ProviderOpenshift} {
+			for _, replicas := range []int{1, 3} {
+				options = append(options, kobject.ConvertOptions{
+					InputFiles:  []string{filepath.Join(dir, file)},
+					Provider:    provider,
+					Replicas:    replicas,
+					YAMLIndent:  2,
+					ProjectName: fmt.Sprintf("%s-%d", provider, replicas),
+				})
+			}
+		}
+	}
+
+	manifests := func(opt kobject.ConvertOptions) (string, error) {
+		objects, err := Transform(opt)
+		if err != nil {
+			return "", err
+		}
+		data, err := kubernetes.MarshalList(objects, opt)
+		return string(data), err
+	}
+	expected := make([]string, len(options))
+	for i, opt := range options {
+		var err error
+		if expected[i], err = manifests(opt); err != nil {
+			t.Fatalf("Transform of %v failed: %v", opt.InputFiles, err)
+		}
+	}
+
+	const rounds = 4
+	var wg sync.WaitGroup
+	errs := make(chan error, rounds*len(options))
+	for round := 0; round < rounds; round++ {
+		for i, opt := range options {
+			wg.Add(1)
+			go func(i int, opt kobject.ConvertOptions) {
+				defer wg.Done()
+				got, err := manifests(opt)
+				if err != nil {
+					errs <- err
+				} else if got != expected[i] {
+					errs <- fmt.Errorf("the concurrent conversion of %v to %s with %d replicas differs:\n%s", opt.InputFiles, opt.Provider, opt.Replicas, got)
+				}
+			}(i, opt)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// newDiscoveryServer returns a fake API server serving the group versions of groups, or failing
+// the discovery if groups is nil
+func newDiscoveryServer(groups []string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if groups == nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var body interface{}
+		switch r.URL.Path {
+		case "/api":
+			body = metav1.APIVersions{Versions: []string{"v1"}}
+		case "/apis":
+			list := metav1.APIGroupList{}
+			for _, groupVersion := range groups {
+				list.Groups = append(list.Groups, metav1.APIGroup{
+					Versions: []metav1.GroupVersionForDiscovery{{GroupVersion: groupVersion}},
+				})
+			}
+			body = list
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+}
+
+func TestAdaptToCluster(t *testing.T) {
+	testCases := map[string]struct {
+		groups      []string
+		apiVersions []string
+	}{
+		"Served group versions are kept": {
+			[]string{"apps/v1", "autoscaling/v2"},
+			[]string{"v1", "apps/v1", "autoscaling/v2"},
+		},
+		"Group versions not served are switched to equivalent ones": {
+			[]string{"apps/v1", "autoscaling/v2beta2"},
+			[]string{"v1", "apps/v1", "autoscaling/v2beta2"},
+		},
+		"Group versions without served equivalent are kept": {
+			[]string{"apps/v1"},
+			[]string{"v1", "apps/v1", "autoscaling/v2"},
+		},
+		"An unreachable cluster keeps the default group versions": {
+			nil,
+			[]string{"v1", "apps/v1", "autoscaling/v2"},
+		},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			server := newDiscoveryServer(test.groups)
+			defer server.Close()
+
+			var objects []runtime.Object
+			for _, typeMeta := range [][]string{{"v1", "Service"}, {"apps/v1", "Deployment"}, {"autoscaling/v2", "HorizontalPodAutoscaler"}} {
+				obj := &unstructured.Unstructured{}
+				obj.SetAPIVersion(typeMeta[0])
+				obj.SetKind(typeMeta[1])
+				obj.SetName("web")
+				objects = append(objects, obj)
+			}
+			objects = adaptToCluster(objects, kobject.ConvertOptions{Discover: true, Server: server.URL})
+
+			var apiVersions []string
+			for _, obj := range objects {
+				apiVersions = append(apiVersions, obj.GetObjectKind().GroupVersionKind().GroupVersion().String())
+			}
+			if !reflect.DeepEqual(apiVersions, test.apiVersions) {
+				t.Errorf("Expected the apiVersions %v, got %v", test.apiVersions, apiVersions)
+			}
+		})
+	}
+}
+
+// newConvertCommand returns a command with the flags of kompose convert read by validateFlags,
+// parsed from args
+func newConvertCommand(t *testing.T, args ...string) *cobra.Command {
+	t.Helper()
+	cmd := &cobra.Command{}
+	cmd.Flags().String("provider", ProviderKubernetes, "")
+	for _, name := range []string{"file", "build-repo", "build-branch", "push", "push-registry-password"} {
+		cmd.Flags().String(name, "", "")
+	}
+	for _, name := range []string{"scc-bindings", "chart", "namespace-per-network", "zero-trust", "local-cluster", FormatYAML, FormatJSON} {
+		cmd.Flags().Bool(name, false, "")
+	}
+	for _, alias := range controllerAliases {
+		cmd.Flags().Bool(alias.flag, false, "")
+	}
+	if err := cmd.Flags().Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return cmd
+}
+
+func TestValidateFlagsFormat(t *testing.T) {
+	testCases := map[string]struct {
+		format    string
+		args      []string
+		expected  string
+		violation string
+	}{
+		"Default":                      {"", nil, "", ""},
+		"--format yaml":                {"yaml", nil, FormatYAML, ""},
+		"--format json":                {"json", nil, FormatJSON, ""},
+		"--yaml":                       {"", []string{"--yaml"}, FormatYAML, ""},
+		"--json":                       {"", []string{"--json"}, FormatJSON, ""},
+		"--json with --format json":    {"json", []string{"--json"}, FormatJSON, ""},
+		"--json with --format yaml":    {"yaml", []string{"--json"}, FormatYAML, "--json and --format=yaml can't be set at the same time"},
+		"--yaml with --json":           {"", []string{"--yaml", "--json"}, FormatYAML, "--json and --yaml can't be set at the same time"},
+		"Unknown format":               {"xml", nil, "xml", "unknown format xml, possible values are: yaml and json"},
+		"Unknown format with an alias": {"xml", []string{"--yaml"}, "xml", "--yaml and --format=xml can't be set at the same time"},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			opt := kobject.ConvertOptions{Format: test.format, Volumes: "persistentVolumeClaim", Replicas: 1, NameStrategy: transformer.NameStrategyService, SummaryFormat: "text"}
+			violations := validateFlags("", nil, newConvertCommand(t, test.args...), &opt)
+			if opt.Format != test.expected {
+				t.Errorf("Expected the format %q, got %q", test.expected, opt.Format)
+			}
+			if opt.GenerateYaml != (test.expected == FormatYAML) || opt.GenerateJSON != (test.expected == FormatJSON) {
+				t.Errorf("Expected GenerateYaml and GenerateJSON to follow the format %q, got %t and %t", test.expected, opt.GenerateYaml, opt.GenerateJSON)
+			}
+			if test.violation == "" && len(violations) > 0 {
+				t.Errorf("Expected no violation, got %q", violations)
+			}
+			if test.violation != "" && !strings.Contains(strings.Join(violations, "\n"), test.violation) {
+				t.Errorf("Expected the violation %q, got %q", test.violation, violations)
+			}
+		})
+	}
+}
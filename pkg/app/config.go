@@ -0,0 +1,108 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/transformer"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultConfigFile is the kompose config file used if no file is explicitly set
+const DefaultConfigFile = ".kompose.yaml"
+
+// Config holds the conversion settings written by `kompose init`
+type Config struct {
+	Controller string                             `yaml:"controller,omitempty"`
+	Volumes    string                             `yaml:"volumes,omitempty"`
+	Replicas   int                                `yaml:"replicas,omitempty"`
+	Services   map[string]kobject.ServiceOverride `yaml:"services,omitempty"`
+}
+
+// LoadConfig reads a kompose config file
+func LoadConfig(file string) (*Config, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to read config file")
+	}
+
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, errors.Wrapf(err, "Unable to parse config file %s", file)
+	}
+	return config, nil
+}
+
+// WriteConfig writes a kompose config file
+func WriteConfig(file string, config *Config) error {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return errors.Wrap(err, "Unable to marshal config file")
+	}
+	return ioutil.WriteFile(file, data, 0644)
+}
+
+// ApplyConfig uses the kompose config file for the options which have not been set from the command line
+func ApplyConfig(file string, cmd *cobra.Command, opt *kobject.ConvertOptions) {
+	if !transformer.Exists(file) {
+		if cmd.Flags().Lookup("config").Changed {
+			log.Fatalf("Config file %q not found", file)
+		}
+		return
+	}
+
+	config, err := LoadConfig(file)
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+	log.Debugf("Using config file: %s", file)
+
+	if config.Controller != "" && !opt.IsPodController() {
+		opt.Controller = strings.ToLower(config.Controller)
+	}
+	if config.Volumes != "" && !cmd.Flags().Lookup("volumes").Changed {
+		opt.Volumes = config.Volumes
+	}
+	if config.Replicas != 0 && !opt.IsReplicaSetFlag {
+		opt.Replicas = config.Replicas
+	}
+	opt.ServiceOverrides = config.Services
+}
+
+// applyServiceOverrides sets the per-service settings from the kompose config file
+func applyServiceOverrides(komposeObject *kobject.KomposeObject, overrides map[string]kobject.ServiceOverride) {
+	for name, override := range overrides {
+		service, ok := komposeObject.ServiceConfigs[name]
+		if !ok {
+			log.WithFields(log.Fields{"service": name, "category": "unused"}).Warn("Service from the config file is not defined in the compose file - ignoring")
+			continue
+		}
+		if override.Expose != "" {
+			service.ExposeService = override.Expose
+		}
+		if override.Replicas != 0 {
+			service.Replicas = override.Replicas
+		}
+		komposeObject.ServiceConfigs[name] = service
+	}
+}
@@ -0,0 +1,382 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kubernetes/kompose/pkg/transformer/kubernetes"
+	"github.com/kubernetes/kompose/pkg/transformer/openshift"
+	"github.com/kubernetes/kompose/pkg/utils/discovery"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// KompositionAPIVersion is the apiVersion of the Komposition custom resource
+	KompositionAPIVersion = "kompose.io/v1alpha1"
+	// KompositionKind is the kind of the Komposition custom resource
+	KompositionKind = "Komposition"
+
+	// operatorFieldManager is the field manager of the objects kompose operator applies
+	operatorFieldManager = "kompose-operator"
+)
+
+// KompositionCRD is the CustomResourceDefinition of the Komposition custom resource, a compose
+// file kompose operator converts and applies in the namespace of the resource
+const KompositionCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: kompositions.kompose.io
+spec:
+  group: kompose.io
+  names:
+    kind: Komposition
+    listKind: KompositionList
+    plural: kompositions
+    singular: komposition
+  scope: Namespaced
+  versions:
+    - name: v1alpha1
+      served: true
+      storage: true
+      subresources:
+        status: {}
+      additionalPrinterColumns:
+        - name: Objects
+          type: integer
+          jsonPath: .status.objectCount
+        - name: Error
+          type: string
+          jsonPath: .status.error
+        - name: Age
+          type: date
+          jsonPath: .metadata.creationTimestamp
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              required: [compose]
+              properties:
+                compose:
+                  type: string
+                  description: The compose file
+                options:
+                  type: object
+                  description: The options of the conversion, like the query parameters of kompose serve
+                  additionalProperties:
+                    type: string
+            status:
+              type: object
+              properties:
+                observedGeneration:
+                  type: integer
+                  format: int64
+                objectCount:
+                  type: integer
+                objects:
+                  type: array
+                  items:
+                    type: object
+                    properties:
+                      apiVersion:
+                        type: string
+                      kind:
+                        type: string
+                      name:
+                        type: string
+                error:
+                  type: string
+`
+
+// Komposition is a compose file kompose operator converts and applies in the namespace of the
+// Komposition, see KompositionCRD
+type Komposition struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KompositionSpec   `json:"spec"`
+	Status KompositionStatus `json:"status,omitempty"`
+}
+
+// KompositionSpec is the compose file of a Komposition and the options of its conversion
+type KompositionSpec struct {
+	Compose string `json:"compose"`
+	// Options are the options kompose serve takes as query parameters, see serveOptions
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// KompositionStatus is the outcome of the last reconciliation of a Komposition
+type KompositionStatus struct {
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	ObjectCount        int   `json:"objectCount,omitempty"`
+	// Objects are the objects applied for the Komposition, the ones missing from the next
+	// conversion are deleted
+	Objects []KompositionObject `json:"objects,omitempty"`
+	Error   string              `json:"error,omitempty"`
+}
+
+// KompositionObject refers to an object applied for a Komposition
+type KompositionObject struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+}
+
+// kompositionList is a list of Kompositions
+type kompositionList struct {
+	Items []Komposition `json:"items"`
+}
+
+// OperatorPermissions are the pods the Kompositions may run besides the ones admitted by the
+// restricted SecurityContextConstraints. The operator applies the objects with its own service
+// account, so the authors of the Kompositions would otherwise get pods they may not be allowed to
+// create themselves.
+type OperatorPermissions struct {
+	// HostPath allows the pods mounting hostPath volumes, like the ones of the volumes option hostPath
+	HostPath bool
+	// Privileged allows privileged containers, added capabilities and the namespaces of the host
+	Privileged bool
+}
+
+// OperatorCluster returns the cluster kompose operator reconciles: the cluster of the kubeconfig
+// if there is one, else the cluster of the pod it runs in
+func OperatorCluster(kubeconfig string) (*discovery.Cluster, error) {
+	if path := discovery.KubeconfigPath(kubeconfig); path != "" {
+		return discovery.NewCluster(path)
+	}
+	if discovery.InCluster() {
+		return discovery.NewInCluster()
+	}
+	return nil, errors.New("no kubeconfig found and not running in a pod")
+}
+
+// RunOperator reconciles the Kompositions of namespace, or of all namespaces if it is empty,
+// every interval until it fails to list them, refusing the pods permissions doesn't allow
+func RunOperator(cluster *discovery.Cluster, namespace string, interval time.Duration, permissions OperatorPermissions) error {
+	path := "/apis/" + KompositionAPIVersion + "/kompositions"
+	if namespace != "" {
+		path = "/apis/" + KompositionAPIVersion + "/namespaces/" + url.PathEscape(namespace) + "/kompositions"
+	}
+	log.Infof("Reconciling the Kompositions of %s every %s", cluster.Server, interval)
+	for {
+		var list kompositionList
+		if err := cluster.Get(path, &list); err != nil {
+			return errors.Wrap(err, "unable to list the Kompositions, the CustomResourceDefinition printed by --print-crd must be installed")
+		}
+		for i := range list.Items {
+			k := &list.Items[i]
+			if k.DeletionTimestamp != nil {
+				continue
+			}
+			if err := reconcileKomposition(cluster, k, permissions); err != nil {
+				log.Errorf("Unable to reconcile the Komposition %s/%s: %s", k.Namespace, k.Name, err)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+// reconcileKomposition converts a Komposition and applies the objects in its namespace, owned by
+// the Komposition so deleting it deletes them. The objects of the previous reconciliation that the
+// conversion no longer generates are deleted. The outcome is written to the status.
+func reconcileKomposition(cluster *discovery.Cluster, k *Komposition, permissions OperatorPermissions) error {
+	start := time.Now()
+	status := map[string]interface{}{"observedGeneration": k.Generation, "error": nil}
+
+	objects, err := convertKomposition(k, permissions)
+	if err != nil {
+		// the objects of the last conversion are kept until the compose file is fixed
+		log.WithField("category", "cluster").Warnf("Unable to convert the Komposition %s/%s: %s", k.Namespace, k.Name, err)
+		status["error"] = err.Error()
+		return patchKompositionStatus(cluster, k, status)
+	}
+
+	controller := true
+	owner := metav1.OwnerReference{
+		APIVersion: KompositionAPIVersion,
+		Kind:       KompositionKind,
+		Name:       k.Name,
+		UID:        k.UID,
+		Controller: &controller,
+	}
+	var applied []KompositionObject
+	var failures []string
+	for _, obj := range objects {
+		u, err := operatorObject(cluster, obj, k.Namespace, owner)
+		if err == nil {
+			err = cluster.Apply(u, operatorFieldManager)
+		}
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		applied = append(applied, KompositionObject{APIVersion: u.GetAPIVersion(), Kind: u.GetKind(), Name: u.GetName()})
+	}
+
+	current := map[KompositionObject]bool{}
+	for _, obj := range applied {
+		current[obj] = true
+	}
+	for _, obj := range k.Status.Objects {
+		if current[obj] {
+			continue
+		}
+		if len(failures) > 0 {
+			// the object may only have failed to apply
+			applied = append(applied, obj)
+			continue
+		}
+		log.Infof("Deleting the %s %s/%s no longer generated for the Komposition %s", obj.Kind, k.Namespace, obj.Name, k.Name)
+		if err := cluster.Delete(obj.APIVersion, obj.Kind, k.Namespace, obj.Name); err != nil {
+			failures = append(failures, err.Error())
+			applied = append(applied, obj)
+		}
+	}
+
+	status["objects"] = applied
+	status["objectCount"] = len(applied)
+	if len(failures) > 0 {
+		status["error"] = strings.Join(failures, "; ")
+	}
+	log.Debugf("Reconciled the Komposition %s/%s with %d objects in %s", k.Namespace, k.Name, len(applied), time.Since(start))
+	return patchKompositionStatus(cluster, k, status)
+}
+
+// convertKomposition converts the compose file of a Komposition with its options, named after
+// the Komposition unless the options set a project-name. The conversions whose pods permissions
+// doesn't allow are refused.
+func convertKomposition(k *Komposition, permissions OperatorPermissions) ([]runtime.Object, error) {
+	query := url.Values{}
+	for name, value := range k.Spec.Options {
+		query.Set(name, value)
+	}
+	if query.Get("project-name") == "" {
+		query.Set("project-name", k.Name)
+	}
+	opt, err := serveOptions(query)
+	if err != nil {
+		return nil, err
+	}
+	if opt.CreateChart {
+		return nil, errors.New("the chart option can't be applied")
+	}
+	if opt.Volumes == "hostPath" && !permissions.HostPath {
+		return nil, errors.New("the volumes option hostPath isn't allowed, kompose operator allows it with --allow-host-path")
+	}
+	// the files and the service account token of the operator mustn't end up in the namespace
+	if err := refuseExternalReferences([]byte(k.Spec.Compose)); err != nil {
+		return nil, err
+	}
+
+	// the compose file is loaded from a directory of its own, like the ones of kompose serve
+	dir, err := ioutil.TempDir("", "kompose-operator-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "docker-compose.yaml")
+	if err := ioutil.WriteFile(file, []byte(k.Spec.Compose), 0644); err != nil {
+		return nil, err
+	}
+	opt.InputFiles = []string{file}
+	objects, err := Transform(opt)
+	if err != nil {
+		return nil, err
+	}
+	if err := refuseForbiddenPods(objects, permissions); err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// refuseForbiddenPods returns an error listing the objects running pods that permissions doesn't
+// allow. The privileged pods are the ones needing the privileged SecurityContextConstraints.
+func refuseForbiddenPods(objects []runtime.Object, permissions OperatorPermissions) error {
+	var forbidden []string
+	for _, obj := range objects {
+		data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return err
+		}
+		u := &unstructured.Unstructured{Object: data}
+		template, err := kubernetes.PodTemplateOf(u)
+		if err != nil {
+			return err
+		}
+		if template == nil {
+			continue
+		}
+		if !permissions.Privileged && openshift.RequiredSCC(&template.Spec) == openshift.SCCPrivileged {
+			forbidden = append(forbidden, fmt.Sprintf("%s %s runs privileged containers, adds capabilities or uses the namespaces of the host, which needs --allow-privileged", u.GetKind(), u.GetName()))
+		}
+		if permissions.HostPath {
+			continue
+		}
+		for _, volume := range template.Spec.Volumes {
+			if volume.HostPath != nil {
+				forbidden = append(forbidden, fmt.Sprintf("%s %s mounts the hostPath volume %s, which needs --allow-host-path", u.GetKind(), u.GetName(), volume.Name))
+			}
+		}
+	}
+	if len(forbidden) > 0 {
+		return errors.Errorf("kompose operator doesn't allow the pods of the Komposition: %s", strings.Join(forbidden, "; "))
+	}
+	return nil
+}
+
+// operatorObject returns an object to apply in namespace, owned by owner if it is namespaced.
+// Objects that aren't namespaced can't be owned by a Komposition and are left when it is deleted.
+func operatorObject(cluster *discovery.Cluster, obj runtime.Object, namespace string, owner metav1.OwnerReference) (*unstructured.Unstructured, error) {
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: data}
+	// the status belongs to the cluster
+	delete(u.Object, "status")
+
+	_, namespaced, err := cluster.Resource(u.GetAPIVersion(), u.GetKind())
+	if err != nil {
+		return nil, err
+	}
+	if namespaced {
+		u.SetNamespace(namespace)
+		u.SetOwnerReferences([]metav1.OwnerReference{owner})
+	}
+	return u, nil
+}
+
+// patchKompositionStatus writes the status of a Komposition
+func patchKompositionStatus(cluster *discovery.Cluster, k *Komposition, status map[string]interface{}) error {
+	path, err := cluster.ObjectPath(KompositionAPIVersion, KompositionKind, k.Namespace, k.Name)
+	if err != nil {
+		return err
+	}
+	return cluster.MergePatch(path+"/status", map[string]interface{}{"status": status})
+}
@@ -0,0 +1,232 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestConvertKomposition(t *testing.T) {
+	k := &Komposition{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "apps"},
+		Spec: KompositionSpec{
+			Options: map[string]string{"replicas": "2"},
+			Compose: `version: "3"
+services:
+  web:
+    image: nginx
+    ports:
+      - "80:80"
+`,
+		},
+	}
+	objects, err := convertKomposition(k, OperatorPermissions{})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	var deployment *appsv1.Deployment
+	for _, obj := range objects {
+		if d, ok := obj.(*appsv1.Deployment); ok {
+			deployment = d
+		}
+	}
+	if deployment == nil {
+		t.Fatalf("Expected a Deployment, got %v", objects)
+	}
+	if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != 2 {
+		t.Errorf("Expected the 2 replicas of the options, got %v", deployment.Spec.Replicas)
+	}
+
+	k.Spec.Options = map[string]string{"chart": "true"}
+	if _, err := convertKomposition(k, OperatorPermissions{}); err == nil || !strings.Contains(err.Error(), "chart") {
+		t.Errorf("Expected an error for the chart option, got %v", err)
+	}
+}
+
+func TestConvertKompositionRefusesOperatorFiles(t *testing.T) {
+	testCases := map[string]struct {
+		compose   string
+		reference string
+	}{
+		"service account token": {`version: "3.5"
+services:
+  web:
+    image: nginx
+    secrets:
+      - s
+secrets:
+  s:
+    file: ../../var/run/secrets/kubernetes.io/serviceaccount/token
+`, "secrets.s.file"},
+		"env_file": {`version: "3"
+services:
+  web:
+    image: nginx
+    env_file:
+      - /var/run/secrets/kubernetes.io/serviceaccount/namespace
+`, "services.web.env_file"},
+		"config file": {`version: "3.5"
+services:
+  web:
+    image: nginx
+configs:
+  c:
+    file: /etc/passwd
+`, "configs.c.file"},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			k := &Komposition{
+				ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "apps"},
+				Spec:       KompositionSpec{Compose: test.compose},
+			}
+			objects, err := convertKomposition(k, OperatorPermissions{})
+			if err == nil {
+				t.Fatalf("Expected an error, got %d objects", len(objects))
+			}
+			if !strings.Contains(err.Error(), test.reference) {
+				t.Errorf("Expected the error to name %s, got %v", test.reference, err)
+			}
+		})
+	}
+}
+
+func TestConvertKompositionIsolatesEnvironment(t *testing.T) {
+	os.Setenv("KOMPOSE_OPERATOR_SECRET", "hunter2")
+	defer os.Unsetenv("KOMPOSE_OPERATOR_SECRET")
+	k := &Komposition{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "apps"},
+		Spec: KompositionSpec{Compose: `version: "3"
+services:
+  web:
+    image: nginx:${KOMPOSE_OPERATOR_SECRET:-latest}
+    environment:
+      - KOMPOSE_OPERATOR_SECRET
+      - TOKEN=$KOMPOSE_OPERATOR_SECRET
+`},
+	}
+	objects, err := convertKomposition(k, OperatorPermissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range objects {
+		d, ok := obj.(*appsv1.Deployment)
+		if !ok {
+			continue
+		}
+		container := d.Spec.Template.Spec.Containers[0]
+		if container.Image != "nginx:latest" {
+			t.Errorf("Expected the default tag of the image, got %s", container.Image)
+		}
+		for _, env := range container.Env {
+			if env.Value == "hunter2" {
+				t.Errorf("Expected the environment of the operator to stay out of the objects, got %s=%s", env.Name, env.Value)
+			}
+		}
+	}
+}
+
+func TestConvertKompositionPermissions(t *testing.T) {
+	testCases := map[string]struct {
+		options     map[string]string
+		compose     string
+		permissions OperatorPermissions
+		err         string
+	}{
+		"privileged": {nil, `version: "3"
+services:
+  web:
+    image: nginx
+    privileged: true
+`, OperatorPermissions{}, "Deployment web runs privileged containers"},
+		"capabilities": {nil, `version: "3"
+services:
+  web:
+    image: nginx
+    cap_add:
+      - NET_ADMIN
+`, OperatorPermissions{HostPath: true}, "--allow-privileged"},
+		"privileged allowed": {nil, `version: "3"
+services:
+  web:
+    image: nginx
+    privileged: true
+`, OperatorPermissions{Privileged: true}, ""},
+		"hostPath volumes": {map[string]string{"volumes": "hostPath"}, `version: "3"
+services:
+  web:
+    image: nginx
+    volumes:
+      - /var/lib/kubelet:/data
+`, OperatorPermissions{Privileged: true}, "--allow-host-path"},
+		"hostPath volumes allowed": {map[string]string{"volumes": "hostPath"}, `version: "3"
+services:
+  web:
+    image: nginx
+    volumes:
+      - /data:/data
+`, OperatorPermissions{HostPath: true}, ""},
+		"privileged with hostPath volumes": {map[string]string{"volumes": "hostPath"}, `version: "3"
+services:
+  web:
+    image: nginx
+    privileged: true
+    volumes:
+      - /data:/data
+`, OperatorPermissions{HostPath: true}, "--allow-privileged"},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			k := &Komposition{
+				ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "apps"},
+				Spec:       KompositionSpec{Options: test.options, Compose: test.compose},
+			}
+			_, err := convertKomposition(k, test.permissions)
+			if test.err == "" && err != nil {
+				t.Errorf("Expected the permissions to allow the pods, got %v", err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Errorf("Expected an error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestRefuseForbiddenPods(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "web"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "web", Image: "nginx"}},
+			Volumes:    []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/data"}}}},
+		}}},
+	}
+	objects := []runtime.Object{deployment}
+	if err := refuseForbiddenPods(objects, OperatorPermissions{Privileged: true}); err == nil || !strings.Contains(err.Error(), "Deployment web mounts the hostPath volume data") {
+		t.Errorf("Expected the hostPath volume to be refused, got %v", err)
+	}
+	if err := refuseForbiddenPods(objects, OperatorPermissions{HostPath: true}); err != nil {
+		t.Errorf("Expected the hostPath volume to be allowed, got %v", err)
+	}
+}
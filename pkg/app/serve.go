@@ -0,0 +1,335 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/loader/compose"
+	"github.com/kubernetes/kompose/pkg/transformer/kubernetes"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// serveMaxBodySize is the size of the largest compose file kompose serve converts
+const serveMaxBodySize = 4 << 20
+
+// serveDefaultProject is the project name of the conversions that set none
+const serveDefaultProject = "app"
+
+// conversion is the response to a conversion
+type conversion struct {
+	key         string
+	contentType string
+	filename    string
+	data        []byte
+}
+
+// conversionCache keeps the last conversions by the hash of their compose file and options, so
+// the unchanged compose files sent again, e.g. on every save of an editor, aren't converted again.
+// The compose files referring to files of the server are refused before the cache is looked up, and
+// the compose files are interpolated without the environment of the server, so a conversion only
+// depends on the compose file and the options.
+type conversionCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// newConversionCache returns a cache of the size last conversions, which caches nothing for 0
+func newConversionCache(size int) *conversionCache {
+	return &conversionCache{size: size, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// conversionKey returns the key of the conversion of data with opt, the options resolved from the
+// query, so the queries giving the same options, like ones setting a default value, share it
+func conversionKey(data []byte, opt kobject.ConvertOptions) (string, error) {
+	options, err := json.Marshal(opt)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write(options)
+	h.Write([]byte{0})
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// get returns the conversion of key, if it is cached
+func (c *conversionCache) get(key string) (*conversion, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*conversion), true
+}
+
+// add caches a conversion, dropping the least recently used one when the cache is full
+func (c *conversionCache) add(conv *conversion) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size <= 0 {
+		return
+	}
+	if element, ok := c.entries[conv.key]; ok {
+		element.Value = conv
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[conv.key] = c.order.PushFront(conv)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*conversion).key)
+	}
+}
+
+// NewServer returns the handler of kompose serve. POST /convert converts the compose file of the
+// body with the options of the query, and GET /healthz reports that the server is up. The
+// responses of the cacheSize last conversions are cached.
+func NewServer(cacheSize int) http.Handler {
+	cache := newConversionCache(cacheSize)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/convert", func(w http.ResponseWriter, r *http.Request) {
+		serveConvert(w, r, cache)
+	})
+	return mux
+}
+
+// Serve serves conversions on address until the server fails
+func Serve(address string, cacheSize int) error {
+	server := &http.Server{
+		Addr:         address,
+		Handler:      NewServer(cacheSize),
+		ReadTimeout:  time.Minute,
+		WriteTimeout: time.Minute,
+	}
+	log.Infof("Serving conversions on %s", address)
+	return server.ListenAndServe()
+}
+
+// serveConvert converts the compose file of the body and responds with the manifests, or with
+// the tarball of a Helm chart when the query sets chart
+func serveConvert(w http.ResponseWriter, r *http.Request, cache *conversionCache) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "POST a compose file to convert it", http.StatusMethodNotAllowed)
+		return
+	}
+	opt, err := serveOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, serveMaxBodySize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("unable to read the compose file: %s", err), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := refuseExternalReferences(data); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	key, err := conversionKey(data, opt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if conv, ok := cache.get(key); ok {
+		log.Debugf("Serving the cached conversion %s to %s", key, r.RemoteAddr)
+		w.Header().Set("X-Kompose-Cache", "hit")
+		writeConversion(w, conv)
+		return
+	}
+
+	// every conversion gets its own directory, the compose file is loaded from
+	dir, err := ioutil.TempDir("", "kompose-serve-")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "docker-compose.yaml")
+	if err := ioutil.WriteFile(file, data, 0644); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	opt.InputFiles = []string{file}
+
+	start := time.Now()
+	objects, err := Transform(opt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	conv := &conversion{key: key, contentType: "application/yaml"}
+	if opt.CreateChart {
+		opt.OutFile = filepath.Join(dir, opt.ProjectName+".tgz")
+		if err := kubernetes.PrintList(objects, opt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		conv.data, err = ioutil.ReadFile(opt.OutFile)
+		conv.contentType = "application/gzip"
+		conv.filename = filepath.Base(opt.OutFile)
+	} else {
+		conv.data, err = kubernetes.MarshalList(objects, opt)
+		if opt.GenerateJSON && !opt.KubectlCompatible {
+			conv.contentType = "application/json"
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Infof("Converted %d objects for %s in %s", len(objects), r.RemoteAddr, time.Since(start))
+	cache.add(conv)
+	w.Header().Set("X-Kompose-Cache", "miss")
+	writeConversion(w, conv)
+}
+
+// refuseExternalReferences returns an error listing the keys of the compose file of a client that
+// would read the files or variables of the server, see compose.ExternalReferences. The compose file
+// is alone in its directory, so no file it refers to would belong to the client.
+func refuseExternalReferences(data []byte) error {
+	references, err := compose.ExternalReferences(data)
+	if err != nil {
+		return errors.Wrap(err, "invalid compose file")
+	}
+	if len(references) > 0 {
+		return errors.Errorf("the compose file can't refer to the files or variables of the server: %s", strings.Join(references, ", "))
+	}
+	return nil
+}
+
+// writeConversion responds with a conversion
+func writeConversion(w http.ResponseWriter, conv *conversion) {
+	w.Header().Set("Content-Type", conv.contentType)
+	if conv.filename != "" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", conv.filename))
+	}
+	w.Write(conv.data)
+}
+
+// serveOptions returns the options of a conversion of kompose serve given by the query. Only the
+// options that neither build images nor read or write the files of the server are accepted.
+func serveOptions(query url.Values) (kobject.ConvertOptions, error) {
+	opt := kobject.ConvertOptions{
+		Provider:     strings.ToLower(query.Get("provider")),
+		Controller:   strings.ToLower(query.Get("controller")),
+		Volumes:      query.Get("volumes"),
+		NameStrategy: query.Get("name-strategy"),
+		ProjectName:  query.Get("project-name"),
+		Replicas:     1,
+		YAMLIndent:   2,
+		// the keys of the server mustn't decrypt the files of the clients
+		DisableSOPS: true,
+		// nor its environment variables be interpolated into them
+		IsolatedEnvironment: true,
+	}
+	if opt.Provider == "" {
+		opt.Provider = DefaultProvider
+	}
+	if opt.Provider != ProviderKubernetes && opt.Provider != ProviderOpenshift {
+		return opt, errors.Errorf("unknown provider %q, possible values are %s and %s", opt.Provider, ProviderKubernetes, ProviderOpenshift)
+	}
+	if opt.Controller != "" {
+		if provider, ok := controllerProviders[opt.Controller]; !ok {
+			return opt, errors.Errorf("unknown controller %q, the supported controllers are %s", opt.Controller, strings.Join(controllers(), ", "))
+		} else if provider != opt.Provider {
+			return opt, errors.Errorf("controller %s is not supported by the %s provider", opt.Controller, opt.Provider)
+		}
+	}
+	if opt.ProjectName == "" {
+		opt.ProjectName = serveDefaultProject
+	}
+	// the project names the file of the chart
+	if errs := validation.IsDNS1123Label(opt.ProjectName); len(errs) > 0 {
+		return opt, errors.Errorf("invalid project-name %q: %s", opt.ProjectName, strings.Join(errs, ", "))
+	}
+	// configMap volumes would package the files of the server
+	switch opt.Volumes {
+	case "", "persistentVolumeClaim", "emptyDir", "hostPath":
+	default:
+		return opt, errors.Errorf("unknown volumes %q, possible values are persistentVolumeClaim, emptyDir and hostPath", opt.Volumes)
+	}
+
+	if value := query.Get("replicas"); value != "" {
+		replicas, err := strconv.Atoi(value)
+		if err != nil || replicas < 0 {
+			return opt, errors.Errorf("invalid replicas %q", value)
+		}
+		opt.Replicas = replicas
+	}
+	flags := map[string]*bool{
+		"json":               &opt.GenerateJSON,
+		"kubectl-compatible": &opt.KubectlCompatible,
+		"chart":              &opt.CreateChart,
+		"smart-defaults":     &opt.SmartDefaults,
+		"legacy-labels":      &opt.LegacyLabels,
+	}
+	for name, flag := range flags {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return opt, errors.Errorf("invalid %s %q, expected true or false", name, value)
+		}
+		*flag = enabled
+	}
+	// format is the --format of kompose convert, json the older boolean
+	if format := strings.ToLower(query.Get("format")); format != "" {
+		if format != FormatYAML && format != FormatJSON {
+			return opt, errors.Errorf("unknown format %q, possible values are %s and %s", format, FormatYAML, FormatJSON)
+		}
+		if query.Get("json") != "" && opt.GenerateJSON != (format == FormatJSON) {
+			return opt, errors.New("json and format can't be set to different formats at the same time")
+		}
+		opt.Format = format
+		opt.GenerateJSON = format == FormatJSON
+	}
+	if opt.CreateChart && opt.Provider != ProviderKubernetes {
+		return opt, errors.New("chart is only supported by the kubernetes provider")
+	}
+	return opt, nil
+}
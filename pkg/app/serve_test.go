@@ -0,0 +1,234 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// post posts a compose file to the /convert endpoint of server with query
+func post(t *testing.T, server *httptest.Server, query, compose string) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.Post(server.URL+"/convert?"+query, "application/yaml", strings.NewReader(compose))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestServeConvert(t *testing.T) {
+	server := httptest.NewServer(NewServer(4))
+	defer server.Close()
+
+	compose := `version: "3"
+services:
+  web:
+    image: nginx
+    ports:
+      - 80:80
+`
+	resp, body := post(t, server, "controller=statefulset", compose)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, body)
+	}
+	if !strings.Contains(body, "kind: StatefulSet") || !strings.Contains(body, "kind: Service") {
+		t.Errorf("Expected a StatefulSet and a Service, got:\n%s", body)
+	}
+	if cache := resp.Header.Get("X-Kompose-Cache"); cache != "miss" {
+		t.Errorf("Expected a cache miss, got %q", cache)
+	}
+	resp, cached := post(t, server, "controller=statefulset", compose)
+	if cache := resp.Header.Get("X-Kompose-Cache"); cache != "hit" || cached != body {
+		t.Errorf("Expected the cached conversion, got %q", cache)
+	}
+	// the cache is keyed by the options, not by the query giving them
+	resp, cached = post(t, server, "provider=kubernetes&controller=statefulset&replicas=1", compose)
+	if cache := resp.Header.Get("X-Kompose-Cache"); cache != "hit" || cached != body {
+		t.Errorf("Expected the cached conversion for the same options, got %q", cache)
+	}
+	if resp, _ := post(t, server, "controller=statefulset&replicas=2", compose); resp.Header.Get("X-Kompose-Cache") != "miss" {
+		t.Errorf("Expected a cache miss for other options, got %q", resp.Header.Get("X-Kompose-Cache"))
+	}
+
+	if resp, body := post(t, server, "provider=docker", compose); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown provider, got %d: %s", resp.StatusCode, body)
+	}
+	// the keys of the server don't decrypt the compose files of the clients
+	encrypted := `version: ENC[AES256_GCM,data:Kw==,iv:aXY=,tag:dGFn,type:str]
+sops:
+  mac: ENC[AES256_GCM,data:bWFj,iv:aXY=,tag:dGFn,type:str]
+  version: 3.8.1
+`
+	if resp, body := post(t, server, "", encrypted); resp.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(body, "sops") {
+		t.Errorf("Expected 422 for a compose file encrypted with sops, got %d: %s", resp.StatusCode, body)
+	}
+	resp, err := http.Get(server.URL + "/convert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for a GET, got %d", resp.StatusCode)
+	}
+}
+
+func TestServeConvertRefusesServerFiles(t *testing.T) {
+	server := httptest.NewServer(NewServer(4))
+	defer server.Close()
+
+	testCases := map[string]struct {
+		compose   string
+		reference string
+	}{
+		"secret file": {`version: "3.5"
+services:
+  web:
+    image: nginx
+    secrets:
+      - s
+secrets:
+  s:
+    file: ../../etc/passwd
+`, "secrets.s.file"},
+		"absolute config file": {`version: "3.5"
+services:
+  web:
+    image: nginx
+configs:
+  c:
+    file: /etc/hostname
+`, "configs.c.file"},
+		"secret of the environment": {`version: "3.5"
+services:
+  web:
+    image: nginx
+secrets:
+  s:
+    environment: HOME
+`, "secrets.s.environment"},
+		"env_file": {`version: "3"
+services:
+  web:
+    image: nginx
+    env_file: ../../etc/hostname
+`, "services.web.env_file"},
+		"env_file of a later document": {`version: "3"
+services:
+  web:
+    image: nginx
+---
+services:
+  web:
+    env_file: /etc/hostname
+`, "services.web.env_file"},
+		"extends": {`version: "2"
+services:
+  web:
+    extends:
+      file: /etc/compose.yaml
+      service: web
+`, "services.web.extends.file"},
+		"include": {`version: "3"
+include:
+  - /etc/compose.yaml
+services:
+  web:
+    image: nginx
+`, "include"},
+		"TLS label": {`version: "3"
+services:
+  web:
+    image: nginx
+    labels:
+      - kompose.volume.tls=/certs
+    volumes:
+      - /etc/ssl:/certs
+`, "services.web.labels.kompose.volume.tls"},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			resp, body := post(t, server, "", test.compose)
+			if resp.StatusCode != http.StatusUnprocessableEntity {
+				t.Fatalf("Expected 422, got %d: %s", resp.StatusCode, body)
+			}
+			if !strings.Contains(body, test.reference) {
+				t.Errorf("Expected the error to name %s, got %s", test.reference, body)
+			}
+		})
+	}
+}
+
+func TestServeConvertIsolatesEnvironment(t *testing.T) {
+	os.Setenv("KOMPOSE_SERVE_SECRET", "hunter2")
+	defer os.Unsetenv("KOMPOSE_SERVE_SECRET")
+	server := httptest.NewServer(NewServer(4))
+	defer server.Close()
+
+	testCases := map[string]string{
+		"v1": `web:
+  image: ${KOMPOSE_SERVE_SECRET}
+  environment:
+    - KOMPOSE_SERVE_SECRET
+    - HOME=$HOME
+    - MODE=${MODE:-production}
+`,
+		"v2": `version: "2"
+services:
+  web:
+    image: ${KOMPOSE_SERVE_SECRET}
+    environment:
+      - KOMPOSE_SERVE_SECRET
+      - HOME=$HOME
+      - MODE=${MODE:-production}
+`,
+		"v3": `version: "3"
+services:
+  web:
+    image: ${KOMPOSE_SERVE_SECRET}
+    environment:
+      - KOMPOSE_SERVE_SECRET
+      - HOME=$HOME
+      - MODE=${MODE:-production}
+`,
+	}
+	for name, compose := range testCases {
+		t.Run(name, func(t *testing.T) {
+			resp, body := post(t, server, "", compose)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Expected 200, got %d: %s", resp.StatusCode, body)
+			}
+			for _, value := range []string{"hunter2", os.Getenv("HOME")} {
+				if value != "" && strings.Contains(body, value) {
+					t.Errorf("Expected the environment of the server to stay out of the conversion, got %q in:\n%s", value, body)
+				}
+			}
+			if !strings.Contains(body, "value: production") {
+				t.Errorf("Expected the default value of MODE, got:\n%s", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// The formats of the summary of a conversion
+const (
+	SummaryText = "text"
+	SummaryJSON = "json"
+	SummaryNone = "none"
+)
+
+// Summary holds the figures of a conversion, printed at its end so pipelines can record them. It
+// is computed locally and never sent anywhere.
+type Summary struct {
+	// Services is the number of services converted
+	Services int `json:"services"`
+	// Objects is the number of objects generated by kind
+	Objects map[string]int `json:"objects"`
+	// Warnings is the number of warnings reported by category
+	Warnings map[string]int `json:"warnings"`
+	// OutputBytes is the size of the generated manifests
+	OutputBytes int `json:"outputBytes"`
+}
+
+// warningCounter is implemented by the log formatter of the kompose commands, which counts the
+// warnings by their category field, like unsupported or volumes
+type warningCounter interface {
+	WarningCounts() map[string]int
+}
+
+// warningPrinter is implemented by the log formatter of the kompose commands, which holds back the
+// warnings to print them once grouped by message
+type warningPrinter interface {
+	PrintWarnings() error
+}
+
+// printWarnings prints the warnings the formatter of the standard logger holds back, so they come
+// before the outcome of the conversion
+func printWarnings() {
+	if printer, ok := log.StandardLogger().Formatter.(warningPrinter); ok {
+		if err := printer.PrintWarnings(); err != nil {
+			log.Fatalf("Unable to print warnings: %s", err)
+		}
+	}
+}
+
+// warningCounts returns the warnings logged so far by category, none if the formatter of the
+// standard logger doesn't count them
+func warningCounts() map[string]int {
+	if counter, ok := log.StandardLogger().Formatter.(warningCounter); ok {
+		return counter.WarningCounts()
+	}
+	return map[string]int{}
+}
+
+// newSummary computes the summary of the conversion of services to objects, whose manifests took
+// written bytes
+func newSummary(services int, objects []runtime.Object, warnings map[string]int, written int) Summary {
+	summary := Summary{
+		Services:    services,
+		Objects:     map[string]int{},
+		Warnings:    warnings,
+		OutputBytes: written,
+	}
+	for _, obj := range objects {
+		summary.Objects[obj.GetObjectKind().GroupVersionKind().Kind]++
+	}
+	return summary
+}
+
+// String formats the summary for humans
+func (s Summary) String() string {
+	return fmt.Sprintf("Converted %d service(s) into %d object(s)%s, %s of manifests, %d warning(s)%s",
+		s.Services, total(s.Objects), parenthesize(counts(s.Objects)), formatSize(s.OutputBytes), total(s.Warnings), parenthesize(counts(s.Warnings)))
+}
+
+// printSummary prints the summary in format: text is logged with the other messages, json is
+// written to stdout, which doesn't hold the manifests
+func printSummary(summary Summary, format string) error {
+	switch format {
+	case SummaryJSON:
+		return json.NewEncoder(os.Stdout).Encode(summary)
+	case SummaryNone:
+		return nil
+	}
+	log.Info(summary)
+	return nil
+}
+
+// total returns the sum of counts
+func total(counts map[string]int) int {
+	sum := 0
+	for _, count := range counts {
+		sum += count
+	}
+	return sum
+}
+
+// counts formats counts sorted by name, like "2 Deployment, 1 Service"
+func counts(counts map[string]int) string {
+	var names []string
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var formatted []string
+	for _, name := range names {
+		formatted = append(formatted, fmt.Sprintf("%d %s", counts[name], name))
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// parenthesize returns s in parentheses preceded by a space, or "" if s is empty
+func parenthesize(s string) string {
+	if s == "" {
+		return ""
+	}
+	return " (" + s + ")"
+}
+
+// formatSize formats a size in bytes for humans, like 12.3 KiB
+func formatSize(size int) string {
+	units := []string{"KiB", "MiB", "GiB"}
+	if size < 1024 {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size) / 1024
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kubernetes/kompose/pkg/transformer/kubernetes"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// isManifestFile returns true if name is a YAML or JSON file
+func isManifestFile(name string) bool {
+	switch filepath.Ext(name) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// isTarball returns true if name is a gzipped tarball, like a packaged Helm chart
+func isTarball(name string) bool {
+	return strings.HasSuffix(name, ".tgz") || strings.HasSuffix(name, ".tar.gz")
+}
+
+// readManifests returns the objects of the manifests of path: a YAML or JSON file, a gzipped
+// tarball like a packaged Helm chart, or a directory of them
+func readManifests(path string) ([]*unstructured.Unstructured, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		var objects []*unstructured.Unstructured
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || (!isManifestFile(file) && !isTarball(file)) {
+				return nil
+			}
+			fileObjects, err := readManifests(file)
+			if err != nil {
+				return err
+			}
+			objects = append(objects, fileObjects...)
+			return nil
+		})
+		return objects, err
+	}
+
+	if isTarball(path) {
+		return readTarballManifests(path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := kubernetes.ParseManifests(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid manifests in %s", path)
+	}
+	log.Debugf("Read %d objects from %s", len(objects), path)
+	return objects, nil
+}
+
+// readTarballManifests returns the objects of the YAML and JSON files of a gzipped tarball
+func readTarballManifests(path string) ([]*unstructured.Unstructured, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read %s", path)
+	}
+	defer gz.Close()
+
+	var objects []*unstructured.Unstructured
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read %s", path)
+		}
+		if header.Typeflag != tar.TypeReg || !isManifestFile(header.Name) {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read %s of %s", header.Name, path)
+		}
+		fileObjects, err := kubernetes.ParseManifests(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid manifests in %s of %s", header.Name, path)
+		}
+		objects = append(objects, fileObjects...)
+	}
+	log.Debugf("Read %d objects from %s", len(objects), path)
+	return objects, nil
+}
+
+// Verify checks that the manifests of paths, files, directories or chart tarballs generated by an
+// earlier conversion, are consistent, and exits with an error listing the problems found. It
+// reads no cluster, so it checks the manifests where they are applied from, e.g. in an air-gapped
+// environment, and catches the mistakes of hand edits.
+func Verify(paths []string) {
+	var objects []*unstructured.Unstructured
+	for _, path := range paths {
+		pathObjects, err := readManifests(path)
+		if err != nil {
+			log.Fatalf("Unable to read the manifests: %s", err)
+		}
+		objects = append(objects, pathObjects...)
+	}
+	if len(objects) == 0 {
+		log.Fatalf("No manifests found in %s", strings.Join(paths, ", "))
+	}
+
+	problems, err := kubernetes.VerifyManifests(objects)
+	if err != nil {
+		log.Fatalf("Unable to verify the manifests: %s", err)
+	}
+	if len(problems) > 0 {
+		log.Fatalf("Found %d problems in %d objects:\n  - %s", len(problems), len(objects), strings.Join(problems, "\n  - "))
+	}
+	fmt.Printf("%d objects verified\n", len(objects))
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/loader"
+	"github.com/kubernetes/kompose/pkg/transformer/kubernetes"
+	"github.com/pkg/errors"
+)
+
+// prompt asks questions and reads the answers line by line
+type prompt struct {
+	reader *bufio.Reader
+	out    io.Writer
+	eof    bool
+}
+
+// text asks a question, an empty answer returns the default value
+func (p *prompt) text(question, defaultValue string) (string, error) {
+	fmt.Fprintf(p.out, "%s [%s]: ", question, defaultValue)
+	if p.eof {
+		fmt.Fprintln(p.out)
+		return defaultValue, nil
+	}
+
+	line, err := p.reader.ReadString('\n')
+	if err == io.EOF {
+		p.eof = true
+		fmt.Fprintln(p.out)
+	} else if err != nil {
+		return "", err
+	}
+
+	answer := strings.TrimSpace(line)
+	if answer == "" {
+		return defaultValue, nil
+	}
+	return answer, nil
+}
+
+// choose asks a question until the answer is one of the choices
+func (p *prompt) choose(question string, choices []string, defaultValue string) (string, error) {
+	for {
+		answer, err := p.text(fmt.Sprintf("%s (%s)", question, strings.Join(choices, "|")), defaultValue)
+		if err != nil {
+			return "", err
+		}
+		for _, choice := range choices {
+			if strings.EqualFold(choice, answer) {
+				return choice, nil
+			}
+		}
+		if p.eof {
+			return "", fmt.Errorf("%q is not a valid choice", answer)
+		}
+		fmt.Fprintf(p.out, "%q is not a valid choice\n", answer)
+	}
+}
+
+// number asks a question until the answer is a positive number
+func (p *prompt) number(question string, defaultValue int) (int, error) {
+	for {
+		answer, err := p.text(question, strconv.Itoa(defaultValue))
+		if err != nil {
+			return 0, err
+		}
+		n, err := strconv.Atoi(answer)
+		if err == nil && n > 0 {
+			return n, nil
+		}
+		if p.eof {
+			return 0, fmt.Errorf("%q is not a positive number", answer)
+		}
+		fmt.Fprintf(p.out, "%q is not a positive number\n", answer)
+	}
+}
+
+// Init walks through the ambiguous conversion decisions and writes the answers into a kompose config file
+func Init(opt kobject.ConvertOptions, file string, in io.Reader, out io.Writer) error {
+	format, err := inputFormat(opt.InputFiles)
+	if err != nil {
+		return err
+	}
+	l, err := loader.GetLoader(format, opt)
+	if err != nil {
+		return err
+	}
+	komposeObject, err := l.LoadFile(opt.InputFiles)
+	if err != nil {
+		return err
+	}
+
+	p := &prompt{reader: bufio.NewReader(in), out: out}
+	config := &Config{Services: map[string]kobject.ServiceOverride{}}
+
+	if opt.Provider == ProviderKubernetes {
+		config.Controller, err = p.choose("Controller kind", []string{"deployment", "daemonSet", "replicationController", "statefulSet"}, "deployment")
+		if err != nil {
+			return errors.Wrap(err, "Unable to read controller kind")
+		}
+	}
+
+	config.Volumes, err = p.choose("Volume type", []string{"persistentVolumeClaim", "emptyDir", "hostPath", "configMap"}, "persistentVolumeClaim")
+	if err != nil {
+		return errors.Wrap(err, "Unable to read volume type")
+	}
+
+	// DaemonSets run one pod per node, so there is no replica count to choose
+	withReplicas := config.Controller != "daemonSet"
+	if withReplicas {
+		config.Replicas, err = p.number("Replicas", 1)
+		if err != nil {
+			return errors.Wrap(err, "Unable to read replicas")
+		}
+	}
+
+	for _, name := range kubernetes.SortedKeys(komposeObject) {
+		service := komposeObject.ServiceConfigs[name]
+		override := kobject.ServiceOverride{}
+
+		if len(service.Port) > 0 {
+			expose, err := p.text(fmt.Sprintf("Expose service %q outside the cluster (true, hostnames separated by comma or empty)", name), service.ExposeService)
+			if err != nil {
+				return errors.Wrapf(err, "Unable to read exposure of service %s", name)
+			}
+			if expose != service.ExposeService {
+				override.Expose = expose
+			}
+		}
+
+		if withReplicas {
+			replicas := service.Replicas
+			if replicas == 0 {
+				replicas = config.Replicas
+			}
+			n, err := p.number(fmt.Sprintf("Replicas for service %q", name), replicas)
+			if err != nil {
+				return errors.Wrapf(err, "Unable to read replicas of service %s", name)
+			}
+			if n != replicas {
+				override.Replicas = n
+			}
+		}
+
+		if override != (kobject.ServiceOverride{}) {
+			config.Services[name] = override
+		}
+	}
+
+	return WriteConfig(file, config)
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/testutils"
+	"github.com/spf13/cobra"
+)
+
+const wizardCompose = `version: "3"
+services:
+  web:
+    image: nginx
+    ports:
+      - "80:80"
+  worker:
+    image: busybox
+    deploy:
+      replicas: 2
+`
+
+// writeCompose writes wizardCompose into dir and returns its path
+func writeCompose(t *testing.T, dir string) string {
+	t.Helper()
+	file := filepath.Join(dir, "docker-compose.yaml")
+	if err := ioutil.WriteFile(file, []byte(wizardCompose), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestInit(t *testing.T) {
+	dir := testutils.CreateLocalDirectory(t)
+	defer os.RemoveAll(dir)
+	compose := writeCompose(t, dir)
+
+	testCases := map[string]struct {
+		provider string
+		answers  string
+		config   Config
+	}{
+		"Defaults": {ProviderKubernetes, "", Config{
+			Controller: "deployment",
+			Volumes:    "persistentVolumeClaim",
+			Replicas:   1,
+			Services:   map[string]kobject.ServiceOverride{},
+		}},
+		"Answers": {ProviderKubernetes, "statefulset\nemptyDir\n3\nweb.example.com\n\n4\n", Config{
+			Controller: "statefulSet",
+			Volumes:    "emptyDir",
+			Replicas:   3,
+			Services: map[string]kobject.ServiceOverride{
+				"web":    {Expose: "web.example.com"},
+				"worker": {Replicas: 4},
+			},
+		}},
+		"Invalid answers are asked again": {ProviderKubernetes, "pod\ndaemonSet\nnfs\nhostPath\n\n", Config{
+			Controller: "daemonSet",
+			Volumes:    "hostPath",
+			Services:   map[string]kobject.ServiceOverride{},
+		}},
+		"No controller for OpenShift": {ProviderOpenshift, "configMap\n0\n2\n", Config{
+			Volumes:  "configMap",
+			Replicas: 2,
+			Services: map[string]kobject.ServiceOverride{},
+		}},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(dir, ".kompose.yaml")
+			opt := kobject.ConvertOptions{InputFiles: []string{compose}, Provider: test.provider}
+			var out bytes.Buffer
+			if err := Init(opt, file, strings.NewReader(test.answers), &out); err != nil {
+				t.Fatal(err)
+			}
+			config, err := LoadConfig(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Services == nil {
+				config.Services = map[string]kobject.ServiceOverride{}
+			}
+			if !reflect.DeepEqual(*config, test.config) {
+				t.Errorf("Expected config %+v, got %+v\nprompts:\n%s", test.config, *config, out.String())
+			}
+		})
+	}
+}
+
+func TestInitRefusesInvalidLastAnswer(t *testing.T) {
+	dir := testutils.CreateLocalDirectory(t)
+	defer os.RemoveAll(dir)
+	compose := writeCompose(t, dir)
+
+	// the input ends with an invalid answer, which can't be asked again
+	opt := kobject.ConvertOptions{InputFiles: []string{compose}, Provider: ProviderKubernetes}
+	err := Init(opt, filepath.Join(dir, ".kompose.yaml"), strings.NewReader("deployment\nnfs"), ioutil.Discard)
+	if err == nil || !strings.Contains(err.Error(), `"nfs" is not a valid choice`) {
+		t.Errorf("Expected the invalid volume type to be refused, got %v", err)
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	dir := testutils.CreateLocalDirectory(t)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, ".kompose.yaml")
+	config := &Config{
+		Controller: "statefulSet",
+		Volumes:    "emptyDir",
+		Replicas:   3,
+		Services:   map[string]kobject.ServiceOverride{"web": {Expose: "true", Replicas: 2}, "gone": {Replicas: 5}},
+	}
+	if err := WriteConfig(file, config); err != nil {
+		t.Fatal(err)
+	}
+
+	newCommand := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().String("config", "", "")
+		cmd.Flags().String("volumes", "", "")
+		if err := cmd.Flags().Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return cmd
+	}
+
+	// the config file sets the options not given on the command line
+	opt := kobject.ConvertOptions{Volumes: "persistentVolumeClaim", Replicas: 1}
+	ApplyConfig(file, newCommand(), &opt)
+	if opt.Controller != "statefulset" || opt.Volumes != "emptyDir" || opt.Replicas != 3 {
+		t.Errorf("Expected the controller, volumes and replicas of the config file, got %q, %q and %d", opt.Controller, opt.Volumes, opt.Replicas)
+	}
+
+	// the command line wins over the config file
+	opt = kobject.ConvertOptions{Controller: "deployment", Volumes: "hostPath", Replicas: 4, IsReplicaSetFlag: true}
+	ApplyConfig(file, newCommand("--volumes", "hostPath"), &opt)
+	if opt.Controller != "deployment" || opt.Volumes != "hostPath" || opt.Replicas != 4 {
+		t.Errorf("Expected the options of the command line, got %q, %q and %d", opt.Controller, opt.Volumes, opt.Replicas)
+	}
+
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{"web": {Image: "nginx", Replicas: 1}},
+	}
+	applyServiceOverrides(&komposeObject, opt.ServiceOverrides)
+	if web := komposeObject.ServiceConfigs["web"]; web.ExposeService != "true" || web.Replicas != 2 {
+		t.Errorf("Expected the exposure and replicas of the config file on web, got %q and %d", web.ExposeService, web.Replicas)
+	}
+	if _, ok := komposeObject.ServiceConfigs["gone"]; ok {
+		t.Errorf("Expected the service missing from the compose file to be ignored")
+	}
+
+	// a missing default config file is ignored
+	opt = kobject.ConvertOptions{Replicas: 1}
+	ApplyConfig(filepath.Join(dir, "missing.yaml"), newCommand(), &opt)
+	if opt.Replicas != 1 || opt.ServiceOverrides != nil {
+		t.Errorf("Expected a missing config file to leave the options alone, got %+v", opt)
+	}
+}
@@ -19,6 +19,7 @@ package kobject
 import (
 	dockerCliTypes "github.com/docker/cli/cli/compose/types"
 	"github.com/docker/libcompose/yaml"
+	"github.com/kubernetes/kompose/pkg/utils/sops"
 	deployapi "github.com/openshift/api/apps/v1"
 	"github.com/pkg/errors"
 	"github.com/spf13/cast"
@@ -38,6 +39,17 @@ type KomposeObject struct {
 	LoadedFrom string
 
 	Secrets map[string]dockerCliTypes.SecretConfig
+
+	// Unused holds the top-level resources of the compose file that no service refers to
+	Unused UnusedResources
+}
+
+// UnusedResources holds the names of top-level volumes, networks, configs and secrets
+type UnusedResources struct {
+	Volumes  []string
+	Networks []string
+	Configs  []string
+	Secrets  []string
 }
 
 // ConvertOptions holds all options that controls transformation process
@@ -51,15 +63,24 @@ type ConvertOptions struct {
 	BuildBranch                 string
 	Build                       string
 	PushImage                   bool
+	PushRegistryUsername        string
+	PushRegistryPassword        string
+	PushRegistryToken           string
+	PushInsecureRegistry        bool
+	PushChart                   string
+	PushPlainHTTP               bool
 	CreateChart                 bool
 	GenerateYaml                bool
 	GenerateJSON                bool
+	Format                      string
 	StoreManifest               bool
 	EmptyVols                   bool
 	Volumes                     string
+	WindowsDrivePrefix          string
 	InsecureRepository          bool
 	Replicas                    int
 	InputFiles                  []string
+	ProjectName                 string
 	OutFile                     string
 	Provider                    string
 	Namespace                   string
@@ -76,6 +97,136 @@ type ConvertOptions struct {
 	YAMLIndent int
 
 	WithKomposeAnnotation bool
+
+	// GroupBy writes the objects of every service into one file when set to "service"
+	GroupBy string
+
+	// KubectlCompatible prints a multi-document YAML stream instead of a List, with the
+	// apiVersions served by the cluster of the Kubeconfig when it is reachable
+	KubectlCompatible bool
+	// Discover adapts the objects to the apiVersions served by the cluster of the Kubeconfig
+	Discover bool
+	// Kubeconfig is the kubeconfig file of the target cluster, see discovery.KubeconfigPath
+	Kubeconfig string
+	// Token is the bearer token authenticating to Server
+	Token string
+	// CertificateAuthority is the file of the certificate authority verifying Server
+	CertificateAuthority string
+
+	// ChecksumAnnotations annotates the pod templates with a checksum of the ConfigMaps and Secrets they use
+	ChecksumAnnotations bool
+	// PruneUnused leaves out the objects generated for top-level resources no service refers to
+	PruneUnused bool
+
+	// HostGatewayIP is the IP the hosts mapped to host-gateway in extra_hosts point to
+	HostGatewayIP string
+
+	// NameStrategy maps the compose service names to object names, see transformer.ObjectName
+	NameStrategy string
+
+	// NamespacePerNetwork places every service into a namespace named after its network
+	NamespacePerNetwork bool
+
+	// DependsOnReadiness generates readiness probes checking the services listed in depends_on
+	DependsOnReadiness bool
+
+	// NamePorts names every container port and references it by name from the Services
+	NamePorts bool
+
+	// EnvExclude drops the environment variables matching one of the glob patterns from the objects
+	EnvExclude []string
+	// EnvMask replaces the values of the environment variables matching one of the glob patterns by placeholders
+	EnvMask []string
+
+	// HostSockets mounts the bind mounted sockets of the host, like /var/run/docker.sock, from the node
+	HostSockets bool
+
+	// PinCPUs gives the services setting a cpuset as many whole CPUs as the cpuset has, with equal
+	// requests and limits, so the static CPU manager policy of the kubelet gives them exclusive CPUs
+	PinCPUs bool
+
+	// SCCBindings grants the ServiceAccount of the services whose pods need a SecurityContextConstraints
+	// other than restricted the use of it, see openshift.ConfigSCC
+	SCCBindings bool
+
+	// PreserveSelectors is a file or directory of manifests generated earlier whose controller selectors are kept
+	PreserveSelectors string
+	// LegacyLabels labels and selects the objects with io.kompose.service instead of the recommended labels
+	LegacyLabels bool
+	// PartOf labels every object as part of this application and managed by kompose, see transformer.AddAppLabels
+	PartOf string
+
+	// LocalCluster adapts the objects to a local cluster like kind or Docker Desktop, see kubernetes.ConfigLocalService
+	LocalCluster bool
+	// LocalMountRoot is the path the nodes of the local cluster mount the directory of the compose file at
+	LocalMountRoot string
+
+	// AutoIngress is the domain the services publishing a web port are exposed at, as <service>.<domain>
+	AutoIngress string
+
+	// Progress logs every service being converted, for compose files with many services
+	Progress bool
+
+	// ZeroTrust isolates the pods with NetworkPolicies and gives every service a cert-manager certificate, see kubernetes.CreateZeroTrustPolicies
+	ZeroTrust bool
+
+	// SmartDefaults applies the ports, volumes and probes of well-known images, see kubernetes.ApplyImageDefaults
+	SmartDefaults bool
+
+	// Mesh is the service mesh ("istio" or "linkerd") the pods are injected into
+	Mesh string
+
+	// Diff prints the differences between the objects converted from two compose files
+	Diff bool
+
+	// Mapping is the file the mapping of the services to the objects generated for them is written to
+	Mapping string
+	// ServiceNames maps the names the objects of the services are named after to the names of the
+	// services in the compose file, for the Mapping
+	ServiceNames map[string]string
+
+	// ExtraResources is the directory of the templates of the objects added for every service, see kubernetes.CreateExtraResources
+	ExtraResources string
+
+	// SingletonPDB creates a PodDisruptionBudget for the services running a single pod that mounts PersistentVolumeClaims, see kubernetes.CreateSingletonPDB
+	SingletonPDB bool
+
+	// Policy is the directory of the Rego policies the objects are checked against, see kubernetes.EvaluatePolicies
+	Policy string
+
+	// RemoveStaleFiles removes the files of the output directory that the conversion no longer generates
+	RemoveStaleFiles bool
+
+	// SummaryFormat is the format of the summary printed at the end of the conversion: text, json or none
+	SummaryFormat string
+
+	// SOPSAgeKeyFile is the file of the age keys the files encrypted with sops are decrypted with
+	SOPSAgeKeyFile string
+
+	// DisableSOPS refuses the files encrypted with sops, for the conversions of the files of other users
+	DisableSOPS bool
+
+	// IsolatedEnvironment interpolates the compose files without the environment variables of the
+	// process, for the conversions of the files of other users, which mustn't read them
+	IsolatedEnvironment bool
+
+	// HeaderFile is a file whose lines are prepended as comments to every generated YAML file, see kubernetes.LoadHeader
+	HeaderFile string
+
+	// SplitServicePorts creates a Service for every port of the services, see kubernetes.CreateSplitServices
+	SplitServicePorts bool
+
+	// FSGroupFromUser sets the fsGroup of the pods mounting PersistentVolumeClaims to the gid of the user of their service
+	FSGroupFromUser bool
+
+	// ServiceOverrides holds per-service settings read from the kompose config file
+	ServiceOverrides map[string]ServiceOverride
+}
+
+// ServiceOverride holds the settings of a service chosen with `kompose init`
+type ServiceOverride struct {
+	Expose   string `yaml:"expose,omitempty"`
+	Replicas int    `yaml:"replicas,omitempty"`
 }
 
 // IsPodController indicate if the user want to use a controller
@@ -83,8 +234,15 @@ func (opt *ConvertOptions) IsPodController() bool {
 	return opt.IsDeploymentFlag || opt.IsDaemonSetFlag || opt.IsReplicationControllerFlag || opt.Controller != ""
 }
 
+// SOPS returns the options of the decryption of the files encrypted with sops
+func (opt *ConvertOptions) SOPS() sops.Options {
+	return sops.Options{AgeKeyFile: opt.SOPSAgeKeyFile, Disabled: opt.DisableSOPS}
+}
+
 // ServiceConfig holds the basic struct of a container
 type ServiceConfig struct {
+	// ComposeName is the name of the service in the compose file, before it is normalized or renamed
+	ComposeName       string
 	ContainerName     string
 	Image             string              `compose:"image"`
 	Environment       []EnvVar            `compose:"environment"`
@@ -97,6 +255,7 @@ type ServiceConfig struct {
 	Args              []string            `compose:"args"`
 	VolList           []string            `compose:"volumes"`
 	Network           []string            `compose:"network"`
+	NetworkAliases    []string            `compose:"aliases"`
 	Labels            map[string]string   `compose:"labels"`
 	Annotations       map[string]string   `compose:""`
 	CPUSet            string              `compose:"cpuset"`
@@ -110,11 +269,17 @@ type ServiceConfig struct {
 	ImagePullPolicy   string              `compose:"kompose.image-pull-policy"`
 	Pid               string              `compose:"pid"`
 	Privileged        bool                `compose:"privileged"`
+	Init              bool                `compose:"init"`
 	Restart           string              `compose:"restart"`
 	User              string              `compose:"user"`
 	VolumesFrom       []string            `compose:"volumes_from"`
+	DependsOn         []string            `compose:"depends_on"`
+	ExtraHosts        []string            `compose:"extra_hosts"`
+	ExternalLinks     []string            `compose:"external_links"`
 	ServiceType       string              `compose:"kompose.service.type"`
 	NodePortPort      int32               `compose:"kompose.service.nodeport.port"`
+	TrafficPolicy     string              `compose:"kompose.service.internal-traffic-policy"`
+	TopologyHints     string              `compose:"kompose.service.topology-aware-hints"`
 	StopGracePeriod   string              `compose:"stop_grace_period"`
 	Build             string              `compose:"build"`
 	BuildArgs         map[string]*string  `compose:"build-args"`
@@ -131,6 +296,8 @@ type ServiceConfig struct {
 	// DeployLabels mapping to kubernetes labels
 	DeployLabels       map[string]string           `compose:""`
 	DeployUpdateConfig dockerCliTypes.UpdateConfig `compose:""`
+	ServiceAnnotations map[string]string           `compose:""`
+	PodAnnotations     map[string]string           `compose:""`
 	TmpFs              []string                    `compose:"tmpfs"`
 	Dockerfile         string                      `compose:"dockerfile"`
 	Replicas           int                         `compose:"replicas"`
@@ -144,6 +311,39 @@ type ServiceConfig struct {
 	//This is for SHORT SYNTAX link(https://docs.docker.com/compose/compose-file/#configs)
 	ConfigsMetaData map[string]dockerCliTypes.ConfigObjConfig `compose:""`
 
+	// DeployRestartPolicy holds the max_attempts and window of deploy.restart_policy, its condition is Restart
+	DeployRestartPolicy dockerCliTypes.RestartPolicy `compose:""`
+
+	// TerminationMessagePolicy and TerminationMessagePath configure the termination message of the container
+	TerminationMessagePolicy string `compose:"kompose.termination-message-policy"`
+	TerminationMessagePath   string `compose:"kompose.termination-message-path"`
+
+	// JobTTLSecondsAfterFinished is the time after which the finished Job of the service is deleted
+	JobTTLSecondsAfterFinished *int32 `compose:"kompose.job.ttl-seconds-after-finished"`
+
+	// FSGroup is the group owning the volumes of the pods, see --fs-group-from-user
+	FSGroup *int64 `compose:"kompose.fsgroup"`
+
+	// SplitPorts creates a Service for every port of the service, see --split-service-ports
+	SplitPorts bool `compose:"kompose.service.split-ports"`
+
+	// HPA is the HorizontalPodAutoscaler of the service, given by the kompose.hpa labels
+	HPA HPA `compose:""`
+
+	// RuntimeClass is the RuntimeClass the pods of the service run with, like gvisor or kata
+	RuntimeClass string `compose:"kompose.pod.runtime-class"`
+
+	// AutomountServiceAccountToken mounts the token of the API server into the pods, the default of
+	// the service account when nil
+	AutomountServiceAccountToken *bool `compose:"kompose.serviceaccount.automount"`
+	// ServiceAccountToken is the token projected into the pods, given by the kompose.serviceaccount.token labels
+	ServiceAccountToken ServiceAccountToken `compose:""`
+
+	// HugePages is the quantity of huge pages of the pods by page size, 2Mi or 1Gi, given by the kompose.hugepages labels
+	HugePages map[string]string `compose:""`
+	// HugePagesPath is the directory the huge pages are mounted at
+	HugePagesPath string `compose:"kompose.hugepages.path"`
+
 	WithKomposeAnnotation bool `compose:""`
 }
 
@@ -159,6 +359,27 @@ type HealthCheck struct {
 	Disable     bool
 }
 
+// HPA holds the HorizontalPodAutoscaler settings of a service, it has an HPA when MaxReplicas is set
+type HPA struct {
+	// MinReplicas defaults to the replicas of the service
+	MinReplicas int32
+	MaxReplicas int32
+	// CPU and Memory are the target average utilization of the requests of the pods, in percent
+	CPU    int32
+	Memory int32
+}
+
+// ServiceAccountToken holds the settings of the service account token projected into the pods of
+// a service, it is projected when any is set
+type ServiceAccountToken struct {
+	// Audience is the audience of the token, the API server when empty
+	Audience string
+	// ExpirationSeconds defaults to an hour
+	ExpirationSeconds int64
+	// Path is the directory of the token file
+	Path string
+}
+
 // EnvVar holds the environment variable struct of a container
 type EnvVar struct {
 	Name  string
@@ -171,6 +392,7 @@ type Ports struct {
 	ContainerPort int32
 	HostIP        string
 	Protocol      corev1.Protocol
+	Name          string
 }
 
 // Volumes holds the volume struct of container
@@ -239,6 +461,37 @@ func (s *ServiceConfig) GetKubernetesUpdateStrategy() *v1.RollingUpdateDeploymen
 
 }
 
+// GetJobBackoffLimit returns the number of retries of a Job from the max_attempts of
+// deploy.restart_policy, or nil if it is not set or 0, which swarm takes for unlimited attempts
+func (s *ServiceConfig) GetJobBackoffLimit() *int32 {
+	if s.DeployRestartPolicy.MaxAttempts == nil || *s.DeployRestartPolicy.MaxAttempts == 0 {
+		return nil
+	}
+	limit := cast.ToInt32(*s.DeployRestartPolicy.MaxAttempts)
+	return &limit
+}
+
+// defaultProgressDeadlineSeconds is the progress deadline Kubernetes gives the Deployments
+// setting none
+const defaultProgressDeadlineSeconds = 600
+
+// GetProgressDeadlineSeconds returns the progress deadline of a Deployment from the window of
+// deploy.restart_policy, rounded up to the second, or nil if it is not set. The window is how long
+// swarm waits for a container to be running, while the deadline covers the rollout of all the
+// pods, so a window shorter than the default deadline of Kubernetes keeps the default, which
+// normal rollouts would otherwise exceed.
+func (s *ServiceConfig) GetProgressDeadlineSeconds() *int32 {
+	if s.DeployRestartPolicy.Window == nil || *s.DeployRestartPolicy.Window <= 0 {
+		return nil
+	}
+	window := time.Duration(*s.DeployRestartPolicy.Window)
+	seconds := int32((window + time.Second - 1) / time.Second)
+	if seconds <= defaultProgressDeadlineSeconds {
+		return nil
+	}
+	return &seconds
+}
+
 // GetOSUpdateStrategy ...
 func (s *ServiceConfig) GetOSUpdateStrategy() *deployapi.RollingDeploymentStrategyParams {
 	config := s.DeployUpdateConfig
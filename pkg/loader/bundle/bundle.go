@@ -193,7 +193,7 @@ func (b *Bundle) LoadFile(files []string) (kobject.KomposeObject, error) {
 
 	noSupKeys := checkUnsupportedKey(bundle)
 	for _, keyName := range noSupKeys {
-		log.Warningf("Unsupported %s key - ignoring", keyName)
+		log.WithField("category", "unsupported").Warningf("Unsupported %s key - ignoring", keyName)
 	}
 
 	for name, service := range bundle.Services {
@@ -18,14 +18,17 @@ package compose
 
 import (
 	"fmt"
+	"os"
 	"reflect"
 	"strings"
+	"sync"
 
 	"gopkg.in/yaml.v2"
 
 	"github.com/docker/libcompose/project"
 	"github.com/fatih/structs"
 	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/utils/sops"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
@@ -33,8 +36,25 @@ import (
 //StdinData is data bytes read from stdin
 var StdinData []byte
 
+// stdinLock guards StdinData, which the conversions running concurrently read once
+var stdinLock sync.Mutex
+
 // Compose is docker compose file loader, implements Loader interface
 type Compose struct {
+	// SOPS are the options of the decryption of the compose files and env_files encrypted with sops
+	SOPS sops.Options
+	// IsolatedEnvironment interpolates the compose files with an empty environment instead of the
+	// one of the process, which doesn't give the environment variables of the services without a value either
+	IsolatedEnvironment bool
+}
+
+// lookupEnv returns the value of an environment variable of the process, none if the environment
+// is isolated
+func (c *Compose) lookupEnv(name string) (string, bool) {
+	if c.IsolatedEnvironment {
+		return "", false
+	}
+	return os.LookupEnv(name)
 }
 
 // checkUnsupportedKey checks if libcompose project contains
@@ -48,30 +68,27 @@ func checkUnsupportedKey(composeProject *project.Project) []string {
 	// to make sure that unsupported key is not going to be reported twice
 	// by keeping record if already saw this key in another service
 	var unsupportedKey = map[string]bool{
-		"CgroupParent":  false,
-		"CPUSet":        false,
-		"CPUShares":     false,
-		"Devices":       false,
-		"DependsOn":     false,
-		"DNS":           false,
-		"DNSSearch":     false,
-		"EnvFile":       false,
-		"ExternalLinks": false,
-		"ExtraHosts":    false,
-		"Ipc":           false,
-		"Logging":       false,
-		"MacAddress":    false,
-		"MemSwapLimit":  false,
-		"NetworkMode":   false,
-		"SecurityOpt":   false,
-		"ShmSize":       false,
-		"StopSignal":    false,
-		"VolumeDriver":  false,
-		"Uts":           false,
-		"ReadOnly":      false,
-		"Ulimits":       false,
-		"Net":           false,
-		"Sysctls":       false,
+		"CgroupParent": false,
+		"CPUShares":    false,
+		"Devices":      false,
+		"DependsOn":    false,
+		"DNS":          false,
+		"DNSSearch":    false,
+		"EnvFile":      false,
+		"ExtraHosts":   false,
+		"Ipc":          false,
+		"Logging":      false,
+		"MacAddress":   false,
+		"NetworkMode":  false,
+		"SecurityOpt":  false,
+		"ShmSize":      false,
+		"StopSignal":   false,
+		"VolumeDriver": false,
+		"Uts":          false,
+		"ReadOnly":     false,
+		"Ulimits":      false,
+		"Net":          false,
+		"Sysctls":      false,
 		//"Networks":    false, // We shall be spporting network now. There are special checks for Network in checkUnsupportedKey function
 		"Links": false,
 	}
@@ -151,7 +168,7 @@ func (c *Compose) LoadFile(files []string) (kobject.KomposeObject, error) {
 	var version string
 
 	for _, file := range files {
-		composeVersion, err := getVersionFromFile(file)
+		composeVersion, err := c.getVersionFromFile(file)
 		if err != nil {
 			return kobject.KomposeObject{}, errors.Wrap(err, "Unable to load yaml/json file for version parsing")
 		}
@@ -170,14 +187,14 @@ func (c *Compose) LoadFile(files []string) (kobject.KomposeObject, error) {
 	// Use libcompose for 1 or 2
 	// If blank, it's assumed it's 1 or 2
 	case "", "1", "1.0", "2", "2.0", "2.1", "2.2":
-		komposeObject, err := parseV1V2(files)
+		komposeObject, err := c.parseV1V2(files)
 		if err != nil {
 			return kobject.KomposeObject{}, err
 		}
 		return komposeObject, nil
 		// Use docker/cli for 3
 	case "3", "3.0", "3.1", "3.2", "3.3", "3.4", "3.5", "3.6", "3.7", "3.8":
-		komposeObject, err := parseV3(files)
+		komposeObject, err := c.parseV3(files)
 		if err != nil {
 			return kobject.KomposeObject{}, err
 		}
@@ -188,21 +205,35 @@ func (c *Compose) LoadFile(files []string) (kobject.KomposeObject, error) {
 
 }
 
-func getVersionFromFile(file string) (string, error) {
+func (c *Compose) getVersionFromFile(file string) (string, error) {
 	type ComposeVersion struct {
 		Version string `json:"version"` // This affects YAML as well
 	}
-	var version ComposeVersion
-	loadedFile, err := ReadFile(file)
+	loadedFile, err := c.readFile(file)
 
 	if err != nil {
 		return "", err
 	}
 
-	err = yaml.Unmarshal(loadedFile, &version)
+	documents, err := splitDocuments(loadedFile)
 	if err != nil {
 		return "", err
 	}
+	// the documents without version take the version of the previous ones
+	var fileVersion string
+	for _, document := range documents {
+		var version ComposeVersion
+		if err := yaml.Unmarshal(document, &version); err != nil {
+			return "", err
+		}
+		if version.Version == "" {
+			continue
+		}
+		if fileVersion != "" && fileVersion != version.Version {
+			return "", errors.Errorf("the documents of %s must be of the same version, found %s and %s", file, fileVersion, version.Version)
+		}
+		fileVersion = version.Version
+	}
 
-	return version.Version, nil
+	return fileVersion, nil
 }
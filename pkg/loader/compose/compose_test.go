@@ -17,9 +17,15 @@ limitations under the License.
 package compose
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -30,6 +36,7 @@ import (
 	"github.com/docker/libcompose/yaml"
 	"github.com/google/go-cmp/cmp"
 	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/utils/sops"
 	"github.com/pkg/errors"
 	api "k8s.io/api/core/v1"
 )
@@ -38,11 +45,6 @@ func durationPtr(value time.Duration) *time.Duration {
 	return &value
 }
 
-func durationTypesPtr(value time.Duration) *types.Duration {
-	target := types.Duration(value)
-	return &target
-}
-
 func TestParseHealthCheck(t *testing.T) {
 	helperValue := uint64(2)
 	check := types.HealthCheckConfig{
@@ -71,6 +73,78 @@ func TestParseHealthCheck(t *testing.T) {
 	}
 }
 
+func TestParseDisabledHealthCheck(t *testing.T) {
+	for _, check := range []types.HealthCheckConfig{
+		{Test: []string{"CMD", "true"}, Disable: true},
+		{Test: []string{"NONE"}},
+	} {
+		output, err := parseHealthCheck(check)
+		if err != nil {
+			t.Errorf("Unable to convert HealthCheckConfig: %s", err)
+		}
+		if !reflect.DeepEqual(output, kobject.HealthCheck{Disable: true}) {
+			t.Errorf("Expected %v to disable the healthcheck, got %v", check, output)
+		}
+	}
+}
+
+func TestLoadV2InheritedHealthCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kompose")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"base.yml": `version: "2.1"
+services:
+  base:
+    image: nginx
+    healthcheck:
+      test: ["CMD", "curl", "-f", "http://localhost"]
+      interval: 30s
+      retries: 3
+`,
+		"docker-compose.yml": `version: "2.1"
+services:
+  web:
+    extends:
+      file: base.yml
+      service: base
+    healthcheck:
+      interval: 5s
+  worker:
+    extends:
+      service: web
+    healthcheck:
+      disable: true
+`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := Compose{}
+	komposeObject, err := c.LoadFile([]string{filepath.Join(dir, "docker-compose.yml")})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	expected := kobject.HealthCheck{
+		Test:     []string{"curl", "-f", "http://localhost"},
+		Interval: 5,
+		Retries:  3,
+	}
+	if web := komposeObject.ServiceConfigs["web"]; !reflect.DeepEqual(web.HealthChecks, expected) {
+		t.Errorf("Expected healthcheck %v, got %v", expected, web.HealthChecks)
+	}
+	if worker := komposeObject.ServiceConfigs["worker"]; !worker.HealthChecks.Disable {
+		t.Errorf("Expected the healthcheck of worker to be disabled, got %v", worker.HealthChecks)
+	}
+}
+
 func TestLoadV3Volumes(t *testing.T) {
 	vol := types.ServiceVolumeConfig{
 		Type:     "volume",
@@ -304,7 +378,7 @@ func TestLoadEnvVar(t *testing.T) {
 	os.Setenv("osfoo", "osbar")
 
 	for _, tt := range tests {
-		result := loadEnvVars(tt.envvars)
+		result := loadEnvVars(tt.envvars, os.LookupEnv)
 		if result[0] != tt.results {
 			t.Errorf("Expected %q, got %q", tt.results, result[0])
 		}
@@ -486,3 +560,995 @@ func TestCheckPlacementCustomLabels(t *testing.T) {
 	}
 
 }
+
+func TestLoadFileWithWindowsPathsAndLineEndings(t *testing.T) {
+	for _, version := range []string{"2", "3"} {
+		content := "version: \"" + version + "\"\r\nservices:\r\n  web:\r\n    image: nginx\r\n    volumes:\r\n      - 'C:\\data:/data:ro'\r\n"
+
+		f, err := ioutil.TempFile("", "docker-compose-*.yml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.WriteString(content); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		c := Compose{}
+		komposeObject, err := c.LoadFile([]string{f.Name()})
+		if err != nil {
+			t.Fatalf("Version %s: unexpected error %v", version, err)
+		}
+
+		volumes := komposeObject.ServiceConfigs["web"].Volumes
+		if len(volumes) != 1 {
+			t.Fatalf("Version %s: expected 1 volume, got %d", version, len(volumes))
+		}
+		if volumes[0].Host != `C:\data` || volumes[0].Container != "/data" || volumes[0].Mode != "ro" {
+			t.Errorf("Version %s: expected C:\\data mounted read only at /data, got %+v", version, volumes[0])
+		}
+	}
+}
+
+func TestParseKomposeAnnotationLabels(t *testing.T) {
+	labels := map[string]string{
+		"com.example.team":                       "web",
+		"kompose.service.annotation.lb/internal": "true",
+		"kompose.pod.annotation.sidecar/inject":  "false",
+	}
+
+	serviceConfig := kobject.ServiceConfig{}
+	if err := parseKomposeLabels(labels, &serviceConfig); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	serviceConfig.Annotations = loadAnnotations(labels)
+
+	if !reflect.DeepEqual(serviceConfig.ServiceAnnotations, map[string]string{"lb/internal": "true"}) {
+		t.Errorf("Unexpected service annotations %v", serviceConfig.ServiceAnnotations)
+	}
+	if !reflect.DeepEqual(serviceConfig.PodAnnotations, map[string]string{"sidecar/inject": "false"}) {
+		t.Errorf("Unexpected pod annotations %v", serviceConfig.PodAnnotations)
+	}
+	if !reflect.DeepEqual(serviceConfig.Annotations, map[string]string{"com.example.team": "web"}) {
+		t.Errorf("Unexpected annotations %v", serviceConfig.Annotations)
+	}
+}
+
+func TestHandlePortName(t *testing.T) {
+	serviceConfig := kobject.ServiceConfig{
+		Port: []kobject.Ports{{HostPort: 80, ContainerPort: 8080, Protocol: api.ProtocolTCP}},
+	}
+
+	if err := parseKomposeLabels(map[string]string{"kompose.port.name.8080": "http"}, &serviceConfig); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if serviceConfig.Port[0].Name != "http" {
+		t.Errorf("Expected port 8080 to be named http, got %q", serviceConfig.Port[0].Name)
+	}
+
+	if err := parseKomposeLabels(map[string]string{"kompose.port.name.9090": "http"}, &serviceConfig); err == nil {
+		t.Errorf("Expected an error for a port that is not exposed")
+	}
+	if err := parseKomposeLabels(map[string]string{"kompose.port.name.8080": "Not_Valid"}, &serviceConfig); err == nil {
+		t.Errorf("Expected an error for an invalid port name")
+	}
+}
+
+func TestParseServiceTopologyLabels(t *testing.T) {
+	serviceConfig := kobject.ServiceConfig{}
+	labels := map[string]string{
+		"kompose.service.internal-traffic-policy": "local",
+		"kompose.service.topology-aware-hints":    "Auto",
+	}
+	if err := parseKomposeLabels(labels, &serviceConfig); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if serviceConfig.TrafficPolicy != "Local" || serviceConfig.TopologyHints != "auto" {
+		t.Errorf("Expected the Local policy and auto hints, got %q and %q", serviceConfig.TrafficPolicy, serviceConfig.TopologyHints)
+	}
+
+	if err := parseKomposeLabels(map[string]string{"kompose.service.internal-traffic-policy": "zone"}, &serviceConfig); err == nil {
+		t.Errorf("Expected an error for an invalid internal traffic policy")
+	}
+}
+
+func TestParseTerminationMessageLabels(t *testing.T) {
+	serviceConfig := kobject.ServiceConfig{}
+	labels := map[string]string{
+		"kompose.termination-message-policy": "fallback-to-logs-on-error",
+		"kompose.termination-message-path":   "/var/run/termination",
+	}
+	if err := parseKomposeLabels(labels, &serviceConfig); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if serviceConfig.TerminationMessagePolicy != "FallbackToLogsOnError" || serviceConfig.TerminationMessagePath != "/var/run/termination" {
+		t.Errorf("Expected the FallbackToLogsOnError policy and path /var/run/termination, got %q and %q", serviceConfig.TerminationMessagePolicy, serviceConfig.TerminationMessagePath)
+	}
+
+	if err := parseKomposeLabels(map[string]string{"kompose.termination-message-policy": "stderr"}, &serviceConfig); err == nil {
+		t.Errorf("Expected an error for an invalid termination message policy")
+	}
+	if err := parseKomposeLabels(map[string]string{"kompose.termination-message-path": "termination"}, &serviceConfig); err == nil {
+		t.Errorf("Expected an error for a relative termination message path")
+	}
+}
+
+func TestParseJobTTLLabel(t *testing.T) {
+	serviceConfig := kobject.ServiceConfig{}
+	if err := parseKomposeLabels(map[string]string{"kompose.job.ttl-seconds-after-finished": "3600"}, &serviceConfig); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if ttl := serviceConfig.JobTTLSecondsAfterFinished; ttl == nil || *ttl != 3600 {
+		t.Errorf("Expected a TTL of 3600 seconds, got %v", ttl)
+	}
+	if err := parseKomposeLabels(map[string]string{"kompose.job.ttl-seconds-after-finished": "1h"}, &serviceConfig); err == nil {
+		t.Errorf("Expected an error for a TTL that is not a number of seconds")
+	}
+}
+
+func TestParseRuntimeClassLabel(t *testing.T) {
+	serviceConfig := kobject.ServiceConfig{}
+	if err := parseKomposeLabels(map[string]string{"kompose.pod.runtime-class": "gvisor"}, &serviceConfig); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if serviceConfig.RuntimeClass != "gvisor" {
+		t.Errorf("Expected the runtime class gvisor, got %q", serviceConfig.RuntimeClass)
+	}
+	if err := parseKomposeLabels(map[string]string{"kompose.pod.runtime-class": "gVisor"}, &serviceConfig); err == nil {
+		t.Errorf("Expected an error for a runtime class that isn't a valid name")
+	}
+}
+
+func TestParseServiceAccountLabels(t *testing.T) {
+	serviceConfig := kobject.ServiceConfig{}
+	labels := map[string]string{
+		"kompose.serviceaccount.automount":        "false",
+		"kompose.serviceaccount.token.audience":   "sts.amazonaws.com",
+		"kompose.serviceaccount.token.expiration": "86400",
+		"kompose.serviceaccount.token.path":       "/var/run/secrets/eks.amazonaws.com/serviceaccount",
+	}
+	if err := parseKomposeLabels(labels, &serviceConfig); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if automount := serviceConfig.AutomountServiceAccountToken; automount == nil || *automount {
+		t.Errorf("Expected the token of the API server not to be mounted, got %v", automount)
+	}
+	expected := kobject.ServiceAccountToken{Audience: "sts.amazonaws.com", ExpirationSeconds: 86400, Path: "/var/run/secrets/eks.amazonaws.com/serviceaccount"}
+	if serviceConfig.ServiceAccountToken != expected {
+		t.Errorf("Expected the service account token %+v, got %+v", expected, serviceConfig.ServiceAccountToken)
+	}
+
+	for key, value := range map[string]string{
+		"kompose.serviceaccount.automount":        "never",
+		"kompose.serviceaccount.token.expiration": "60",
+		"kompose.serviceaccount.token.path":       "tokens",
+	} {
+		if err := parseKomposeLabels(map[string]string{key: value}, &kobject.ServiceConfig{}); err == nil {
+			t.Errorf("Expected an error for %s: %s", key, value)
+		}
+	}
+}
+
+func TestParseHugePagesLabels(t *testing.T) {
+	serviceConfig := kobject.ServiceConfig{}
+	labels := map[string]string{
+		"kompose.hugepages.2Mi":  "512Mi",
+		"kompose.hugepages.1Gi":  "2Gi",
+		"kompose.hugepages.path": "/mnt/hugepages",
+	}
+	if err := parseKomposeLabels(labels, &serviceConfig); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	expected := map[string]string{"2Mi": "512Mi", "1Gi": "2Gi"}
+	if !reflect.DeepEqual(serviceConfig.HugePages, expected) {
+		t.Errorf("Expected the huge pages %v, got %v", expected, serviceConfig.HugePages)
+	}
+	if serviceConfig.HugePagesPath != "/mnt/hugepages" {
+		t.Errorf("Expected the huge pages path /mnt/hugepages, got %s", serviceConfig.HugePagesPath)
+	}
+
+	for key, value := range map[string]string{
+		"kompose.hugepages.2Mi":  "3M",
+		"kompose.hugepages.1Gi":  "512Mi",
+		"kompose.hugepages.path": "hugepages",
+	} {
+		if err := parseKomposeLabels(map[string]string{key: value}, &kobject.ServiceConfig{}); err == nil {
+			t.Errorf("Expected an error for %s: %s", key, value)
+		}
+	}
+}
+
+func TestParseHPALabels(t *testing.T) {
+	serviceConfig := kobject.ServiceConfig{}
+	labels := map[string]string{
+		"kompose.hpa.replicas.min": "2",
+		"kompose.hpa.replicas.max": "10",
+		"kompose.hpa.cpu":          "60",
+		"kompose.hpa.memory":       "80",
+	}
+	if err := parseKomposeLabels(labels, &serviceConfig); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	expected := kobject.HPA{MinReplicas: 2, MaxReplicas: 10, CPU: 60, Memory: 80}
+	if serviceConfig.HPA != expected {
+		t.Errorf("Expected the HPA %+v, got %+v", expected, serviceConfig.HPA)
+	}
+	if _, ok := serviceConfig.Labels["kompose.hpa.cpu"]; ok {
+		t.Errorf("Expected the kompose.hpa labels to be left out of the labels")
+	}
+	if err := parseKomposeLabels(map[string]string{"kompose.hpa.replicas.max": "0"}, &serviceConfig); err == nil {
+		t.Errorf("Expected an error for 0 max replicas")
+	}
+}
+
+func TestLoadV2MemoryKeys(t *testing.T) {
+	content := `version: "2"
+services:
+  web:
+    image: nginx
+    mem_limit: 512m
+    memswap_limit: 1g
+    mem_swappiness: 10
+    kernel_memory: 64m
+  db:
+    image: redis
+    mem_limit: 256m
+    memswap_limit: 256m
+`
+	f, err := ioutil.TempFile("", "docker-compose-*.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	c := Compose{}
+	komposeObject, err := c.LoadFile([]string{f.Name()})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	web := komposeObject.ServiceConfigs["web"]
+	if web.MemLimit != 512*1024*1024 {
+		t.Errorf("Expected mem_limit to be kept, got %d", web.MemLimit)
+	}
+	expected := map[string]string{
+		AnnotationMemoryPrefix + "memswap_limit":  "1g",
+		AnnotationMemoryPrefix + "mem_swappiness": "10",
+		AnnotationMemoryPrefix + "kernel_memory":  "64m",
+	}
+	for key, value := range expected {
+		if web.Annotations[key] != value {
+			t.Errorf("Expected annotation %s to be %q, got %q", key, value, web.Annotations[key])
+		}
+	}
+
+	db := komposeObject.ServiceConfigs["db"]
+	if db.MemLimit != 256*1024*1024 {
+		t.Errorf("Expected mem_limit to be kept, got %d", db.MemLimit)
+	}
+	if _, ok := db.Annotations[AnnotationMemoryPrefix+"memswap_limit"]; ok {
+		t.Errorf("Expected no annotation for a memswap_limit that equals mem_limit")
+	}
+}
+
+func TestLoadInit(t *testing.T) {
+	for _, version := range []string{"2.2", "3.7"} {
+		content := `version: "` + version + `"
+services:
+  web:
+    image: nginx
+    init: true
+  db:
+    image: redis
+`
+		f, err := ioutil.TempFile("", "docker-compose-*.yml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.WriteString(content); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		c := Compose{}
+		komposeObject, err := c.LoadFile([]string{f.Name()})
+		if err != nil {
+			t.Fatalf("Version %s: unexpected error %v", version, err)
+		}
+		if !komposeObject.ServiceConfigs["web"].Init {
+			t.Errorf("Version %s: expected init of web to be loaded", version)
+		}
+		if komposeObject.ServiceConfigs["db"].Init {
+			t.Errorf("Version %s: expected no init for db", version)
+		}
+	}
+}
+
+func TestLabelSchema(t *testing.T) {
+	data, err := LabelSchema()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	var schema struct {
+		Definitions map[string]struct {
+			OneOf []struct {
+				Properties        map[string]map[string]interface{}
+				PatternProperties map[string]map[string]interface{}
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("Unable to parse the schema: %v", err)
+	}
+
+	for _, directive := range LabelDirectives {
+		if directive.Pattern != "" {
+			if _, err := regexp.Compile(directive.Pattern); err != nil {
+				t.Errorf("Invalid pattern of label %s: %v", directive.Key, err)
+			}
+		}
+		for _, scope := range directive.Scopes {
+			labels := schema.Definitions[scope+"_labels"].OneOf[0]
+			found := false
+			if directive.Prefix {
+				for pattern := range labels.PatternProperties {
+					if regexp.MustCompile(pattern).MatchString(directive.Key + "foo") {
+						found = true
+					}
+				}
+			} else {
+				_, found = labels.Properties[directive.Key]
+			}
+			if !found {
+				t.Errorf("Expected label %s in the %s labels of the schema", directive.Key, scope)
+			}
+		}
+	}
+
+	serviceType := regexp.MustCompile(LabelDirectives[0].Pattern)
+	for _, value := range []string{"NodePort", "clusterip", "LoadBalancer", "Headless"} {
+		if !serviceType.MatchString(value) {
+			t.Errorf("Expected %s to be a valid %s", value, LabelServiceType)
+		}
+	}
+	if serviceType.MatchString("external") {
+		t.Errorf("Expected external to be an invalid %s", LabelServiceType)
+	}
+}
+
+func TestLoadUnusedResources(t *testing.T) {
+	testCases := map[string]struct {
+		content  string
+		expected kobject.UnusedResources
+	}{
+		"v2": {`version: "2"
+services:
+  web:
+    image: nginx
+    volumes:
+      - data:/data
+      - ./html:/html
+  db:
+    image: redis
+    networks:
+      - back
+volumes:
+  data:
+  cache:
+networks:
+  back:
+  front:
+`, kobject.UnusedResources{Volumes: []string{"cache"}, Networks: []string{"front"}}},
+		"v3": {`version: "3.5"
+services:
+  web:
+    image: nginx
+    volumes:
+      - data:/data
+    configs:
+      - site
+    secrets:
+      - token
+  db:
+    image: redis
+volumes:
+  data:
+  cache:
+networks:
+  default:
+  front:
+configs:
+  site:
+    external: true
+  old:
+    external: true
+secrets:
+  token:
+    external: true
+  stale:
+    external: true
+`, kobject.UnusedResources{Volumes: []string{"cache"}, Networks: []string{"front"}, Configs: []string{"old"}, Secrets: []string{"stale"}}},
+	}
+
+	for name, test := range testCases {
+		t.Log("Test case:", name)
+		f, err := ioutil.TempFile("", "docker-compose-*.yml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.WriteString(test.content); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		c := Compose{}
+		komposeObject, err := c.LoadFile([]string{f.Name()})
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if !reflect.DeepEqual(komposeObject.Unused, test.expected) {
+			t.Errorf("Expected unused resources %+v, got %+v", test.expected, komposeObject.Unused)
+		}
+	}
+}
+
+func TestLoadNetworkAliases(t *testing.T) {
+	testCases := map[string]string{
+		"v2": `version: "2"
+services:
+  db:
+    image: postgres
+    networks:
+      back:
+        aliases:
+          - database
+          - pg.internal
+          - db
+networks:
+  back:
+`,
+		"v3": `version: "3.5"
+services:
+  db:
+    image: postgres
+    networks:
+      back:
+        aliases:
+          - database
+          - pg.internal
+          - db
+networks:
+  back:
+`,
+	}
+
+	for name, content := range testCases {
+		t.Log("Test case:", name)
+		f, err := ioutil.TempFile("", "docker-compose-*.yml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.WriteString(content); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		c := Compose{}
+		komposeObject, err := c.LoadFile([]string{f.Name()})
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		expected := []string{"database", "pg-internal"}
+		if aliases := komposeObject.ServiceConfigs["db"].NetworkAliases; !reflect.DeepEqual(aliases, expected) {
+			t.Errorf("Expected network aliases %v, got %v", expected, aliases)
+		}
+	}
+}
+
+func TestLoadV3RestartPolicy(t *testing.T) {
+	content := `version: "3.7"
+services:
+  migrate:
+    image: migrate
+    deploy:
+      restart_policy:
+        condition: on-failure
+        max_attempts: 3
+        window: 15m
+  seed:
+    image: seed
+    deploy:
+      restart_policy:
+        condition: none
+`
+	f, err := ioutil.TempFile("", "docker-compose-*.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	c := Compose{}
+	komposeObject, err := c.LoadFile([]string{f.Name()})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	migrate := komposeObject.ServiceConfigs["migrate"]
+	if migrate.Restart != "on-failure" {
+		t.Errorf("Expected restart on-failure, got %q", migrate.Restart)
+	}
+	if limit := migrate.GetJobBackoffLimit(); limit == nil || *limit != 3 {
+		t.Errorf("Expected a backoff limit of 3, got %v", limit)
+	}
+	if deadline := migrate.GetProgressDeadlineSeconds(); deadline == nil || *deadline != 900 {
+		t.Errorf("Expected a progress deadline of 900 seconds, got %v", deadline)
+	}
+	if restart := komposeObject.ServiceConfigs["seed"].Restart; restart != "no" {
+		t.Errorf("Expected the condition none to be loaded as restart no, got %q", restart)
+	}
+}
+
+func TestLoadV3Include(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kompose")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"docker-compose.yml": `version: "3.7"
+include:
+  - path: db/compose.yaml
+    env_file: db/versions.env
+services:
+  web:
+    image: web
+`,
+		"db/compose.yaml": `services:
+  db:
+    image: postgres:${POSTGRES_TAG}
+    build: .
+    env_file: db.env
+volumes:
+  data:
+`,
+		"db/versions.env": "POSTGRES_TAG=13\n",
+		"db/db.env":       "POSTGRES_DB=app\n",
+		"cycle.yml": `version: "3.7"
+include:
+  - cycle/compose.yaml
+`,
+		"cycle/compose.yaml": `include:
+  - ../cycle.yml
+`,
+		"conflict.yml": `version: "3.7"
+include:
+  - db/compose.yaml
+services:
+  db:
+    image: mysql
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := Compose{}
+	komposeObject, err := c.LoadFile([]string{filepath.Join(dir, "docker-compose.yml")})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if _, ok := komposeObject.ServiceConfigs["web"]; !ok {
+		t.Errorf("Expected the service web to be loaded")
+	}
+	db, ok := komposeObject.ServiceConfigs["db"]
+	if !ok {
+		t.Fatalf("Expected the included service db to be loaded")
+	}
+	if db.Image != "postgres:13" {
+		t.Errorf("Expected the image postgres:13 interpolated with the env_file of the include, got %q", db.Image)
+	}
+	if db.Build != "db" {
+		t.Errorf("Expected the build context db, got %q", db.Build)
+	}
+	if expected := []string{filepath.Join("db", "db.env")}; !reflect.DeepEqual(db.EnvFile, expected) {
+		t.Errorf("Expected the env_file %v, got %v", expected, db.EnvFile)
+	}
+
+	if _, err := c.LoadFile([]string{filepath.Join(dir, "cycle.yml")}); err == nil || !strings.Contains(err.Error(), "includes itself") {
+		t.Errorf("Expected an error for a file including itself, got %v", err)
+	}
+	if _, err := c.LoadFile([]string{filepath.Join(dir, "conflict.yml")}); err == nil || !strings.Contains(err.Error(), "already defined") {
+		t.Errorf("Expected an error for an included service that is already defined, got %v", err)
+	}
+}
+
+func TestLoadV3GroupAddAndUsernsMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kompose")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"docker-compose.yml": `version: "3"
+services:
+  media:
+    image: media
+    group_add:
+      - 44
+      - "107"
+    userns_mode: host
+  web:
+    image: web
+`,
+		"docker-compose.override.yml": `version: "3"
+services:
+  web:
+    group_add:
+      - 33
+`,
+		"docker-compose-fail.yml": `version: "3"
+services:
+  media:
+    image: media
+    group_add:
+      - video
+`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := Compose{}
+	komposeObject, err := c.LoadFile([]string{filepath.Join(dir, "docker-compose.yml"), filepath.Join(dir, "docker-compose.override.yml")})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	media := komposeObject.ServiceConfigs["media"]
+	if expected := []int64{44, 107}; !reflect.DeepEqual(media.GroupAdd, expected) {
+		t.Errorf("Expected the groups %v, got %v", expected, media.GroupAdd)
+	}
+	if mode := media.Annotations["kompose.userns_mode"]; mode != "host" {
+		t.Errorf("Expected the userns_mode to be kept as annotation, got %q", mode)
+	}
+	if expected := []int64{33}; !reflect.DeepEqual(komposeObject.ServiceConfigs["web"].GroupAdd, expected) {
+		t.Errorf("Expected the groups %v of the override file, got %v", expected, komposeObject.ServiceConfigs["web"].GroupAdd)
+	}
+
+	if _, err := c.LoadFile([]string{filepath.Join(dir, "docker-compose-fail.yml")}); err == nil {
+		t.Errorf("Expected an error for a group name in group_add")
+	}
+}
+
+func TestLoadMultipleDocuments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kompose")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"v3.yml": `version: "3"
+services:
+  web:
+    image: web:1
+    ports:
+      - 8080:80
+    environment:
+      LEVEL: info
+---
+services:
+  web:
+    image: web:2
+    environment:
+      MODE: production
+    group_add:
+      - 33
+  db:
+    image: postgres
+---
+`,
+		"v2.yml": `version: "2"
+services:
+  web:
+    image: web:1
+    mem_limit: 64m
+---
+version: "2"
+services:
+  web:
+    image: web:2
+`,
+		"v2-versionless.yml": `version: "2"
+services:
+  web:
+    image: web:1
+---
+services:
+  web:
+    image: web:2
+  db:
+    image: postgres
+`,
+		"invalid.yml": `version: "3"
+services:
+  web:
+    image: web
+---
+services:
+  web:
+    image: [web
+`,
+		"mismatch.yml": `version: "3"
+services:
+  web:
+    image: web
+---
+version: "2"
+services:
+  db:
+    image: postgres
+`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := Compose{}
+	komposeObject, err := c.LoadFile([]string{filepath.Join(dir, "v3.yml")})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	web := komposeObject.ServiceConfigs["web"]
+	if web.Image != "web:2" {
+		t.Errorf("Expected the image web:2 of the second document, got %q", web.Image)
+	}
+	if len(web.Port) != 1 || web.Port[0].HostPort != 8080 {
+		t.Errorf("Expected the port of the first document to be kept, got %+v", web.Port)
+	}
+	environment := map[string]string{}
+	for _, env := range web.Environment {
+		environment[env.Name] = env.Value
+	}
+	if expected := map[string]string{"LEVEL": "info", "MODE": "production"}; !reflect.DeepEqual(environment, expected) {
+		t.Errorf("Expected the merged environment %v, got %v", expected, environment)
+	}
+	if expected := []int64{33}; !reflect.DeepEqual(web.GroupAdd, expected) {
+		t.Errorf("Expected the groups %v of the second document, got %v", expected, web.GroupAdd)
+	}
+	if _, ok := komposeObject.ServiceConfigs["db"]; !ok {
+		t.Errorf("Expected the service db of the second document to be loaded")
+	}
+
+	komposeObject, err = c.LoadFile([]string{filepath.Join(dir, "v2.yml")})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	web = komposeObject.ServiceConfigs["web"]
+	if web.Image != "web:2" {
+		t.Errorf("Expected the image web:2 of the second v2 document, got %q", web.Image)
+	}
+	if web.MemLimit != 64*1024*1024 {
+		t.Errorf("Expected the mem_limit of the first v2 document to be kept, got %d", web.MemLimit)
+	}
+
+	// the documents without version take the version of the previous ones rather than version 1
+	komposeObject, err = c.LoadFile([]string{filepath.Join(dir, "v2-versionless.yml")})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if web := komposeObject.ServiceConfigs["web"]; web.Image != "web:2" {
+		t.Errorf("Expected the image web:2 of the versionless v2 document, got %q", web.Image)
+	}
+	if _, ok := komposeObject.ServiceConfigs["db"]; !ok {
+		t.Errorf("Expected the service db of the versionless v2 document to be loaded")
+	}
+
+	// the errors give the lines of the file
+	if _, err := c.LoadFile([]string{filepath.Join(dir, "invalid.yml")}); err == nil || !strings.Contains(err.Error(), "line 8") {
+		t.Errorf("Expected an error at line 8 of the file, got %v", err)
+	}
+
+	if _, err := c.LoadFile([]string{filepath.Join(dir, "mismatch.yml")}); err == nil || !strings.Contains(err.Error(), "same version") {
+		t.Errorf("Expected an error for documents of different versions, got %v", err)
+	}
+}
+
+func TestLoadSOPSEncryptedFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake sops is a shell script")
+	}
+	dir, err := ioutil.TempDir("", "kompose")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the fake sops prints the decrypted files of the input type it is given
+	files := map[string]string{
+		"sops": `#!/bin/sh
+case "$3" in
+yaml) printf 'version: "3"\nservices:\n  web:\n    image: web\n    env_file: web.env\n    environment:\n      LEVEL: info\n' ;;
+dotenv) printf 'PASSWORD=secret\nLEVEL=debug\n' ;;
+*) exit 1 ;;
+esac
+`,
+		"docker-compose.yml": `version: ENC[AES256_GCM,data:Kw==,iv:aXY=,tag:dGFn,type:str]
+services: ENC[AES256_GCM,data:c2VydmljZXM=,iv:aXY=,tag:dGFn,type:str]
+sops:
+  mac: ENC[AES256_GCM,data:bWFj,iv:aXY=,tag:dGFn,type:str]
+  version: 3.8.1
+`,
+		"web.env": `PASSWORD=ENC[AES256_GCM,data:c2VjcmV0,iv:aXY=,tag:dGFn,type:str]
+LEVEL=ENC[AES256_GCM,data:ZGVidWc=,iv:aXY=,tag:dGFn,type:str]
+sops_mac=ENC[AES256_GCM,data:bWFj,iv:aXY=,tag:dGFn,type:str]
+sops_version=3.8.1
+`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := Compose{SOPS: sops.Options{Binary: filepath.Join(dir, "sops")}}
+	komposeObject, err := c.LoadFile([]string{filepath.Join(dir, "docker-compose.yml")})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	web, ok := komposeObject.ServiceConfigs["web"]
+	if !ok {
+		t.Fatalf("Expected the service web of the decrypted compose file, got %v", komposeObject.ServiceConfigs)
+	}
+	env := map[string]string{}
+	for _, e := range web.Environment {
+		env[e.Name] = e.Value
+	}
+	// the variables of the encrypted env_file are left to the transformers, which put them in Secrets
+	if expected := map[string]string{"LEVEL": "info"}; !reflect.DeepEqual(env, expected) {
+		t.Errorf("Expected the environment %v, got %v", expected, env)
+	}
+	if !reflect.DeepEqual(web.EnvFile, []string{"web.env"}) {
+		t.Errorf("Expected the env_file web.env to be kept, got %v", web.EnvFile)
+	}
+
+	c = Compose{SOPS: sops.Options{Binary: filepath.Join(dir, "sops"), Disabled: true}}
+	if _, err := c.LoadFile([]string{filepath.Join(dir, "docker-compose.yml")}); err == nil || !strings.Contains(err.Error(), "can't be decrypted") {
+		t.Errorf("Expected an error for an encrypted compose file with sops disabled, got %v", err)
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kompose")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"docker-compose.yml": `version: "3.7"
+services:
+  web_app:
+    image: nginx:${TAG:-1.25}
+    entrypoint: ["nginx"]
+    command: ["-g", "daemon off;"]
+    environment:
+      PRICE: $$5
+      GREETING: hello
+    env_file: web.env
+    ports:
+      - "8080:80"
+      - "53:53/udp"
+    expose:
+      - "9000"
+    volumes:
+      - data:/data:ro
+      - ./html:/usr/share/nginx/html
+      - /cache
+    networks:
+      front:
+        aliases:
+          - www
+    labels:
+      kompose.service.type: nodeport
+      kompose.hpa.replicas.max: "5"
+    healthcheck:
+      test: curl -f http://localhost/
+      interval: 30s
+      retries: 3
+    stop_grace_period: 1m30s
+    group_add:
+      - 33
+    deploy:
+      replicas: 2
+      resources:
+        limits:
+          cpus: "0.5"
+          memory: 512M
+      restart_policy:
+        condition: on-failure
+        max_attempts: 3
+      placement:
+        constraints:
+          - node.hostname == node1
+          - node.labels.disk == ssd
+  db:
+    image: postgres
+    restart: always
+    user: "999"
+    healthcheck:
+      disable: true
+volumes:
+  data:
+    labels:
+      kompose.volume.size: 1Gi
+networks:
+  front:
+`,
+		"web.env": "LOG_LEVEL=debug\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := Compose{}
+	expected, err := c.LoadFile([]string{filepath.Join(dir, "docker-compose.yml")})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	data, err := Marshal(expected)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	exported := filepath.Join(dir, "exported.yml")
+	if err := ioutil.WriteFile(exported, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := c.LoadFile([]string{exported})
+	if err != nil {
+		t.Fatalf("Unable to load the exported compose file %v:\n%s", err, data)
+	}
+
+	// the environment and networks are loaded from maps
+	for _, komposeObject := range []kobject.KomposeObject{expected, actual} {
+		for name, service := range komposeObject.ServiceConfigs {
+			sort.Slice(service.Environment, func(i, j int) bool { return service.Environment[i].Name < service.Environment[j].Name })
+			sort.Strings(service.Network)
+			komposeObject.ServiceConfigs[name] = service
+		}
+	}
+	if diff := cmp.Diff(expected.ServiceConfigs, actual.ServiceConfigs); diff != "" {
+		t.Errorf("Expected the exported compose file to load the same services, got (-expected +actual):\n%s\n%s", diff, data)
+	}
+
+	again, err := Marshal(actual)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if string(again) != string(data) {
+		t.Errorf("Expected the same compose file when exporting again, got:\n%s\nthen:\n%s", data, again)
+	}
+}
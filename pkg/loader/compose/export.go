@@ -0,0 +1,396 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compose
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/cli/cli/compose/types"
+	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/transformer"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+	api "k8s.io/api/core/v1"
+)
+
+// exportVersion is the version of the compose files Marshal writes
+const exportVersion = "3.7"
+
+// Marshal writes a KomposeObject back as a version 3 compose file, which loads into the same
+// KomposeObject. The services, their keys and the top-level resources are sorted, so the file of
+// a KomposeObject is always the same, whichever loader created it. The values are escaped, they
+// aren't interpolated again when the file is loaded. The keys of version 1 and 2 that version 3
+// lacks are reported and left out.
+func Marshal(komposeObject kobject.KomposeObject) ([]byte, error) {
+	config := types.Config{
+		Version:  exportVersion,
+		Networks: map[string]types.NetworkConfig{},
+		Volumes:  map[string]types.VolumeConfig{},
+		Secrets:  komposeObject.Secrets,
+		Configs:  map[string]types.ConfigObjConfig{},
+	}
+
+	for _, name := range sortedServiceNames(komposeObject) {
+		service := komposeObject.ServiceConfigs[name]
+		composeService, err := exportService(name, service)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to export the service %s", name)
+		}
+		config.Services = append(config.Services, composeService)
+
+		for _, network := range exportNetworks(service) {
+			config.Networks[network] = types.NetworkConfig{}
+		}
+		for _, volume := range service.Volumes {
+			if volume.VolumeName == "" {
+				continue
+			}
+			volumeConfig := config.Volumes[volume.VolumeName]
+			if volume.PVCSize != "" || volume.SelectorValue != "" {
+				volumeConfig.Labels = types.Labels{}
+				if volume.PVCSize != "" {
+					volumeConfig.Labels[LabelVolumeSize] = volume.PVCSize
+				}
+				if volume.SelectorValue != "" {
+					volumeConfig.Labels[LabelVolumeSelector] = volume.SelectorValue
+				}
+			}
+			config.Volumes[volume.VolumeName] = volumeConfig
+		}
+		for configName, configObj := range service.ConfigsMetaData {
+			config.Configs[configName] = configObj
+		}
+	}
+
+	// the values are written as they were interpolated, so $ is escaped
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var document map[interface{}]interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	escapeInterpolation(document)
+
+	// the version comes first, like in the files written by hand
+	var ordered yaml.MapSlice
+	for _, key := range []string{"version", "services", "networks", "volumes", "secrets", "configs"} {
+		if value, ok := document[key]; ok {
+			ordered = append(ordered, yaml.MapItem{Key: key, Value: value})
+		}
+	}
+	return yaml.Marshal(ordered)
+}
+
+// sortedServiceNames returns the names of the services of komposeObject in order
+func sortedServiceNames(komposeObject kobject.KomposeObject) []string {
+	var names []string
+	for name := range komposeObject.ServiceConfigs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// escapeInterpolation escapes the $ of the strings of a document
+func escapeInterpolation(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return strings.Replace(v, "$", "$$", -1)
+	case []interface{}:
+		for i := range v {
+			v[i] = escapeInterpolation(v[i])
+		}
+	case map[interface{}]interface{}:
+		for key := range v {
+			v[key] = escapeInterpolation(v[key])
+		}
+	}
+	return value
+}
+
+// exportService returns the compose service of the service name, the inverse of
+// dockerComposeToKomposeMapping
+func exportService(name string, service kobject.ServiceConfig) (types.ServiceConfig, error) {
+	composeName := service.ComposeName
+	if composeName == "" {
+		composeName = name
+	}
+	composeService := types.ServiceConfig{
+		Name:          composeName,
+		Image:         service.Image,
+		WorkingDir:    service.WorkingDir,
+		CapAdd:        service.CapAdd,
+		CapDrop:       service.CapDrop,
+		Expose:        service.Expose,
+		Privileged:    service.Privileged,
+		User:          service.User,
+		StdinOpen:     service.Stdin,
+		Tty:           service.Tty,
+		Tmpfs:         service.TmpFs,
+		DependsOn:     service.DependsOn,
+		ExtraHosts:    service.ExtraHosts,
+		ExternalLinks: service.ExternalLinks,
+		ContainerName: service.ContainerName,
+		Entrypoint:    service.Command,
+		Command:       service.Args,
+		Labels:        service.Labels,
+		Hostname:      service.HostName,
+		DomainName:    service.DomainName,
+		Secrets:       service.Secrets,
+		Configs:       service.Configs,
+		EnvFile:       service.EnvFile,
+		Pid:           service.Pid,
+		UserNSMode:    service.Annotations[AnnotationUsernsMode],
+		Build: types.BuildConfig{
+			Context:    service.Build,
+			Dockerfile: service.Dockerfile,
+			Args:       service.BuildArgs,
+			Labels:     service.BuildLabels,
+		},
+	}
+	if service.Init {
+		composeService.Init = &service.Init
+	}
+
+	if len(service.Environment) > 0 {
+		composeService.Environment = types.MappingWithEquals{}
+		for _, env := range service.Environment {
+			value := env.Value
+			composeService.Environment[env.Name] = &value
+		}
+	}
+
+	if service.StopGracePeriod != "" {
+		duration, err := time.ParseDuration(service.StopGracePeriod)
+		if err != nil {
+			return types.ServiceConfig{}, errors.Wrap(err, "invalid stop_grace_period")
+		}
+		gracePeriod := types.Duration(duration)
+		composeService.StopGracePeriod = &gracePeriod
+	}
+
+	composeService.Ports = exportPorts(service.Port, service.Expose)
+
+	volumes, err := exportVolumes(service.VolList)
+	if err != nil {
+		return types.ServiceConfig{}, err
+	}
+	composeService.Volumes = volumes
+
+	if networks := exportNetworks(service); len(networks) > 0 {
+		composeService.Networks = map[string]*types.ServiceNetworkConfig{}
+		for i, network := range networks {
+			composeService.Networks[network] = nil
+			// the aliases are the names of the service on all its networks
+			if i == 0 && len(service.NetworkAliases) > 0 {
+				composeService.Networks[network] = &types.ServiceNetworkConfig{Aliases: service.NetworkAliases}
+			}
+		}
+	}
+
+	if service.HealthChecks.Disable {
+		composeService.HealthCheck = &types.HealthCheckConfig{Disable: true}
+	} else if len(service.HealthChecks.Test) > 0 {
+		composeService.HealthCheck = exportHealthCheck(service.HealthChecks)
+	}
+
+	composeService.Deploy = exportDeploy(service)
+	composeService.Restart = service.Restart
+	if composeService.Deploy.RestartPolicy != nil {
+		composeService.Restart = ""
+	}
+
+	if len(service.GroupAdd) > 0 {
+		composeService.Extras = map[string]interface{}{"group_add": service.GroupAdd}
+	}
+
+	for key, set := range map[string]bool{
+		"cpuset":       service.CPUSet != "",
+		"cpu_shares":   service.CPUShares != 0,
+		"cpu_quota":    service.CPUQuota != 0,
+		"volumes_from": len(service.VolumesFrom) > 0,
+	} {
+		if set {
+			log.WithFields(log.Fields{"service": name, "category": "unsupported"}).Warnf("The %s key has no equivalent in version %s of compose files - ignoring", key, exportVersion)
+		}
+	}
+	return composeService, nil
+}
+
+// exportNetworks returns the names of the networks of a service in order, without the unnamed
+// default network
+func exportNetworks(service kobject.ServiceConfig) []string {
+	var networks []string
+	for _, network := range service.Network {
+		if network != "" {
+			networks = append(networks, network)
+		}
+	}
+	sort.Strings(networks)
+	return networks
+}
+
+// exportPorts returns the ports of a service, without the ones its expose already lists
+func exportPorts(ports []kobject.Ports, expose []string) []types.ServicePortConfig {
+	exposed := map[string]bool{}
+	for _, port := range expose {
+		if !strings.Contains(port, "/") {
+			port += "/" + string(api.ProtocolTCP)
+		}
+		exposed[strings.ToUpper(port)] = true
+	}
+
+	var composePorts []types.ServicePortConfig
+	for _, port := range ports {
+		protocol := port.Protocol
+		if protocol == "" {
+			protocol = api.ProtocolTCP
+		}
+		if port.HostPort == port.ContainerPort && exposed[strconv.Itoa(int(port.ContainerPort))+"/"+string(protocol)] {
+			continue
+		}
+		composePorts = append(composePorts, types.ServicePortConfig{
+			Target:    uint32(port.ContainerPort),
+			Published: uint32(port.HostPort),
+			Protocol:  strings.ToLower(string(protocol)),
+		})
+	}
+	return composePorts
+}
+
+// exportVolumes returns the volumes of a service in the long syntax, like loadV3Volumes reads them
+func exportVolumes(volList []string) ([]types.ServiceVolumeConfig, error) {
+	var volumes []types.ServiceVolumeConfig
+	for _, volume := range volList {
+		name, host, container, mode, err := transformer.ParseVolume(volume)
+		if err != nil {
+			return nil, err
+		}
+		composeVolume := types.ServiceVolumeConfig{Target: container, ReadOnly: mode == "ro"}
+		switch {
+		case host != "":
+			composeVolume.Type = "bind"
+			composeVolume.Source = host
+		case name != "":
+			composeVolume.Type = "volume"
+			composeVolume.Source = name
+		default:
+			composeVolume.Type = "volume"
+		}
+		volumes = append(volumes, composeVolume)
+	}
+	return volumes, nil
+}
+
+// exportHealthCheck returns the healthcheck of a health check with a test
+func exportHealthCheck(healthCheck kobject.HealthCheck) *types.HealthCheckConfig {
+	// the loader drops the CMD or CMD-SHELL of the test
+	test := append([]string{"CMD"}, healthCheck.Test...)
+	if len(healthCheck.Test) == 1 {
+		test[0] = "CMD-SHELL"
+	}
+	composeHealthCheck := &types.HealthCheckConfig{
+		Test:        test,
+		Timeout:     exportSeconds(healthCheck.Timeout),
+		Interval:    exportSeconds(healthCheck.Interval),
+		StartPeriod: exportSeconds(healthCheck.StartPeriod),
+	}
+	if healthCheck.Retries > 0 {
+		retries := uint64(healthCheck.Retries)
+		composeHealthCheck.Retries = &retries
+	}
+	return composeHealthCheck
+}
+
+// exportSeconds returns a duration of seconds, nil for none
+func exportSeconds(seconds int32) *types.Duration {
+	if seconds == 0 {
+		return nil
+	}
+	duration := types.Duration(time.Duration(seconds) * time.Second)
+	return &duration
+}
+
+// exportDeploy returns the deploy key of a service
+func exportDeploy(service kobject.ServiceConfig) types.DeployConfig {
+	deploy := types.DeployConfig{
+		Mode:   service.DeployMode,
+		Labels: service.DeployLabels,
+	}
+	if service.Replicas > 0 {
+		replicas := uint64(service.Replicas)
+		deploy.Replicas = &replicas
+	}
+	if (service.DeployUpdateConfig != types.UpdateConfig{}) {
+		updateConfig := service.DeployUpdateConfig
+		deploy.UpdateConfig = &updateConfig
+	}
+	if service.DeployRestartPolicy.MaxAttempts != nil || service.DeployRestartPolicy.Window != nil {
+		restartPolicy := service.DeployRestartPolicy
+		restartPolicy.Condition = service.Restart
+		if restartPolicy.Condition == "no" {
+			restartPolicy.Condition = "none"
+		}
+		deploy.RestartPolicy = &restartPolicy
+	}
+	if _, ok := service.Labels[LabelServiceType]; !ok && service.ServiceType == string(api.ServiceTypeNodePort) {
+		deploy.EndpointMode = "vip"
+	}
+
+	if service.MemLimit != 0 || service.CPULimit != 0 {
+		deploy.Resources.Limits = &types.ResourceLimit{
+			NanoCPUs:    exportCPUs(service.CPULimit),
+			MemoryBytes: types.UnitBytes(service.MemLimit),
+		}
+	}
+	if service.MemReservation != 0 || service.CPUReservation != 0 {
+		deploy.Resources.Reservations = &types.Resource{
+			NanoCPUs:    exportCPUs(service.CPUReservation),
+			MemoryBytes: types.UnitBytes(service.MemReservation),
+		}
+	}
+
+	var constraints []string
+	for key, value := range service.Placement {
+		switch key {
+		case "kubernetes.io/hostname":
+			key = "node.hostname"
+		case "beta.kubernetes.io/os":
+			key = "engine.labels.operatingsystem"
+		default:
+			key = "node.labels." + key
+		}
+		constraints = append(constraints, key+" == "+value)
+	}
+	sort.Strings(constraints)
+	deploy.Placement.Constraints = constraints
+	return deploy
+}
+
+// exportCPUs returns the cpus of millicores, empty for none
+func exportCPUs(millicores int64) string {
+	if millicores == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(float64(millicores)/1000, 'f', -1, 64)
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compose
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/cli/cli/compose/types"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
+)
+
+// composeInclude is an entry of the top-level include key of the compose specification, which
+// pulls the services, networks, volumes, configs and secrets of other compose files in
+type composeInclude struct {
+	// Path lists the included files, merged like the files given with --file
+	Path []string
+	// ProjectDirectory is the directory the relative paths of the included files are relative to,
+	// the directory of the first file by default
+	ProjectDirectory string
+	// EnvFile lists the files of the variables the included files are interpolated with
+	EnvFile []string
+}
+
+// parseInclude returns the entries of the include key of a compose file in dir, with the paths
+// made absolute. An entry is either the path of a file or a mapping of path, project_directory
+// and env_file.
+func parseInclude(value interface{}, dir string) ([]composeInclude, error) {
+	entries, ok := value.([]interface{})
+	if !ok {
+		return nil, errors.New("include must be a list")
+	}
+	absPath := func(p string) string {
+		if filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+
+	var includes []composeInclude
+	for _, entry := range entries {
+		var include composeInclude
+		switch e := entry.(type) {
+		case string:
+			include.Path = []string{e}
+		case map[string]interface{}:
+			for key, value := range e {
+				var err error
+				switch key {
+				case "path":
+					include.Path, err = stringOrList(value)
+				case "env_file":
+					include.EnvFile, err = stringOrList(value)
+				case "project_directory":
+					include.ProjectDirectory, err = cast.ToStringE(value)
+				default:
+					err = errors.Errorf("unknown key %s", key)
+				}
+				if err != nil {
+					return nil, errors.Wrapf(err, "invalid include %s", key)
+				}
+			}
+		default:
+			return nil, errors.Errorf("invalid include %v, expected a path or a mapping", entry)
+		}
+		if len(include.Path) == 0 {
+			return nil, errors.New("include without path")
+		}
+
+		for i, p := range include.Path {
+			include.Path[i] = absPath(p)
+		}
+		for i, p := range include.EnvFile {
+			include.EnvFile[i] = absPath(p)
+		}
+		if include.ProjectDirectory == "" {
+			include.ProjectDirectory = filepath.Dir(include.Path[0])
+		} else {
+			include.ProjectDirectory = absPath(include.ProjectDirectory)
+		}
+		includes = append(includes, include)
+	}
+	return includes, nil
+}
+
+// stringOrList returns the strings of a value that is a string or a list of strings
+func stringOrList(value interface{}) ([]string, error) {
+	if s, ok := value.(string); ok {
+		return []string{s}, nil
+	}
+	return cast.ToStringSliceE(value)
+}
+
+// includeEnvironment returns the variables the files of include are interpolated with: the
+// variables of env, which take precedence, and of its env_file
+func (c *Compose) includeEnvironment(include composeInclude, env map[string]string) (map[string]string, error) {
+	if len(include.EnvFile) == 0 {
+		return env, nil
+	}
+	result := map[string]string{}
+	for _, file := range include.EnvFile {
+		vars, err := c.SOPS.ReadEnvFile(file)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read the env_file of the include")
+		}
+		for name, value := range vars {
+			result[name] = value
+		}
+	}
+	for name, value := range env {
+		result[name] = value
+	}
+	return result, nil
+}
+
+// loadV3Include loads the files of include into config. The paths of the build contexts and
+// env_files of the included services, relative to the project directory of the include, are made
+// relative to workingDir, the directory of the compose file, which the transformers resolve them
+// against. The services of an include may not be defined by the including file.
+func (c *Compose) loadV3Include(config *types.Config, include composeInclude, workingDir string, env map[string]string, version string, chain []string) (*types.Config, error) {
+	env, err := c.includeEnvironment(include, env)
+	if err != nil {
+		return nil, err
+	}
+
+	var included *types.Config
+	for _, file := range include.Path {
+		current, err := c.loadV3File(file, include.ProjectDirectory, env, version, chain)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to include %s", file)
+		}
+		if included == nil {
+			included = current
+		} else if included, err = mergeComposeObject(included, current); err != nil {
+			return nil, err
+		}
+	}
+
+	rebase := func(p string) string {
+		if p == "" || filepath.IsAbs(p) || strings.Contains(p, "://") {
+			return p
+		}
+		if rel, err := filepath.Rel(workingDir, filepath.Join(include.ProjectDirectory, p)); err == nil {
+			return rel
+		}
+		return p
+	}
+	defined := map[string]bool{}
+	for _, service := range config.Services {
+		defined[service.Name] = true
+	}
+	for i, service := range included.Services {
+		if defined[service.Name] {
+			return nil, errors.Errorf("service %s of the included %s is already defined", service.Name, strings.Join(include.Path, ", "))
+		}
+		included.Services[i].Build.Context = rebase(service.Build.Context)
+		for j, envFile := range service.EnvFile {
+			included.Services[i].EnvFile[j] = rebase(envFile)
+		}
+	}
+	log.Debugf("Included %d services of %s", len(included.Services), strings.Join(include.Path, ", "))
+
+	if config.Networks == nil {
+		config.Networks = map[string]types.NetworkConfig{}
+	}
+	if config.Volumes == nil {
+		config.Volumes = map[string]types.VolumeConfig{}
+	}
+	if config.Secrets == nil {
+		config.Secrets = map[string]types.SecretConfig{}
+	}
+	if config.Configs == nil {
+		config.Configs = map[string]types.ConfigObjConfig{}
+	}
+	return mergeComposeObject(config, included)
+}
@@ -0,0 +1,397 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compose
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+const (
+	// LabelScopeService marks labels set on the compose services
+	LabelScopeService = "service"
+	// LabelScopeVolume marks labels set on the top-level compose volumes
+	LabelScopeVolume = "volume"
+)
+
+// LabelDirective describes a kompose label of the compose file that drives the conversion
+type LabelDirective struct {
+	// Key of the label, or the prefix of the key if Prefix is set
+	Key    string
+	Prefix bool
+	// Scopes lists where the label is read from, see the LabelScope constants
+	Scopes      []string
+	Description string
+	// Types are the JSON types of the value, string if empty
+	Types []string
+	// Enum lists the valid values, Pattern is a regular expression matching them
+	Enum    []string
+	Pattern string
+}
+
+// LabelDirectives lists all kompose labels the loader understands
+var LabelDirectives = []LabelDirective{
+	{
+		Key:         LabelServiceType,
+		Scopes:      []string{LabelScopeService},
+		Description: "Type of the Service to create",
+		Pattern:     anyCase("nodeport", "clusterip", "loadbalancer", "headless"),
+	},
+	{
+		Key:         LabelServiceExpose,
+		Scopes:      []string{LabelScopeService},
+		Description: "Make the service reachable from outside the cluster with an Ingress (Kubernetes) or Route (OpenShift), true or hostnames separated by comma",
+		Types:       []string{"string", "boolean"},
+	},
+	{
+		Key:         LabelServiceExposeTLSSecret,
+		Scopes:      []string{LabelScopeService},
+		Description: "Name of the TLS secret of the Ingress, requires " + LabelServiceExpose,
+	},
+	{
+		Key:         LabelNodePortPort,
+		Scopes:      []string{LabelScopeService},
+		Description: "Port of a NodePort Service, requires " + LabelServiceType + " nodeport and a single port",
+		Types:       []string{"string", "integer"},
+		Pattern:     "^[0-9]+$",
+	},
+	{
+		Key:         LabelServiceInternalTrafficPolicy,
+		Scopes:      []string{LabelScopeService},
+		Description: "Route the traffic of the Service to the pods of the node of the client only (local) or to all pods (cluster)",
+		Pattern:     anyCase("cluster", "local"),
+	},
+	{
+		Key:         LabelServiceTopologyAwareHints,
+		Scopes:      []string{LabelScopeService},
+		Description: "Prefer the endpoints of the zone of the client with topology aware hints, auto or disabled",
+		Types:       []string{"string", "boolean"},
+		Pattern:     anyCase("auto", "disabled", "true", "false"),
+	},
+	{
+		Key:         LabelServiceSplitPorts,
+		Scopes:      []string{LabelScopeService},
+		Description: "Create a Service named <service>-<port> for every port instead of one Service with all ports, like --split-service-ports",
+		Types:       []string{"string", "boolean"},
+		Pattern:     anyCase("true", "false"),
+	},
+	{
+		Key:         LabelControllerType,
+		Scopes:      []string{LabelScopeService},
+		Description: "Kind of controller to create, overriding --controller",
+		Enum:        []string{"deployment", "daemonset", "replicationcontroller", "statefulset"},
+	},
+	{
+		Key:         LabelImagePullPolicy,
+		Scopes:      []string{LabelScopeService},
+		Description: "imagePullPolicy of the container",
+		Enum:        []string{"Always", "IfNotPresent", "Never"},
+	},
+	{
+		Key:         LabelTerminationMessagePolicy,
+		Scopes:      []string{LabelScopeService},
+		Description: "terminationMessagePolicy of the container, fallback-to-logs-on-error reports the end of the log when the termination message file is empty",
+		Pattern:     anyCase("file", "fallbacktologsonerror", "fallback-to-logs-on-error"),
+	},
+	{
+		Key:         LabelTerminationMessagePath,
+		Scopes:      []string{LabelScopeService},
+		Description: "Absolute path of the file the container writes its termination message to, /dev/termination-log by default",
+		Pattern:     "^/",
+	},
+	{
+		Key:         LabelJobTTLSecondsAfterFinished,
+		Scopes:      []string{LabelScopeService},
+		Description: "Seconds after which the Job of a service that finished is deleted with its pods, the service is converted to a Job",
+		Types:       []string{"string", "integer"},
+		Pattern:     "^[0-9]+$",
+	},
+	{
+		Key:         LabelFSGroup,
+		Scopes:      []string{LabelScopeService},
+		Description: "fsGroup of the pods, the gid the volumes are made writable for, overriding --fs-group-from-user",
+		Types:       []string{"string", "integer"},
+		Pattern:     "^[0-9]+$",
+	},
+	{
+		Key:         LabelHPAMinReplicas,
+		Scopes:      []string{LabelScopeService},
+		Description: "Minimum replicas of the HorizontalPodAutoscaler of the service, the replicas of the service by default",
+		Types:       []string{"string", "integer"},
+		Pattern:     "^[1-9][0-9]*$",
+	},
+	{
+		Key:         LabelHPAMaxReplicas,
+		Scopes:      []string{LabelScopeService},
+		Description: "Maximum replicas of the HorizontalPodAutoscaler of the service, which is created when it is set",
+		Types:       []string{"string", "integer"},
+		Pattern:     "^[1-9][0-9]*$",
+	},
+	{
+		Key:         LabelHPACPU,
+		Scopes:      []string{LabelScopeService},
+		Description: "Target average CPU utilization of the HorizontalPodAutoscaler, in percent of the CPU requests, 50 when no target is set",
+		Types:       []string{"string", "integer"},
+		Pattern:     "^[1-9][0-9]*$",
+	},
+	{
+		Key:         LabelHPAMemory,
+		Scopes:      []string{LabelScopeService},
+		Description: "Target average memory utilization of the HorizontalPodAutoscaler, in percent of the memory requests",
+		Types:       []string{"string", "integer"},
+		Pattern:     "^[1-9][0-9]*$",
+	},
+	{
+		Key:         LabelRuntimeClass,
+		Scopes:      []string{LabelScopeService},
+		Description: "Name of the RuntimeClass the pods run with, like gvisor, kata or nvidia",
+		Pattern:     "^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$",
+	},
+	{
+		Key:         LabelServiceAccountAutomount,
+		Scopes:      []string{LabelScopeService},
+		Description: "Whether the token of the API server is mounted into the pods, the default of the service account when not set",
+		Types:       []string{"string", "boolean"},
+		Pattern:     anyCase("true", "false"),
+	},
+	{
+		Key:         LabelServiceAccountTokenAudience,
+		Scopes:      []string{LabelScopeService},
+		Description: "Audience of a service account token projected into the pods, like the one of a cloud IAM",
+	},
+	{
+		Key:         LabelServiceAccountTokenExpiration,
+		Scopes:      []string{LabelScopeService},
+		Description: "Seconds the projected service account token is valid, at least 600, an hour by default",
+		Types:       []string{"string", "integer"},
+		Pattern:     "^[0-9]+$",
+	},
+	{
+		Key:         LabelServiceAccountTokenPath,
+		Scopes:      []string{LabelScopeService},
+		Description: "Directory the token file of the projected service account token is mounted at, /var/run/secrets/tokens by default",
+		Pattern:     "^/",
+	},
+	{
+		Key:         LabelHugePages2Mi,
+		Scopes:      []string{LabelScopeService},
+		Description: "Quantity of 2Mi huge pages the pods request and mount, like 512Mi",
+		Pattern:     "^[0-9]+(Ki|Mi|Gi|Ti|M|G|T)?$",
+	},
+	{
+		Key:         LabelHugePages1Gi,
+		Scopes:      []string{LabelScopeService},
+		Description: "Quantity of 1Gi huge pages the pods request and mount, like 2Gi",
+		Pattern:     "^[0-9]+(Ki|Mi|Gi|Ti|M|G|T)?$",
+	},
+	{
+		Key:         LabelHugePagesPath,
+		Scopes:      []string{LabelScopeService},
+		Description: "Directory the huge pages are mounted at, /dev/hugepages by default",
+		Pattern:     "^/",
+	},
+	{
+		Key:         LabelImagePullSecret,
+		Scopes:      []string{LabelScopeService},
+		Description: "Name of the secret used as imagePullSecrets",
+	},
+	{
+		Key:         LabelDeploymentConfigStrategy,
+		Scopes:      []string{LabelScopeService},
+		Description: "Strategy of the OpenShift DeploymentConfig",
+		Pattern:     anyCase("rolling", "recreate", "custom"),
+	},
+	{
+		Key:         LabelDeploymentConfigStrategyImage,
+		Scopes:      []string{LabelScopeService},
+		Description: "Image carrying out the custom strategy of the OpenShift DeploymentConfig",
+	},
+	{
+		Key:         LabelDeploymentConfigPreHook,
+		Scopes:      []string{LabelScopeService},
+		Description: "Shell command run in a new pod of the service before the OpenShift DeploymentConfig strategy starts",
+	},
+	{
+		Key:         LabelDeploymentConfigMidHook,
+		Scopes:      []string{LabelScopeService},
+		Description: "Shell command run in a new pod of the service once the recreate strategy scaled the old pods down",
+	},
+	{
+		Key:         LabelDeploymentConfigPostHook,
+		Scopes:      []string{LabelScopeService},
+		Description: "Shell command run in a new pod of the service after the OpenShift DeploymentConfig strategy finished",
+	},
+	{
+		Key:         LabelDeploymentConfigHookFailurePolicy,
+		Scopes:      []string{LabelScopeService},
+		Description: "What happens when a hook of the OpenShift DeploymentConfig fails, abort by default",
+		Pattern:     anyCase("abort", "retry", "ignore"),
+	},
+	{
+		Key:         LabelVolumeSize,
+		Scopes:      []string{LabelScopeService, LabelScopeVolume},
+		Description: "Requested size of the PersistentVolumeClaims",
+		Pattern:     `^[+-]?[0-9.]+[eEinumkKMGTP]*[-+]?[0-9]*$`,
+	},
+	{
+		Key:         LabelVolumeSelector,
+		Scopes:      []string{LabelScopeVolume},
+		Description: "Value of the io.kompose.volume label the PersistentVolumeClaim selects its PersistentVolume by",
+	},
+	{
+		Key:         LabelVolumeTLS,
+		Scopes:      []string{LabelScopeService},
+		Description: "Host paths of bind mounts separated by comma, whose certificates and keys are packaged into a Secret mounted in their place",
+	},
+	{
+		Key:         LabelServiceAnnotationPrefix,
+		Prefix:      true,
+		Scopes:      []string{LabelScopeService},
+		Description: "Annotation added to the Service objects only",
+	},
+	{
+		Key:         LabelPodAnnotationPrefix,
+		Prefix:      true,
+		Scopes:      []string{LabelScopeService},
+		Description: "Annotation added to the pod template only",
+	},
+	{
+		Key:         LabelPortNamePrefix,
+		Prefix:      true,
+		Scopes:      []string{LabelScopeService},
+		Description: "Name of the container port given by the key, e.g. " + LabelPortNamePrefix + "8080: http",
+		Pattern:     "^[a-z0-9]([a-z0-9-]{0,13}[a-z0-9])?$",
+	},
+	{
+		Key:         LabelExternalLinkPrefix,
+		Prefix:      true,
+		Scopes:      []string{LabelScopeService},
+		Description: "DNS name of the host reached by the name of external_links given by the key, e.g. " + LabelExternalLinkPrefix + "db: db.example.com",
+	},
+}
+
+// anyCase returns a pattern matching any of values regardless of their case
+func anyCase(values ...string) string {
+	var alternatives []string
+	for _, value := range values {
+		var b strings.Builder
+		for _, c := range value {
+			if upper := strings.ToUpper(string(c)); upper != string(c) {
+				b.WriteString("[" + string(c) + upper + "]")
+			} else {
+				b.WriteRune(c)
+			}
+		}
+		alternatives = append(alternatives, b.String())
+	}
+	return "^(" + strings.Join(alternatives, "|") + ")$"
+}
+
+// LabelSchema returns a JSON Schema of the labels in LabelDirectives, which validates the
+// labels of the services and top-level volumes of a compose file
+func LabelSchema() ([]byte, error) {
+	return json.MarshalIndent(map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"title":       "kompose labels",
+		"description": "Labels of the compose file that drive the conversion by kompose",
+		"type":        "object",
+		"properties": map[string]interface{}{
+			"services": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": labelsProperty(LabelScopeService, "object"),
+			},
+			"volumes": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": labelsProperty(LabelScopeVolume, "object", "null"),
+			},
+		},
+		"definitions": map[string]interface{}{
+			LabelScopeService + "_labels": labelsSchema(LabelScopeService),
+			LabelScopeVolume + "_labels":  labelsSchema(LabelScopeVolume),
+		},
+	}, "", "  ")
+}
+
+// labelsProperty returns the schema of a service or volume, whose labels are described by the definition of scope
+func labelsProperty(scope string, types ...string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": types,
+		"properties": map[string]interface{}{
+			"labels": map[string]interface{}{
+				"$ref": "#/definitions/" + scope + "_labels",
+			},
+		},
+	}
+}
+
+// labelsSchema returns the schema of the labels of scope, in both the mapping and the
+// list syntax of compose
+func labelsSchema(scope string) map[string]interface{} {
+	properties := map[string]interface{}{}
+	patternProperties := map[string]interface{}{}
+	for _, directive := range LabelDirectives {
+		if !hasScope(directive, scope) {
+			continue
+		}
+		if directive.Prefix {
+			patternProperties["^"+regexp.QuoteMeta(directive.Key)+".+$"] = valueSchema(directive)
+		} else {
+			properties[directive.Key] = valueSchema(directive)
+		}
+	}
+	return map[string]interface{}{
+		"oneOf": []interface{}{
+			map[string]interface{}{
+				"type":              "object",
+				"properties":        properties,
+				"patternProperties": patternProperties,
+			},
+			map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "string"},
+			},
+		},
+	}
+}
+
+// valueSchema returns the schema of the value of a label
+func valueSchema(directive LabelDirective) map[string]interface{} {
+	schema := map[string]interface{}{
+		"description": directive.Description,
+		"type":        "string",
+	}
+	if len(directive.Types) > 0 {
+		schema["type"] = directive.Types
+	}
+	if len(directive.Enum) > 0 {
+		schema["enum"] = directive.Enum
+	}
+	if directive.Pattern != "" {
+		schema["pattern"] = directive.Pattern
+	}
+	return schema
+}
+
+func hasScope(directive LabelDirective, scope string) bool {
+	for _, s := range directive.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
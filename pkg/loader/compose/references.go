@@ -0,0 +1,99 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compose
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ExternalReferences returns the keys of a compose file that make its conversion read files or
+// variables of the machine converting it: include, env_file, the extends of other files, the file
+// and environment of the top-level secrets and configs, and the kompose.volume.tls label reading
+// the files of bind mounts. kompose serve and operator convert the compose files of their clients,
+// which must not get the files of the server, so they refuse the compose files referring to any.
+// The references are found before interpolation, so variables can't hide them, and the compose files
+// are interpolated with an isolated environment, see Compose.IsolatedEnvironment.
+func ExternalReferences(data []byte) ([]string, error) {
+	documents, err := splitDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+	var references []string
+	for _, document := range documents {
+		var composeFile map[string]interface{}
+		if err := yaml.Unmarshal(document, &composeFile); err != nil {
+			return nil, err
+		}
+		if _, ok := composeFile["include"]; ok {
+			references = append(references, "include")
+		}
+		for _, kind := range []string{"secrets", "configs"} {
+			for name, value := range mapping(composeFile[kind]) {
+				for _, key := range []string{"file", "environment"} {
+					if _, ok := mapping(value)[key]; ok {
+						references = append(references, fmt.Sprintf("%s.%s.%s", kind, name, key))
+					}
+				}
+			}
+		}
+		for name, value := range mapping(composeFile["services"]) {
+			service := mapping(value)
+			if _, ok := service["env_file"]; ok {
+				references = append(references, fmt.Sprintf("services.%s.env_file", name))
+			}
+			if _, ok := mapping(service["extends"])["file"]; ok {
+				references = append(references, fmt.Sprintf("services.%s.extends.file", name))
+			}
+			if hasLabel(service["labels"], LabelVolumeTLS) {
+				references = append(references, fmt.Sprintf("services.%s.labels.%s", name, LabelVolumeTLS))
+			}
+		}
+	}
+	sort.Strings(references)
+	return references, nil
+}
+
+// mapping returns value as a mapping with string keys, or nil if it isn't one
+func mapping(value interface{}) map[string]interface{} {
+	m, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil
+	}
+	result := make(map[string]interface{}, len(m))
+	for key, v := range m {
+		result[fmt.Sprint(key)] = v
+	}
+	return result
+}
+
+// hasLabel returns whether labels, a mapping or a list of key=value, holds the label key
+func hasLabel(labels interface{}, key string) bool {
+	if _, ok := mapping(labels)[key]; ok {
+		return true
+	}
+	list, _ := labels.([]interface{})
+	for _, label := range list {
+		if strings.SplitN(fmt.Sprint(label), "=", 2)[0] == key {
+			return true
+		}
+	}
+	return false
+}
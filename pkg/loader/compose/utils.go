@@ -17,14 +17,19 @@ limitations under the License.
 package compose
 
 import (
+	"bytes"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/kubernetes/kompose/pkg/kobject"
 	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
 
 	api "k8s.io/api/core/v1"
 )
@@ -34,6 +39,12 @@ const (
 	LabelServiceType = "kompose.service.type"
 	// LabelNodePortPort defines the port value for NodePort service
 	LabelNodePortPort = "kompose.service.nodeport.port"
+	// LabelServiceInternalTrafficPolicy defines if the Service routes traffic to the pods of the node of the client only
+	LabelServiceInternalTrafficPolicy = "kompose.service.internal-traffic-policy"
+	// LabelServiceTopologyAwareHints defines if the Service prefers the endpoints of the zone of the client
+	LabelServiceTopologyAwareHints = "kompose.service.topology-aware-hints"
+	// LabelServiceSplitPorts defines if a Service named <service>-<port> is created for every port of the service
+	LabelServiceSplitPorts = "kompose.service.split-ports"
 	// LabelServiceExpose defines if the service needs to be made accessible from outside the cluster or not
 	LabelServiceExpose = "kompose.service.expose"
 	// LabelServiceExposeTLSSecret  provides the name of the TLS secret to use with the Kubernetes ingress controller
@@ -44,13 +55,95 @@ const (
 	LabelImagePullSecret = "kompose.image-pull-secret"
 	// LabelImagePullPolicy defines Kubernetes PodSpec imagePullPolicy.
 	LabelImagePullPolicy = "kompose.image-pull-policy"
+	// LabelTerminationMessagePolicy defines Kubernetes Container terminationMessagePolicy: file or fallback-to-logs-on-error
+	LabelTerminationMessagePolicy = "kompose.termination-message-policy"
+	// LabelTerminationMessagePath defines the absolute path of the file the container writes its termination message to
+	LabelTerminationMessagePath = "kompose.termination-message-path"
+	// LabelJobTTLSecondsAfterFinished defines the seconds after which the Job of a service that finished is deleted
+	LabelJobTTLSecondsAfterFinished = "kompose.job.ttl-seconds-after-finished"
+	// LabelFSGroup defines the Kubernetes PodSecurityContext fsGroup, the group owning the volumes of the pods
+	LabelFSGroup = "kompose.fsgroup"
+	// LabelHPAMinReplicas defines the minimum replicas of the HorizontalPodAutoscaler of the service
+	LabelHPAMinReplicas = "kompose.hpa.replicas.min"
+	// LabelHPAMaxReplicas defines the maximum replicas of the HorizontalPodAutoscaler, which is created when it is set
+	LabelHPAMaxReplicas = "kompose.hpa.replicas.max"
+	// LabelHPACPU defines the target average CPU utilization of the HorizontalPodAutoscaler, in percent of the requests
+	LabelHPACPU = "kompose.hpa.cpu"
+	// LabelHPAMemory defines the target average memory utilization of the HorizontalPodAutoscaler, in percent of the requests
+	LabelHPAMemory = "kompose.hpa.memory"
+	// LabelRuntimeClass defines the Kubernetes PodSpec runtimeClassName, the container runtime of the pods like gVisor or Kata
+	LabelRuntimeClass = "kompose.pod.runtime-class"
+	// LabelServiceAccountAutomount defines whether the token of the API server is mounted into the pods
+	LabelServiceAccountAutomount = "kompose.serviceaccount.automount"
+	// LabelServiceAccountTokenAudience defines the audience of the service account token projected into the pods
+	LabelServiceAccountTokenAudience = "kompose.serviceaccount.token.audience"
+	// LabelServiceAccountTokenExpiration defines the seconds the projected service account token is valid
+	LabelServiceAccountTokenExpiration = "kompose.serviceaccount.token.expiration"
+	// LabelServiceAccountTokenPath defines the directory the projected service account token is mounted at
+	LabelServiceAccountTokenPath = "kompose.serviceaccount.token.path"
+	// LabelHugePages2Mi defines the quantity of 2Mi huge pages the pods request and mount
+	LabelHugePages2Mi = "kompose.hugepages.2Mi"
+	// LabelHugePages1Gi defines the quantity of 1Gi huge pages the pods request and mount
+	LabelHugePages1Gi = "kompose.hugepages.1Gi"
+	// LabelHugePagesPath defines the directory the huge pages are mounted at
+	LabelHugePagesPath = "kompose.hugepages.path"
+	// LabelServiceAnnotationPrefix prefixes annotations that are only added to the Service objects
+	LabelServiceAnnotationPrefix = "kompose.service.annotation."
+	// LabelPodAnnotationPrefix prefixes annotations that are only added to the pod template
+	LabelPodAnnotationPrefix = "kompose.pod.annotation."
+	// LabelDeploymentConfigStrategy defines the strategy of the OpenShift DeploymentConfig: rolling, recreate or custom
+	LabelDeploymentConfigStrategy = "kompose.deploymentconfig.strategy"
+	// LabelDeploymentConfigStrategyImage defines the image carrying out the custom DeploymentConfig strategy
+	LabelDeploymentConfigStrategyImage = "kompose.deploymentconfig.strategy.image"
+	// LabelDeploymentConfigPreHook defines the command run before the DeploymentConfig strategy starts
+	LabelDeploymentConfigPreHook = "kompose.deploymentconfig.hook.pre"
+	// LabelDeploymentConfigMidHook defines the command run while the recreate strategy scaled the old pods down
+	LabelDeploymentConfigMidHook = "kompose.deploymentconfig.hook.mid"
+	// LabelDeploymentConfigPostHook defines the command run after the DeploymentConfig strategy finished
+	LabelDeploymentConfigPostHook = "kompose.deploymentconfig.hook.post"
+	// LabelDeploymentConfigHookFailurePolicy defines what happens when a hook fails: abort, retry or ignore
+	LabelDeploymentConfigHookFailurePolicy = "kompose.deploymentconfig.hook.failure-policy"
+	// LabelVolumeSize defines the requested size of the PersistentVolumeClaims
+	LabelVolumeSize = "kompose.volume.size"
+	// LabelVolumeSelector defines the io.kompose.volume label value the PersistentVolumeClaim selects
+	LabelVolumeSelector = "kompose.volume.selector"
+	// LabelVolumeTLS lists the host paths of bind mounts, separated by comma, whose PEM files are packaged into a Secret
+	LabelVolumeTLS = "kompose.volume.tls"
+	// LabelPortNamePrefix prefixes the container port whose name is given as value, e.g. kompose.port.name.8080: http
+	LabelPortNamePrefix = "kompose.port.name."
+	// LabelExternalLinkPrefix prefixes the name of an external_links container whose host is given as value, e.g. kompose.external-link.db: db.example.com
+	LabelExternalLinkPrefix = "kompose.external-link."
+
+	// AnnotationMemoryPrefix prefixes the annotations keeping memory settings that have no Kubernetes equivalent
+	AnnotationMemoryPrefix = "kompose.memory."
+	// AnnotationUsernsMode keeps the userns_mode of a service, which has no Kubernetes equivalent
+	AnnotationUsernsMode = "kompose.userns_mode"
+
+	// extraGroupAdd keeps the group_add of a version 3 service, which docker/cli doesn't know, in its extras
+	extraGroupAdd = "x-kompose-group-add"
 
 	// ServiceTypeHeadless ...
 	ServiceTypeHeadless = "Headless"
 )
 
-// load environment variables from compose file
-func loadEnvVars(envars []string) []kobject.EnvVar {
+// loadAnnotations converts compose labels to annotations, leaving out the labels
+// that are only meant for the Service objects or the pod template
+func loadAnnotations(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+	annotations := make(map[string]string)
+	for key, value := range labels {
+		if strings.HasPrefix(key, LabelServiceAnnotationPrefix) || strings.HasPrefix(key, LabelPodAnnotationPrefix) {
+			continue
+		}
+		annotations[key] = value
+	}
+	return annotations
+}
+
+// load environment variables from compose file, looking up the ones without a value with lookupEnv
+func loadEnvVars(envars []string, lookupEnv func(string) (string, bool)) []kobject.EnvVar {
 	envs := []kobject.EnvVar{}
 	for _, e := range envars {
 		character := ""
@@ -72,15 +165,16 @@ func loadEnvVars(envars []string) []kobject.EnvVar {
 		}
 
 		if character == "" {
+			value, _ := lookupEnv(e)
 			envs = append(envs, kobject.EnvVar{
 				Name:  e,
-				Value: os.Getenv(e),
+				Value: value,
 			})
 		} else {
 			values := strings.SplitN(e, character, 2)
 			// try to get value from os env
 			if values[1] == "" {
-				values[1] = os.Getenv(values[0])
+				values[1], _ = lookupEnv(values[0])
 			}
 			envs = append(envs, kobject.EnvVar{
 				Name:  values[0],
@@ -121,6 +215,42 @@ func handleServiceType(ServiceType string) (string, error) {
 	}
 }
 
+// handleTrafficPolicy validates the value of the kompose.service.internal-traffic-policy label
+func handleTrafficPolicy(policy string) (string, error) {
+	switch strings.ToLower(policy) {
+	case "cluster":
+		return "Cluster", nil
+	case "local":
+		return "Local", nil
+	default:
+		return "", errors.New("Unknown value " + policy + " , supported values are 'cluster or local'")
+	}
+}
+
+// handleTopologyHints validates the value of the kompose.service.topology-aware-hints label
+func handleTopologyHints(hints string) (string, error) {
+	switch strings.ToLower(hints) {
+	case "auto", "true":
+		return "auto", nil
+	case "disabled", "false":
+		return "disabled", nil
+	default:
+		return "", errors.New("Unknown value " + hints + " , supported values are 'auto or disabled'")
+	}
+}
+
+// handleTerminationMessagePolicy validates the value of the kompose.termination-message-policy label
+func handleTerminationMessagePolicy(policy string) (string, error) {
+	switch strings.ToLower(policy) {
+	case "file":
+		return string(api.TerminationMessageReadFile), nil
+	case "fallbacktologsonerror", "fallback-to-logs-on-error":
+		return string(api.TerminationMessageFallbackToLogsOnError), nil
+	default:
+		return "", errors.New("Unknown value " + policy + " , supported values are 'file or fallback-to-logs-on-error'")
+	}
+}
+
 func normalizeContainerNames(svcName string) string {
 	return strings.ToLower(svcName)
 }
@@ -130,6 +260,46 @@ func normalizeServiceNames(svcName string) string {
 	return strings.ToLower(re.ReplaceAllString(svcName, "-"))
 }
 
+// loadDependsOn normalizes the names of the services a service depends on
+func loadDependsOn(dependsOn []string) []string {
+	var services []string
+	for _, name := range dependsOn {
+		services = append(services, normalizeServiceNames(name))
+	}
+	return services
+}
+
+// loadNetworkAliases normalizes the network aliases of a service like the service names, dropping
+// duplicates and aliases that are the name of the service
+func loadNetworkAliases(name string, aliases []string) []string {
+	var names []string
+	seen := map[string]bool{normalizeServiceNames(name): true}
+	for _, alias := range aliases {
+		normalized := normalizeServiceNames(alias)
+		if seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		if strings.Contains(alias, ".") {
+			log.WithFields(log.Fields{"service": name, "category": "networking"}).Warnf("Network alias %q is converted to the Service %q, Service names can't contain dots", alias, normalized)
+		}
+		names = append(names, normalized)
+	}
+	return names
+}
+
+// unusedNames returns the sorted names of declared that are not in used
+func unusedNames(declared []string, used map[string]bool) []string {
+	var names []string
+	for _, name := range declared {
+		if !used[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 func normalizeVolumes(svcName string) string {
 	return strings.Replace(svcName, "_", "-", -1)
 }
@@ -145,16 +315,91 @@ func normalizeNetworkNames(netName string) (string, error) {
 	return netval, nil
 }
 
-// ReadFile read data from file or stdin
-func ReadFile(fileName string) ([]byte, error) {
+// readFile read data from file or stdin, decrypted if it is encrypted with sops
+// Windows line endings are converted, so that no "\r" ends up in the parsed values
+func (c *Compose) readFile(fileName string) ([]byte, error) {
 	if fileName == "-" {
-		if StdinData == nil {
-			data, err := ioutil.ReadAll(os.Stdin)
-			StdinData = data
-			return data, err
+		data, err := readStdin()
+		if err != nil {
+			return nil, err
 		}
-		return StdinData, nil
+		data, err = c.SOPS.Decrypt("the compose file of stdin", data)
+		return normalizeLineEndings(data), err
 	}
-	return ioutil.ReadFile(fileName)
+	data, err := c.SOPS.ReadFile(fileName)
+	return normalizeLineEndings(data), err
+}
+
+// readStdin returns the compose file of stdin, which is read once. An encrypted file is kept
+// encrypted, it is decrypted by every read.
+func readStdin() ([]byte, error) {
+	stdinLock.Lock()
+	defer stdinLock.Unlock()
+	if StdinData == nil {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		StdinData = data
+	}
+	return StdinData, nil
+}
+
+// splitDocuments returns the YAML documents of a compose file. Some generators write compose files
+// of several documents, which are merged in order like compose files given with several --file.
+// The empty documents are left out, and a file of a single document is returned as it is. Every
+// document keeps its lines in the file, the lines before it are left empty, so the errors of its
+// parsing give the lines of the file.
+func splitDocuments(data []byte) ([][]byte, error) {
+	var documents [][]byte
+	var document []byte
+	// start is the number of lines before the current document
+	lines, start := 0, 0
+	add := func() error {
+		document = append(bytes.Repeat([]byte("\n"), start), document...)
+		var value interface{}
+		if err := yaml.Unmarshal(document, &value); err != nil {
+			return err
+		}
+		if value != nil {
+			documents = append(documents, document)
+		}
+		return nil
+	}
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		lines++
+		// the markers at the start of a line start or end a document, the content of a
+		// document can't start a line with them
+		marker := bytes.TrimRight(line, "\r\n")
+		if bytes.Equal(marker, []byte("---")) || bytes.Equal(marker, []byte("...")) {
+			if err := add(); err != nil {
+				return nil, err
+			}
+			document, start = nil, lines
+			continue
+		}
+		document = append(document, line...)
+	}
+	if err := add(); err != nil {
+		return nil, err
+	}
+	if len(documents) <= 1 {
+		return [][]byte{data}, nil
+	}
+	return documents, nil
+}
+
+// setDocumentVersion sets the version of a document of splitDocuments, which has none, on the
+// last of its empty lines, so the document keeps the lines of the file
+func setDocumentVersion(document []byte, version string) []byte {
+	line := []byte(fmt.Sprintf("version: %q", version))
+	if !bytes.HasPrefix(document, []byte("\n")) {
+		return append(append(line, '\n'), document...)
+	}
+	empty := len(document) - len(bytes.TrimLeft(document, "\n"))
+	return append(append(append([]byte{}, document[:empty-1]...), line...), document[empty-1:]...)
+}
 
+func normalizeLineEndings(data []byte) []byte {
+	return bytes.Replace(data, []byte("\r\n"), []byte("\n"), -1)
 }
@@ -24,7 +24,9 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/docker/cli/cli/compose/types"
 	"github.com/docker/cli/opts"
 	"github.com/docker/go-connections/nat"
 	"github.com/docker/libcompose/config"
@@ -35,22 +37,72 @@ import (
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cast"
+	"gopkg.in/yaml.v2"
 	api "k8s.io/api/core/v1"
 )
 
 // Parse Docker Compose with libcompose (only supports v1 and v2). Eventually we will
 // switch to using only libcompose once v3 is supported.
-func parseV1V2(files []string) (kobject.KomposeObject, error) {
+func (c *Compose) parseV1V2(files []string) (kobject.KomposeObject, error) {
 
 	// Gather the appropriate context for parsing
 	context := &project.Context{}
-	context.ComposeFiles = files
+	memoryKeys := make(map[string]map[string]string)
+	healthChecks := make(map[string]types.HealthCheckConfig)
+	inits := make(map[string]bool)
+	for _, file := range files {
+		fileData, err := c.readFile(file)
+		if err != nil {
+			return kobject.KomposeObject{}, errors.Wrap(err, "Unable to read compose file")
+		}
+		// libcompose merges the documents of a file like the files, it takes the file of every
+		// document for resolving the paths relative to it
+		documents, err := splitDocuments(fileData)
+		if err != nil {
+			return kobject.KomposeObject{}, errors.Wrap(err, "Unable to read compose file")
+		}
+		var version string
+		for _, data := range documents {
+			// libcompose takes the documents without version for version 1, they take the version
+			// of the previous ones
+			var composeVersion struct {
+				Version string `yaml:"version"`
+			}
+			if err := yaml.Unmarshal(data, &composeVersion); err != nil {
+				return kobject.KomposeObject{}, errors.Wrap(err, "Unable to read compose file")
+			}
+			if composeVersion.Version != "" {
+				version = composeVersion.Version
+			} else if version != "" {
+				data = setDocumentVersion(data, version)
+			}
+			if err := readMemoryKeys(data, memoryKeys); err != nil {
+				return kobject.KomposeObject{}, errors.Wrap(err, "Unable to read compose file")
+			}
+			if err := c.readHealthChecks(file, data, healthChecks); err != nil {
+				return kobject.KomposeObject{}, errors.Wrap(err, "Unable to read healthchecks")
+			}
+			if err := readInits(data, inits); err != nil {
+				return kobject.KomposeObject{}, errors.Wrap(err, "Unable to read compose file")
+			}
+			// libcompose doesn't know these keys, so they are taken out before parsing
+			data, err = removeServiceKeys(data, unknownServiceKeys)
+			if err != nil {
+				return kobject.KomposeObject{}, errors.Wrap(err, "Unable to read compose file")
+			}
+			context.ComposeFiles = append(context.ComposeFiles, file)
+			context.ComposeBytes = append(context.ComposeBytes, data)
+		}
+	}
 
 	if context.ResourceLookup == nil {
-		context.ResourceLookup = &lookup.FileResourceLookup{}
+		context.ResourceLookup = &resourceLookup{&lookup.FileResourceLookup{}}
 	}
 
-	if context.EnvironmentLookup == nil {
+	if c.IsolatedEnvironment {
+		// neither the variables of the process nor its .env file
+		context.EnvironmentLookup = &lookup.ComposableEnvLookup{}
+	} else if context.EnvironmentLookup == nil {
 		cwd, err := os.Getwd()
 		if err != nil {
 			return kobject.KomposeObject{}, nil
@@ -74,11 +126,11 @@ func parseV1V2(files []string) (kobject.KomposeObject, error) {
 
 	noSupKeys := checkUnsupportedKey(composeObject)
 	for _, keyName := range noSupKeys {
-		log.Warningf("Unsupported %s key - ignoring", keyName)
+		log.WithField("category", "unsupported").Warningf("Unsupported %s key - ignoring", keyName)
 	}
 
 	// Map the parsed struct to a struct we understand (kobject)
-	komposeObject, err := libComposeToKomposeMapping(composeObject)
+	komposeObject, err := libComposeToKomposeMapping(composeObject, memoryKeys, healthChecks, inits, c.lookupEnv)
 	if err != nil {
 		return kobject.KomposeObject{}, err
 	}
@@ -86,6 +138,275 @@ func parseV1V2(files []string) (kobject.KomposeObject, error) {
 	return komposeObject, nil
 }
 
+// unknownServiceKeys are the keys of v2 services that libcompose doesn't know
+var unknownServiceKeys = []string{"kernel_memory", "healthcheck", "init"}
+
+// resourceLookup removes the unknownServiceKeys from the files that services extend
+type resourceLookup struct {
+	config.ResourceLookup
+}
+
+// Lookup returns the content of an extended file without the unknownServiceKeys
+func (r *resourceLookup) Lookup(file, relativeTo string) ([]byte, string, error) {
+	data, resolved, err := r.ResourceLookup.Lookup(file, relativeTo)
+	if err != nil {
+		return nil, resolved, err
+	}
+	data, err = removeServiceKeys(normalizeLineEndings(data), unknownServiceKeys)
+	return data, resolved, err
+}
+
+// loadServices returns the services of a compose file
+func loadServices(data []byte) (map[interface{}]interface{}, error) {
+	var composeFile map[string]interface{}
+	if err := yaml.Unmarshal(data, &composeFile); err != nil {
+		return nil, err
+	}
+	services, _ := composeFile["services"].(map[interface{}]interface{})
+	return services, nil
+}
+
+// removeServiceKeys removes the given keys from the services of a compose file
+func removeServiceKeys(data []byte, keys []string) ([]byte, error) {
+	var composeFile map[string]interface{}
+	if err := yaml.Unmarshal(data, &composeFile); err != nil {
+		return nil, err
+	}
+	services, ok := composeFile["services"].(map[interface{}]interface{})
+	if !ok {
+		return data, nil
+	}
+
+	removed := false
+	for _, service := range services {
+		serviceMap, ok := service.(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range keys {
+			if _, ok := serviceMap[key]; ok {
+				delete(serviceMap, key)
+				removed = true
+			}
+		}
+	}
+	if !removed {
+		return data, nil
+	}
+	return yaml.Marshal(composeFile)
+}
+
+// readMemoryKeys stores the values of memswap_limit, mem_swappiness and kernel_memory of the
+// services of a compose file as written in memoryKeys
+func readMemoryKeys(data []byte, memoryKeys map[string]map[string]string) error {
+	services, err := loadServices(data)
+	if err != nil {
+		return err
+	}
+
+	for name, service := range services {
+		serviceMap, ok := service.(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"memswap_limit", "mem_swappiness", "kernel_memory"} {
+			value, ok := serviceMap[key]
+			if !ok {
+				continue
+			}
+			if memoryKeys[fmt.Sprint(name)] == nil {
+				memoryKeys[fmt.Sprint(name)] = make(map[string]string)
+			}
+			memoryKeys[fmt.Sprint(name)][key] = fmt.Sprint(value)
+		}
+	}
+	return nil
+}
+
+// readInits stores the init key of the services of a compose file in inits, the files given
+// later overriding the earlier ones
+func readInits(data []byte, inits map[string]bool) error {
+	services, err := loadServices(data)
+	if err != nil {
+		return err
+	}
+
+	for name, service := range services {
+		serviceMap, ok := service.(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+		value, ok := serviceMap["init"]
+		if !ok {
+			continue
+		}
+		init, ok := value.(bool)
+		if !ok {
+			return errors.Errorf("init of service %q must be a boolean", name)
+		}
+		inits[fmt.Sprint(name)] = init
+	}
+	return nil
+}
+
+// readHealthChecks stores the healthchecks of the services of a compose file in healthChecks,
+// including the ones inherited from the services they extend
+func (c *Compose) readHealthChecks(file string, data []byte, healthChecks map[string]types.HealthCheckConfig) error {
+	services, err := loadServices(data)
+	if err != nil {
+		return err
+	}
+
+	for name := range services {
+		healthCheck, err := c.resolveHealthCheck(file, services, fmt.Sprint(name), 0)
+		if err != nil {
+			return errors.Wrapf(err, "service %q", name)
+		}
+		if healthCheck == nil {
+			continue
+		}
+		healthChecks[fmt.Sprint(name)], err = toHealthCheckConfig(healthCheck)
+		if err != nil {
+			return errors.Wrapf(err, "service %q", name)
+		}
+	}
+	return nil
+}
+
+// resolveHealthCheck returns the healthcheck of a service, with the keys it doesn't set taken from
+// the service it extends
+func (c *Compose) resolveHealthCheck(file string, services map[interface{}]interface{}, name string, depth int) (map[interface{}]interface{}, error) {
+	if depth > 10 {
+		return nil, errors.Errorf("too many levels of extends at service %q", name)
+	}
+	service, ok := services[name].(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.Errorf("unable to find service %q to extend in %s", name, file)
+	}
+	healthCheck, _ := service["healthcheck"].(map[interface{}]interface{})
+
+	extends, ok := service["extends"].(map[interface{}]interface{})
+	if !ok || extends["service"] == nil {
+		return healthCheck, nil
+	}
+	baseFile, baseServices := file, services
+	if extendsFile, ok := extends["file"].(string); ok {
+		baseFile = extendsFile
+		if !filepath.IsAbs(baseFile) {
+			baseFile = filepath.Join(filepath.Dir(file), baseFile)
+		}
+		data, err := c.readFile(baseFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read extended file")
+		}
+		baseServices, err = loadServices(data)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read extended file")
+		}
+	}
+	base, err := c.resolveHealthCheck(baseFile, baseServices, fmt.Sprint(extends["service"]), depth+1)
+	if err != nil || base == nil {
+		return healthCheck, err
+	}
+
+	merged := make(map[interface{}]interface{})
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range healthCheck {
+		merged[key] = value
+	}
+	return merged, nil
+}
+
+// toHealthCheckConfig converts a healthcheck of a compose file to the docker/cli representation
+// used by v3 files
+func toHealthCheckConfig(healthCheck map[interface{}]interface{}) (types.HealthCheckConfig, error) {
+	config := types.HealthCheckConfig{
+		Disable: cast.ToBool(healthCheck["disable"]),
+	}
+
+	switch test := healthCheck["test"].(type) {
+	case string:
+		config.Test = []string{"CMD-SHELL", test}
+	case []interface{}:
+		for _, arg := range test {
+			config.Test = append(config.Test, fmt.Sprint(arg))
+		}
+	}
+
+	durations := map[string]**types.Duration{
+		"interval":     &config.Interval,
+		"timeout":      &config.Timeout,
+		"start_period": &config.StartPeriod,
+	}
+	for key, target := range durations {
+		value, ok := healthCheck[key]
+		if !ok {
+			continue
+		}
+		duration, err := time.ParseDuration(fmt.Sprint(value))
+		if err != nil {
+			return config, errors.Wrapf(err, "invalid healthcheck %s", key)
+		}
+		*target = durationTypesPtr(duration)
+	}
+
+	if value, ok := healthCheck["retries"]; ok {
+		retries, err := cast.ToUint64E(value)
+		if err != nil {
+			return config, errors.Wrap(err, "invalid healthcheck retries")
+		}
+		config.Retries = &retries
+	}
+	return config, nil
+}
+
+func durationTypesPtr(value time.Duration) *types.Duration {
+	target := types.Duration(value)
+	return &target
+}
+
+// loadMemoryKeys maps memswap_limit, mem_swappiness and kernel_memory to the service.
+// Kubernetes doesn't use swap, so only a memswap_limit that equals mem_limit maps cleanly.
+// The values that have no equivalent are kept as annotations, as written in the compose file.
+func loadMemoryKeys(name string, composeServiceConfig *config.ServiceConfig, memoryKeys map[string]string, serviceConfig *kobject.ServiceConfig) {
+	var unsupported []string
+
+	memSwapLimit := int64(composeServiceConfig.MemSwapLimit)
+	// memswap_limit requires mem_limit, when both are equal the container doesn't swap like on Kubernetes
+	if memSwapLimit != 0 && memSwapLimit != int64(serviceConfig.MemLimit) {
+		unsupported = append(unsupported, "memswap_limit")
+	}
+	if _, ok := memoryKeys["mem_swappiness"]; ok || composeServiceConfig.MemSwappiness != 0 {
+		unsupported = append(unsupported, "mem_swappiness")
+	}
+	if _, ok := memoryKeys["kernel_memory"]; ok {
+		unsupported = append(unsupported, "kernel_memory")
+	}
+
+	for _, key := range unsupported {
+		value, ok := memoryKeys[key]
+		if !ok {
+			// the key was set in a file extended by the service, only the parsed value is known
+			parsed := map[string]int64{
+				"memswap_limit":  memSwapLimit,
+				"mem_swappiness": int64(composeServiceConfig.MemSwappiness),
+			}
+			value = strconv.FormatInt(parsed[key], 10)
+		}
+		log.WithFields(log.Fields{
+			"service":  name,
+			"value":    value,
+			"category": "unsupported",
+		}).Warnf("%s has no Kubernetes equivalent, keeping it as annotation %s", key, AnnotationMemoryPrefix+key)
+		if serviceConfig.Annotations == nil {
+			serviceConfig.Annotations = make(map[string]string)
+		}
+		serviceConfig.Annotations[AnnotationMemoryPrefix+key] = value
+	}
+}
+
 // Load ports from compose file
 // also load `expose` here
 func loadPorts(composePorts []string, expose []string) ([]kobject.Ports, error) {
@@ -167,13 +488,50 @@ func loadPorts(composePorts []string, expose []string) ([]kobject.Ports, error)
 	return kp, nil
 }
 
+// unusedV1V2Resources returns the top-level volumes and networks no service refers to,
+// v1 and v2 files have neither configs nor secrets
+func unusedV1V2Resources(composeObject *project.Project) kobject.UnusedResources {
+	volumes, networks := map[string]bool{}, map[string]bool{}
+	for _, service := range composeObject.ServiceConfigs.All() {
+		if service.Volumes != nil {
+			for _, volume := range service.Volumes.Volumes {
+				if volume.Source != "" && project.IsNamedVolume(volume.Source) {
+					volumes[volume.Source] = true
+				}
+			}
+		}
+		if service.Networks == nil || len(service.Networks.Networks) == 0 {
+			networks["default"] = true
+			continue
+		}
+		for _, network := range service.Networks.Networks {
+			networks[network.Name] = true
+		}
+	}
+
+	var declaredVolumes, declaredNetworks []string
+	for name := range composeObject.VolumeConfigs {
+		declaredVolumes = append(declaredVolumes, name)
+	}
+	for name := range composeObject.NetworkConfigs {
+		declaredNetworks = append(declaredNetworks, name)
+	}
+	return kobject.UnusedResources{
+		Volumes:  unusedNames(declaredVolumes, volumes),
+		Networks: unusedNames(declaredNetworks, networks),
+	}
+}
+
 // Uses libcompose's APIProject type and converts it to a Kompose object for us to understand
-func libComposeToKomposeMapping(composeObject *project.Project) (kobject.KomposeObject, error) {
+// memoryKeys, healthChecks and inits hold the keys libcompose doesn't parse by service name, see readMemoryKeys, readHealthChecks and readInits
+// lookupEnv looks up the environment variables of the services without a value
+func libComposeToKomposeMapping(composeObject *project.Project, memoryKeys map[string]map[string]string, healthChecks map[string]types.HealthCheckConfig, inits map[string]bool, lookupEnv func(string) (string, bool)) (kobject.KomposeObject, error) {
 
 	// Initialize what's going to be returned
 	komposeObject := kobject.KomposeObject{
 		ServiceConfigs: make(map[string]kobject.ServiceConfig),
 		LoadedFrom:     "compose",
+		Unused:         unusedV1V2Resources(composeObject),
 	}
 
 	// Here we "clean up" the service configuration so we return something that includes
@@ -195,12 +553,12 @@ func libComposeToKomposeMapping(composeObject *project.Project) (kobject.Kompose
 		serviceConfig.BuildArgs = composeServiceConfig.Build.Args
 		serviceConfig.Expose = composeServiceConfig.Expose
 
-		envs := loadEnvVars(composeServiceConfig.Environment)
+		envs := loadEnvVars(composeServiceConfig.Environment, lookupEnv)
 		serviceConfig.Environment = envs
 
 		// Validate dockerfile path
 		if filepath.IsAbs(serviceConfig.Dockerfile) {
-			log.Fatalf("%q defined in service %q is an absolute path, it must be a relative path.", serviceConfig.Dockerfile, name)
+			return kobject.KomposeObject{}, fmt.Errorf("%q defined in service %q is an absolute path, it must be a relative path", serviceConfig.Dockerfile, name)
 		}
 
 		// load ports, same as v3, we also load `expose`
@@ -232,36 +590,52 @@ func libComposeToKomposeMapping(composeObject *project.Project) (kobject.Kompose
 		}
 
 		// convert compose labels to annotations
-		serviceConfig.Annotations = map[string]string(composeServiceConfig.Labels)
+		serviceConfig.Annotations = loadAnnotations(composeServiceConfig.Labels)
 		serviceConfig.CPUQuota = int64(composeServiceConfig.CPUQuota)
+		serviceConfig.CPUSet = composeServiceConfig.CPUSet
 		serviceConfig.CapAdd = composeServiceConfig.CapAdd
 		serviceConfig.CapDrop = composeServiceConfig.CapDrop
 		serviceConfig.Pid = composeServiceConfig.Pid
 
 		serviceConfig.Privileged = composeServiceConfig.Privileged
+		serviceConfig.Init = inits[name]
 		serviceConfig.User = composeServiceConfig.User
 		serviceConfig.VolumesFrom = composeServiceConfig.VolumesFrom
+		serviceConfig.DependsOn = loadDependsOn(composeServiceConfig.DependsOn)
+		serviceConfig.ExtraHosts = composeServiceConfig.ExtraHosts
+		serviceConfig.ExternalLinks = composeServiceConfig.ExternalLinks
 		serviceConfig.Stdin = composeServiceConfig.StdinOpen
 		serviceConfig.Tty = composeServiceConfig.Tty
 		serviceConfig.MemLimit = composeServiceConfig.MemLimit
+		loadMemoryKeys(name, composeServiceConfig, memoryKeys[name], &serviceConfig)
+
+		if healthCheck, ok := healthChecks[name]; ok {
+			serviceConfig.HealthChecks, err = parseHealthCheck(healthCheck)
+			if err != nil {
+				return kobject.KomposeObject{}, errors.Wrap(err, "Unable to parse health check")
+			}
+		}
 		serviceConfig.TmpFs = composeServiceConfig.Tmpfs
 		serviceConfig.StopGracePeriod = composeServiceConfig.StopGracePeriod
 
 		// pretty much same as v3
 		serviceConfig.Restart = composeServiceConfig.Restart
 		if serviceConfig.Restart == "unless-stopped" {
-			log.Warnf("Restart policy 'unless-stopped' in service %s is not supported, convert it to 'always'", name)
+			log.WithFields(log.Fields{"service": name, "category": "unsupported"}).Warn("Restart policy 'unless-stopped' is not supported, convert it to 'always'")
 			serviceConfig.Restart = "always"
 		}
 
 		if composeServiceConfig.Networks != nil {
+			var aliases []string
 			if len(composeServiceConfig.Networks.Networks) > 0 {
 				for _, value := range composeServiceConfig.Networks.Networks {
 					if value.Name != "default" {
 						serviceConfig.Network = append(serviceConfig.Network, value.RealName)
 					}
+					aliases = append(aliases, value.Aliases...)
 				}
 			}
+			serviceConfig.NetworkAliases = loadNetworkAliases(name, aliases)
 		}
 		// Get GroupAdd, group should be mentioned in gid format but not the group name
 		groupAdd, err := getGroupAdd(composeServiceConfig.GroupAdd)
@@ -269,6 +643,7 @@ func libComposeToKomposeMapping(composeObject *project.Project) (kobject.Kompose
 			return kobject.KomposeObject{}, errors.Wrap(err, "GroupAdd should be mentioned in gid format, not a group name")
 		}
 		serviceConfig.GroupAdd = groupAdd
+		serviceConfig.ComposeName = name
 
 		komposeObject.ServiceConfigs[normalizeServiceNames(name)] = serviceConfig
 		if normalizeServiceNames(name) != name {
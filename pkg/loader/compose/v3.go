@@ -17,6 +17,10 @@ limitations under the License.
 package compose
 
 import (
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -26,6 +30,8 @@ import (
 	libcomposeyaml "github.com/docker/libcompose/yaml"
 
 	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	"github.com/docker/cli/cli/compose/loader"
 	"github.com/docker/cli/cli/compose/types"
@@ -35,13 +41,17 @@ import (
 	"fmt"
 
 	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/utils/sops"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
-// converts os.Environ() ([]string) to map[string]string
+// converts os.Environ() ([]string) to map[string]string, or returns an empty one if the environment is isolated
 // based on https://github.com/docker/cli/blob/5dd30732a23bbf14db1c64d084ae4a375f592cfa/cli/command/stack/deploy_composefile.go#L143
-func buildEnvironment() (map[string]string, error) {
+func (c *Compose) buildEnvironment() (map[string]string, error) {
+	if c.IsolatedEnvironment {
+		return map[string]string{}, nil
+	}
 	env := os.Environ()
 	result := make(map[string]string, len(env))
 	for _, s := range env {
@@ -58,7 +68,7 @@ func buildEnvironment() (map[string]string, error) {
 // The purpose of this is not to deploy, but to be able to parse
 // v3 of Docker Compose into a suitable format. In this case, whatever is returned
 // by docker/cli's ServiceConfig
-func parseV3(files []string) (kobject.KomposeObject, error) {
+func (c *Compose) parseV3(files []string) (kobject.KomposeObject, error) {
 
 	// In order to get V3 parsing to work, we have to go through some preliminary steps
 	// for us to hack up github.com/docker/cli in order to correctly convert to a kobject.KomposeObject
@@ -70,68 +80,236 @@ func parseV3(files []string) (kobject.KomposeObject, error) {
 	}
 
 	// get environment variables
-	env, err := buildEnvironment()
+	env, err := c.buildEnvironment()
 	if err != nil {
 		return kobject.KomposeObject{}, errors.Wrap(err, "cannot build environment variables")
 	}
 
 	var config *types.Config
 	for _, file := range files {
-		// Load and then parse the YAML first!
-		loadedFile, err := ReadFile(file)
+		currentConfig, err := c.loadV3File(file, workingDir, env, "", nil)
 		if err != nil {
 			return kobject.KomposeObject{}, err
 		}
+		if config == nil {
+			config = currentConfig
+		} else {
+			config, err = mergeComposeObject(config, currentConfig)
+			if err != nil {
+				return kobject.KomposeObject{}, err
+			}
+		}
+	}
 
+	noSupKeys := checkUnsupportedKeyForV3(config)
+	for _, keyName := range noSupKeys {
+		log.WithField("category", "unsupported").Warningf("Unsupported %s key - ignoring", keyName)
+	}
+
+	// Finally, we convert the object from docker/cli's ServiceConfig to our appropriate one
+	komposeObject, err := dockerComposeToKomposeMapping(config, c.lookupEnv)
+	if err != nil {
+		return kobject.KomposeObject{}, err
+	}
+
+	return komposeObject, nil
+}
+
+// loadV3File loads a compose file with the files it includes. The files of chain include it, and
+// the version of the file including it is taken for a file without version.
+func (c *Compose) loadV3File(file string, workingDir string, env map[string]string, version string, chain []string) (*types.Config, error) {
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get the absolute path of %s", file)
+	}
+	for _, f := range chain {
+		if f == absFile {
+			return nil, errors.Errorf("%s includes itself through %s", file, strings.Join(chain, " -> "))
+		}
+	}
+
+	// Load and then parse the YAML first!
+	loadedFile, err := c.readFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// docker/cli merges the documents of a file like the files
+	documents, err := splitDocuments(loadedFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var includes []composeInclude
+	var configFiles []types.ConfigFile
+	groupAdd := map[string]interface{}{}
+	encryptedEnvFiles := map[string][]string{}
+	for _, document := range documents {
 		// Parse the Compose File
-		parsedComposeFile, err := loader.ParseYAML(loadedFile)
+		parsedComposeFile, err := loader.ParseYAML(document)
 		if err != nil {
-			return kobject.KomposeObject{}, err
+			return nil, err
+		}
+
+		// docker/cli doesn't know the include key, so it is loaded here
+		if value, ok := parsedComposeFile["include"]; ok {
+			documentIncludes, err := parseInclude(value, filepath.Dir(file))
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid include in %s", file)
+			}
+			includes = append(includes, documentIncludes...)
+			delete(parsedComposeFile, "include")
+		}
+		// docker/cli doesn't know group_add either, it is kept in the extras of the services
+		for name, groups := range takeV3GroupAdd(parsedComposeFile) {
+			groupAdd[name] = groups
+		}
+		// docker/cli would read the sops encrypted env_files as they are, they are decrypted here
+		documentEnvFiles, err := takeV3EncryptedEnvFiles(parsedComposeFile, workingDir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid env_file in %s", file)
+		}
+		for name, envFiles := range documentEnvFiles {
+			encryptedEnvFiles[name] = envFiles
+		}
+		// the documents without version take the version of the previous ones
+		if value, ok := parsedComposeFile["version"]; ok {
+			version = fmt.Sprint(value)
+		} else if version != "" {
+			parsedComposeFile["version"] = version
 		}
 
 		// Config file
-		configFile := types.ConfigFile{
+		configFiles = append(configFiles, types.ConfigFile{
 			Filename: file,
 			Config:   parsedComposeFile,
+		})
+	}
+
+	// Config details
+	configDetails := types.ConfigDetails{
+		WorkingDir:  workingDir,
+		ConfigFiles: configFiles,
+		Environment: env,
+	}
+
+	// Actual config
+	// We load it in order to retrieve the parsed output configuration!
+	// This will output a github.com/docker/cli ServiceConfig
+	// Which is similar to our version of ServiceConfig
+	config, err := loader.Load(configDetails)
+	if err != nil {
+		return nil, err
+	}
+	for i, service := range config.Services {
+		if groups, ok := groupAdd[service.Name]; ok {
+			if service.Extras == nil {
+				config.Services[i].Extras = make(map[string]interface{})
+			}
+			config.Services[i].Extras[extraGroupAdd] = groups
+		}
+		// the transformers read the encrypted env_files into Secrets, so their variables are
+		// kept out of the environment, which ends up in plaintext in the manifests
+		if envFiles, ok := encryptedEnvFiles[service.Name]; ok {
+			config.Services[i].EnvFile = envFiles
 		}
+	}
 
-		// Config details
-		configDetails := types.ConfigDetails{
-			WorkingDir:  workingDir,
-			ConfigFiles: []types.ConfigFile{configFile},
-			Environment: env,
+	for _, include := range includes {
+		config, err = c.loadV3Include(config, include, workingDir, env, config.Version, append(chain, absFile))
+		if err != nil {
+			return nil, err
 		}
+	}
+	return config, nil
+}
 
-		// Actual config
-		// We load it in order to retrieve the parsed output configuration!
-		// This will output a github.com/docker/cli ServiceConfig
-		// Which is similar to our version of ServiceConfig
-		currentConfig, err := loader.Load(configDetails)
+// takeV3EncryptedEnvFiles removes the env_file keys of the services of a parsed compose file that
+// have an env_file encrypted with sops, and returns them by service
+func takeV3EncryptedEnvFiles(composeFile map[string]interface{}, workingDir string) (map[string][]string, error) {
+	encrypted := map[string][]string{}
+	services, _ := composeFile["services"].(map[string]interface{})
+	for name, service := range services {
+		s, ok := service.(map[string]interface{})
+		if !ok || s["env_file"] == nil {
+			continue
+		}
+		envFiles, err := stringOrList(s["env_file"])
 		if err != nil {
-			return kobject.KomposeObject{}, err
+			return nil, err
 		}
-		if config == nil {
-			config = currentConfig
-		} else {
-			config, err = mergeComposeObject(config, currentConfig)
+		for _, envFile := range envFiles {
+			data, err := ioutil.ReadFile(envFilePath(workingDir, envFile))
 			if err != nil {
-				return kobject.KomposeObject{}, err
+				// docker/cli reports it
+				continue
+			}
+			if sops.IsEncrypted(data) {
+				encrypted[name] = envFiles
+				delete(s, "env_file")
+				break
 			}
 		}
 	}
+	return encrypted, nil
+}
 
-	noSupKeys := checkUnsupportedKeyForV3(config)
-	for _, keyName := range noSupKeys {
-		log.Warningf("Unsupported %s key - ignoring", keyName)
+// envFilePath returns the path of an env_file, relative to workingDir
+func envFilePath(workingDir string, envFile string) string {
+	if filepath.IsAbs(envFile) {
+		return envFile
 	}
+	return filepath.Join(workingDir, envFile)
+}
 
-	// Finally, we convert the object from docker/cli's ServiceConfig to our appropriate one
-	komposeObject, err := dockerComposeToKomposeMapping(config)
+// takeV3GroupAdd removes the group_add keys of the services of a parsed compose file and returns
+// them by service
+func takeV3GroupAdd(composeFile map[string]interface{}) map[string]interface{} {
+	groupAdd := map[string]interface{}{}
+	services, _ := composeFile["services"].(map[string]interface{})
+	for name, service := range services {
+		if s, ok := service.(map[string]interface{}); ok {
+			if groups, ok := s["group_add"]; ok {
+				groupAdd[name] = groups
+				delete(s, "group_add")
+			}
+		}
+	}
+	return groupAdd
+}
+
+// parseV3GroupAdd loads the group_add of a service, which has to list the groups by gid
+func parseV3GroupAdd(composeServiceConfig *types.ServiceConfig, serviceConfig *kobject.ServiceConfig) error {
+	value, ok := composeServiceConfig.Extras[extraGroupAdd]
+	if !ok {
+		return nil
+	}
+	groups, err := cast.ToStringSliceE(value)
 	if err != nil {
-		return kobject.KomposeObject{}, err
+		return errors.Wrap(err, "group_add must list groups")
+	}
+	serviceConfig.GroupAdd, err = getGroupAdd(groups)
+	if err != nil {
+		return errors.Wrap(err, "GroupAdd should be mentioned in gid format, not a group name")
 	}
+	return nil
+}
 
-	return komposeObject, nil
+// parseV3UsernsMode keeps the userns_mode of a service as annotation, the pods always run in the
+// user namespace of the node
+func parseV3UsernsMode(composeServiceConfig *types.ServiceConfig, serviceConfig *kobject.ServiceConfig) {
+	if composeServiceConfig.UserNSMode == "" {
+		return
+	}
+	log.WithFields(log.Fields{
+		"service":  composeServiceConfig.Name,
+		"value":    composeServiceConfig.UserNSMode,
+		"category": "unsupported",
+	}).Warnf("userns_mode has no Kubernetes equivalent, keeping it as annotation %s", AnnotationUsernsMode)
+	if serviceConfig.Annotations == nil {
+		serviceConfig.Annotations = make(map[string]string)
+	}
+	serviceConfig.Annotations[AnnotationUsernsMode] = composeServiceConfig.UserNSMode
 }
 
 func loadV3Placement(constraints []string) map[string]string {
@@ -140,7 +318,7 @@ func loadV3Placement(constraints []string) map[string]string {
 	for _, j := range constraints {
 		p := strings.Split(j, " == ")
 		if len(p) < 2 {
-			log.Warn(p[0], errMsg)
+			log.WithField("category", "unsupported").Warn(p[0], errMsg)
 			continue
 		}
 		if p[0] == "node.hostname" {
@@ -151,7 +329,7 @@ func loadV3Placement(constraints []string) map[string]string {
 			label := strings.TrimPrefix(p[0], "node.labels.")
 			placement[label] = p[1]
 		} else {
-			log.Warn(p[0], errMsg)
+			log.WithField("category", "unsupported").Warn(p[0], errMsg)
 		}
 	}
 	return placement
@@ -233,6 +411,11 @@ a Kubernetes-compatible format.
 */
 func parseHealthCheck(composeHealthCheck types.HealthCheckConfig) (kobject.HealthCheck, error) {
 
+	// "disable: true" and "test: NONE" turn off the healthcheck, including the one of the image
+	if composeHealthCheck.Disable || (len(composeHealthCheck.Test) > 0 && composeHealthCheck.Test[0] == "NONE") {
+		return kobject.HealthCheck{Disable: true}, nil
+	}
+
 	var timeout, interval, retries, startPeriod int32
 
 	// Here we convert the timeout from 1h30s (example) to 36030 seconds.
@@ -265,8 +448,12 @@ func parseHealthCheck(composeHealthCheck types.HealthCheckConfig) (kobject.Healt
 	}
 
 	// Due to docker/cli adding "CMD-SHELL" to the struct, we remove the first element of composeHealthCheck.Test
+	var test []string
+	if len(composeHealthCheck.Test) > 1 {
+		test = composeHealthCheck.Test[1:]
+	}
 	return kobject.HealthCheck{
-		Test:        composeHealthCheck.Test[1:],
+		Test:        test,
 		Timeout:     timeout,
 		Interval:    interval,
 		Retries:     retries,
@@ -274,13 +461,15 @@ func parseHealthCheck(composeHealthCheck types.HealthCheckConfig) (kobject.Healt
 	}, nil
 }
 
-func dockerComposeToKomposeMapping(composeObject *types.Config) (kobject.KomposeObject, error) {
+// lookupEnv looks up the environment variables of the services without a value
+func dockerComposeToKomposeMapping(composeObject *types.Config, lookupEnv func(string) (string, bool)) (kobject.KomposeObject, error) {
 
 	// Step 1. Initialize what's going to be returned
 	komposeObject := kobject.KomposeObject{
 		ServiceConfigs: make(map[string]kobject.ServiceConfig),
 		LoadedFrom:     "compose",
 		Secrets:        composeObject.Secrets,
+		Unused:         unusedV3Resources(composeObject),
 	}
 
 	// Step 2. Parse through the object and convert it to kobject.KomposeObject!
@@ -294,15 +483,19 @@ func dockerComposeToKomposeMapping(composeObject *types.Config) (kobject.Kompose
 		serviceConfig := kobject.ServiceConfig{}
 		serviceConfig.Image = composeServiceConfig.Image
 		serviceConfig.WorkingDir = composeServiceConfig.WorkingDir
-		serviceConfig.Annotations = map[string]string(composeServiceConfig.Labels)
+		serviceConfig.Annotations = loadAnnotations(composeServiceConfig.Labels)
 		serviceConfig.CapAdd = composeServiceConfig.CapAdd
 		serviceConfig.CapDrop = composeServiceConfig.CapDrop
 		serviceConfig.Expose = composeServiceConfig.Expose
 		serviceConfig.Privileged = composeServiceConfig.Privileged
+		serviceConfig.Init = composeServiceConfig.Init != nil && *composeServiceConfig.Init
 		serviceConfig.User = composeServiceConfig.User
 		serviceConfig.Stdin = composeServiceConfig.StdinOpen
 		serviceConfig.Tty = composeServiceConfig.Tty
 		serviceConfig.TmpFs = composeServiceConfig.Tmpfs
+		serviceConfig.DependsOn = loadDependsOn(composeServiceConfig.DependsOn)
+		serviceConfig.ExtraHosts = composeServiceConfig.ExtraHosts
+		serviceConfig.ExternalLinks = composeServiceConfig.ExternalLinks
 		serviceConfig.ContainerName = normalizeContainerNames(composeServiceConfig.ContainerName)
 		serviceConfig.Command = composeServiceConfig.Entrypoint
 		serviceConfig.Args = composeServiceConfig.Command
@@ -316,6 +509,7 @@ func dockerComposeToKomposeMapping(composeObject *types.Config) (kobject.Kompose
 		}
 
 		parseV3Network(&composeServiceConfig, &serviceConfig, composeObject)
+		serviceConfig.NetworkAliases = loadNetworkAliases(name, v3NetworkAliases(composeServiceConfig))
 
 		if err := parseV3Resources(&composeServiceConfig, &serviceConfig); err != nil {
 			return kobject.KomposeObject{}, err
@@ -328,7 +522,7 @@ func dockerComposeToKomposeMapping(composeObject *types.Config) (kobject.Kompose
 		serviceConfig.DeployLabels = composeServiceConfig.Deploy.Labels
 
 		// HealthCheck
-		if composeServiceConfig.HealthCheck != nil && !composeServiceConfig.HealthCheck.Disable {
+		if composeServiceConfig.HealthCheck != nil {
 			var err error
 			serviceConfig.HealthChecks, err = parseHealthCheck(*composeServiceConfig.HealthCheck)
 			if err != nil {
@@ -339,11 +533,19 @@ func dockerComposeToKomposeMapping(composeObject *types.Config) (kobject.Kompose
 		// restart-policy: deploy.restart_policy.condition will rewrite restart option
 		// see: https://docs.docker.com/compose/compose-file/#restart_policy
 		serviceConfig.Restart = composeServiceConfig.Restart
-		if composeServiceConfig.Deploy.RestartPolicy != nil {
-			serviceConfig.Restart = composeServiceConfig.Deploy.RestartPolicy.Condition
+		if restartPolicy := composeServiceConfig.Deploy.RestartPolicy; restartPolicy != nil {
+			serviceConfig.Restart = restartPolicy.Condition
+			// the condition none doesn't restart the containers, like restart "no"
+			if serviceConfig.Restart == "none" {
+				serviceConfig.Restart = "no"
+			}
+			serviceConfig.DeployRestartPolicy = *restartPolicy
+			if restartPolicy.Delay != nil {
+				log.WithFields(log.Fields{"service": name, "category": "unsupported"}).Warn("deploy.restart_policy.delay is not supported, the kubelet restarts the containers with an exponential back-off")
+			}
 		}
 		if serviceConfig.Restart == "unless-stopped" {
-			log.Warnf("Restart policy 'unless-stopped' in service %s is not supported, convert it to 'always'", name)
+			log.WithFields(log.Fields{"service": name, "category": "unsupported"}).Warn("Restart policy 'unless-stopped' is not supported, convert it to 'always'")
 			serviceConfig.Restart = "always"
 		}
 
@@ -368,7 +570,7 @@ func dockerComposeToKomposeMapping(composeObject *types.Config) (kobject.Kompose
 		serviceConfig.BuildLabels = composeServiceConfig.Build.Labels
 
 		// env
-		parseV3Environment(&composeServiceConfig, &serviceConfig)
+		parseV3Environment(&composeServiceConfig, &serviceConfig, lookupEnv)
 
 		// Get env_file
 		serviceConfig.EnvFile = composeServiceConfig.EnvFile
@@ -389,6 +591,11 @@ func dockerComposeToKomposeMapping(composeObject *types.Config) (kobject.Kompose
 			return kobject.KomposeObject{}, err
 		}
 
+		if err := parseV3GroupAdd(&composeServiceConfig, &serviceConfig); err != nil {
+			return kobject.KomposeObject{}, err
+		}
+		parseV3UsernsMode(&composeServiceConfig, &serviceConfig)
+
 		// Log if the name will been changed
 		if normalizeServiceNames(name) != name {
 			log.Infof("Service name in docker-compose has been changed from %q to %q", name, normalizeServiceNames(name))
@@ -400,6 +607,7 @@ func dockerComposeToKomposeMapping(composeObject *types.Config) (kobject.Kompose
 			serviceConfig.ServiceType = string(api.ServiceTypeNodePort)
 		}
 		// Final step, add to the array!
+		serviceConfig.ComposeName = name
 		komposeObject.ServiceConfigs[normalizeServiceNames(name)] = serviceConfig
 	}
 
@@ -428,6 +636,23 @@ func parseV3Network(composeServiceConfig *types.ServiceConfig, serviceConfig *ko
 	}
 }
 
+// v3NetworkAliases returns the aliases of a service in all its networks, ordered by network
+func v3NetworkAliases(composeServiceConfig types.ServiceConfig) []string {
+	var networks []string
+	for network := range composeServiceConfig.Networks {
+		networks = append(networks, network)
+	}
+	sort.Strings(networks)
+
+	var aliases []string
+	for _, network := range networks {
+		if config := composeServiceConfig.Networks[network]; config != nil {
+			aliases = append(aliases, config.Aliases...)
+		}
+	}
+	return aliases
+}
+
 func parseV3Resources(composeServiceConfig *types.ServiceConfig, serviceConfig *kobject.ServiceConfig) error {
 	if (composeServiceConfig.Deploy.Resources != types.Resources{}) {
 
@@ -466,7 +691,7 @@ func parseV3Resources(composeServiceConfig *types.ServiceConfig, serviceConfig *
 
 }
 
-func parseV3Environment(composeServiceConfig *types.ServiceConfig, serviceConfig *kobject.ServiceConfig) {
+func parseV3Environment(composeServiceConfig *types.ServiceConfig, serviceConfig *kobject.ServiceConfig, lookupEnv func(string) (string, bool)) {
 	// Gather the environment values
 	// DockerCompose uses map[string]*string while we use []string
 	// So let's convert that using this hack
@@ -476,7 +701,7 @@ func parseV3Environment(composeServiceConfig *types.ServiceConfig, serviceConfig
 		if value != nil {
 			env = kobject.EnvVar{Name: name, Value: *value}
 		} else {
-			result, ok := os.LookupEnv(name)
+			result, ok := lookupEnv(name)
 			if ok {
 				env = kobject.EnvVar{Name: name, Value: result}
 			} else {
@@ -487,6 +712,63 @@ func parseV3Environment(composeServiceConfig *types.ServiceConfig, serviceConfig
 	}
 }
 
+// unusedV3Resources returns the top-level volumes, networks, configs and secrets no service refers to
+func unusedV3Resources(composeObject *types.Config) kobject.UnusedResources {
+	volumes, networks, configs, secrets := map[string]bool{}, map[string]bool{}, map[string]bool{}, map[string]bool{}
+	for _, service := range composeObject.Services {
+		for _, volume := range service.Volumes {
+			if volume.Type == "volume" {
+				volumes[volume.Source] = true
+			}
+		}
+		if len(service.Networks) == 0 {
+			networks["default"] = true
+		}
+		for network := range service.Networks {
+			networks[network] = true
+		}
+		for _, config := range service.Configs {
+			configs[config.Source] = true
+		}
+		for _, secret := range service.Secrets {
+			secrets[secret.Source] = true
+		}
+	}
+
+	var declaredVolumes, declaredNetworks, declaredConfigs, declaredSecrets []string
+	for name := range composeObject.Volumes {
+		declaredVolumes = append(declaredVolumes, name)
+	}
+	for name := range composeObject.Networks {
+		declaredNetworks = append(declaredNetworks, name)
+	}
+	for name := range composeObject.Configs {
+		declaredConfigs = append(declaredConfigs, name)
+	}
+	for name := range composeObject.Secrets {
+		declaredSecrets = append(declaredSecrets, name)
+	}
+	return kobject.UnusedResources{
+		Volumes:  unusedNames(declaredVolumes, volumes),
+		Networks: unusedNames(declaredNetworks, networks),
+		Configs:  unusedNames(declaredConfigs, configs),
+		Secrets:  unusedNames(declaredSecrets, secrets),
+	}
+}
+
+// validateHugePages checks that value is a quantity of whole huge pages of pageSize
+func validateHugePages(value string, pageSize string) error {
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return err
+	}
+	size := resource.MustParse(pageSize)
+	if quantity.Value() <= 0 || quantity.Value()%size.Value() != 0 {
+		return errors.Errorf("%s is not a positive multiple of the page size %s", value, pageSize)
+	}
+	return nil
+}
+
 // parseKomposeLabels parse kompose labels, also do some validation
 func parseKomposeLabels(labels map[string]string, serviceConfig *kobject.ServiceConfig) error {
 	// Label handler
@@ -498,6 +780,27 @@ func parseKomposeLabels(labels map[string]string, serviceConfig *kobject.Service
 	}
 
 	for key, value := range labels {
+		if strings.HasPrefix(key, LabelServiceAnnotationPrefix) {
+			if serviceConfig.ServiceAnnotations == nil {
+				serviceConfig.ServiceAnnotations = make(map[string]string)
+			}
+			serviceConfig.ServiceAnnotations[strings.TrimPrefix(key, LabelServiceAnnotationPrefix)] = value
+			continue
+		}
+		if strings.HasPrefix(key, LabelPodAnnotationPrefix) {
+			if serviceConfig.PodAnnotations == nil {
+				serviceConfig.PodAnnotations = make(map[string]string)
+			}
+			serviceConfig.PodAnnotations[strings.TrimPrefix(key, LabelPodAnnotationPrefix)] = value
+			continue
+		}
+		if strings.HasPrefix(key, LabelPortNamePrefix) {
+			if err := handlePortName(strings.TrimPrefix(key, LabelPortNamePrefix), value, serviceConfig); err != nil {
+				return errors.Wrap(err, "handlePortName failed")
+			}
+			continue
+		}
+
 		switch key {
 		case LabelServiceType:
 			serviceType, err := handleServiceType(value)
@@ -506,6 +809,24 @@ func parseKomposeLabels(labels map[string]string, serviceConfig *kobject.Service
 			}
 
 			serviceConfig.ServiceType = serviceType
+		case LabelServiceInternalTrafficPolicy:
+			policy, err := handleTrafficPolicy(value)
+			if err != nil {
+				return errors.Wrap(err, "handleTrafficPolicy failed")
+			}
+			serviceConfig.TrafficPolicy = policy
+		case LabelServiceTopologyAwareHints:
+			hints, err := handleTopologyHints(value)
+			if err != nil {
+				return errors.Wrap(err, "handleTopologyHints failed")
+			}
+			serviceConfig.TopologyHints = hints
+		case LabelServiceSplitPorts:
+			split, err := strconv.ParseBool(value)
+			if err != nil {
+				return errors.Errorf("%s must be true or false, got %q", LabelServiceSplitPorts, value)
+			}
+			serviceConfig.SplitPorts = split
 		case LabelServiceExpose:
 			serviceConfig.ExposeService = strings.Trim(strings.ToLower(value), " ,")
 		case LabelNodePortPort:
@@ -516,6 +837,84 @@ func parseKomposeLabels(labels map[string]string, serviceConfig *kobject.Service
 			serviceConfig.ImagePullSecret = value
 		case LabelImagePullPolicy:
 			serviceConfig.ImagePullPolicy = value
+		case LabelTerminationMessagePolicy:
+			policy, err := handleTerminationMessagePolicy(value)
+			if err != nil {
+				return errors.Wrap(err, "handleTerminationMessagePolicy failed")
+			}
+			serviceConfig.TerminationMessagePolicy = policy
+		case LabelTerminationMessagePath:
+			if !path.IsAbs(value) {
+				return errors.Errorf("%s must be an absolute path, got %q", LabelTerminationMessagePath, value)
+			}
+			serviceConfig.TerminationMessagePath = value
+		case LabelJobTTLSecondsAfterFinished:
+			ttl, err := strconv.ParseInt(value, 10, 32)
+			if err != nil || ttl < 0 {
+				return errors.Errorf("%s must be a number of seconds, got %q", LabelJobTTLSecondsAfterFinished, value)
+			}
+			seconds := int32(ttl)
+			serviceConfig.JobTTLSecondsAfterFinished = &seconds
+		case LabelFSGroup:
+			gid, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || gid < 0 {
+				return errors.Errorf("%s must be a gid, got %q", LabelFSGroup, value)
+			}
+			serviceConfig.FSGroup = &gid
+		case LabelRuntimeClass:
+			if errs := validation.IsDNS1123Subdomain(value); len(errs) > 0 {
+				return errors.Errorf("%s must be the name of a RuntimeClass, got %q: %s", LabelRuntimeClass, value, strings.Join(errs, ", "))
+			}
+			serviceConfig.RuntimeClass = value
+		case LabelServiceAccountAutomount:
+			automount, err := strconv.ParseBool(value)
+			if err != nil {
+				return errors.Errorf("%s must be true or false, got %q", LabelServiceAccountAutomount, value)
+			}
+			serviceConfig.AutomountServiceAccountToken = &automount
+		case LabelServiceAccountTokenAudience:
+			serviceConfig.ServiceAccountToken.Audience = value
+		case LabelServiceAccountTokenExpiration:
+			// the API server doesn't issue tokens valid for less than 10 minutes
+			seconds, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || seconds < 600 {
+				return errors.Errorf("%s must be a number of seconds of at least 600, got %q", LabelServiceAccountTokenExpiration, value)
+			}
+			serviceConfig.ServiceAccountToken.ExpirationSeconds = seconds
+		case LabelServiceAccountTokenPath:
+			if !path.IsAbs(value) {
+				return errors.Errorf("%s must be an absolute path, got %q", LabelServiceAccountTokenPath, value)
+			}
+			serviceConfig.ServiceAccountToken.Path = value
+		case LabelHugePages2Mi, LabelHugePages1Gi:
+			pageSize := strings.TrimPrefix(key, "kompose.hugepages.")
+			if err := validateHugePages(value, pageSize); err != nil {
+				return errors.Wrapf(err, "invalid %s", key)
+			}
+			if serviceConfig.HugePages == nil {
+				serviceConfig.HugePages = make(map[string]string)
+			}
+			serviceConfig.HugePages[pageSize] = value
+		case LabelHugePagesPath:
+			if !path.IsAbs(value) {
+				return errors.Errorf("%s must be an absolute path, got %q", LabelHugePagesPath, value)
+			}
+			serviceConfig.HugePagesPath = value
+		case LabelHPAMinReplicas, LabelHPAMaxReplicas, LabelHPACPU, LabelHPAMemory:
+			n, err := strconv.ParseInt(value, 10, 32)
+			if err != nil || n < 1 {
+				return errors.Errorf("%s must be a positive number, got %q", key, value)
+			}
+			switch key {
+			case LabelHPAMinReplicas:
+				serviceConfig.HPA.MinReplicas = int32(n)
+			case LabelHPAMaxReplicas:
+				serviceConfig.HPA.MaxReplicas = int32(n)
+			case LabelHPACPU:
+				serviceConfig.HPA.CPU = int32(n)
+			case LabelHPAMemory:
+				serviceConfig.HPA.Memory = int32(n)
+			}
 		default:
 			serviceConfig.Labels[key] = value
 		}
@@ -536,6 +935,25 @@ func parseKomposeLabels(labels map[string]string, serviceConfig *kobject.Service
 	return nil
 }
 
+// handlePortName names the container port given by the label key
+func handlePortName(port string, name string, serviceConfig *kobject.ServiceConfig) error {
+	if errs := validation.IsValidPortName(name); len(errs) != 0 {
+		return fmt.Errorf("invalid name %q for port %s: %s", name, port, strings.Join(errs, ", "))
+	}
+	containerPort := cast.ToInt32(port)
+	found := false
+	for i := range serviceConfig.Port {
+		if serviceConfig.Port[i].ContainerPort == containerPort {
+			serviceConfig.Port[i].Name = name
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("%s%s is set but port %s is not exposed", LabelPortNamePrefix, port, port)
+	}
+	return nil
+}
+
 func handleV3Volume(komposeObject *kobject.KomposeObject, volumes *map[string]types.VolumeConfig) {
 	for name := range komposeObject.ServiceConfigs {
 		// retrieve volumes of service
@@ -565,9 +983,9 @@ func getV3VolumeLabels(name string, volumes *map[string]types.VolumeConfig) (str
 
 	if volume, ok := (*volumes)[name]; ok {
 		for key, value := range volume.Labels {
-			if key == "kompose.volume.size" {
+			if key == LabelVolumeSize {
 				size = value
-			} else if key == "kompose.volume.selector" {
+			} else if key == LabelVolumeSelector {
 				selector = value
 			}
 		}
@@ -715,6 +1133,9 @@ func mergeComposeObject(oldCompose *types.Config, newCompose *types.Config) (*ty
 		if service.Privileged != tmpOldService.Privileged {
 			tmpOldService.Privileged = service.Privileged
 		}
+		if service.Init != nil {
+			tmpOldService.Init = service.Init
+		}
 		if service.ReadOnly != tmpOldService.ReadOnly {
 			tmpOldService.ReadOnly = service.ReadOnly
 		}
@@ -780,6 +1201,15 @@ func mergeComposeObject(oldCompose *types.Config, newCompose *types.Config) (*ty
 		if service.WorkingDir != "" {
 			tmpOldService.WorkingDir = service.WorkingDir
 		}
+		if service.UserNSMode != "" {
+			tmpOldService.UserNSMode = service.UserNSMode
+		}
+		for key, value := range service.Extras {
+			if tmpOldService.Extras == nil {
+				tmpOldService.Extras = make(map[string]interface{})
+			}
+			tmpOldService.Extras[key] = value
+		}
 		oldCompose.Services[index] = tmpOldService
 	}
 
@@ -22,6 +22,7 @@ import (
 	"github.com/kubernetes/kompose/pkg/kobject"
 	"github.com/kubernetes/kompose/pkg/loader/bundle"
 	"github.com/kubernetes/kompose/pkg/loader/compose"
+	"github.com/kubernetes/kompose/pkg/loader/quadlet"
 )
 
 // Loader interface defines loader that loads files and converts it to kobject representation
@@ -30,15 +31,17 @@ type Loader interface {
 	///Name() string
 }
 
-// GetLoader returns loader for given format
-func GetLoader(format string) (Loader, error) {
+// GetLoader returns loader for given format, loading the files with the options of opt
+func GetLoader(format string, opt kobject.ConvertOptions) (Loader, error) {
 	var l Loader
 
 	switch format {
 	case "bundle":
 		l = new(bundle.Bundle)
 	case "compose":
-		l = new(compose.Compose)
+		l = &compose.Compose{SOPS: opt.SOPS(), IsolatedEnvironment: opt.IsolatedEnvironment}
+	case "quadlet":
+		l = new(quadlet.Quadlet)
 	default:
 		return nil, fmt.Errorf("Input file format %s is not supported", format)
 	}
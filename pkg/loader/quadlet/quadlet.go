@@ -0,0 +1,402 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quadlet
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/shlex"
+	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/loader/compose"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// Quadlet is the loader of Podman quadlet unit files, implements Loader interface. The .container
+// units are services named after their file, e.g. web for web.container, as the systemd services
+// quadlet generates for them are.
+type Quadlet struct {
+}
+
+// IsUnitFile returns true if file is a quadlet unit file
+func IsUnitFile(file string) bool {
+	switch filepath.Ext(file) {
+	case ".container", ".volume", ".network", ".pod", ".kube", ".image", ".build":
+		return true
+	}
+	return false
+}
+
+// unitEntry is an assignment of a section of a unit file
+type unitEntry struct {
+	Key   string
+	Value string
+}
+
+// unit is the assignments of a unit file by section, in the order of the file
+type unit map[string][]unitEntry
+
+// value returns the last value of key in section, the one systemd uses for single valued keys
+func (u unit) value(section, key string) string {
+	var value string
+	for _, entry := range u[section] {
+		if entry.Key == key {
+			value = entry.Value
+		}
+	}
+	return value
+}
+
+// parseUnit parses a unit file named name, without extension. Lines ending with a backslash are
+// continued on the next line, and the %%, %h, %n and %N specifiers are expanded.
+func parseUnit(data []byte, name string) (unit, error) {
+	home, _ := os.UserHomeDir()
+	specifiers := strings.NewReplacer("%%", "%", "%h", home, "%n", name+".service", "%N", name)
+
+	u := unit{}
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	number := 0
+	for scanner.Scan() {
+		number++
+		line := strings.TrimSpace(scanner.Text())
+		for strings.HasSuffix(line, "\\") && scanner.Scan() {
+			number++
+			line = strings.TrimSpace(strings.TrimSuffix(line, "\\")) + " " + strings.TrimSpace(scanner.Text())
+		}
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = line[1 : len(line)-1]
+		default:
+			parts := strings.SplitN(line, "=", 2)
+			if len(parts) != 2 || section == "" {
+				return nil, fmt.Errorf("line %d: invalid assignment %q", number, line)
+			}
+			key := strings.TrimSpace(parts[0])
+			u[section] = append(u[section], unitEntry{Key: key, Value: specifiers.Replace(strings.TrimSpace(parts[1]))})
+		}
+	}
+	return u, scanner.Err()
+}
+
+// LoadFile loads the .container units of files, the .volume and .network units are declared by
+// the containers referring to them, and returns them as a KomposeObject. The units are translated
+// to a compose file, so they are loaded the way the equivalent compose file is.
+func (q *Quadlet) LoadFile(files []string) (kobject.KomposeObject, error) {
+	units := map[string]unit{}
+	dirs := map[string]string{}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		switch filepath.Ext(file) {
+		case ".container":
+		case ".volume", ".network":
+			log.Debugf("Skipping %s, the containers referring to it declare it", file)
+			continue
+		default:
+			log.WithField("category", "unsupported").Warnf("Unsupported unit %s - ignoring, only .container, .volume and .network units are converted", file)
+			continue
+		}
+
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return kobject.KomposeObject{}, err
+		}
+		u, err := parseUnit(data, name)
+		if err != nil {
+			return kobject.KomposeObject{}, errors.Wrapf(err, "unable to parse %s", file)
+		}
+		if _, ok := units[name]; ok {
+			return kobject.KomposeObject{}, fmt.Errorf("the container %s is defined twice, by %s and %s", name, filepath.Join(dirs[name], name+".container"), file)
+		}
+		dir, err := filepath.Abs(filepath.Dir(file))
+		if err != nil {
+			return kobject.KomposeObject{}, err
+		}
+		units[name] = u
+		dirs[name] = dir
+	}
+	if len(units) == 0 {
+		return kobject.KomposeObject{}, errors.New("no .container unit found")
+	}
+
+	project := composeProject{
+		Version:  "3.7",
+		Services: map[string]map[string]interface{}{},
+		Volumes:  map[string]interface{}{},
+		Networks: map[string]interface{}{},
+	}
+	for name, u := range units {
+		service, err := project.addContainer(name, u, dirs[name], units)
+		if err != nil {
+			return kobject.KomposeObject{}, errors.Wrapf(err, "unable to convert %s.container", name)
+		}
+		project.Services[name] = service
+	}
+	return project.load()
+}
+
+// composeProject is the compose file the units are translated to
+type composeProject struct {
+	Version  string                            `yaml:"version"`
+	Services map[string]map[string]interface{} `yaml:"services"`
+	Volumes  map[string]interface{}            `yaml:"volumes,omitempty"`
+	Networks map[string]interface{}            `yaml:"networks,omitempty"`
+}
+
+// load loads the compose file with the compose loader
+func (p composeProject) load() (kobject.KomposeObject, error) {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return kobject.KomposeObject{}, err
+	}
+	log.Debugf("Compose file of the units:\n%s", data)
+
+	dir, err := ioutil.TempDir("", "kompose-quadlet-")
+	if err != nil {
+		return kobject.KomposeObject{}, err
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "docker-compose.yaml")
+	if err := ioutil.WriteFile(file, data, 0644); err != nil {
+		return kobject.KomposeObject{}, err
+	}
+	return new(compose.Compose).LoadFile([]string{file})
+}
+
+// literal escapes the $ of s, units aren't interpolated like compose files
+func literal(s string) string {
+	return strings.Replace(s, "$", "$$", -1)
+}
+
+// systemdBool returns true if s is a true boolean of systemd
+func systemdBool(s string) bool {
+	switch strings.ToLower(s) {
+	case "1", "yes", "true", "on":
+		return true
+	}
+	return false
+}
+
+// restartPolicies maps the Restart of systemd to the restart of compose
+var restartPolicies = map[string]string{
+	"no":          "no",
+	"always":      "always",
+	"on-failure":  "on-failure",
+	"on-abnormal": "on-failure",
+	"on-abort":    "on-failure",
+	"on-watchdog": "on-failure",
+}
+
+// addContainer returns the compose service of the .container unit u of the directory dir, and
+// declares the volumes and networks it refers to. The services of units are the containers its
+// dependencies may refer to.
+func (p composeProject) addContainer(name string, u unit, dir string, units map[string]unit) (map[string]interface{}, error) {
+	service := map[string]interface{}{}
+	var environment, labels, ports, expose, volumes, networks, tmpfs, capAdd, capDrop, envFiles []string
+	healthcheck := map[string]interface{}{}
+	var user, group string
+
+	for _, entry := range u["Container"] {
+		value := entry.Value
+		switch entry.Key {
+		case "Image":
+			if ext := filepath.Ext(value); ext == ".image" || ext == ".build" {
+				log.WithFields(log.Fields{"service": name, "category": "images"}).Warnf("The image refers to the %s unit, using the image %s", value, strings.TrimSuffix(value, ext))
+				value = strings.TrimSuffix(value, ext)
+			}
+			service["image"] = literal(value)
+		case "ContainerName":
+			service["container_name"] = literal(value)
+		case "Exec":
+			service["command"] = literal(value)
+		case "Entrypoint":
+			service["entrypoint"] = literal(value)
+		case "WorkingDir":
+			service["working_dir"] = literal(value)
+		case "HostName":
+			service["hostname"] = literal(value)
+		case "ReadOnly":
+			service["read_only"] = systemdBool(value)
+		case "UserNS":
+			service["userns_mode"] = literal(value)
+		case "User":
+			user = value
+		case "Group":
+			group = value
+		case "Environment", "Label":
+			words, err := shlex.Split(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid %s %q", entry.Key, value)
+			}
+			for _, word := range words {
+				if entry.Key == "Environment" {
+					environment = append(environment, literal(word))
+				} else {
+					labels = append(labels, literal(word))
+				}
+			}
+		case "EnvironmentFile":
+			envFiles = append(envFiles, absPath(value, dir))
+		case "PublishPort":
+			ports = append(ports, value)
+		case "ExposeHostPort":
+			expose = append(expose, value)
+		case "Volume":
+			volume, named := containerVolume(value, dir)
+			if named != "" {
+				p.Volumes[named] = nil
+			}
+			volumes = append(volumes, literal(volume))
+		case "Tmpfs":
+			tmpfs = append(tmpfs, value)
+		case "Network":
+			network := strings.SplitN(value, ":", 2)[0]
+			switch network {
+			case "host", "none":
+				service["network_mode"] = network
+			case "", "private", "bridge", "slirp4netns", "pasta":
+			default:
+				network = strings.TrimSuffix(network, ".network")
+				p.Networks[network] = nil
+				networks = append(networks, network)
+			}
+		case "AddCapability":
+			capAdd = append(capAdd, strings.Fields(value)...)
+		case "DropCapability":
+			capDrop = append(capDrop, strings.Fields(value)...)
+		case "HealthCmd":
+			if value == "none" {
+				healthcheck["disable"] = true
+			} else {
+				healthcheck["test"] = []string{"CMD-SHELL", literal(value)}
+			}
+		case "HealthInterval":
+			healthcheck["interval"] = value
+		case "HealthTimeout":
+			healthcheck["timeout"] = value
+		case "HealthStartPeriod":
+			healthcheck["start_period"] = value
+		case "HealthRetries":
+			retries, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid HealthRetries %q", value)
+			}
+			healthcheck["retries"] = retries
+		case "StopTimeout":
+			service["stop_grace_period"] = value + "s"
+		case "Pod":
+			log.WithFields(log.Fields{"service": name, "category": "unsupported"}).Warnf("The pod %s isn't converted, the containers of a pod are converted to separate services", value)
+		default:
+			log.WithFields(log.Fields{"service": name, "category": "unsupported"}).Warnf("Unsupported %s key - ignoring", entry.Key)
+		}
+	}
+	if service["image"] == nil {
+		return nil, errors.New("no Image set")
+	}
+
+	if user != "" {
+		if group != "" {
+			user += ":" + group
+		}
+		service["user"] = user
+	} else if group != "" {
+		log.WithFields(log.Fields{"service": name, "category": "unsupported"}).Warn("The Group can't be converted without a User - ignoring")
+	}
+	if restart := u.value("Service", "Restart"); restart != "" {
+		if policy, ok := restartPolicies[restart]; ok {
+			service["restart"] = policy
+		} else {
+			log.WithFields(log.Fields{"service": name, "category": "unsupported"}).Warnf("Unsupported Restart %s - ignoring", restart)
+		}
+	}
+	if dependsOn := containerDependencies(u, units); len(dependsOn) > 0 {
+		service["depends_on"] = dependsOn
+	}
+
+	for key, values := range map[string][]string{
+		"environment": environment, "labels": labels, "ports": ports, "expose": expose,
+		"volumes": volumes, "networks": networks, "tmpfs": tmpfs, "cap_add": capAdd,
+		"cap_drop": capDrop, "env_file": envFiles,
+	} {
+		if len(values) > 0 {
+			service[key] = values
+		}
+	}
+	if len(healthcheck) > 0 {
+		service["healthcheck"] = healthcheck
+	}
+	return service, nil
+}
+
+// absPath returns path relative to dir if it isn't absolute, the way quadlet resolves them
+func absPath(path, dir string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// containerVolume returns the compose volume of the Volume of a .container unit of dir, and the
+// name of the named volume it mounts if any, e.g. data for data.volume:/data
+func containerVolume(value, dir string) (string, string) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) == 1 {
+		// an anonymous volume
+		return value, ""
+	}
+	source := parts[0]
+	if strings.HasPrefix(source, "/") || strings.HasPrefix(source, ".") {
+		return absPath(source, dir) + ":" + parts[1], ""
+	}
+	source = strings.TrimSuffix(source, ".volume")
+	return source + ":" + parts[1], source
+}
+
+// containerDependencies returns the containers of units the .container unit u requires or is
+// ordered after, the units of the systemd services quadlet generates for them
+func containerDependencies(u unit, units map[string]unit) []string {
+	dependencies := map[string]bool{}
+	for _, entry := range u["Unit"] {
+		switch entry.Key {
+		case "Requires", "Wants", "BindsTo", "After":
+			for _, dependency := range strings.Fields(entry.Value) {
+				name := strings.TrimSuffix(dependency, ".service")
+				if _, ok := units[name]; ok && name != dependency {
+					dependencies[name] = true
+				}
+			}
+		}
+	}
+
+	var dependsOn []string
+	for name := range dependencies {
+		dependsOn = append(dependsOn, name)
+	}
+	sort.Strings(dependsOn)
+	return dependsOn
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quadlet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kubernetes/kompose/pkg/kobject"
+)
+
+func TestParseUnit(t *testing.T) {
+	data := []byte(`# comment
+[Container]
+Image=nginx
+; comment
+Exec=nginx \
+  -g 'daemon off;'
+Volume=%N-data:/data
+Environment=PERCENT=100%%
+
+[Service]
+Restart=always
+Restart=on-failure
+`)
+	u, err := parseUnit(data, "web")
+	if err != nil {
+		t.Fatalf("parseUnit returned unexpected error %v", err)
+	}
+
+	expected := []unitEntry{
+		{Key: "Image", Value: "nginx"},
+		{Key: "Exec", Value: "nginx -g 'daemon off;'"},
+		{Key: "Volume", Value: "web-data:/data"},
+		{Key: "Environment", Value: "PERCENT=100%"},
+	}
+	if !reflect.DeepEqual(u["Container"], expected) {
+		t.Errorf("Expected the Container section %v, got %v", expected, u["Container"])
+	}
+	if restart := u.value("Service", "Restart"); restart != "on-failure" {
+		t.Errorf("Expected the last Restart on-failure, got %s", restart)
+	}
+
+	if _, err := parseUnit([]byte("Image=nginx\n"), "web"); err == nil {
+		t.Errorf("Expected an error for an assignment outside a section")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kompose-quadlet-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	units := map[string]string{
+		"web.container": `[Unit]
+Requires=db.service network-online.target
+
+[Container]
+Image=nginx
+PublishPort=8080:80
+Environment=GREETING="hello world" PRICE=$5
+Volume=cache.volume:/var/cache/nginx
+Label=kompose.service.type=nodeport
+HealthCmd=curl -f http://localhost/
+HealthRetries=3
+
+[Service]
+Restart=on-abnormal
+`,
+		"db.container": `[Container]
+Image=postgres
+Volume=./data:/var/lib/postgresql/data:Z
+`,
+		"app.network": `[Network]
+`,
+	}
+	var files []string
+	for name, content := range units {
+		file := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	komposeObject, err := new(Quadlet).LoadFile(files)
+	if err != nil {
+		t.Fatalf("LoadFile returned unexpected error %v", err)
+	}
+	if len(komposeObject.ServiceConfigs) != 2 {
+		t.Fatalf("Expected 2 services, got %d", len(komposeObject.ServiceConfigs))
+	}
+
+	web := komposeObject.ServiceConfigs["web"]
+	if web.Image != "nginx" {
+		t.Errorf("Expected the image nginx, got %s", web.Image)
+	}
+	if len(web.Port) != 1 || web.Port[0].HostPort != 8080 || web.Port[0].ContainerPort != 80 {
+		t.Errorf("Expected the port 8080:80, got %v", web.Port)
+	}
+	env := map[string]string{}
+	for _, e := range web.Environment {
+		env[e.Name] = e.Value
+	}
+	if env["GREETING"] != "hello world" || env["PRICE"] != "$5" {
+		t.Errorf("Expected the environment GREETING=hello world and PRICE=$5, got %v", web.Environment)
+	}
+	if !reflect.DeepEqual(web.DependsOn, []string{"db"}) {
+		t.Errorf("Expected the dependencies [db], got %v", web.DependsOn)
+	}
+	if web.Restart != "on-failure" {
+		t.Errorf("Expected the restart policy on-failure, got %s", web.Restart)
+	}
+	if web.ServiceType != "NodePort" {
+		t.Errorf("Expected the service type NodePort of the kompose.service.type label, got %s", web.ServiceType)
+	}
+	expectedHealthCheck := kobject.HealthCheck{Test: []string{"curl -f http://localhost/"}, Retries: 3}
+	if !reflect.DeepEqual(web.HealthChecks, expectedHealthCheck) {
+		t.Errorf("Expected the health check %v, got %v", expectedHealthCheck, web.HealthChecks)
+	}
+	if !reflect.DeepEqual(web.VolList, []string{"cache:/var/cache/nginx"}) {
+		t.Errorf("Expected the volume cache:/var/cache/nginx, got %v", web.VolList)
+	}
+
+	db := komposeObject.ServiceConfigs["db"]
+	expectedVolume := filepath.Join(dir, "data") + ":/var/lib/postgresql/data"
+	if len(db.VolList) != 1 || db.VolList[0] != expectedVolume {
+		t.Errorf("Expected the volume %s, got %v", expectedVolume, db.VolList)
+	}
+}
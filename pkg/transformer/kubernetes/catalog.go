@@ -0,0 +1,193 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/novln/docker-parser"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// imageDefaults holds the defaults of a well-known image applied by --smart-defaults
+type imageDefaults struct {
+	// Port is the container port the image listens on
+	Port int32
+	// Volume is the directory the image keeps its data in
+	Volume    string
+	Liveness  api.Probe
+	Readiness api.Probe
+}
+
+// tcpProbe checks that port accepts connections
+func tcpProbe(port int32, initialDelay int32) api.Probe {
+	return api.Probe{
+		Handler: api.Handler{
+			TCPSocket: &api.TCPSocketAction{Port: intstr.FromInt(int(port))},
+		},
+		InitialDelaySeconds: initialDelay,
+		PeriodSeconds:       10,
+	}
+}
+
+// execProbe runs command in the container
+func execProbe(initialDelay int32, timeout int32, command ...string) api.Probe {
+	return api.Probe{
+		Handler: api.Handler{
+			Exec: &api.ExecAction{Command: command},
+		},
+		InitialDelaySeconds: initialDelay,
+		TimeoutSeconds:      timeout,
+		PeriodSeconds:       10,
+	}
+}
+
+// imageCatalog holds the defaults of the official Docker Hub images by name
+var imageCatalog = map[string]imageDefaults{
+	"nginx": {
+		Port:      80,
+		Liveness:  tcpProbe(80, 10),
+		Readiness: tcpProbe(80, 0),
+	},
+	"postgres": {
+		Port:      5432,
+		Volume:    "/var/lib/postgresql/data",
+		Liveness:  execProbe(30, 5, "pg_isready", "-h", "127.0.0.1"),
+		Readiness: execProbe(5, 5, "pg_isready", "-h", "127.0.0.1"),
+	},
+	"mysql": {
+		Port:      3306,
+		Volume:    "/var/lib/mysql",
+		Liveness:  execProbe(30, 5, "mysqladmin", "ping", "-h", "127.0.0.1"),
+		Readiness: execProbe(5, 5, "mysqladmin", "ping", "-h", "127.0.0.1"),
+	},
+	"redis": {
+		Port:      6379,
+		Volume:    "/data",
+		Liveness:  tcpProbe(6379, 15),
+		Readiness: execProbe(5, 5, "redis-cli", "ping"),
+	},
+	"rabbitmq": {
+		Port:      5672,
+		Volume:    "/var/lib/rabbitmq",
+		Liveness:  execProbe(60, 15, "rabbitmq-diagnostics", "-q", "ping"),
+		Readiness: execProbe(20, 10, "rabbitmq-diagnostics", "-q", "ping"),
+	},
+}
+
+// lookupImageDefaults returns the catalog entry of an official Docker Hub image
+func lookupImageDefaults(image string) (imageDefaults, bool) {
+	ref, err := dockerparser.Parse(image)
+	if err != nil || ref.Registry() != "docker.io" || !strings.HasPrefix(ref.ShortName(), "library/") {
+		return imageDefaults{}, false
+	}
+	defaults, ok := imageCatalog[strings.TrimPrefix(ref.ShortName(), "library/")]
+	return defaults, ok
+}
+
+// ApplyImageDefaults adds the port and the data volume of the catalog to the services of well-known
+// images that declare no ports, or don't mount a volume at the data directory
+func ApplyImageDefaults(komposeObject *kobject.KomposeObject) {
+	for _, name := range SortedKeys(*komposeObject) {
+		service := komposeObject.ServiceConfigs[name]
+		defaults, ok := lookupImageDefaults(service.Image)
+		if !ok {
+			continue
+		}
+
+		if defaults.Port != 0 && len(service.Port) == 0 {
+			log.Infof("Service %s exposes port %d, the default port of image %s", name, defaults.Port, service.Image)
+			service.Port = []kobject.Ports{{ContainerPort: defaults.Port, Protocol: api.ProtocolTCP}}
+		}
+
+		if defaults.Volume != "" && !mountsDirectory(service, defaults.Volume) {
+			log.Infof("Service %s keeps its data in a volume at %s, the data directory of image %s", name, defaults.Volume, service.Image)
+			service.Volumes = append(service.Volumes, kobject.Volumes{
+				SvcName:   name,
+				MountPath: ":" + defaults.Volume,
+				Container: defaults.Volume,
+				PVCName:   fmt.Sprintf("%s-claim%d", name, len(service.Volumes)),
+			})
+		}
+		komposeObject.ServiceConfigs[name] = service
+	}
+}
+
+// mountsDirectory returns true if a volume of service is mounted at dir or one of its parents
+func mountsDirectory(service kobject.ServiceConfig, dir string) bool {
+	for _, volume := range service.Volumes {
+		if volume.Container == dir || strings.HasPrefix(dir, strings.TrimSuffix(volume.Container, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigImageProbes sets the liveness and readiness probes of the catalog on the pod templates of
+// a service of a well-known image, keeping the probes set from the healthcheck or depends_on
+func (k *Kubernetes) ConfigImageProbes(name string, service kobject.ServiceConfig, objects []runtime.Object) error {
+	defaults, ok := lookupImageDefaults(service.Image)
+	if !ok || service.HealthChecks.Disable {
+		return nil
+	}
+
+	updateTemplate := func(template *api.PodTemplateSpec) error {
+		container := &template.Spec.Containers[0]
+		if container.LivenessProbe == nil {
+			liveness := namePortProbe(defaults.Liveness, container.Ports)
+			container.LivenessProbe = &liveness
+		}
+		if container.ReadinessProbe == nil {
+			readiness := namePortProbe(defaults.Readiness, container.Ports)
+			container.ReadinessProbe = &readiness
+		}
+		return nil
+	}
+	for _, obj := range objects {
+		if err := k.UpdateController(obj, updateTemplate, func(*metav1.ObjectMeta) {}); err != nil {
+			return errors.Wrap(err, "k.UpdateController failed")
+		}
+	}
+	log.Debugf("Service %s uses the probes of image %s", name, service.Image)
+	return nil
+}
+
+// namePortProbe returns a copy of the tcpSocket probe of the catalog referring to its port by the
+// name of the container port, so the probe follows the port if it is renumbered. Probes of unnamed
+// ports and other probes are returned as they are.
+func namePortProbe(probe api.Probe, ports []api.ContainerPort) api.Probe {
+	if probe.TCPSocket == nil || probe.TCPSocket.Port.Type != intstr.Int {
+		return probe
+	}
+	for _, port := range ports {
+		if port.Name != "" && port.ContainerPort == probe.TCPSocket.Port.IntVal {
+			// the catalog entries share their handlers, so the handler is copied
+			tcpSocket := *probe.TCPSocket
+			tcpSocket.Port = intstr.FromString(port.Name)
+			probe.TCPSocket = &tcpSocket
+			break
+		}
+	}
+	return probe
+}
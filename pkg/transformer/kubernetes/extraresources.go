@@ -0,0 +1,139 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"text/template"
+
+	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/transformer"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// ExtraResourceData is passed to the templates of --extra-resources for every service
+type ExtraResourceData struct {
+	// Name is the name the objects of the service are named after
+	Name string
+	// Service is the name of the service in the compose file
+	Service string
+	Project string
+	Image   string
+	Ports   []kobject.Ports
+	Labels  map[string]string
+}
+
+// LoadExtraResources parses the YAML and JSON files of dir, sorted by name, as the templates of
+// the objects --extra-resources adds for every service
+func LoadExtraResources(dir string) ([]*template.Template, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the extra resources")
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+
+	var templates []*template.Template
+	for _, file := range files {
+		switch filepath.Ext(file.Name()) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		if file.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read the extra resources")
+		}
+		t, err := template.New(file.Name()).Option("missingkey=zero").Parse(string(data))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid extra resource template %s", file.Name())
+		}
+		templates = append(templates, t)
+	}
+	log.Debugf("Found %d extra resource templates in %s", len(templates), dir)
+	return templates, nil
+}
+
+// CreateExtraResources renders the templates of --extra-resources for a service and returns the
+// objects of the documents they render. A template renders no document for the services it
+// doesn't apply to. The objects are labeled as objects of the service unless the template labels
+// them with io.kompose.service.
+func (k *Kubernetes) CreateExtraResources(name string, service kobject.ServiceConfig, templates []*template.Template, opt kobject.ConvertOptions) ([]runtime.Object, error) {
+	project, err := transformer.ProjectName(opt)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get the project name")
+	}
+	data := ExtraResourceData{
+		Name:    name,
+		Service: service.ComposeName,
+		Project: project,
+		Image:   service.Image,
+		Ports:   service.Port,
+		Labels:  service.Labels,
+	}
+	if data.Service == "" {
+		data.Service = name
+	}
+
+	var objects []runtime.Object
+	for _, t := range templates {
+		var b bytes.Buffer
+		if err := t.Execute(&b, data); err != nil {
+			return nil, errors.Wrapf(err, "unable to render the extra resource template %s", t.Name())
+		}
+
+		decoder := yaml.NewYAMLOrJSONDecoder(&b, 4096)
+		for {
+			var document map[string]interface{}
+			if err := decoder.Decode(&document); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return nil, errors.Wrapf(err, "extra resource template %s rendered invalid YAML for service %s", t.Name(), name)
+			}
+			if len(document) == 0 {
+				continue
+			}
+
+			obj := &unstructured.Unstructured{Object: document}
+			if obj.GetKind() == "" || obj.GetAPIVersion() == "" || obj.GetName() == "" {
+				return nil, errors.Errorf("extra resource template %s rendered an object without apiVersion, kind or metadata.name for service %s", t.Name(), name)
+			}
+			labels := obj.GetLabels()
+			if _, ok := labels[transformer.Selector]; !ok {
+				if labels == nil {
+					labels = map[string]string{}
+				}
+				labels[transformer.Selector] = name
+				obj.SetLabels(labels)
+			}
+			log.WithField("service", name).Debugf("Adding the %s %s of the extra resource template %s", obj.GetKind(), obj.GetName(), t.Name())
+			objects = append(objects, obj)
+		}
+	}
+	return objects, nil
+}
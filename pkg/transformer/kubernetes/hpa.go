@@ -0,0 +1,150 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/loader/compose"
+	"github.com/kubernetes/kompose/pkg/transformer"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// hpaDefaultCPU is the target average CPU utilization of the HPAs that set no target
+const hpaDefaultCPU = 50
+
+// ScaleTarget returns the reference of an HPA to the controller among the objects of a service,
+// and leaves out the replicas of the controller: kubectl apply would otherwise reset the replicas
+// the HPA scaled the controller to on every apply. ok is false if the objects have no
+// Deployment, StatefulSet or ReplicationController.
+func ScaleTarget(objects []runtime.Object) (target autoscalingv2beta2.CrossVersionObjectReference, ok bool) {
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *appsv1.Deployment:
+			o.Spec.Replicas = nil
+		case *appsv1.StatefulSet:
+			o.Spec.Replicas = nil
+		case *api.ReplicationController:
+			o.Spec.Replicas = nil
+		default:
+			continue
+		}
+		typeMeta, objectMeta := getObjectMeta(obj)
+		return autoscalingv2beta2.CrossVersionObjectReference{
+			Kind:       typeMeta.Kind,
+			APIVersion: typeMeta.APIVersion,
+			Name:       objectMeta.Name,
+		}, true
+	}
+	return target, false
+}
+
+// HPAReplicas returns the minimum and maximum replicas of the HPA of a service. The minimum
+// defaults to the replicas of the service, given by deploy.replicas, scale or --replicas, else 1.
+// Fixed replicas out of the range of the HPA are reported, as the HPA overrides them.
+func HPAReplicas(name string, service kobject.ServiceConfig, opt kobject.ConvertOptions) (int32, int32, error) {
+	min, max := service.HPA.MinReplicas, service.HPA.MaxReplicas
+	if max == 0 {
+		return 0, 0, errors.Errorf("%s is required by the kompose.hpa labels of service %s", compose.LabelHPAMaxReplicas, name)
+	}
+	if min > max {
+		return 0, 0, errors.Errorf("%s %d of service %s is greater than %s %d", compose.LabelHPAMinReplicas, min, name, compose.LabelHPAMaxReplicas, max)
+	}
+
+	replicas := int32(service.Replicas)
+	if opt.IsReplicaSetFlag {
+		replicas = int32(opt.Replicas)
+	}
+	if min == 0 {
+		min = 1
+		if replicas > 0 && replicas <= max {
+			min = replicas
+		}
+	}
+	switch {
+	case replicas > max:
+		log.WithFields(log.Fields{"service": name, "category": "resources"}).Warnf("The %d replicas of the service are more than the %s %d, the HorizontalPodAutoscaler scales it down to %d", replicas, compose.LabelHPAMaxReplicas, max, max)
+	case replicas > 0 && replicas < min:
+		log.WithFields(log.Fields{"service": name, "category": "resources"}).Warnf("The %d replicas of the service are less than the %s %d, the HorizontalPodAutoscaler scales it up to %d", replicas, compose.LabelHPAMinReplicas, min, min)
+	}
+	return min, max, nil
+}
+
+// CreateHPA creates the HorizontalPodAutoscaler of a service scaling target by the average CPU and
+// memory utilization of its pods. The utilization is relative to the requests of the containers,
+// so the services without requests for a target are reported. The HPA is an autoscaling/v2
+// unstructured object: autoscaling/v2beta2 was removed in Kubernetes 1.26, while the vendored
+// k8s.io/api only has its types, whose schema autoscaling/v2 kept.
+func (k *Kubernetes) CreateHPA(name string, service kobject.ServiceConfig, opt kobject.ConvertOptions, target autoscalingv2beta2.CrossVersionObjectReference) (*unstructured.Unstructured, error) {
+	min, max, err := HPAReplicas(name, service, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	cpu, memory := service.HPA.CPU, service.HPA.Memory
+	if cpu == 0 && memory == 0 {
+		cpu = hpaDefaultCPU
+	}
+	var metrics []autoscalingv2beta2.MetricSpec
+	if cpu > 0 {
+		if service.CPUReservation == 0 {
+			log.WithFields(log.Fields{"service": name, "category": "resources"}).Warn("The HorizontalPodAutoscaler needs the CPU requests of the pods to scale by CPU utilization, set deploy.resources.reservations.cpus")
+		}
+		metrics = append(metrics, hpaMetric(api.ResourceCPU, cpu))
+	}
+	if memory > 0 {
+		if service.MemReservation == 0 {
+			log.WithFields(log.Fields{"service": name, "category": "resources"}).Warn("The HorizontalPodAutoscaler needs the memory requests of the pods to scale by memory utilization, set deploy.resources.reservations.memory")
+		}
+		metrics = append(metrics, hpaMetric(api.ResourceMemory, memory))
+	}
+
+	spec, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&autoscalingv2beta2.HorizontalPodAutoscalerSpec{
+		ScaleTargetRef: target,
+		MinReplicas:    &min,
+		MaxReplicas:    max,
+		Metrics:        metrics,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to convert the spec of the HorizontalPodAutoscaler")
+	}
+	hpa := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	hpa.SetAPIVersion("autoscaling/v2")
+	hpa.SetKind("HorizontalPodAutoscaler")
+	hpa.SetName(name)
+	hpa.SetLabels(transformer.ConfigLabels(name))
+	return hpa, nil
+}
+
+// hpaMetric returns the metric of an HPA targeting the average utilization of a resource
+func hpaMetric(resource api.ResourceName, utilization int32) autoscalingv2beta2.MetricSpec {
+	return autoscalingv2beta2.MetricSpec{
+		Type: autoscalingv2beta2.ResourceMetricSourceType,
+		Resource: &autoscalingv2beta2.ResourceMetricSource{
+			Name: resource,
+			Target: autoscalingv2beta2.MetricTarget{
+				Type:               autoscalingv2beta2.UtilizationMetricType,
+				AverageUtilization: &utilization,
+			},
+		},
+	}
+}
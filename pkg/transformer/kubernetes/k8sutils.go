@@ -18,14 +18,17 @@ package kubernetes
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	api "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	"os"
 	"path"
@@ -39,25 +42,35 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/kubernetes/kompose/pkg/kobject"
 	"github.com/kubernetes/kompose/pkg/transformer"
+	"github.com/kubernetes/kompose/pkg/utils/archive"
+	"github.com/kubernetes/kompose/pkg/utils/docker"
+	"github.com/kubernetes/kompose/pkg/utils/oci"
+	"github.com/kubernetes/kompose/pkg/utils/sops"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"sort"
 
 	deployapi "github.com/openshift/api/apps/v1"
 	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
 )
 
 /**
  * Generate Helm Chart configuration
  */
-func generateHelm(dirName string) error {
+func generateHelm(dirName string, header []byte) error {
 	type ChartDetails struct {
-		Name string
+		Name    string
+		Version string
 	}
 
-	details := ChartDetails{dirName}
+	details := ChartDetails{filepath.Base(dirName), ChartVersion}
 	manifestDir := dirName + string(os.PathSeparator) + "templates"
 	dir, err := os.Open(dirName)
 
@@ -80,7 +93,7 @@ func generateHelm(dirName string) error {
 
 	/* Create the readme file */
 	readme := "This chart was created by Kompose\n"
-	err = ioutil.WriteFile(dirName+string(os.PathSeparator)+"README.md", []byte(readme), 0644)
+	_, err = transformer.WriteFileIfChanged(dirName+string(os.PathSeparator)+"README.md", []byte(readme))
 	if err != nil {
 		return err
 	}
@@ -88,7 +101,7 @@ func generateHelm(dirName string) error {
 	/* Create the Chart.yaml file */
 	chart := `name: {{.Name}}
 description: A generated Helm Chart for {{.Name}} from Skippbox Kompose
-version: 0.0.1
+version: {{.Version}}
 apiVersion: v1
 keywords:
   - {{.Name}}
@@ -103,7 +116,7 @@ home:
 	var chartData bytes.Buffer
 	_ = t.Execute(&chartData, details)
 
-	err = ioutil.WriteFile(dirName+string(os.PathSeparator)+"Chart.yaml", chartData.Bytes(), 0644)
+	_, err = transformer.WriteFileIfChanged(dirName+string(os.PathSeparator)+"Chart.yaml", withHeader(header, chartData.Bytes()))
 	if err != nil {
 		return err
 	}
@@ -112,6 +125,61 @@ home:
 	return nil
 }
 
+// pushHelm packages the chart in dirName and pushes it to the OCI repository given by --push, as
+// <repository>/<chart name>:<chart version> like "helm push" does
+func pushHelm(dirName string, opt kobject.ConvertOptions) error {
+	host, repository, err := oci.ParseReference(opt.PushChart)
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(dirName)
+
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "kompose-chart-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create a temporary directory")
+	}
+	defer os.RemoveAll(tmpDir)
+	target := filepath.Join(tmpDir, name+"-"+ChartVersion+".tgz")
+	if err := archive.CreateGzipTarball(filepath.Clean(dirName), target); err != nil {
+		return errors.Wrap(err, "archive.CreateGzipTarball failed")
+	}
+	chart, err := ioutil.ReadFile(target)
+	if err != nil {
+		return err
+	}
+
+	// the config of a chart is its Chart.yaml as JSON
+	config, err := json.Marshal(map[string]interface{}{
+		"name":        name,
+		"version":     ChartVersion,
+		"description": "A generated Helm Chart for " + name + " from Skippbox Kompose",
+		"apiVersion":  "v1",
+		"keywords":    []string{name},
+	})
+	if err != nil {
+		return err
+	}
+
+	repo := &oci.Repository{
+		Host:      host,
+		Name:      strings.TrimPrefix(repository+"/"+name, "/"),
+		PlainHTTP: opt.PushPlainHTTP,
+		Auth:      transformer.PushCredentials(opt),
+	}
+	if repo.Auth == nil {
+		repo.Auth = docker.RegistryCredentials(host)
+	}
+	digest, err := repo.PushHelmChart(chart, config, ChartVersion, map[string]string{
+		"org.opencontainers.image.title":   name,
+		"org.opencontainers.image.version": ChartVersion,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "unable to push the chart to %s", opt.PushChart)
+	}
+	log.Infof("Chart pushed to %s/%s:%s (digest %s)", host, repo.Name, ChartVersion, digest)
+	return nil
+}
+
 // Check if given path is a directory
 func isDir(name string) (bool, error) {
 
@@ -139,7 +207,9 @@ func getDirName(opt kobject.ConvertOptions) string {
 	dirName := opt.OutFile
 	if dirName == "" {
 		// Let assume all the docker-compose files are in the same directory
-		if opt.CreateChart {
+		if opt.CreateChart && opt.ProjectName != "" {
+			dirName = opt.ProjectName
+		} else if opt.CreateChart {
 			filename := opt.InputFiles[0]
 			extension := filepath.Ext(filename)
 			dirName = filename[0 : len(filename)-len(extension)]
@@ -162,8 +232,143 @@ func objectToRaw(object runtime.Object) runtime.RawExtension {
 
 }
 
+// isArchive checks if the output file is a gzip compressed tarball
+func isArchive(out string) bool {
+	return strings.HasSuffix(out, ".tar.gz") || strings.HasSuffix(out, ".tgz")
+}
+
+// printArchive writes the converted objects into a temporary directory and bundles
+// them into a gzip compressed tarball together with an index of the generated files
+func printArchive(objects []runtime.Object, opt kobject.ConvertOptions) (int, error) {
+	target := opt.OutFile
+	if opt.Mapping != "" {
+		return 0, errors.New("the mapping can't be written for an archive, use --out with a directory")
+	}
+
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "kompose-archive-")
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create a temporary directory")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Charts are written in a directory named after the archive
+	opt.OutFile = tmpDir + string(os.PathSeparator)
+	if opt.CreateChart {
+		name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(target), ".tgz"), ".tar.gz")
+		opt.OutFile = filepath.Join(tmpDir, name) + string(os.PathSeparator)
+	}
+
+	written, err := PrintListSize(objects, opt)
+	if err != nil {
+		return 0, err
+	}
+
+	var files []string
+	err = filepath.Walk(tmpDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			rel, err := filepath.Rel(tmpDir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to index the generated files")
+	}
+
+	index := strings.Join(files, "\n") + "\n"
+	err = ioutil.WriteFile(filepath.Join(tmpDir, ArchiveIndexFile), []byte(index), 0644)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to write the archive index")
+	}
+
+	if dir := filepath.Dir(target); !transformer.Exists(dir) {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return 0, errors.Wrap(err, "failed to create directories")
+		}
+	}
+	err = archive.CreateGzipTarball(tmpDir+string(os.PathSeparator), target)
+	if err != nil {
+		return 0, errors.Wrap(err, "archive.CreateGzipTarball failed")
+	}
+
+	log.Infof("Archive %q created", target)
+	return written, nil
+}
+
+// LoadHeader reads the --header-file and returns its lines as YAML comments, to be prepended to
+// the generated files. Lines that are comments already are kept as they are.
+func LoadHeader(file string) ([]byte, error) {
+	if file == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the header file")
+	}
+	var b bytes.Buffer
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\r\n"), "\n") {
+		line = strings.TrimRight(line, "\r")
+		switch {
+		case line == "":
+			b.WriteString("#\n")
+		case strings.HasPrefix(line, "#"):
+			b.WriteString(line + "\n")
+		default:
+			b.WriteString("# " + line + "\n")
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// withHeader returns data with the header prepended
+func withHeader(header, data []byte) []byte {
+	if len(header) == 0 {
+		return data
+	}
+	return append(append([]byte{}, header...), data...)
+}
+
+// MarshalList marshals the objects the way PrintList prints them to stdout: a List in YAML or
+// JSON, or a stream of YAML documents with --kubectl-compatible
+func MarshalList(objects []runtime.Object, opt kobject.ConvertOptions) ([]byte, error) {
+	if opt.KubectlCompatible {
+		return marshalDocuments(objects, opt.YAMLIndent)
+	}
+	convertedList, err := createList(objects)
+	if err != nil {
+		return nil, err
+	}
+	data, err := marshal(convertedList, opt.GenerateJSON, opt.YAMLIndent)
+	if err != nil {
+		return nil, fmt.Errorf("error in marshalling the List: %v", err)
+	}
+	return data, nil
+}
+
 // PrintList will take the data converted and decide on the commandline attributes given
 func PrintList(objects []runtime.Object, opt kobject.ConvertOptions) error {
+	_, err := PrintListSize(objects, opt)
+	return err
+}
+
+// PrintListSize prints the objects like PrintList and returns the number of bytes of manifests
+// written, before the compression of an archive
+func PrintListSize(objects []runtime.Object, opt kobject.ConvertOptions) (int, error) {
+	if isArchive(opt.OutFile) {
+		return printArchive(objects, opt)
+	}
+
+	header, err := LoadHeader(opt.HeaderFile)
+	if err != nil {
+		return 0, err
+	}
+
 	var f *os.File
 	dirName := getDirName(opt)
 	log.Debugf("Target Dir: %s", dirName)
@@ -171,14 +376,14 @@ func PrintList(objects []runtime.Object, opt kobject.ConvertOptions) error {
 	// Create a directory if "out" ends with "/" and does not exist.
 	if !transformer.Exists(opt.OutFile) && strings.HasSuffix(opt.OutFile, "/") {
 		if err := os.MkdirAll(opt.OutFile, os.ModePerm); err != nil {
-			return errors.Wrap(err, "failed to create a directory")
+			return 0, errors.Wrap(err, "failed to create a directory")
 		}
 	}
 
 	// Check if output file is a directory
 	isDirVal, err := isDir(opt.OutFile)
 	if err != nil {
-		return errors.Wrap(err, "isDir failed")
+		return 0, errors.Wrap(err, "isDir failed")
 	}
 	if opt.CreateChart {
 		isDirVal = true
@@ -186,44 +391,39 @@ func PrintList(objects []runtime.Object, opt kobject.ConvertOptions) error {
 	if !isDirVal {
 		f, err = transformer.CreateOutFile(opt.OutFile)
 		if err != nil {
-			return errors.Wrap(err, "transformer.CreateOutFile failed")
+			return 0, errors.Wrap(err, "transformer.CreateOutFile failed")
 		}
 		log.Printf("Kubernetes file %q created", opt.OutFile)
 		defer f.Close()
 	}
 
 	var files []string
+	// the size of the manifests written
+	written := 0
+	// the objects in the order they are written, and the file each one is written to, for the mapping
+	var printed []runtime.Object
+	var printedFiles []string
+	if opt.ToStdout || f != nil {
+		printed = objects
+		for range objects {
+			printedFiles = append(printedFiles, opt.OutFile)
+		}
+	}
 
 	// if asked to print to stdout or to put in single file
-	// we will create a list
+	// we will create a list, or a stream of YAML documents kubectl reads as well
 	if opt.ToStdout || f != nil {
-		list := &api.List{}
-		// convert objects to versioned and add them to list
-		for _, object := range objects {
-			versionedObject, err := convertToVersion(object, metav1.GroupVersion{})
-			if err != nil {
-				return err
-			}
-
-			list.Items = append(list.Items, objectToRaw(versionedObject))
-
-		}
-		// version list itself
-		listVersion := metav1.GroupVersion{Group: "", Version: "v1"}
-		list.Kind = "List"
-		list.APIVersion = "v1"
-		convertedList, err := convertToVersion(list, listVersion)
-		if err != nil {
-			return err
-		}
-		data, err := marshal(convertedList, opt.GenerateJSON, opt.YAMLIndent)
+		data, err := MarshalList(objects, opt)
 		if err != nil {
-			return fmt.Errorf("error in marshalling the List: %v", err)
+			return 0, err
 		}
-		printVal, err := transformer.Print("", dirName, "", data, opt.ToStdout, opt.GenerateJSON, f, opt.Provider)
+		data = withHeader(header, data)
+		printVal, _, err := transformer.Print("", dirName, "", data, opt.ToStdout, opt.GenerateJSON && !opt.KubectlCompatible, f, opt.Provider)
 		if err != nil {
-			return errors.Wrap(err, "transformer.Print failed")
+			return 0, errors.Wrap(err, "transformer.Print failed")
 		}
+		// the List is followed by a newline
+		written += len(data) + 1
 		files = append(files, printVal)
 	} else {
 		finalDirName := dirName
@@ -232,7 +432,27 @@ func PrintList(objects []runtime.Object, opt kobject.ConvertOptions) error {
 		}
 
 		if err := os.MkdirAll(finalDirName, 0755); err != nil {
-			return err
+			return 0, err
+		}
+		// the files written, by how they changed
+		changes := map[string]transformer.FileChange{}
+
+		if opt.GroupBy == GroupByService {
+			var groups []objectGroup
+			groups, objects = groupByService(objects)
+			for _, group := range groups {
+				file, change, size, err := printGroup(group, finalDirName, header, opt)
+				if err != nil {
+					return 0, err
+				}
+				written += size
+				files = append(files, file)
+				changes[file] = change
+				for _, obj := range group.objects {
+					printed = append(printed, obj)
+					printedFiles = append(printedFiles, file)
+				}
+			}
 		}
 
 		var file string
@@ -240,53 +460,615 @@ func PrintList(objects []runtime.Object, opt kobject.ConvertOptions) error {
 		for _, v := range objects {
 			versionedObject, err := convertToVersion(v, metav1.GroupVersion{})
 			if err != nil {
-				return err
+				return 0, err
 			}
 			data, err := marshal(versionedObject, opt.GenerateJSON, opt.YAMLIndent)
 			if err != nil {
-				return err
+				return 0, err
 			}
 
-			var typeMeta metav1.TypeMeta
-			var objectMeta metav1.ObjectMeta
-
-			if us, ok := v.(*unstructured.Unstructured); ok {
-				typeMeta = metav1.TypeMeta{
-					Kind:       us.GetKind(),
-					APIVersion: us.GetAPIVersion(),
-				}
-				objectMeta = metav1.ObjectMeta{
-					Name: us.GetName(),
-				}
-			} else {
-				val := reflect.ValueOf(v).Elem()
-				// Use reflect to access TypeMeta struct inside runtime.Object.
-				// cast it to correct type - metav1.TypeMeta
-				typeMeta = val.FieldByName("TypeMeta").Interface().(metav1.TypeMeta)
-
-				// Use reflect to access ObjectMeta struct inside runtime.Object.
-				// cast it to correct type - api.ObjectMeta
-				objectMeta = val.FieldByName("ObjectMeta").Interface().(metav1.ObjectMeta)
+			typeMeta, objectMeta := getObjectMeta(v)
 
+			fileName := objectMeta.Name
+			if objectMeta.Namespace != "" {
+				fileName = objectMeta.Namespace + "-" + objectMeta.Name
 			}
 
-			file, err = transformer.Print(objectMeta.Name, finalDirName, strings.ToLower(typeMeta.Kind), data, opt.ToStdout, opt.GenerateJSON, f, opt.Provider)
+			var change transformer.FileChange
+			data = withHeader(header, data)
+			file, change, err = transformer.Print(fileName, finalDirName, strings.ToLower(typeMeta.Kind), data, opt.ToStdout, opt.GenerateJSON, f, opt.Provider)
 			if err != nil {
-				return errors.Wrap(err, "transformer.Print failed")
+				return 0, errors.Wrap(err, "transformer.Print failed")
 			}
+			written += len(data)
 
 			files = append(files, file)
+			changes[file] = change
+			printed = append(printed, v)
+			printedFiles = append(printedFiles, file)
+		}
+
+		if err := reportOutputChanges(finalDirName, changes, opt.RemoveStaleFiles); err != nil {
+			return 0, err
+		}
+	}
+	if opt.Mapping != "" {
+		if err := writeMapping(opt.Mapping, printed, printedFiles, opt.ServiceNames); err != nil {
+			return 0, errors.Wrap(err, "writeMapping failed")
 		}
 	}
 	if opt.CreateChart {
-		err = generateHelm(dirName)
+		err = generateHelm(dirName, header)
+		if err != nil {
+			return 0, errors.Wrap(err, "generateHelm failed")
+		}
+		if opt.PushChart != "" {
+			if err := pushHelm(dirName, opt); err != nil {
+				return 0, errors.Wrap(err, "pushHelm failed")
+			}
+		}
+	}
+	return written, nil
+}
+
+// createList converts the objects to a versioned List
+func createList(objects []runtime.Object) (runtime.Object, error) {
+	list := &api.List{}
+	// convert objects to versioned and add them to list
+	for _, object := range objects {
+		versionedObject, err := convertToVersion(object, metav1.GroupVersion{})
+		if err != nil {
+			return nil, err
+		}
+
+		list.Items = append(list.Items, objectToRaw(versionedObject))
+
+	}
+	// version list itself
+	listVersion := metav1.GroupVersion{Group: "", Version: "v1"}
+	list.Kind = "List"
+	list.APIVersion = "v1"
+	return convertToVersion(list, listVersion)
+}
+
+// objectGroup holds the objects of a service written into one file
+type objectGroup struct {
+	name    string
+	objects []runtime.Object
+}
+
+// groupByService groups the objects by the service they belong to. The ConfigMaps, Secrets and
+// PersistentVolumeClaims belong to the service whose pods use them. The objects that belong to
+// no or several services, like NetworkPolicies or shared volumes, are returned separately.
+func groupByService(objects []runtime.Object) ([]objectGroup, []runtime.Object) {
+	// the services using the ConfigMaps, Secrets and PersistentVolumeClaims by "kind/namespace/name"
+	users := map[string][]string{}
+	addUser := func(key, service string) {
+		for _, user := range users[key] {
+			if user == service {
+				return
+			}
+		}
+		users[key] = append(users[key], service)
+	}
+	for _, obj := range objects {
+		_, objectMeta := getObjectMeta(obj)
+		spec := podSpec(obj)
+		service := transformer.ServiceLabel(objectMeta.Labels)
+		if spec == nil || service == "" {
+			continue
+		}
+		configMaps, secrets := podReferences(*spec)
+		for _, name := range configMaps {
+			addUser("ConfigMap/"+objectMeta.Namespace+"/"+name, service)
+		}
+		for _, name := range secrets {
+			addUser("Secret/"+objectMeta.Namespace+"/"+name, service)
+		}
+		for _, volume := range spec.Volumes {
+			if volume.PersistentVolumeClaim != nil {
+				addUser("PersistentVolumeClaim/"+objectMeta.Namespace+"/"+volume.PersistentVolumeClaim.ClaimName, service)
+			}
+		}
+	}
+
+	var groups []objectGroup
+	var rest []runtime.Object
+	index := map[string]int{}
+	for _, obj := range objects {
+		typeMeta, objectMeta := getObjectMeta(obj)
+
+		var service string
+		switch typeMeta.Kind {
+		case "ConfigMap", "Secret", "PersistentVolumeClaim":
+			if services := users[typeMeta.Kind+"/"+objectMeta.Namespace+"/"+objectMeta.Name]; len(services) == 1 {
+				service = services[0]
+			}
+		case "NetworkPolicy", "Namespace":
+		default:
+			service = transformer.ServiceLabel(objectMeta.Labels)
+		}
+		if service == "" {
+			rest = append(rest, obj)
+			continue
+		}
+
+		name := service
+		if objectMeta.Namespace != "" {
+			name = objectMeta.Namespace + "-" + service
+		}
+		if i, ok := index[name]; ok {
+			groups[i].objects = append(groups[i].objects, obj)
+		} else {
+			index[name] = len(groups)
+			groups = append(groups, objectGroup{name: name, objects: []runtime.Object{obj}})
+		}
+	}
+	return groups, rest
+}
+
+// podSpec returns the pod spec of pods and pod controllers, or nil for other objects
+func podSpec(obj runtime.Object) *api.PodSpec {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return &o.Spec.Template.Spec
+	case *appsv1.DaemonSet:
+		return &o.Spec.Template.Spec
+	case *appsv1.StatefulSet:
+		return &o.Spec.Template.Spec
+	case *api.ReplicationController:
+		if o.Spec.Template != nil {
+			return &o.Spec.Template.Spec
+		}
+	case *deployapi.DeploymentConfig:
+		if o.Spec.Template != nil {
+			return &o.Spec.Template.Spec
+		}
+	case *batchv1.Job:
+		return &o.Spec.Template.Spec
+	case *api.Pod:
+		return &o.Spec
+	}
+	return nil
+}
+
+// printGroup writes the objects of a group into one file named after the group,
+// as a multi-document YAML file or as a List in JSON
+func printGroup(group objectGroup, dirName string, header []byte, opt kobject.ConvertOptions) (string, transformer.FileChange, int, error) {
+	var data []byte
+	if opt.GenerateJSON {
+		list, err := createList(group.objects)
+		if err != nil {
+			return "", "", 0, err
+		}
+		data, err = marshal(list, true, opt.YAMLIndent)
+		if err != nil {
+			return "", "", 0, err
+		}
+	} else {
+		var err error
+		data, err = marshalDocuments(group.objects, opt.YAMLIndent)
+		if err != nil {
+			return "", "", 0, err
+		}
+	}
+
+	data = withHeader(header, data)
+	file, change, err := transformer.Print(group.name, dirName, "", data, false, opt.GenerateJSON, nil, opt.Provider)
+	if err != nil {
+		return "", "", 0, errors.Wrap(err, "transformer.Print failed")
+	}
+	return file, change, len(data), nil
+}
+
+// reportOutputChanges prints how many files of the output directory dir were added, changed and
+// left unchanged, as given by changes, and the stale files: the files named after the kind of
+// their object, like web-deployment.yaml, that the conversion no longer generates. The stale
+// files are removed if remove is set.
+func reportOutputChanges(dir string, changes map[string]transformer.FileChange, remove bool) error {
+	counts := map[transformer.FileChange]int{}
+	for _, change := range changes {
+		counts[change]++
+	}
+
+	stale, err := staleFiles(dir, changes)
+	if err != nil {
+		return errors.Wrap(err, "unable to look for the files no longer generated")
+	}
+	removed := 0
+	if remove {
+		for _, file := range stale {
+			if err := os.Remove(file); err != nil {
+				return errors.Wrap(err, "unable to remove the file no longer generated")
+			}
+			log.Printf("File %q removed", file)
+			removed++
+		}
+	} else if len(stale) > 0 {
+		log.Infof("%d file(s) of %s are no longer generated, --remove-stale-files removes them: %s", len(stale), dir, strings.Join(stale, ", "))
+	}
+
+	log.Infof("%d file(s) added, %d changed, %d unchanged and %d removed in %s", counts[transformer.FileAdded], counts[transformer.FileChanged], counts[transformer.FileUnchanged], removed, dir)
+	return nil
+}
+
+// staleFiles returns the YAML and JSON files of dir named after the kind of their object, as
+// kompose names them, that aren't in written
+func staleFiles(dir string, written map[string]transformer.FileChange) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var stale []string
+	for _, entry := range entries {
+		file := filepath.Join(dir, entry.Name())
+		ext := filepath.Ext(entry.Name())
+		if _, ok := written[file]; ok || entry.IsDir() || (ext != ".yaml" && ext != ".json") {
+			continue
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var typeMeta struct {
+			Kind string `yaml:"kind"`
+		}
+		if err := yaml.Unmarshal(data, &typeMeta); err != nil || typeMeta.Kind == "" {
+			continue
+		}
+		if strings.HasSuffix(strings.TrimSuffix(entry.Name(), ext), "-"+strings.ToLower(typeMeta.Kind)) {
+			stale = append(stale, file)
+		}
+	}
+	return stale, nil
+}
+
+// marshalDocuments marshals the objects into a multi-document YAML stream
+func marshalDocuments(objects []runtime.Object, indent int) ([]byte, error) {
+	var documents [][]byte
+	for _, obj := range objects {
+		versionedObject, err := convertToVersion(obj, metav1.GroupVersion{})
+		if err != nil {
+			return nil, err
+		}
+		document, err := marshal(versionedObject, false, indent)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+	return bytes.Join(documents, []byte("---\n")), nil
+}
+
+// equivalentAPIVersions lists, per kind, the apiVersions of the same object schema, from the preferred one
+var equivalentAPIVersions = map[string][]string{
+	"Ingress":                 {"networking.k8s.io/v1beta1", "extensions/v1beta1"},
+	"NetworkPolicy":           {"networking.k8s.io/v1", "extensions/v1beta1"},
+	"HorizontalPodAutoscaler": {"autoscaling/v2", "autoscaling/v2beta2"},
+	"PodDisruptionBudget":     {"policy/v1", "policy/v1beta1"},
+}
+
+// AdaptAPIVersions switches the objects whose apiVersion the cluster doesn't serve to an
+// equivalent apiVersion it serves. served holds group/versions like "apps/v1". Ingresses are
+// converted to networking.k8s.io/v1 for clusters serving none of the v1beta1 versions.
+func AdaptAPIVersions(objects []runtime.Object, served map[string]bool) []runtime.Object {
+	var adaptedObjects []runtime.Object
+	for _, obj := range objects {
+		gvk := obj.GetObjectKind().GroupVersionKind()
+		apiVersion := gvk.GroupVersion().String()
+		if served[apiVersion] {
+			adaptedObjects = append(adaptedObjects, obj)
+			continue
+		}
+		_, objectMeta := getObjectMeta(obj)
+		adapted := false
+		for _, equivalent := range equivalentAPIVersions[gvk.Kind] {
+			if served[equivalent] {
+				gv, err := schema.ParseGroupVersion(equivalent)
+				if err != nil {
+					continue
+				}
+				log.Infof("%s %s uses %s, which the cluster serves instead of %s", gvk.Kind, objectMeta.Name, equivalent, apiVersion)
+				obj.GetObjectKind().SetGroupVersionKind(gv.WithKind(gvk.Kind))
+				adapted = true
+				break
+			}
+		}
+		if ingress, ok := obj.(*networkingv1beta1.Ingress); ok && !adapted && served[networkingv1.SchemeGroupVersion.String()] {
+			log.Infof("Ingress %s is converted to %s, which the cluster serves instead of %s", objectMeta.Name, networkingv1.SchemeGroupVersion, apiVersion)
+			obj = ingressToV1(ingress)
+			adapted = true
+		}
+		if !adapted {
+			log.WithField("category", "cluster").Warnf("The cluster doesn't serve %s of %s %s", apiVersion, gvk.Kind, objectMeta.Name)
+		}
+		adaptedObjects = append(adaptedObjects, obj)
+	}
+	return adaptedObjects
+}
+
+// ingressToV1 converts a v1beta1 Ingress to networking.k8s.io/v1, whose paths require a type
+// and whose backends refer to the Service port by number or name
+func ingressToV1(ingress *networkingv1beta1.Ingress) *networkingv1.Ingress {
+	backend := func(b networkingv1beta1.IngressBackend) networkingv1.IngressBackend {
+		port := networkingv1.ServiceBackendPort{}
+		if b.ServicePort.Type == intstr.String {
+			port.Name = b.ServicePort.StrVal
+		} else {
+			port.Number = b.ServicePort.IntVal
+		}
+		return networkingv1.IngressBackend{
+			Service: &networkingv1.IngressServiceBackend{Name: b.ServiceName, Port: port},
+		}
+	}
+
+	v1 := &networkingv1.Ingress{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Ingress",
+			APIVersion: networkingv1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: ingress.ObjectMeta,
+	}
+	for _, tls := range ingress.Spec.TLS {
+		v1.Spec.TLS = append(v1.Spec.TLS, networkingv1.IngressTLS{Hosts: tls.Hosts, SecretName: tls.SecretName})
+	}
+	for _, rule := range ingress.Spec.Rules {
+		v1Rule := networkingv1.IngressRule{Host: rule.Host}
+		if rule.HTTP != nil {
+			v1Rule.HTTP = &networkingv1.HTTPIngressRuleValue{}
+			for _, p := range rule.HTTP.Paths {
+				pathType := networkingv1.PathTypeImplementationSpecific
+				if p.PathType != nil {
+					pathType = networkingv1.PathType(*p.PathType)
+				}
+				v1Rule.HTTP.Paths = append(v1Rule.HTTP.Paths, networkingv1.HTTPIngressPath{
+					Path:     p.Path,
+					PathType: &pathType,
+					Backend:  backend(p.Backend),
+				})
+			}
+		}
+		v1.Spec.Rules = append(v1.Spec.Rules, v1Rule)
+	}
+	return v1
+}
+
+// configMesh sets the sidecar injection label or annotation of the given mesh on the pod template
+func configMesh(template *api.PodTemplateSpec, mesh string) {
+	switch mesh {
+	case MeshIstio:
+		if template.Labels == nil {
+			template.Labels = make(map[string]string)
+		}
+		template.Labels["sidecar.istio.io/inject"] = "true"
+	case MeshLinkerd:
+		if template.Annotations == nil {
+			template.Annotations = make(map[string]string)
+		}
+		template.Annotations["linkerd.io/inject"] = "enabled"
+	}
+}
+
+// configHostGatewayAliases resolves the hosts mapped to host-gateway in extra_hosts to hostGatewayIP
+// within the pod, so the containers keep using the host names of the compose file
+func configHostGatewayAliases(template *api.PodTemplateSpec, service kobject.ServiceConfig, hostGatewayIP string) {
+	hosts := HostGatewayHosts(service)
+	if hostGatewayIP == "" || len(hosts) == 0 {
+		return
+	}
+	template.Spec.HostAliases = append(template.Spec.HostAliases, api.HostAlias{
+		IP:        hostGatewayIP,
+		Hostnames: hosts,
+	})
+}
+
+// AddChecksumAnnotations annotates the pod templates with a checksum of the ConfigMaps and Secrets they use,
+// so that converting a changed config and applying it rolls out the pods, like the checksum pattern of Helm charts.
+// The pods only use the ConfigMaps and Secrets of their namespace.
+func (k *Kubernetes) AddChecksumAnnotations(objects []runtime.Object) error {
+	// the contents by namespace and name
+	configMaps := map[string]interface{}{}
+	secrets := map[string]interface{}{}
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *api.ConfigMap:
+			configMaps[o.Namespace+"/"+o.Name] = []interface{}{o.Data, o.BinaryData}
+		case *api.Secret:
+			secrets[o.Namespace+"/"+o.Name] = []interface{}{o.Data, o.StringData}
+		}
+	}
+	if len(configMaps) == 0 && len(secrets) == 0 {
+		return nil
+	}
+
+	for _, obj := range objects {
+		// a bare pod isn't rolled out, and the pod template of a Job can't be changed
+		switch obj.(type) {
+		case *api.Pod, *batchv1.Job:
+			continue
+		}
+		_, objectMeta := getObjectMeta(obj)
+		err := k.UpdateController(obj, func(template *api.PodTemplateSpec) error {
+			configMapNames, secretNames := podReferences(template.Spec)
+			for annotation, contents := range map[string][]interface{}{
+				AnnotationConfigChecksum: selectContents(configMaps, objectMeta.Namespace, configMapNames),
+				AnnotationSecretChecksum: selectContents(secrets, objectMeta.Namespace, secretNames),
+			} {
+				if len(contents) == 0 {
+					continue
+				}
+				data, err := json.Marshal(contents)
+				if err != nil {
+					return errors.Wrap(err, "unable to compute the checksum")
+				}
+				if template.Annotations == nil {
+					template.Annotations = make(map[string]string)
+				}
+				template.Annotations[annotation] = fmt.Sprintf("%x", sha256.Sum256(data))
+			}
+			return nil
+		}, func(meta *metav1.ObjectMeta) {})
 		if err != nil {
-			return errors.Wrap(err, "generateHelm failed")
+			return err
 		}
 	}
 	return nil
 }
 
+// podReferences returns the sorted names of the ConfigMaps and Secrets used by a pod
+func podReferences(spec api.PodSpec) (configMaps []string, secrets []string) {
+	configMapSet := map[string]bool{}
+	secretSet := map[string]bool{}
+	for _, volume := range spec.Volumes {
+		if volume.ConfigMap != nil {
+			configMapSet[volume.ConfigMap.Name] = true
+		}
+		if volume.Secret != nil {
+			secretSet[volume.Secret.SecretName] = true
+		}
+	}
+	for _, container := range append(spec.InitContainers, spec.Containers...) {
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil {
+				configMapSet[env.ValueFrom.ConfigMapKeyRef.Name] = true
+			}
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				secretSet[env.ValueFrom.SecretKeyRef.Name] = true
+			}
+		}
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				configMapSet[envFrom.ConfigMapRef.Name] = true
+			}
+			if envFrom.SecretRef != nil {
+				secretSet[envFrom.SecretRef.Name] = true
+			}
+		}
+	}
+
+	for name := range configMapSet {
+		configMaps = append(configMaps, name)
+	}
+	for name := range secretSet {
+		secrets = append(secrets, name)
+	}
+	sort.Strings(configMaps)
+	sort.Strings(secrets)
+	return configMaps, secrets
+}
+
+// selectContents returns the contents of the given names of namespace, skipping the ones that weren't generated
+func selectContents(contents map[string]interface{}, namespace string, names []string) []interface{} {
+	var selected []interface{}
+	for _, name := range names {
+		if content, ok := contents[namespace+"/"+name]; ok {
+			selected = append(selected, name, content)
+		}
+	}
+	return selected
+}
+
+// getObjectMeta returns the TypeMeta and ObjectMeta of the given object
+func getObjectMeta(v runtime.Object) (metav1.TypeMeta, metav1.ObjectMeta) {
+	if us, ok := v.(*unstructured.Unstructured); ok {
+		typeMeta := metav1.TypeMeta{
+			Kind:       us.GetKind(),
+			APIVersion: us.GetAPIVersion(),
+		}
+		objectMeta := metav1.ObjectMeta{
+			Name:      us.GetName(),
+			Namespace: us.GetNamespace(),
+			Labels:    us.GetLabels(),
+		}
+		return typeMeta, objectMeta
+	}
+
+	val := reflect.ValueOf(v).Elem()
+	// Use reflect to access TypeMeta struct inside runtime.Object.
+	// cast it to correct type - metav1.TypeMeta
+	typeMeta := val.FieldByName("TypeMeta").Interface().(metav1.TypeMeta)
+
+	// Use reflect to access ObjectMeta struct inside runtime.Object.
+	// cast it to correct type - api.ObjectMeta
+	objectMeta := val.FieldByName("ObjectMeta").Interface().(metav1.ObjectMeta)
+
+	return typeMeta, objectMeta
+}
+
+// PrintDiff writes a unified diff for every object that differs between oldObjects and newObjects.
+// Objects are matched by kind and name. It returns the number of objects that were added, removed or changed.
+func PrintDiff(oldObjects, newObjects []runtime.Object, opt kobject.ConvertOptions, out io.Writer) (int, error) {
+	oldData, err := marshalObjects(oldObjects, opt)
+	if err != nil {
+		return 0, err
+	}
+	newData, err := marshalObjects(newObjects, opt)
+	if err != nil {
+		return 0, err
+	}
+
+	var keys []string
+	for key := range oldData {
+		keys = append(keys, key)
+	}
+	for key := range newData {
+		if _, ok := oldData[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	changed := 0
+	for _, key := range keys {
+		if oldData[key] == newData[key] {
+			continue
+		}
+		diff := difflib.UnifiedDiff{
+			A:        splitLines(oldData[key]),
+			FromFile: "a/" + key,
+			B:        splitLines(newData[key]),
+			ToFile:   "b/" + key,
+			Context:  3,
+		}
+		if err := difflib.WriteUnifiedDiff(out, diff); err != nil {
+			return changed, errors.Wrap(err, "difflib.WriteUnifiedDiff failed")
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+// splitLines splits s into lines keeping the line endings, an empty string has no lines
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// marshalObjects marshals every object and indexes the result by "Kind/name"
+func marshalObjects(objects []runtime.Object, opt kobject.ConvertOptions) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, v := range objects {
+		versionedObject, err := convertToVersion(v, metav1.GroupVersion{})
+		if err != nil {
+			return nil, err
+		}
+		data, err := marshal(versionedObject, opt.GenerateJSON, opt.YAMLIndent)
+		if err != nil {
+			return nil, err
+		}
+		typeMeta, objectMeta := getObjectMeta(v)
+		key := typeMeta.Kind + "/" + objectMeta.Name
+		if objectMeta.Namespace != "" {
+			key = typeMeta.Kind + "/" + objectMeta.Namespace + "/" + objectMeta.Name
+		}
+		result[key] = string(data)
+	}
+	return result, nil
+}
+
 // marshal object runtime.Object and return byte array
 func marshal(obj runtime.Object, jsonFormat bool, indent int) (data []byte, err error) {
 	// convert data to yaml or json
@@ -386,6 +1168,71 @@ func (k *Kubernetes) CreateLBService(name string, service kobject.ServiceConfig,
 	return svcs
 }
 
+// SplitPorts tells if a Service is created for every port of a service, by its
+// kompose.service.split-ports label or by --split-service-ports
+func (k *Kubernetes) SplitPorts(service kobject.ServiceConfig) bool {
+	return service.SplitPorts || k.Opt.SplitServicePorts
+}
+
+// CreateSplitServices creates a Service named <service>-<port> for every port of a service, for
+// load balancers and ingress controllers that need a Service per port, or to publish a port with
+// TCP and UDP. A port published with both protocols gets a Service per protocol, the one of UDP
+// named <service>-<port>-udp. The Service named <service> is kept for the clients within the
+// cluster, see CreateClusterService.
+func (k *Kubernetes) CreateSplitServices(name string, service kobject.ServiceConfig, objects []runtime.Object) []*api.Service {
+	var svcs []*api.Service
+	seen := map[string]bool{}
+	for _, port := range service.Port {
+		single := service
+		single.Port = []kobject.Ports{port}
+		svcName := fmt.Sprintf("%s-%d", name, splitPort(port))
+		if seen[svcName] {
+			svcName = fmt.Sprintf("%s-%s", svcName, strings.ToLower(string(port.Protocol)))
+		}
+		seen[svcName] = true
+
+		svc := k.CreateService(svcName, single, objects)
+		// the Services belong to the service and select its pods
+		svc.ObjectMeta.Labels = transformer.ConfigLabels(name)
+		svc.Spec.Selector = transformer.ConfigLabels(name)
+		svcs = append(svcs, svc)
+	}
+	return svcs
+}
+
+// CreateClusterService creates the Service named <service> of a service whose ports are split, with
+// all its ports, so the other services still reach it by its name. It is only reachable within the
+// cluster, exposing the pods to the outside is left to the Services of the ports.
+func (k *Kubernetes) CreateClusterService(name string, service kobject.ServiceConfig, objects []runtime.Object) *api.Service {
+	svc := k.CreateService(name, service, objects)
+	svc.Spec.Type = api.ServiceTypeClusterIP
+	svc.Spec.LoadBalancerIP = ""
+	svc.Spec.ExternalTrafficPolicy = ""
+	for i := range svc.Spec.Ports {
+		svc.Spec.Ports[i].NodePort = 0
+	}
+	return svc
+}
+
+// splitPort returns the port of the Service of a port of a service
+func splitPort(port kobject.Ports) int32 {
+	if port.HostPort != 0 {
+		return port.HostPort
+	}
+	return port.ContainerPort
+}
+
+// SplitServiceOf returns the Service of port among the Services of CreateSplitServices, or the
+// first one if no Service has the port
+func SplitServiceOf(svcs []*api.Service, port int32) *api.Service {
+	for _, svc := range svcs {
+		if svc.Spec.Ports[0].Port == port {
+			return svc
+		}
+	}
+	return svcs[0]
+}
+
 func (k *Kubernetes) initSvcObject(name string, service kobject.ServiceConfig, ports []api.ServicePort) *api.Service {
 	svc := k.InitSvc(name, service)
 	svc.Spec.Ports = ports
@@ -393,8 +1240,9 @@ func (k *Kubernetes) initSvcObject(name string, service kobject.ServiceConfig, p
 	svc.Spec.Type = api.ServiceType(service.ServiceType)
 
 	// Configure annotations
-	annotations := transformer.ConfigAnnotations(service)
+	annotations := transformer.ConfigServiceAnnotations(service)
 	svc.ObjectMeta.Annotations = annotations
+	configServiceTopology(svc, service)
 
 	return svc
 }
@@ -415,12 +1263,71 @@ func (k *Kubernetes) CreateService(name string, service kobject.ServiceConfig, o
 	}
 
 	// Configure annotations
-	annotations := transformer.ConfigAnnotations(service)
+	annotations := transformer.ConfigServiceAnnotations(service)
 	svc.ObjectMeta.Annotations = annotations
+	configServiceTopology(svc, service)
 
 	return svc
 }
 
+// annotationInternalTrafficPolicy holds the internalTrafficPolicy of a Service until
+// SetInternalTrafficPolicy sets it
+const annotationInternalTrafficPolicy = "kompose.service.internal-traffic-policy"
+
+// configServiceTopology configures the routing of the traffic of the Service given by the
+// kompose.service.internal-traffic-policy and kompose.service.topology-aware-hints labels
+func configServiceTopology(svc *api.Service, service kobject.ServiceConfig) {
+	if service.TrafficPolicy != "" || service.TopologyHints != "" {
+		if svc.ObjectMeta.Annotations == nil {
+			svc.ObjectMeta.Annotations = make(map[string]string)
+		}
+	}
+	if service.TrafficPolicy != "" {
+		svc.ObjectMeta.Annotations[annotationInternalTrafficPolicy] = service.TrafficPolicy
+	}
+	if service.TopologyHints != "" {
+		svc.ObjectMeta.Annotations["service.kubernetes.io/topology-aware-hints"] = service.TopologyHints
+		if service.TopologyHints == "auto" && service.TrafficPolicy == "Local" {
+			log.WithFields(log.Fields{"service": svc.Name, "category": "networking"}).Warn("The traffic is routed to the node of the client, the topology aware hints are ignored")
+		}
+		if service.TopologyHints == "auto" {
+			svc.ObjectMeta.Annotations["service.kubernetes.io/topology-mode"] = "Auto"
+		}
+	}
+}
+
+// SetInternalTrafficPolicy sets the internalTrafficPolicy of the Services given by the
+// kompose.service.internal-traffic-policy label. The vendored k8s.io/api has no such field, so
+// these Services are converted to unstructured objects, once the conversion doesn't change the
+// typed Services anymore.
+func SetInternalTrafficPolicy(objects []runtime.Object) ([]runtime.Object, error) {
+	for i, obj := range objects {
+		svc, ok := obj.(*api.Service)
+		if !ok {
+			continue
+		}
+		policy, ok := svc.Annotations[annotationInternalTrafficPolicy]
+		if !ok {
+			continue
+		}
+		svc = svc.DeepCopy()
+		delete(svc.Annotations, annotationInternalTrafficPolicy)
+		if len(svc.Annotations) == 0 {
+			svc.Annotations = nil
+		}
+		data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(svc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to convert Service %s", svc.Name)
+		}
+		u := &unstructured.Unstructured{Object: data}
+		if err := unstructured.SetNestedField(u.Object, policy, "spec", "internalTrafficPolicy"); err != nil {
+			return nil, errors.Wrapf(err, "unable to set the internalTrafficPolicy of Service %s", svc.Name)
+		}
+		objects[i] = u
+	}
+	return objects, nil
+}
+
 // CreateHeadlessService creates a k8s headless service.
 // This is used for docker-compose services without ports. For such services we can't create regular Kubernetes Service.
 // and without Service Pods can't find each other using DNS names.
@@ -440,7 +1347,7 @@ func (k *Kubernetes) CreateHeadlessService(name string, service kobject.ServiceC
 	svc.Spec.ClusterIP = "None"
 
 	// Configure annotations
-	annotations := transformer.ConfigAnnotations(service)
+	annotations := transformer.ConfigServiceAnnotations(service)
 	svc.ObjectMeta.Annotations = annotations
 
 	return svc
@@ -456,7 +1363,7 @@ func (k *Kubernetes) UpdateKubernetesObjects(name string, service kobject.Servic
 	}
 
 	// Configure the container volumes.
-	volumesMount, volumes, pvc, cms, err := k.ConfigVolumes(name, service)
+	volumesMount, volumes, pvc, cms, secrets, err := k.ConfigVolumes(name, service)
 	if err != nil {
 		return errors.Wrap(err, "k.ConfigVolumes failed")
 	}
@@ -485,12 +1392,20 @@ func (k *Kubernetes) UpdateKubernetesObjects(name string, service kobject.Servic
 		}
 	}
 
+	for _, s := range secrets {
+		*objects = append(*objects, s)
+	}
+
 	// Configure the container ports.
 	ports := k.ConfigPorts(name, service)
 
 	// Configure capabilities
 	capabilities := k.ConfigCapabilities(service)
 
+	if service.Init {
+		log.Infof("Service %s shares the process namespace of its pod for init: true, so the pause container reaps its zombie processes", name)
+	}
+
 	// Configure annotations
 	annotations := transformer.ConfigAnnotations(service)
 
@@ -508,9 +1423,15 @@ func (k *Kubernetes) UpdateKubernetesObjects(name string, service kobject.Servic
 		template.Spec.Containers[0].TTY = service.Tty
 		template.Spec.Volumes = append(template.Spec.Volumes, volumes...)
 		template.Spec.NodeSelector = service.Placement
+		// The pause container of a pod sharing its process namespace runs as PID 1 and reaps
+		// the zombie processes, like the init process docker runs for init: true
+		if service.Init {
+			shareProcessNamespace := true
+			template.Spec.ShareProcessNamespace = &shareProcessNamespace
+		}
 		// Configure the HealthCheck
-		// We check to see if it's blank
-		if !reflect.DeepEqual(service.HealthChecks, kobject.HealthCheck{}) {
+		// We check to see if it's blank or disabled
+		if !service.HealthChecks.Disable && !reflect.DeepEqual(service.HealthChecks, kobject.HealthCheck{}) {
 			probe := api.Probe{}
 
 			if len(service.HealthChecks.Test) > 0 {
@@ -538,11 +1459,15 @@ func (k *Kubernetes) UpdateKubernetesObjects(name string, service kobject.Servic
 		if service.StopGracePeriod != "" {
 			template.Spec.TerminationGracePeriodSeconds, err = DurationStrToSecondsInt(service.StopGracePeriod)
 			if err != nil {
-				log.Warningf("Failed to parse duration \"%v\" for service \"%v\"", service.StopGracePeriod, name)
+				log.WithFields(log.Fields{"service": name, "category": "unsupported"}).Warningf("Failed to parse duration \"%v\"", service.StopGracePeriod)
 			}
 		}
 
+		if service.CPUSet != "" {
+			pinCPUs(name, &service, opt)
+		}
 		TranslatePodResource(&service, template)
+		configHugePages(name, service, template)
 
 		// Configure resource reservations
 		podSecurityContext := &api.PodSecurityContext{}
@@ -552,7 +1477,7 @@ func (k *Kubernetes) UpdateKubernetesObjects(name string, service kobject.Servic
 			if service.Pid == "host" {
 				// podSecurityContext.HostPID = true
 			} else {
-				log.Warningf("Ignoring PID key for service \"%v\". Invalid value \"%v\".", name, service.Pid)
+				log.WithFields(log.Fields{"service": name, "category": "unsupported"}).Warningf("Ignoring PID key. Invalid value \"%v\".", service.Pid)
 			}
 		}
 
@@ -560,16 +1485,21 @@ func (k *Kubernetes) UpdateKubernetesObjects(name string, service kobject.Servic
 		if service.GroupAdd != nil {
 			podSecurityContext.SupplementalGroups = service.GroupAdd
 		}
+		podSecurityContext.FSGroup = configFSGroup(name, service, opt, template.Spec.Volumes)
 
 		// Setup security context
 		securityContext := &api.SecurityContext{}
 		if service.Privileged {
 			securityContext.Privileged = &service.Privileged
 		}
+		// SELinux keeps containers from connecting to the sockets of the node unless they run as spc_t
+		if opt.HostSockets && k.mountsHostSocket(service) {
+			securityContext.SELinuxOptions = &api.SELinuxOptions{Type: "spc_t"}
+		}
 		if service.User != "" {
 			uid, err := strconv.ParseInt(service.User, 10, 64)
 			if err != nil {
-				log.Warn("Ignoring user directive. User to be specified as a UID (numeric).")
+				log.WithFields(log.Fields{"service": name, "category": "security"}).Warn("Ignoring user directive. User to be specified as a UID (numeric).")
 			} else {
 				securityContext.RunAsUser = &uid
 			}
@@ -598,6 +1528,14 @@ func (k *Kubernetes) UpdateKubernetesObjects(name string, service kobject.Servic
 			template.Spec.Containers[0].ImagePullPolicy = policy
 		}
 
+		// Configure the termination message, e.g. for error reporting tools reading the status of the pods
+		if service.TerminationMessagePolicy != "" {
+			template.Spec.Containers[0].TerminationMessagePolicy = api.TerminationMessagePolicy(service.TerminationMessagePolicy)
+		}
+		if service.TerminationMessagePath != "" {
+			template.Spec.Containers[0].TerminationMessagePath = service.TerminationMessagePath
+		}
+
 		// Configure the container restart policy.
 		if restart, err := GetRestartPolicy(name, service.Restart); err != nil {
 			return err
@@ -613,6 +1551,25 @@ func (k *Kubernetes) UpdateKubernetesObjects(name string, service kobject.Servic
 			template.Spec.Subdomain = service.DomainName
 		}
 
+		// Run the pods with another container runtime, like gVisor for untrusted code or nvidia for GPUs
+		if service.RuntimeClass != "" {
+			runtimeClass := service.RuntimeClass
+			template.Spec.RuntimeClassName = &runtimeClass
+		}
+		configServiceAccountToken(name, service, template)
+
+		// Join the service mesh, the pod annotations below can still opt out
+		configMesh(template, opt.Mesh)
+		configHostGatewayAliases(template, service, opt.HostGatewayIP)
+
+		// Configure the annotations only meant for the pod template
+		for key, value := range service.PodAnnotations {
+			if template.Annotations == nil {
+				template.Annotations = make(map[string]string)
+			}
+			template.Annotations[key] = value
+		}
+
 		return nil
 	}
 
@@ -631,14 +1588,205 @@ func (k *Kubernetes) UpdateKubernetesObjects(name string, service kobject.Servic
 			switch objType := obj.(type) {
 			case *appsv1.Deployment:
 				objType.Spec.Strategy.Type = appsv1.RecreateDeploymentStrategyType
+				if service.WithKomposeAnnotation {
+					annotateRecreate(&objType.ObjectMeta, objType.Spec.Template.Spec)
+				}
 			case *deployapi.DeploymentConfig:
 				objType.Spec.Strategy.Type = deployapi.DeploymentStrategyTypeRecreate
+				if service.WithKomposeAnnotation && objType.Spec.Template != nil {
+					annotateRecreate(&objType.ObjectMeta, objType.Spec.Template.Spec)
+				}
 			}
 		}
 	}
 	return nil
 }
 
+// CPUSetSize returns the number of CPUs of a cpuset like "0-3,8"
+func CPUSetSize(cpuset string) (int, error) {
+	cpus := map[int]bool{}
+	for _, part := range strings.Split(cpuset, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil || first < 0 {
+			return 0, fmt.Errorf("invalid cpuset %q, expected CPUs like 0-3,8", cpuset)
+		}
+		last := first
+		if len(bounds) == 2 {
+			last, err = strconv.Atoi(bounds[1])
+			if err != nil || last < first {
+				return 0, fmt.Errorf("invalid cpuset %q, expected CPUs like 0-3,8", cpuset)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus[cpu] = true
+		}
+	}
+	return len(cpus), nil
+}
+
+// pinCPUs warns that the cpuset of a service can't be converted, Kubernetes doesn't pin containers
+// to given CPUs. With --pin-cpus, it requests and limits as many whole CPUs as the cpuset has, and
+// requests the memory limit, so the pods have the Guaranteed QoS class the static CPU manager
+// policy of the kubelet gives exclusive CPUs to.
+func pinCPUs(name string, service *kobject.ServiceConfig, opt kobject.ConvertOptions) {
+	logger := log.WithField("service", name)
+	if !opt.PinCPUs {
+		logger.Warnf("cpuset %q is not supported, Kubernetes doesn't pin containers to given CPUs, "+
+			"use --pin-cpus to get exclusive CPUs from the static CPU manager policy of the kubelet instead", service.CPUSet)
+		return
+	}
+	cpus, err := CPUSetSize(service.CPUSet)
+	if err != nil {
+		logger.Warn(err)
+		return
+	}
+
+	service.CPULimit = int64(cpus) * 1000
+	service.CPUReservation = service.CPULimit
+	switch {
+	case service.MemLimit != 0:
+		service.MemReservation = service.MemLimit
+	case service.MemReservation != 0:
+		service.MemLimit = service.MemReservation
+	default:
+		logger.Warnf("No memory limit is set, the pods won't have the Guaranteed QoS class and won't get exclusive CPUs, set mem_limit")
+	}
+	logger.Infof("Requesting %d whole CPUs for cpuset %q", cpus, service.CPUSet)
+}
+
+// defaultHugePagesPath is the directory the huge pages of the pods are mounted at
+const defaultHugePagesPath = "/dev/hugepages"
+
+// configHugePages requests the huge pages of a service for its container and mounts them with
+// emptyDir volumes. Huge pages aren't overcommitted, so their requests equal their limits. With
+// several page sizes, every size is mounted at the directory suffixed by the size, like
+// /dev/hugepages-1Gi.
+func configHugePages(name string, service kobject.ServiceConfig, template *api.PodTemplateSpec) {
+	if len(service.HugePages) == 0 {
+		return
+	}
+	container := &template.Spec.Containers[0]
+	if container.Resources.Limits == nil {
+		container.Resources.Limits = api.ResourceList{}
+	}
+	if container.Resources.Requests == nil {
+		container.Resources.Requests = api.ResourceList{}
+	}
+	// the API server rejects containers requesting huge pages only
+	_, cpu := container.Resources.Limits[api.ResourceCPU]
+	_, memory := container.Resources.Limits[api.ResourceMemory]
+	if !cpu && !memory {
+		log.WithFields(log.Fields{"service": name, "category": "resources"}).Warn("Containers requesting huge pages must request CPU or memory too, set deploy.resources.limits")
+	}
+
+	mountPath := service.HugePagesPath
+	if mountPath == "" {
+		mountPath = defaultHugePagesPath
+	}
+	var pageSizes []string
+	for pageSize := range service.HugePages {
+		pageSizes = append(pageSizes, pageSize)
+	}
+	sort.Strings(pageSizes)
+
+	for _, pageSize := range pageSizes {
+		quantity := resource.MustParse(service.HugePages[pageSize])
+		resourceName := api.ResourceName(api.ResourceHugePagesPrefix + pageSize)
+		container.Resources.Limits[resourceName] = quantity
+		container.Resources.Requests[resourceName] = quantity
+
+		medium, volumeMountPath := api.StorageMediumHugePages, mountPath
+		if len(pageSizes) > 1 {
+			medium = api.StorageMediumHugePagesPrefix + api.StorageMedium(pageSize)
+			volumeMountPath = mountPath + "-" + pageSize
+		}
+		volumeName := name + "-hugepages-" + strings.ToLower(pageSize)
+		template.Spec.Volumes = append(template.Spec.Volumes, api.Volume{
+			Name:         volumeName,
+			VolumeSource: api.VolumeSource{EmptyDir: &api.EmptyDirVolumeSource{Medium: medium}},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, api.VolumeMount{Name: volumeName, MountPath: volumeMountPath})
+	}
+}
+
+// defaultServiceAccountTokenPath is the directory the projected service account token is mounted at
+const defaultServiceAccountTokenPath = "/var/run/secrets/tokens"
+
+// configServiceAccountToken configures the service account tokens of the pods of a service: whether
+// the token of the API server is mounted, and a token projected for the audience of the
+// kompose.serviceaccount.token labels, e.g. for a cloud IAM exchanging it for credentials, which the
+// kubelet renews before it expires. The projected token is the token file of its directory.
+func configServiceAccountToken(name string, service kobject.ServiceConfig, template *api.PodTemplateSpec) {
+	template.Spec.AutomountServiceAccountToken = service.AutomountServiceAccountToken
+
+	token := service.ServiceAccountToken
+	if token == (kobject.ServiceAccountToken{}) {
+		return
+	}
+	projection := &api.ServiceAccountTokenProjection{Audience: token.Audience, Path: "token"}
+	if token.ExpirationSeconds != 0 {
+		expiration := token.ExpirationSeconds
+		projection.ExpirationSeconds = &expiration
+	}
+	mountPath := token.Path
+	if mountPath == "" {
+		mountPath = defaultServiceAccountTokenPath
+	}
+
+	volumeName := name + "-token"
+	template.Spec.Volumes = append(template.Spec.Volumes, api.Volume{
+		Name: volumeName,
+		VolumeSource: api.VolumeSource{
+			Projected: &api.ProjectedVolumeSource{
+				Sources: []api.VolumeProjection{{ServiceAccountToken: projection}},
+			},
+		},
+	})
+	template.Spec.Containers[0].VolumeMounts = append(template.Spec.Containers[0].VolumeMounts, api.VolumeMount{
+		Name:      volumeName,
+		MountPath: mountPath,
+		ReadOnly:  true,
+	})
+	log.WithField("service", name).Debugf("Projecting a service account token for the audience %q at %s/token", token.Audience, mountPath)
+}
+
+// configFSGroup returns the fsGroup of the pods of a service: the gid of its kompose.fsgroup label,
+// or with --fs-group-from-user the gid of its user when the pods mount PersistentVolumeClaims,
+// which are often owned by root and not writable for the containers that don't run as root.
+func configFSGroup(name string, service kobject.ServiceConfig, opt kobject.ConvertOptions, volumes []api.Volume) *int64 {
+	if service.FSGroup != nil {
+		return service.FSGroup
+	}
+	if !opt.FSGroupFromUser {
+		return nil
+	}
+	claims := false
+	for _, volume := range volumes {
+		if volume.PersistentVolumeClaim != nil {
+			claims = true
+		}
+	}
+	// containers running as root can write to the volumes anyway
+	if !claims || service.User == "" {
+		return nil
+	}
+
+	// the user is given as user[:group], the group by gid or by name
+	parts := strings.SplitN(service.User, ":", 2)
+	if len(parts) != 2 {
+		log.WithFields(log.Fields{"service": name, "category": "security"}).Warnf("No fsGroup is set, the user %q doesn't give a group, use user: uid:gid or the kompose.fsgroup label", service.User)
+		return nil
+	}
+	gid, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || gid < 0 {
+		log.WithFields(log.Fields{"service": name, "category": "security"}).Warnf("No fsGroup is set, the group of the user %q is not a gid", service.User)
+		return nil
+	}
+	log.WithField("service", name).Debugf("Setting the fsGroup %d of the user %q", gid, service.User)
+	return &gid
+}
+
 // TranslatePodResource config pod resources
 func TranslatePodResource(service *kobject.ServiceConfig, template *api.PodTemplateSpec) {
 	// Configure the resource limits
@@ -706,22 +1854,56 @@ func GetRestartPolicy(name, restart string) (api.RestartPolicy, error) {
 	}
 }
 
-// SortServicesFirst - the objects that we get can be in any order this keeps services first
-// according to best practice kubernetes services should be created first
-// http://kubernetes.io/docs/user-guide/config-best-practices/
-func (k *Kubernetes) SortServicesFirst(objs *[]runtime.Object) {
-	var svc, others, ret []runtime.Object
+// kindOrder ranks the kinds of the objects by the order they are applied in, see SortByDependency
+var kindOrder = map[string]int{
+	"Namespace": 0,
+
+	"ServiceAccount":     1,
+	"Role":               1,
+	"ClusterRole":        1,
+	"RoleBinding":        1,
+	"ClusterRoleBinding": 1,
+	"NetworkPolicy":      1,
+	"ClusterIssuer":      1,
+
+	"ConfigMap":             2,
+	"Secret":                2,
+	"PersistentVolumeClaim": 2,
+	"ImageStream":           2,
+	"BuildConfig":           2,
+	"Certificate":           2,
+
+	"Deployment":            3,
+	"DaemonSet":             3,
+	"StatefulSet":           3,
+	"ReplicationController": 3,
+	"DeploymentConfig":      3,
+	"Pod":                   3,
+	"Job":                   3,
+
+	"Service":                 4,
+	"Endpoints":               4,
+	"Ingress":                 4,
+	"Route":                   4,
+	"HorizontalPodAutoscaler": 4,
+}
 
-	for _, obj := range *objs {
-		if obj.GetObjectKind().GroupVersionKind().Kind == "Service" {
-			svc = append(svc, obj)
-		} else {
-			others = append(others, obj)
+// SortByDependency sorts the objects so every object comes after the objects it depends on, and a
+// single `kubectl apply -f` never starts pods before their config: the Namespaces first, then the
+// ServiceAccounts, RBAC objects and NetworkPolicies, then the ConfigMaps, Secrets and
+// PersistentVolumeClaims, then the controllers, then the Services and Ingresses routing to the pods
+// and the HorizontalPodAutoscalers scaling them. Other kinds, like the extra resources, come last.
+// Objects of the same rank keep their order.
+func (k *Kubernetes) SortByDependency(objs *[]runtime.Object) {
+	rank := func(obj runtime.Object) int {
+		if order, ok := kindOrder[obj.GetObjectKind().GroupVersionKind().Kind]; ok {
+			return order
 		}
+		return len(kindOrder)
 	}
-	ret = append(ret, svc...)
-	ret = append(ret, others...)
-	*objs = ret
+	sort.SliceStable(*objs, func(i, j int) bool {
+		return rank((*objs)[i]) < rank((*objs)[j])
+	})
 }
 
 // RemoveDupObjects remove objects that are dups...eg. configmaps from env.
@@ -772,22 +1954,50 @@ func DurationStrToSecondsInt(s string) (*int64, error) {
 	return &r, nil
 }
 
-// GetEnvsFromFile get env vars from env_file
-func GetEnvsFromFile(file string, opt kobject.ConvertOptions) (map[string]string, error) {
+// GetEnvsFromFile get env vars from env_file, and whether it is encrypted with sops
+func GetEnvsFromFile(file string, opt kobject.ConvertOptions) (map[string]string, bool, error) {
+	envLoad, encrypted, err := readEnvFile(file, opt)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for name, value := range envLoad {
+		if value, ok := transformer.FilterEnv(name, value, opt); ok {
+			envLoad[name] = value
+		} else {
+			delete(envLoad, name)
+		}
+	}
+
+	return envLoad, encrypted, nil
+}
+
+// readEnvFile reads the environment variables of an env_file, relative to the compose file, and
+// whether it is encrypted with sops, in which case its variables go into a Secret
+func readEnvFile(file string, opt kobject.ConvertOptions) (map[string]string, bool, error) {
 	// Get the correct file context / directory
 	composeDir, err := transformer.GetComposeFileDir(opt.InputFiles)
 	if err != nil {
-		return nil, errors.Wrap(err, "Unable to load file context")
+		return nil, false, errors.Wrap(err, "Unable to load file context")
 	}
 	fileLocation := path.Join(composeDir, file)
 
 	// Load environment variables from file
-	envLoad, err := godotenv.Read(fileLocation)
+	data, err := ioutil.ReadFile(fileLocation)
 	if err != nil {
-		return nil, errors.Wrap(err, "Unable to read env_file")
+		return nil, false, errors.Wrap(err, "Unable to read env_file")
 	}
-
-	return envLoad, nil
+	encrypted := sops.IsEncrypted(data)
+	if encrypted {
+		if data, err = opt.SOPS().ReadFile(fileLocation); err != nil {
+			return nil, false, errors.Wrap(err, "Unable to read env_file")
+		}
+	}
+	envLoad, err := godotenv.Unmarshal(string(data))
+	if err != nil {
+		return nil, false, errors.Wrap(err, "Unable to read env_file")
+	}
+	return envLoad, encrypted, nil
 }
 
 // GetContentFromFile gets the content from the file..
@@ -17,16 +17,29 @@ limitations under the License.
 package kubernetes
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"os"
 	"path/filepath"
 
 	"github.com/kubernetes/kompose/pkg/kobject"
 	"github.com/kubernetes/kompose/pkg/testutils"
+	"k8s.io/apimachinery/pkg/runtime"
 
 	"reflect"
 
@@ -432,6 +445,30 @@ func TestSortedKeys(t *testing.T) {
 	}
 }
 
+func TestSortByDependency(t *testing.T) {
+	k := Kubernetes{}
+	objects, err := k.Transform(kobject.KomposeObject{ServiceConfigs: map[string]kobject.ServiceConfig{
+		"web": {
+			Image:         "nginx",
+			Port:          []kobject.Ports{{HostPort: 80, ContainerPort: 80, Protocol: corev1.ProtocolTCP}},
+			Network:       []string{"front"},
+			ExposeService: "web.example.com",
+		},
+	}}, kobject.ConvertOptions{CreateD: true, Replicas: 1, NamespacePerNetwork: true})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	var kinds []string
+	for _, obj := range objects {
+		kinds = append(kinds, obj.GetObjectKind().GroupVersionKind().Kind)
+	}
+	expected := []string{"Namespace", "NetworkPolicy", "Deployment", "Service", "Ingress"}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("Expected the kinds %v, got %v", expected, kinds)
+	}
+}
+
 //test conversion from duration string to seconds *int64
 func TestDurationStrToSecondsInt(t *testing.T) {
 	testCases := map[string]struct {
@@ -458,3 +495,585 @@ func TestDurationStrToSecondsInt(t *testing.T) {
 		}
 	}
 }
+
+// Tests if the converted objects are bundled into a tarball along with an index
+func TestPrintListArchive(t *testing.T) {
+	service := kobject.ServiceConfig{
+		ContainerName: "name",
+		Image:         "image",
+		Port:          []kobject.Ports{kobject.Ports{HostPort: 123, ContainerPort: 456, Protocol: corev1.ProtocolTCP}},
+	}
+
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{"app": service},
+	}
+	k := Kubernetes{}
+
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1})
+	if err != nil {
+		t.Error(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	dir := testutils.CreateLocalDirectory(t)
+	defer os.RemoveAll(dir)
+	target := filepath.Join(dir, "bundle.tar.gz")
+
+	written, err := PrintListSize(objects, kobject.ConvertOptions{OutFile: target, YAMLIndent: 2})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "PrintList failed"))
+	}
+
+	f, err := os.Open(target)
+	if err != nil {
+		t.Fatalf("Unable to open the archive: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("Unable to read the archive: %v", err)
+	}
+
+	var names []string
+	// the size of the manifests of the archive
+	size := 0
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unable to read the archive: %v", err)
+		}
+		names = append(names, header.Name)
+		if header.Name != ArchiveIndexFile {
+			size += int(header.Size)
+		}
+	}
+
+	expected := []string{"app-deployment.yaml", "app-service.yaml", ArchiveIndexFile}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected archive content %v, got %v", expected, names)
+	}
+	if written != size {
+		t.Errorf("Expected %d bytes of manifests written, got %d", size, written)
+	}
+}
+
+func TestPrintDiff(t *testing.T) {
+	oldService := kobject.ServiceConfig{
+		ContainerName: "name",
+		Image:         "image:1",
+		Port:          []kobject.Ports{kobject.Ports{HostPort: 123, ContainerPort: 456, Protocol: corev1.ProtocolTCP}},
+	}
+	newService := oldService
+	newService.Image = "image:2"
+
+	k := Kubernetes{}
+	opt := kobject.ConvertOptions{CreateD: true, Replicas: 1, YAMLIndent: 2}
+
+	oldObjects, err := k.Transform(kobject.KomposeObject{ServiceConfigs: map[string]kobject.ServiceConfig{"app": oldService}}, opt)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	newObjects, err := k.Transform(kobject.KomposeObject{ServiceConfigs: map[string]kobject.ServiceConfig{"app": newService, "db": oldService}}, opt)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	var out bytes.Buffer
+	changed, err := PrintDiff(oldObjects, newObjects, opt, &out)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "PrintDiff failed"))
+	}
+
+	// the app deployment changed, the db deployment and service were added
+	if changed != 3 {
+		t.Errorf("Expected 3 changed objects, got %d\n%s", changed, out.String())
+	}
+	for _, expected := range []string{"--- a/Deployment/app", "-        - image: image:1", "+        - image: image:2", "+++ b/Service/db"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected diff to contain %q, got\n%s", expected, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "Service/app") {
+		t.Errorf("Expected unchanged Service/app to be skipped, got\n%s", out.String())
+	}
+
+	changed, err = PrintDiff(oldObjects, oldObjects, opt, &out)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "PrintDiff failed"))
+	}
+	if changed != 0 {
+		t.Errorf("Expected no changed objects, got %d", changed)
+	}
+}
+
+func TestGetDirName(t *testing.T) {
+	testCases := map[string]struct {
+		opt      kobject.ConvertOptions
+		expected string
+	}{
+		"Default output":        {kobject.ConvertOptions{InputFiles: []string{"docker-compose.yml"}}, "."},
+		"Output set":            {kobject.ConvertOptions{OutFile: "out", CreateChart: true, ProjectName: "proj"}, "out"},
+		"Chart from input file": {kobject.ConvertOptions{InputFiles: []string{"docker-compose.yml"}, CreateChart: true}, "docker-compose"},
+		"Chart from project":    {kobject.ConvertOptions{InputFiles: []string{"docker-compose.yml"}, CreateChart: true, ProjectName: "proj"}, "proj"},
+	}
+
+	for name, test := range testCases {
+		t.Log("Test case:", name)
+		if result := getDirName(test.opt); result != test.expected {
+			t.Errorf("Expected %q, got %q", test.expected, result)
+		}
+	}
+}
+
+func TestAddChecksumAnnotations(t *testing.T) {
+	checksums := func(config string) map[string]string {
+		deployment := &appsv1.Deployment{}
+		deployment.Spec.Template.Spec = corev1.PodSpec{
+			Volumes: []corev1.Volume{{
+				Name:         "config",
+				VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "web-config"}}},
+			}},
+			Containers: []corev1.Container{{
+				EnvFrom: []corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "password"}}}},
+			}},
+		}
+		configMap := &corev1.ConfigMap{Data: map[string]string{"app.conf": config}}
+		configMap.Name = "web-config"
+		secret := &corev1.Secret{Data: map[string][]byte{"password": []byte("secret")}}
+		secret.Name = "password"
+
+		k := Kubernetes{}
+		if err := k.AddChecksumAnnotations([]runtime.Object{configMap, secret, deployment}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return deployment.Spec.Template.Annotations
+	}
+
+	before := checksums("a")
+	after := checksums("b")
+	if before[AnnotationConfigChecksum] == "" || before[AnnotationSecretChecksum] == "" {
+		t.Fatalf("Expected checksum annotations for the ConfigMap and the Secret, got %v", before)
+	}
+	if before[AnnotationConfigChecksum] == after[AnnotationConfigChecksum] {
+		t.Errorf("Expected the config checksum to change with the ConfigMap")
+	}
+	if before[AnnotationSecretChecksum] != after[AnnotationSecretChecksum] {
+		t.Errorf("Expected the secret checksum to stay the same")
+	}
+
+	// the ConfigMap of the same name in another namespace isn't used by the pods
+	deployment := &appsv1.Deployment{}
+	deployment.Namespace = "back"
+	deployment.Spec.Template.Spec.Volumes = []corev1.Volume{{
+		Name:         "config",
+		VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "web-config"}}},
+	}}
+	configMap := &corev1.ConfigMap{Data: map[string]string{"app.conf": "a"}}
+	configMap.Name = "web-config"
+	configMap.Namespace = "front"
+	k := Kubernetes{}
+	if err := k.AddChecksumAnnotations([]runtime.Object{configMap, deployment}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if checksum, ok := deployment.Spec.Template.Annotations[AnnotationConfigChecksum]; ok {
+		t.Errorf("Expected no checksum of the ConfigMap of another namespace, got %s", checksum)
+	}
+}
+
+func TestPrintListGroupByService(t *testing.T) {
+	port := []kobject.Ports{{HostPort: 123, ContainerPort: 456, Protocol: corev1.ProtocolTCP}}
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"app": {ContainerName: "app", Image: "image", Port: port, Network: []string{"front"}},
+			"db":  {ContainerName: "db", Image: "image", Port: port},
+		},
+	}
+	k := Kubernetes{}
+
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	dir := testutils.CreateLocalDirectory(t)
+	defer os.RemoveAll(dir)
+
+	err = PrintList(objects, kobject.ConvertOptions{OutFile: dir + "/", YAMLIndent: 2, GroupBy: GroupByService})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "PrintList failed"))
+	}
+
+	for _, name := range []string{"app.yaml", "db.yaml", "front-networkpolicy.yaml"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected file %s to be created: %v", name, err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "app.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "kind: Deployment") || !strings.Contains(string(data), "kind: Service") || !strings.Contains(string(data), "---\n") {
+		t.Errorf("Expected app.yaml to hold the Deployment and the Service of app, got:\n%s", data)
+	}
+}
+
+func TestPrintListMapping(t *testing.T) {
+	port := []kobject.Ports{{HostPort: 123, ContainerPort: 456, Protocol: corev1.ProtocolTCP}}
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"web-app": {ComposeName: "web_app", Image: "image", Port: port},
+		},
+	}
+	k := Kubernetes{}
+
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	dir := testutils.CreateLocalDirectory(t)
+	defer os.RemoveAll(dir)
+
+	mappingFile := filepath.Join(dir, "kompose-mapping.json")
+	err = PrintList(objects, kobject.ConvertOptions{OutFile: filepath.Join(dir, "k8s") + "/", YAMLIndent: 2,
+		Mapping: mappingFile, ServiceNames: map[string]string{"web-app": "web_app"}})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "PrintList failed"))
+	}
+
+	data, err := ioutil.ReadFile(mappingFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mapping Mapping
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		t.Fatal(err)
+	}
+	service, ok := mapping.Services["web_app"]
+	if !ok || service.Name != "web-app" {
+		t.Fatalf("Expected the service web_app to be mapped to web-app, got %s", data)
+	}
+	expected := []MappedObject{
+		{Kind: "Deployment", Name: "web-app", File: "k8s/web-app-deployment.yaml", Service: "web_app"},
+		{Kind: "Service", Name: "web-app", File: "k8s/web-app-service.yaml", Service: "web_app"},
+	}
+	if !reflect.DeepEqual(service.Objects, expected) {
+		t.Errorf("Expected the objects %v, got %v", expected, service.Objects)
+	}
+	if len(mapping.Objects) != len(objects) {
+		t.Errorf("Expected %d mapped objects, got %d", len(objects), len(mapping.Objects))
+	}
+}
+
+func TestPrintListKubectlCompatible(t *testing.T) {
+	port := []kobject.Ports{{HostPort: 123, ContainerPort: 456, Protocol: corev1.ProtocolTCP}}
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"app": {ContainerName: "app", Image: "image", Port: port, ExposeService: "true"},
+		},
+	}
+	k := Kubernetes{}
+
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	objects = AdaptAPIVersions(objects, map[string]bool{"v1": true, "apps/v1": true, "networking.k8s.io/v1beta1": true})
+
+	dir := testutils.CreateLocalDirectory(t)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "app.yaml")
+
+	err = PrintList(objects, kobject.ConvertOptions{OutFile: file, YAMLIndent: 2, KubectlCompatible: true})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "PrintList failed"))
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "kind: List") {
+		t.Errorf("Expected no List, got:\n%s", data)
+	}
+	if documents := strings.Split(string(data), "---\n"); len(documents) != 3 {
+		t.Errorf("Expected a document for the Service, Deployment and Ingress, got %d:\n%s", len(documents), data)
+	}
+	if !strings.Contains(string(data), "apiVersion: networking.k8s.io/v1beta1\nkind: Ingress") {
+		t.Errorf("Expected the Ingress to use the apiVersion served by the cluster, got:\n%s", data)
+	}
+}
+
+func TestPrintListHeader(t *testing.T) {
+	port := []kobject.Ports{{HostPort: 123, ContainerPort: 456, Protocol: corev1.ProtocolTCP}}
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"app": {ContainerName: "app", Image: "image", Port: port},
+		},
+	}
+	k := Kubernetes{}
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	dir := testutils.CreateLocalDirectory(t)
+	defer os.RemoveAll(dir)
+	headerFile := filepath.Join(dir, "header.txt")
+	if err := ioutil.WriteFile(headerFile, []byte("Copyright 2020 Example Inc.\n\n# Generated by kompose, do not edit.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out") + string(os.PathSeparator)
+
+	err = PrintList(objects, kobject.ConvertOptions{OutFile: out, YAMLIndent: 2, HeaderFile: headerFile})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "PrintList failed"))
+	}
+
+	header := "# Copyright 2020 Example Inc.\n#\n# Generated by kompose, do not edit.\n"
+	for _, name := range []string{"app-deployment.yaml", "app-service.yaml"} {
+		data, err := ioutil.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(data), header+"apiVersion: ") {
+			t.Errorf("Expected %s to start with the header, got:\n%s", name, data)
+		}
+	}
+}
+
+func TestPrintListUnchangedAndStaleFiles(t *testing.T) {
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"app": {ContainerName: "app", Image: "image", Port: []kobject.Ports{{HostPort: 123, ContainerPort: 456, Protocol: corev1.ProtocolTCP}}},
+		},
+	}
+	k := Kubernetes{}
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	dir := testutils.CreateLocalDirectory(t)
+	defer os.RemoveAll(dir)
+	opt := kobject.ConvertOptions{OutFile: dir + "/", YAMLIndent: 2, RemoveStaleFiles: true}
+	if err := PrintList(objects, opt); err != nil {
+		t.Fatal(errors.Wrap(err, "PrintList failed"))
+	}
+
+	deployment := filepath.Join(dir, "app-deployment.yaml")
+	modTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(deployment, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"db-service.yaml": "apiVersion: v1\nkind: Service\nmetadata:\n  name: db\n",
+		"notes.yaml":      "todo: ingress\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := PrintList(objects, opt); err != nil {
+		t.Fatal(errors.Wrap(err, "PrintList failed"))
+	}
+	info, err := os.Stat(deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("Expected the unchanged app-deployment.yaml not to be rewritten, got the modification time %s", info.ModTime())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "db-service.yaml")); !os.IsNotExist(err) {
+		t.Errorf("Expected the stale db-service.yaml to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.yaml")); err != nil {
+		t.Errorf("Expected notes.yaml, which holds no object, to be kept: %v", err)
+	}
+}
+
+func TestAdaptAPIVersionsIngressV1(t *testing.T) {
+	port := []kobject.Ports{{HostPort: 123, ContainerPort: 456, Protocol: corev1.ProtocolTCP}}
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"app": {ContainerName: "app", Image: "image", Port: port, ExposeService: "example.com/api", ExposeServiceTLS: "app-tls"},
+		},
+	}
+	k := Kubernetes{}
+
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	objects = AdaptAPIVersions(objects, map[string]bool{"v1": true, "apps/v1": true, "networking.k8s.io/v1": true})
+
+	var ingress *networkingv1.Ingress
+	for _, obj := range objects {
+		if i, ok := obj.(*networkingv1.Ingress); ok {
+			ingress = i
+		}
+	}
+	if ingress == nil {
+		t.Fatalf("Expected the Ingress to be converted to networking.k8s.io/v1, got %v", objects)
+	}
+	if ingress.APIVersion != "networking.k8s.io/v1" || ingress.Name != "app" {
+		t.Errorf("Expected the networking.k8s.io/v1 Ingress app, got %s %s", ingress.APIVersion, ingress.Name)
+	}
+	if len(ingress.Spec.TLS) != 1 || ingress.Spec.TLS[0].SecretName != "app-tls" {
+		t.Errorf("Expected the TLS secret app-tls, got %v", ingress.Spec.TLS)
+	}
+	path := ingress.Spec.Rules[0].HTTP.Paths[0]
+	if ingress.Spec.Rules[0].Host != "example.com" || path.Path != "/api" || path.PathType == nil {
+		t.Errorf("Expected a typed path /api of example.com, got %v", ingress.Spec.Rules[0])
+	}
+	if path.Backend.Service == nil || path.Backend.Service.Name != "app" || path.Backend.Service.Port.Number != 123 {
+		t.Errorf("Expected the backend to be port 123 of the Service app, got %v", path.Backend)
+	}
+}
+
+func TestPrintListPushChart(t *testing.T) {
+	blobs := map[string][]byte{}
+	var manifest []byte
+	var manifestPath string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			w.Write([]byte(`{"token": "secret"}`))
+			return
+		case r.Header.Get("Authorization") != "Bearer secret":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		switch {
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodPost:
+			w.Header().Set("Location", "/upload")
+			w.WriteHeader(http.StatusAccepted)
+		case r.URL.Path == "/upload":
+			blobs[r.URL.Query().Get("digest")] = body
+			w.WriteHeader(http.StatusCreated)
+		default:
+			manifest, manifestPath = body, r.URL.Path
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+
+	dir := testutils.CreateLocalDirectory(t)
+	defer os.RemoveAll(dir)
+	opt := kobject.ConvertOptions{
+		OutFile:       filepath.Join(dir, "web") + "/",
+		CreateChart:   true,
+		YAMLIndent:    2,
+		PushChart:     "oci://" + strings.TrimPrefix(server.URL, "http://") + "/charts",
+		PushPlainHTTP: true,
+	}
+	if err := PrintList([]runtime.Object{&corev1.Service{}}, opt); err != nil {
+		t.Fatal(errors.Wrap(err, "PrintList failed"))
+	}
+
+	if manifestPath != "/v2/charts/web/manifests/"+ChartVersion {
+		t.Fatalf("Expected the chart pushed as charts/web:%s, got %q", ChartVersion, manifestPath)
+	}
+	var pushed struct {
+		Config struct{ Digest string }
+		Layers []struct{ MediaType, Digest string }
+	}
+	if err := json.Unmarshal(manifest, &pushed); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(blobs[pushed.Config.Digest]), `"name":"web"`) {
+		t.Errorf("Expected the config of chart web, got %s", blobs[pushed.Config.Digest])
+	}
+	if len(pushed.Layers) != 1 || pushed.Layers[0].MediaType != "application/vnd.cncf.helm.chart.content.v1.tar+gzip" {
+		t.Fatalf("Expected a single chart layer, got %v", pushed.Layers)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(blobs[pushed.Layers[0].Digest]))
+	if err != nil {
+		t.Fatalf("Unable to read the chart: %v", err)
+	}
+	found := false
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unable to read the chart: %v", err)
+		}
+		if header.Name == "web/Chart.yaml" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected web/Chart.yaml in the packaged chart")
+	}
+}
+
+func TestCPUSetSize(t *testing.T) {
+	testCases := map[string]int{
+		"0":       1,
+		"0-3":     4,
+		"1,3":     2,
+		"0-3,2,8": 5,
+		"a":       -1,
+		"3-1":     -1,
+		"":        -1,
+	}
+	for cpuset, want := range testCases {
+		got, err := CPUSetSize(cpuset)
+		if want == -1 {
+			if err == nil {
+				t.Errorf("Expected an error for cpuset %q", cpuset)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Errorf("Expected %d CPUs for cpuset %q, got %d, %v", want, cpuset, got, err)
+		}
+	}
+}
+
+func TestConfigFSGroup(t *testing.T) {
+	claim := []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data"}}}}
+	emptyDir := []corev1.Volume{{Name: "cache", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}}
+	gid := int64(2000)
+
+	testCases := map[string]struct {
+		service         kobject.ServiceConfig
+		fsGroupFromUser bool
+		volumes         []corev1.Volume
+		want            int64
+	}{
+		"inferred from the user":        {kobject.ServiceConfig{User: "1000:1001"}, true, claim, 1001},
+		"not inferred without the flag": {kobject.ServiceConfig{User: "1000:1001"}, false, claim, -1},
+		"no claim":                      {kobject.ServiceConfig{User: "1000:1001"}, true, emptyDir, -1},
+		"user without group":            {kobject.ServiceConfig{User: "1000"}, true, claim, -1},
+		"group name":                    {kobject.ServiceConfig{User: "app:app"}, true, claim, -1},
+		"label":                         {kobject.ServiceConfig{User: "1000:1001", FSGroup: &gid}, false, emptyDir, 2000},
+	}
+	for name, test := range testCases {
+		got := configFSGroup("app", test.service, kobject.ConvertOptions{FSGroupFromUser: test.fsGroupFromUser}, test.volumes)
+		if test.want == -1 {
+			if got != nil {
+				t.Errorf("%s: expected no fsGroup, got %d", name, *got)
+			}
+			continue
+		}
+		if got == nil || *got != test.want {
+			t.Errorf("%s: expected the fsGroup %d, got %v", name, test.want, got)
+		}
+	}
+}
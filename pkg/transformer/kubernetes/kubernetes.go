@@ -17,6 +17,7 @@ limitations under the License.
 package kubernetes
 
 import (
+	"encoding/pem"
 	"fmt"
 	"github.com/fatih/structs"
 	"github.com/kubernetes/kompose/pkg/kobject"
@@ -33,9 +34,12 @@ import (
 	"reflect"
 	"regexp"
 	"strconv"
+	"text/template"
+	"time"
 
 	buildapi "github.com/openshift/api/build/v1"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	networkingv1beta1 "k8s.io/api/networking/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -63,6 +67,9 @@ const TIMEOUT = 300
 // PVCRequestSize (Persistent Volume Claim) has default size
 const PVCRequestSize = "100Mi"
 
+// ArchiveIndexFile lists the generated files when the output is a tarball
+const ArchiveIndexFile = "index.txt"
+
 const (
 	// DeploymentController is controller type for Deployment
 	DeploymentController = "deployment"
@@ -70,15 +77,42 @@ const (
 	DaemonSetController = "daemonset"
 	// ReplicationController is controller type for  ReplicationController
 	ReplicationController = "replicationcontroller"
+	// StatefulSetController is controller type for StatefulSet
+	StatefulSetController = "statefulset"
+	// DeploymentConfigController is controller type for the OpenShift DeploymentConfig
+	DeploymentConfigController = "deploymentconfig"
+)
+
+const (
+	// MeshIstio injects the Istio sidecar into the pods
+	MeshIstio = "istio"
+	// MeshLinkerd injects the Linkerd proxy into the pods
+	MeshLinkerd = "linkerd"
+
+	// AnnotationConfigChecksum is the pod template annotation holding the checksum of the ConfigMaps used by the pods
+	AnnotationConfigChecksum = "checksum/config"
+	// AnnotationSecretChecksum is the pod template annotation holding the checksum of the Secrets used by the pods
+	AnnotationSecretChecksum = "checksum/secret"
+
+	// ChartVersion is the version of the generated Helm charts, and their tag when pushed with --push
+	ChartVersion = "0.0.1"
+
+	// GroupByService writes the objects of every service into one file
+	GroupByService = "service"
+
+	// HostGateway is the IP of extra_hosts that docker replaces with the IP of the host
+	HostGateway = "host-gateway"
 )
 
 // CheckUnsupportedKey checks if given komposeObject contains
 // keys that are not supported by this transformer.
-// list of all unsupported keys are stored in unsupportedKey variable
+// list of all unsupported keys are stored in unsupportedKey variable,
+// which is left unchanged so the transformers can share it
 // returns list of TODO: ....
 func (k *Kubernetes) CheckUnsupportedKey(komposeObject *kobject.KomposeObject, unsupportedKey map[string]bool) []string {
 	// collect all keys found in project
 	var keysFound []string
+	seen := map[string]bool{}
 
 	for _, serviceConfig := range komposeObject.ServiceConfigs {
 		// this reflection is used in check for empty arrays
@@ -87,7 +121,7 @@ func (k *Kubernetes) CheckUnsupportedKey(komposeObject *kobject.KomposeObject, u
 
 		for _, f := range s.Fields() {
 			// Check if given key is among unsupported keys, and skip it if we already saw this key
-			if alreadySaw, ok := unsupportedKey[f.Name()]; ok && !alreadySaw {
+			if _, ok := unsupportedKey[f.Name()]; ok && !seen[f.Name()] {
 
 				if f.IsExported() && !f.IsZero() {
 					// IsZero returns false for empty array/slice ([])
@@ -101,7 +135,7 @@ func (k *Kubernetes) CheckUnsupportedKey(komposeObject *kobject.KomposeObject, u
 					//get tag from kobject service configure
 					tag := f.Tag(komposeObject.LoadedFrom)
 					keysFound = append(keysFound, tag)
-					unsupportedKey[f.Name()] = true
+					seen[f.Name()] = true
 				}
 			}
 		}
@@ -152,7 +186,7 @@ func (k *Kubernetes) InitPodSpecWithConfigMap(name string, image string, service
 		volSource.Name = cmVolName
 		key, err := service.GetConfigMapKeyFromMeta(value.Source)
 		if err != nil {
-			log.Warnf("cannot parse config %s , %s", value.Source, err.Error())
+			log.WithField("category", "volumes").Warnf("cannot parse config %s , %s", value.Source, err.Error())
 			// mostly it's external
 			continue
 		}
@@ -212,12 +246,42 @@ func (k *Kubernetes) InitSvc(name string, service kobject.ServiceConfig) *api.Se
 	return svc
 }
 
+// InitObjectForEnv initializes the object holding the variables of an env_file: a ConfigMap, or a
+// Secret if the env_file is encrypted with sops, so its decrypted values aren't written in plaintext
+func (k *Kubernetes) InitObjectForEnv(name string, service kobject.ServiceConfig, opt kobject.ConvertOptions, envFile string) (runtime.Object, error) {
+	envs, encrypted, err := GetEnvsFromFile(envFile, opt)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to retrieve env file")
+	}
+	if !encrypted {
+		return k.InitConfigMapForEnv(name, service, opt, envFile)
+	}
+
+	envName := FormatEnvName(envFile)
+	data := make(map[string][]byte, len(envs))
+	for key, value := range envs {
+		data[key] = []byte(value)
+	}
+	return &api.Secret{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Secret",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   envName,
+			Labels: transformer.ConfigLabels(name + "-" + envName),
+		},
+		Type: api.SecretTypeOpaque,
+		Data: data,
+	}, nil
+}
+
 // InitConfigMapForEnv initializes a ConfigMap object
-func (k *Kubernetes) InitConfigMapForEnv(name string, service kobject.ServiceConfig, opt kobject.ConvertOptions, envFile string) *api.ConfigMap {
+func (k *Kubernetes) InitConfigMapForEnv(name string, service kobject.ServiceConfig, opt kobject.ConvertOptions, envFile string) (*api.ConfigMap, error) {
 
-	envs, err := GetEnvsFromFile(envFile, opt)
+	envs, _, err := GetEnvsFromFile(envFile, opt)
 	if err != nil {
-		log.Fatalf("Unable to retrieve env file: %s", err)
+		return nil, errors.Wrap(err, "unable to retrieve env file")
 	}
 
 	// Remove root pathing
@@ -237,7 +301,7 @@ func (k *Kubernetes) InitConfigMapForEnv(name string, service kobject.ServiceCon
 		Data: envs,
 	}
 
-	return configMap
+	return configMap, nil
 }
 
 // IntiConfigMapFromFileOrDir will create a configmap from dir or file
@@ -282,7 +346,10 @@ func (k *Kubernetes) IntiConfigMapFromFileOrDir(name, cmName, filePath string, s
 
 	case mode.IsRegular():
 		// do file stuff
-		configMap = k.InitConfigMapFromFile(name, service, filePath)
+		configMap, err = k.InitConfigMapFromFile(name, service, filePath)
+		if err != nil {
+			return nil, err
+		}
 		configMap.Name = cmName
 		configMap.Annotations = map[string]string{
 			"use-subpath": "true",
@@ -292,6 +359,104 @@ func (k *Kubernetes) IntiConfigMapFromFileOrDir(name, cmName, filePath string, s
 	return configMap, nil
 }
 
+// InitSecretFromFileOrDir creates a Secret from the PEM files of a TLS bind mount, which is
+// either a single file or a directory. A certificate and key named tls.crt and tls.key make it
+// a kubernetes.io/tls Secret.
+func (k *Kubernetes) InitSecretFromFileOrDir(name, secretName, filePath string) (*api.Secret, error) {
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read the TLS files of service %s", name)
+	}
+
+	files := []string{filePath}
+	if fi.IsDir() {
+		entries, err := ioutil.ReadDir(filePath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read the TLS files of service %s", name)
+		}
+		files = nil
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(filePath, entry.Name()))
+			}
+		}
+	}
+
+	data := make(map[string][]byte)
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read the TLS files of service %s", name)
+		}
+		if block, _ := pem.Decode(content); block == nil {
+			log.WithFields(log.Fields{"service": name, "category": "security"}).Warnf("TLS file %q isn't PEM encoded", file)
+		}
+		data[filepath.Base(file)] = content
+	}
+
+	secret := &api.Secret{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Secret",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   secretName,
+			Labels: transformer.ConfigLabels(name),
+		},
+		Type: api.SecretTypeOpaque,
+		Data: data,
+	}
+	_, hasCert := data[api.TLSCertKey]
+	_, hasKey := data[api.TLSPrivateKeyKey]
+	if hasCert && hasKey {
+		secret.Type = api.SecretTypeTLS
+	}
+	if !fi.IsDir() {
+		secret.Annotations = map[string]string{
+			"use-subpath": "true",
+		}
+	}
+	return secret, nil
+}
+
+// tlsVolumePaths returns the host paths of the bind mounts listed by the kompose.volume.tls label,
+// relative paths being resolved against the directory of the compose file
+func (k *Kubernetes) tlsVolumePaths(service kobject.ServiceConfig) (map[string]bool, error) {
+	paths := make(map[string]bool)
+	value := service.Labels[compose.LabelVolumeTLS]
+	if value == "" {
+		return paths, nil
+	}
+	for _, p := range strings.Split(value, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		abs, err := k.absHostPath(p)
+		if err != nil {
+			return nil, err
+		}
+		paths[abs] = false
+	}
+	return paths, nil
+}
+
+// absHostPath resolves a relative host path against the directory of the compose file
+func (k *Kubernetes) absHostPath(p string) (string, error) {
+	if filepath.IsAbs(p) {
+		return filepath.Clean(p), nil
+	}
+	dir := "."
+	if len(k.Opt.InputFiles) > 0 {
+		var err error
+		dir, err = transformer.GetComposeFileDir(k.Opt.InputFiles)
+		if err != nil {
+			return "", err
+		}
+	}
+	return filepath.Abs(filepath.Join(dir, p))
+}
+
 // useSubPathMount check if a configmap should be mounted as subpath
 // in this situation, this configmap will only contains 1 key in data
 func useSubPathMount(cm *api.ConfigMap) bool {
@@ -305,10 +470,10 @@ func useSubPathMount(cm *api.ConfigMap) bool {
 }
 
 //InitConfigMapFromFile initializes a ConfigMap object
-func (k *Kubernetes) InitConfigMapFromFile(name string, service kobject.ServiceConfig, fileName string) *api.ConfigMap {
+func (k *Kubernetes) InitConfigMapFromFile(name string, service kobject.ServiceConfig, fileName string) (*api.ConfigMap, error) {
 	content, err := GetContentFromFile(fileName)
 	if err != nil {
-		log.Fatalf("Unable to retrieve file: %s", err)
+		return nil, errors.Wrap(err, "unable to retrieve file")
 	}
 
 	dataMap := make(map[string]string)
@@ -331,7 +496,7 @@ func (k *Kubernetes) InitConfigMapFromFile(name string, service kobject.ServiceC
 		},
 		Data: dataMap,
 	}
-	return configMap
+	return configMap, nil
 }
 
 // InitD initializes Kubernetes Deployment object
@@ -371,6 +536,8 @@ func (k *Kubernetes) InitD(name string, service kobject.ServiceConfig, replicas
 	}
 	dc.Spec.Template.Labels = transformer.ConfigLabels(name)
 
+	dc.Spec.ProgressDeadlineSeconds = service.GetProgressDeadlineSeconds()
+
 	update := service.GetKubernetesUpdateStrategy()
 	if update != nil {
 		dc.Spec.Strategy = appsv1.DeploymentStrategy{
@@ -403,6 +570,71 @@ func (k *Kubernetes) InitDS(name string, service kobject.ServiceConfig) *appsv1.
 	return ds
 }
 
+// InitRC initializes Kubernetes ReplicationController object
+func (k *Kubernetes) InitRC(name string, service kobject.ServiceConfig, replicas int) *api.ReplicationController {
+	rp := int32(replicas)
+
+	rc := &api.ReplicationController{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ReplicationController",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: transformer.ConfigAllLabels(name, &service),
+		},
+		Spec: api.ReplicationControllerSpec{
+			Replicas: &rp,
+			Template: &api.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: transformer.ConfigLabels(name),
+				},
+				Spec: k.InitPodSpec(name, service.Image, service.ImagePullSecret),
+			},
+		},
+	}
+	return rc
+}
+
+// InitSS initializes Kubernetes StatefulSet object, governed by the Service of the same name
+func (k *Kubernetes) InitSS(name string, service kobject.ServiceConfig, replicas int) *appsv1.StatefulSet {
+
+	var podSpec api.PodSpec
+	if len(service.Configs) > 0 {
+		podSpec = k.InitPodSpecWithConfigMap(name, service.Image, service)
+	} else {
+		podSpec = k.InitPodSpec(name, service.Image, service.ImagePullSecret)
+	}
+
+	rp := int32(replicas)
+
+	ss := &appsv1.StatefulSet{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "StatefulSet",
+			APIVersion: "apps/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: transformer.ConfigAllLabels(name, &service),
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:    &rp,
+			ServiceName: name,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: transformer.ConfigLabels(name),
+			},
+			Template: api.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      transformer.ConfigLabels(name),
+					Annotations: transformer.ConfigAnnotations(service),
+				},
+				Spec: podSpec,
+			},
+		},
+	}
+	return ss
+}
+
 func (k *Kubernetes) initIngress(name string, service kobject.ServiceConfig, port int32) *networkingv1beta1.Ingress {
 
 	hosts := regexp.MustCompile("[ ,]*,[ ,]*").Split(service.ExposeService, -1)
@@ -473,8 +705,7 @@ func (k *Kubernetes) CreateSecrets(komposeObject kobject.KomposeObject) ([]*api.
 		if config.File != "" {
 			dataString, err := GetContentFromFile(config.File)
 			if err != nil {
-				log.Fatal("unable to read secret from file: ", config.File)
-				return nil, err
+				return nil, errors.Wrapf(err, "unable to read secret from file %s", config.File)
 			}
 			data := []byte(dataString)
 			secret := &api.Secret{
@@ -491,7 +722,7 @@ func (k *Kubernetes) CreateSecrets(komposeObject kobject.KomposeObject) ([]*api.
 			}
 			objects = append(objects, secret)
 		} else {
-			log.Warnf("External secrets %s is not currently supported - ignoring", name)
+			log.WithField("category", "unsupported").Warnf("External secrets %s is not currently supported - ignoring", name)
 		}
 	}
 	return objects, nil
@@ -549,11 +780,13 @@ func (k *Kubernetes) ConfigPorts(name string, service kobject.ServiceConfig) []a
 		// If the default is already TCP, no need to include it.
 		if port.Protocol == api.ProtocolTCP {
 			ports = append(ports, api.ContainerPort{
+				Name:          k.ConfigPortName(port),
 				ContainerPort: port.ContainerPort,
 				HostIP:        port.HostIP,
 			})
 		} else {
 			ports = append(ports, api.ContainerPort{
+				Name:          k.ConfigPortName(port),
 				ContainerPort: port.ContainerPort,
 				Protocol:      port.Protocol,
 				HostIP:        port.HostIP,
@@ -566,21 +799,55 @@ func (k *Kubernetes) ConfigPorts(name string, service kobject.ServiceConfig) []a
 	return ports
 }
 
+// ConfigPortName returns the name of the container port. Ports are named either by the
+// kompose.port.name.* labels or, with --name-ports, after their number ("port-8080").
+// It returns an empty string for ports that stay unnamed.
+func (k *Kubernetes) ConfigPortName(port kobject.Ports) string {
+	if port.Name != "" {
+		return port.Name
+	}
+	if !k.Opt.NamePorts {
+		return ""
+	}
+	name := fmt.Sprintf("port-%d", port.ContainerPort)
+	if port.Protocol != "" && port.Protocol != api.ProtocolTCP {
+		name = fmt.Sprintf("%s-%s", name, strings.ToLower(string(port.Protocol)))
+	}
+	return name
+}
+
+// configTargetPort returns the target port of a service port, which refers to the
+// container port by name if it has one
+func (k *Kubernetes) configTargetPort(port kobject.Ports) intstr.IntOrString {
+	if name := k.ConfigPortName(port); name != "" {
+		return intstr.FromString(name)
+	}
+	var targetPort intstr.IntOrString
+	targetPort.IntVal = port.ContainerPort
+	targetPort.StrVal = strconv.Itoa(int(port.ContainerPort))
+	return targetPort
+}
+
 func (k *Kubernetes) ConfigLBServicePorts(name string, service kobject.ServiceConfig) ([]api.ServicePort, []api.ServicePort) {
 	var tcpPorts []api.ServicePort
 	var udpPorts []api.ServicePort
+	seenNames := map[string]bool{}
 	for _, port := range service.Port {
 		if port.HostPort == 0 {
 			port.HostPort = port.ContainerPort
 		}
-		var targetPort intstr.IntOrString
-		targetPort.IntVal = port.ContainerPort
-		targetPort.StrVal = strconv.Itoa(int(port.ContainerPort))
+
+		// use the name of the container port unless another service port already took it
+		name := strconv.Itoa(int(port.HostPort))
+		if portName := k.ConfigPortName(port); portName != "" && !seenNames[portName] {
+			name = portName
+		}
+		seenNames[name] = true
 
 		servicePort := api.ServicePort{
-			Name:       strconv.Itoa(int(port.HostPort)),
+			Name:       name,
 			Port:       port.HostPort,
-			TargetPort: targetPort,
+			TargetPort: k.configTargetPort(port),
 		}
 
 		// If the default is already TCP, no need to include it.
@@ -602,6 +869,7 @@ func (k *Kubernetes) ConfigLBServicePorts(name string, service kobject.ServiceCo
 func (k *Kubernetes) ConfigServicePorts(name string, service kobject.ServiceConfig) []api.ServicePort {
 	servicePorts := []api.ServicePort{}
 	seenPorts := make(map[int]struct{}, len(service.Port))
+	seenNames := map[string]bool{}
 
 	var servicePort api.ServicePort
 	for _, port := range service.Port {
@@ -609,10 +877,6 @@ func (k *Kubernetes) ConfigServicePorts(name string, service kobject.ServiceConf
 			port.HostPort = port.ContainerPort
 		}
 
-		var targetPort intstr.IntOrString
-		targetPort.IntVal = port.ContainerPort
-		targetPort.StrVal = strconv.Itoa(int(port.ContainerPort))
-
 		// decide the name based on whether we saw this port before
 		name := strconv.Itoa(int(port.HostPort))
 		if _, ok := seenPorts[int(port.HostPort)]; ok {
@@ -622,11 +886,16 @@ func (k *Kubernetes) ConfigServicePorts(name string, service kobject.ServiceConf
 			}
 			name = fmt.Sprintf("%s-%s", name, strings.ToLower(string(port.Protocol)))
 		}
+		// use the name of the container port unless another service port already took it
+		if portName := k.ConfigPortName(port); portName != "" && !seenNames[portName] {
+			name = portName
+		}
+		seenNames[name] = true
 
 		servicePort = api.ServicePort{
 			Name:       name,
 			Port:       port.HostPort,
-			TargetPort: targetPort,
+			TargetPort: k.configTargetPort(port),
 		}
 
 		if service.ServiceType == string(api.ServiceTypeNodePort) && service.NodePortPort != 0 {
@@ -702,10 +971,10 @@ func (k *Kubernetes) ConfigSecretVolumes(name string, service kobject.ServiceCon
 	if len(service.Secrets) > 0 {
 		for _, secretConfig := range service.Secrets {
 			if secretConfig.UID != "" {
-				log.Warnf("Ignore pid in secrets for service: %s", name)
+				log.WithFields(log.Fields{"service": name, "category": "unsupported"}).Warn("Ignore uid in secrets")
 			}
 			if secretConfig.GID != "" {
-				log.Warnf("Ignore gid in secrets for service: %s", name)
+				log.WithFields(log.Fields{"service": name, "category": "unsupported"}).Warn("Ignore gid in secrets")
 			}
 
 			var itemPath string // should be the filename
@@ -771,11 +1040,12 @@ func (k *Kubernetes) ConfigSecretVolumes(name string, service kobject.ServiceCon
 }
 
 // ConfigVolumes configure the container volumes.
-func (k *Kubernetes) ConfigVolumes(name string, service kobject.ServiceConfig) ([]api.VolumeMount, []api.Volume, []*api.PersistentVolumeClaim, []*api.ConfigMap, error) {
+func (k *Kubernetes) ConfigVolumes(name string, service kobject.ServiceConfig) ([]api.VolumeMount, []api.Volume, []*api.PersistentVolumeClaim, []*api.ConfigMap, []*api.Secret, error) {
 	volumeMounts := []api.VolumeMount{}
 	volumes := []api.Volume{}
 	var PVCs []*api.PersistentVolumeClaim
 	var cms []*api.ConfigMap
+	var secrets []*api.Secret
 	var volumeName string
 
 	// Set a var based on if the user wants to use empty volumes
@@ -788,11 +1058,19 @@ func (k *Kubernetes) ConfigVolumes(name string, service kobject.ServiceConfig) (
 		useEmptyVolumes = true
 	}
 
+	// local clusters mount the bind mounts from the nodes, which mount the directories of the user
+	bindHostPath := k.Opt.LocalCluster && !useEmptyVolumes && !useConfigMap
+
 	// config volumes from secret if present
 	secretsVolumeMounts, secretsVolumes := k.ConfigSecretVolumes(name, service)
 	volumeMounts = append(volumeMounts, secretsVolumeMounts...)
 	volumes = append(volumes, secretsVolumes...)
 
+	tlsPaths, err := k.tlsVolumePaths(service)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
 	var count int
 	//iterating over array of `Vols` struct as it contains all necessary information about volumes
 	for _, volume := range service.Volumes {
@@ -800,10 +1078,64 @@ func (k *Kubernetes) ConfigVolumes(name string, service kobject.ServiceConfig) (
 		// check if ro/rw mode is defined, default rw
 		readonly := len(volume.Mode) > 0 && volume.Mode == "ro"
 
+		var host string
+		if volume.Host != "" {
+			if host, err = k.absHostPath(volume.Host); err != nil {
+				return nil, nil, nil, nil, nil, err
+			}
+		}
+
+		// bind mounts of TLS material are packaged into a Secret, whatever the --volumes type
+		if host != "" {
+			if _, ok := tlsPaths[host]; ok {
+				tlsPaths[host] = true
+				secretName := strings.Replace(volume.PVCName, "claim", "tls", 1)
+				secret, err := k.InitSecretFromFileOrDir(name, secretName, host)
+				if err != nil {
+					return nil, nil, nil, nil, nil, err
+				}
+				secrets = append(secrets, secret)
+				volMount := api.VolumeMount{
+					Name:      secretName,
+					ReadOnly:  true,
+					MountPath: volume.Container,
+				}
+				volsource := k.ConfigTLSSecretVolumeSource(secretName, volume.Container, secret)
+				if volsource.Secret.Items != nil {
+					volMount.SubPath = volsource.Secret.Items[0].Path
+				}
+				volumeMounts = append(volumeMounts, volMount)
+				volumes = append(volumes, api.Volume{
+					Name:         secretName,
+					VolumeSource: *volsource,
+				})
+				count++
+				continue
+			}
+		}
+
+		if host != "" && isHostSocket(host) {
+			if k.Opt.HostSockets {
+				volumeName = strings.Replace(volume.PVCName, "claim", "socket", 1)
+				volumeMounts = append(volumeMounts, api.VolumeMount{
+					Name:      volumeName,
+					MountPath: volume.Container,
+				})
+				volumes = append(volumes, api.Volume{
+					Name:         volumeName,
+					VolumeSource: *k.ConfigHostSocketVolumeSource(host),
+				})
+				count++
+				continue
+			}
+			warnHostSocket(name, volume.Host)
+		}
+
+		hostPath := useHostPath || (bindHostPath && volume.Host != "")
 		if volume.VolumeName == "" {
 			if useEmptyVolumes {
 				volumeName = strings.Replace(volume.PVCName, "claim", "empty", 1)
-			} else if useHostPath {
+			} else if hostPath {
 				volumeName = strings.Replace(volume.PVCName, "claim", "hostpath", 1)
 			} else if useConfigMap {
 				volumeName = strings.Replace(volume.PVCName, "claim", "cm", 1)
@@ -826,17 +1158,17 @@ func (k *Kubernetes) ConfigVolumes(name string, service kobject.ServiceConfig) (
 
 		if useEmptyVolumes {
 			volsource = k.ConfigEmptyVolumeSource("volume")
-		} else if useHostPath {
+		} else if hostPath {
 			source, err := k.ConfigHostPathVolumeSource(volume.Host)
 			if err != nil {
-				return nil, nil, nil, nil, errors.Wrap(err, "k.ConfigHostPathVolumeSource failed")
+				return nil, nil, nil, nil, nil, errors.Wrap(err, "k.ConfigHostPathVolumeSource failed")
 			}
 			volsource = source
 		} else if useConfigMap {
 			log.Debugf("Use configmap volume")
 
 			if cm, err := k.IntiConfigMapFromFileOrDir(name, volumeName, volume.Host, service); err != nil {
-				return nil, nil, nil, nil, err
+				return nil, nil, nil, nil, nil, err
 			} else {
 				cms = append(cms, cm)
 				volsource = k.ConfigConfigMapVolumeSource(volumeName, volume.Container, cm)
@@ -855,7 +1187,7 @@ func (k *Kubernetes) ConfigVolumes(name string, service kobject.ServiceConfig) (
 					defaultSize = volume.PVCSize
 				} else {
 					for key, value := range service.Labels {
-						if key == "kompose.volume.size" {
+						if key == compose.LabelVolumeSize {
 							defaultSize = value
 						}
 					}
@@ -864,7 +1196,7 @@ func (k *Kubernetes) ConfigVolumes(name string, service kobject.ServiceConfig) (
 				createdPVC, err := k.CreatePVC(volumeName, volume.Mode, defaultSize, volume.SelectorValue)
 
 				if err != nil {
-					return nil, nil, nil, nil, errors.Wrap(err, "k.CreatePVC failed")
+					return nil, nil, nil, nil, nil, errors.Wrap(err, "k.CreatePVC failed")
 				}
 
 				PVCs = append(PVCs, createdPVC)
@@ -880,13 +1212,19 @@ func (k *Kubernetes) ConfigVolumes(name string, service kobject.ServiceConfig) (
 		}
 		volumes = append(volumes, vol)
 
-		if len(volume.Host) > 0 && (!useHostPath && !useConfigMap) {
-			log.Warningf("Volume mount on the host %q isn't supported - ignoring path on the host", volume.Host)
+		if len(volume.Host) > 0 && (!hostPath && !useConfigMap) {
+			log.WithFields(log.Fields{"service": name, "category": "volumes"}).Warningf("Volume mount on the host %q isn't supported - ignoring path on the host", volume.Host)
 		}
 
 	}
 
-	return volumeMounts, volumes, PVCs, cms, nil
+	for p, found := range tlsPaths {
+		if !found {
+			log.WithFields(log.Fields{"service": name, "category": "volumes"}).Warnf("No bind mount of %q listed by the %s label - ignoring", p, compose.LabelVolumeTLS)
+		}
+	}
+
+	return volumeMounts, volumes, PVCs, cms, secrets, nil
 }
 
 // ConfigEmptyVolumeSource is helper function to create an EmptyDir api.VolumeSource
@@ -931,8 +1269,82 @@ func (k *Kubernetes) ConfigConfigMapVolumeSource(cmName string, targetPath strin
 
 }
 
+// ConfigTLSSecretVolumeSource configures a Secret of TLS files as volume source, a single file
+// being mounted as subpath like the ConfigMaps
+func (k *Kubernetes) ConfigTLSSecretVolumeSource(secretName string, targetPath string, secret *api.Secret) *api.VolumeSource {
+	s := api.SecretVolumeSource{SecretName: secretName}
+	if secret.Annotations["use-subpath"] == "true" {
+		for key := range secret.Data {
+			_, p := path.Split(targetPath)
+			s.Items = []api.KeyToPath{
+				{
+					Key:  key,
+					Path: p,
+				},
+			}
+		}
+	}
+	return &api.VolumeSource{
+		Secret: &s,
+	}
+}
+
+// isHostSocket returns true if path is a Unix socket, like /var/run/docker.sock
+func isHostSocket(path string) bool {
+	if strings.HasSuffix(path, ".sock") {
+		return true
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&os.ModeSocket != 0
+}
+
+// mountsHostSocket returns true if a bind mount of service mounts a socket of the host
+func (k *Kubernetes) mountsHostSocket(service kobject.ServiceConfig) bool {
+	for _, volume := range service.Volumes {
+		if volume.Host == "" {
+			continue
+		}
+		if host, err := k.absHostPath(volume.Host); err == nil && isHostSocket(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// warnHostSocket describes the in-cluster alternatives to the bind mount of a socket of the host
+func warnHostSocket(name, path string) {
+	if filepath.Base(path) == "docker.sock" {
+		log.WithFields(log.Fields{"service": name, "category": "volumes"}).Warnf("Mounting the Docker socket %s, which Kubernetes nodes running containerd don't have: "+
+			"run a docker:dind sidecar and set DOCKER_HOST=tcp://localhost:2375, build the images with kaniko or BuildKit, "+
+			"or use --host-sockets to mount the socket of the node", path)
+		return
+	}
+	log.WithFields(log.Fields{"service": name, "category": "volumes"}).Warnf("Mounting the socket %s of the host, which isn't shared with the pod: "+
+		"run the process serving it as a sidecar sharing an emptyDir volume, or use --host-sockets to mount the socket of the node", path)
+}
+
+// ConfigHostSocketVolumeSource mounts the socket at path of the node
+func (k *Kubernetes) ConfigHostSocketVolumeSource(path string) *api.VolumeSource {
+	socket := api.HostPathSocket
+	return &api.VolumeSource{
+		HostPath: &api.HostPathVolumeSource{Path: path, Type: &socket},
+	}
+}
+
 // ConfigHostPathVolumeSource is a helper function to create a HostPath api.VolumeSource
 func (k *Kubernetes) ConfigHostPathVolumeSource(path string) (*api.VolumeSource, error) {
+	if transformer.IsWindowsPath(path) {
+		if k.Opt.WindowsDrivePrefix == "" {
+			log.WithField("category", "volumes").Warnf("Windows host path %q can't be used on Linux nodes, use --windows-drive-prefix to translate it", path)
+			return &api.VolumeSource{
+				HostPath: &api.HostPathVolumeSource{Path: path},
+			}, nil
+		}
+		return &api.VolumeSource{
+			HostPath: &api.HostPathVolumeSource{Path: transformer.TranslateWindowsPath(path, k.Opt.WindowsDrivePrefix)},
+		}, nil
+	}
+
 	dir, err := transformer.GetComposeFileDir(k.Opt.InputFiles)
 	if err != nil {
 		return nil, err
@@ -941,6 +1353,9 @@ func (k *Kubernetes) ConfigHostPathVolumeSource(path string) (*api.VolumeSource,
 	if !filepath.IsAbs(path) {
 		absPath = filepath.Join(dir, path)
 	}
+	if k.Opt.LocalCluster {
+		absPath = k.localHostPath(absPath, dir)
+	}
 
 	return &api.VolumeSource{
 		HostPath: &api.HostPathVolumeSource{Path: absPath},
@@ -962,48 +1377,77 @@ func (k *Kubernetes) ConfigEnvs(name string, service kobject.ServiceConfig, opt
 
 	envs := transformer.EnvSort{}
 
-	keysFromEnvFile := make(map[string]bool)
-
-	// If there is an env_file, use ConfigMaps and ignore the environment variables
-	// already specified
-
-	if len(service.EnvFile) > 0 {
-
-		// Load each env_file
-
-		for _, file := range service.EnvFile {
-
-			envName := FormatEnvName(file)
-
-			// Load environment variables from file
-			envLoad, err := GetEnvsFromFile(file, opt)
-			if err != nil {
-				return envs, errors.Wrap(err, "Unable to read env_file")
+	// If there is an env_file, use ConfigMaps, or Secrets for the encrypted ones. Like
+	// docker-compose, a variable set by several env_files is taken from the last one, and the
+	// inline environment takes precedence.
+	envFiles := make(map[string]string)
+	envFileValues := make(map[string]string)
+	encryptedEnvFiles := make(map[string]bool)
+	for _, file := range service.EnvFile {
+		envName := FormatEnvName(file)
+
+		// Load environment variables from file
+		envLoad, encrypted, err := readEnvFile(file, opt)
+		if err != nil {
+			return envs, errors.Wrap(err, "Unable to read env_file")
+		}
+		encryptedEnvFiles[envName] = encrypted
+		for k, v := range envLoad {
+			if _, ok := transformer.FilterEnv(k, v, opt); ok {
+				envFiles[k] = envName
+				envFileValues[k] = v
 			}
+		}
+	}
 
-			// Add configMapKeyRef to each environment variable
-			for k := range envLoad {
-				envs = append(envs, api.EnvVar{
-					Name: k,
-					ValueFrom: &api.EnvVarSource{
-						ConfigMapKeyRef: &api.ConfigMapKeySelector{
-							LocalObjectReference: api.LocalObjectReference{
-								Name: envName,
-							},
-							Key: k,
-						}},
-				})
-				keysFromEnvFile[k] = true
-			}
+	// The environment of the service holds the variables of the env_files merged with the
+	// inline environment, the variables whose value differs from the env_files are set inline
+	for _, v := range service.Environment {
+		if envName, ok := envFiles[v.Name]; ok && envName != "" && v.Value != envFileValues[v.Name] {
+			envFiles[v.Name] = ""
 		}
 	}
 
+	// Add configMapKeyRef to each environment variable of the env_files
+	for k, envName := range envFiles {
+		if envName == "" {
+			continue
+		}
+		if encryptedEnvFiles[envName] {
+			envs = append(envs, api.EnvVar{
+				Name: k,
+				ValueFrom: &api.EnvVarSource{
+					SecretKeyRef: &api.SecretKeySelector{
+						LocalObjectReference: api.LocalObjectReference{
+							Name: envName,
+						},
+						Key: k,
+					}},
+			})
+			continue
+		}
+		envs = append(envs, api.EnvVar{
+			Name: k,
+			ValueFrom: &api.EnvVarSource{
+				ConfigMapKeyRef: &api.ConfigMapKeySelector{
+					LocalObjectReference: api.LocalObjectReference{
+						Name: envName,
+					},
+					Key: k,
+				}},
+		})
+	}
+
 	// Load up the environment variables
 	for _, v := range service.Environment {
-		if !keysFromEnvFile[v.Name] {
+		if envFiles[v.Name] == "" {
+			value, ok := transformer.FilterEnv(v.Name, v.Value, opt)
+			if !ok {
+				continue
+			}
 			envs = append(envs, api.EnvVar{
 				Name:  v.Name,
-				Value: v.Value,
+				Value: value,
 			})
 		}
 
@@ -1017,7 +1461,7 @@ func (k *Kubernetes) ConfigEnvs(name string, service kobject.ServiceConfig, opt
 }
 
 // CreateKubernetesObjects generates a Kubernetes artifact for each input type service
-func (k *Kubernetes) CreateKubernetesObjects(name string, service kobject.ServiceConfig, opt kobject.ConvertOptions) []runtime.Object {
+func (k *Kubernetes) CreateKubernetesObjects(name string, service kobject.ServiceConfig, opt kobject.ConvertOptions) ([]runtime.Object, error) {
 	var objects []runtime.Object
 	var replica int
 
@@ -1033,8 +1477,8 @@ func (k *Kubernetes) CreateKubernetesObjects(name string, service kobject.Servic
 		if opt.Controller == "" {
 			opt.CreateD = false
 			opt.CreateDS = true
-		} else if opt.Controller != "daemonset" {
-			log.Warnf("Global deploy mode service is best converted to daemonset, now it convert to %s", opt.Controller)
+		} else if opt.Controller != DaemonSetController {
+			log.WithFields(log.Fields{"service": name, "category": "controllers"}).Warnf("Global deploy mode service is best converted to daemonset, now it convert to %s", opt.Controller)
 		}
 
 	}
@@ -1045,13 +1489,16 @@ func (k *Kubernetes) CreateKubernetesObjects(name string, service kobject.Servic
 		opt.CreateDS = false
 		opt.CreateRC = false
 		if opt.Controller != "" {
-			log.Warnf("Use label %s type %s for service %s, ignore %s flags", compose.LabelControllerType, val, name, opt.Controller)
+			log.WithFields(log.Fields{"service": name, "category": "controllers"}).Warnf("Use label %s type %s, ignore %s flags", compose.LabelControllerType, val, opt.Controller)
 		}
 		opt.Controller = val
 	}
 
 	if len(service.Configs) > 0 {
-		objects = k.createConfigMapFromComposeConfig(name, opt, service, objects)
+		var err error
+		if objects, err = k.createConfigMapFromComposeConfig(name, service, objects); err != nil {
+			return nil, err
+		}
 	}
 
 	if opt.CreateD || opt.Controller == DeploymentController {
@@ -1062,17 +1509,36 @@ func (k *Kubernetes) CreateKubernetesObjects(name string, service kobject.Servic
 		objects = append(objects, k.InitDS(name, service))
 	}
 
-	if len(service.EnvFile) > 0 {
-		for _, envFile := range service.EnvFile {
-			configMap := k.InitConfigMapForEnv(name, service, opt, envFile)
-			objects = append(objects, configMap)
-		}
+	if opt.CreateRC || opt.Controller == ReplicationController {
+		objects = append(objects, k.InitRC(name, service, replica))
 	}
 
-	return objects
+	if opt.Controller == StatefulSetController {
+		objects = append(objects, k.InitSS(name, service, replica))
+	}
+
+	envObjects, err := k.CreateEnvFileObjects(name, service, opt)
+	if err != nil {
+		return nil, err
+	}
+	return append(objects, envObjects...), nil
+}
+
+// CreateEnvFileObjects creates the objects holding the variables of the env_files of a service,
+// which its pods reference whatever object runs them
+func (k *Kubernetes) CreateEnvFileObjects(name string, service kobject.ServiceConfig, opt kobject.ConvertOptions) ([]runtime.Object, error) {
+	var objects []runtime.Object
+	for _, envFile := range service.EnvFile {
+		object, err := k.InitObjectForEnv(name, service, opt, envFile)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
 }
 
-func (k *Kubernetes) createConfigMapFromComposeConfig(name string, opt kobject.ConvertOptions, service kobject.ServiceConfig, objects []runtime.Object) []runtime.Object {
+func (k *Kubernetes) createConfigMapFromComposeConfig(name string, service kobject.ServiceConfig, objects []runtime.Object) ([]runtime.Object, error) {
 	for _, config := range service.Configs {
 		currentConfigName := config.Source
 		currentConfigObj := service.ConfigsMetaData[currentConfigName]
@@ -1080,10 +1546,13 @@ func (k *Kubernetes) createConfigMapFromComposeConfig(name string, opt kobject.C
 			continue
 		}
 		currentFileName := currentConfigObj.File
-		configMap := k.InitConfigMapFromFile(name, service, currentFileName)
+		configMap, err := k.InitConfigMapFromFile(name, service, currentFileName)
+		if err != nil {
+			return nil, err
+		}
 		objects = append(objects, configMap)
 	}
-	return objects
+	return objects, nil
 }
 
 // InitPod initializes Kubernetes Pod object
@@ -1103,6 +1572,46 @@ func (k *Kubernetes) InitPod(name string, service kobject.ServiceConfig) *api.Po
 	return &pod
 }
 
+// InitJob initializes a Kubernetes Job running the pod of a service to completion, retrying it
+// up to the max_attempts of deploy.restart_policy, and deleted with its pods the
+// kompose.job.ttl-seconds-after-finished after it finished
+func (k *Kubernetes) InitJob(name string, service kobject.ServiceConfig) *batchv1.Job {
+	job := batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Job",
+			APIVersion: "batch/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      transformer.ConfigLabels(name),
+			Annotations: transformer.ConfigAnnotations(service),
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            service.GetJobBackoffLimit(),
+			TTLSecondsAfterFinished: service.JobTTLSecondsAfterFinished,
+			Template: api.PodTemplateSpec{
+				Spec: k.InitPodSpec(name, service.Image, service.ImagePullSecret),
+			},
+		},
+	}
+	return &job
+}
+
+// InitPodOrJob initializes the pod of a service whose containers aren't always restarted, or a
+// Job when deploy.restart_policy limits the number of restarts or the finished Job is to be
+// deleted, which a pod can't do
+func (k *Kubernetes) InitPodOrJob(name string, service kobject.ServiceConfig) runtime.Object {
+	if service.GetJobBackoffLimit() != nil {
+		log.Infof("Create kubernetes job instead of pod due to deploy.restart_policy.max_attempts: %d", *service.GetJobBackoffLimit())
+		return k.InitJob(name, service)
+	}
+	if service.JobTTLSecondsAfterFinished != nil {
+		log.Infof("Create kubernetes job instead of pod due to kompose.job.ttl-seconds-after-finished: %d", *service.JobTTLSecondsAfterFinished)
+		return k.InitJob(name, service)
+	}
+	return k.InitPod(name, service)
+}
+
 // CreateNetworkPolicy initializes Network policy
 func (k *Kubernetes) CreateNetworkPolicy(name string, networkName string) (*networkingv1.NetworkPolicy, error) {
 
@@ -1133,8 +1642,337 @@ func (k *Kubernetes) CreateNetworkPolicy(name string, networkName string) (*netw
 	return np, nil
 }
 
+// NetworkNamespace returns the namespace of a service when placing services into a namespace per
+// network, which is derived from the alphabetically first network of the service
+func NetworkNamespace(service kobject.ServiceConfig) string {
+	if len(service.Network) == 0 {
+		return ""
+	}
+	networks := append([]string{}, service.Network...)
+	sort.Strings(networks)
+	return strings.ToLower(strings.NewReplacer("_", "-", ".", "-").Replace(networks[0]))
+}
+
+// SetNamespace sets the namespace of all the given objects
+func SetNamespace(objects []runtime.Object, namespace string) {
+	for _, obj := range objects {
+		if meta, ok := obj.(metav1.Object); ok {
+			meta.SetNamespace(namespace)
+		}
+	}
+}
+
+// CreateNamespacesPerNetwork creates a Namespace for every network. Services sharing a network
+// but placed into different namespaces can't reach each other by their short name anymore,
+// so an ExternalName Service pointing to the other namespace is created for them.
+func (k *Kubernetes) CreateNamespacesPerNetwork(komposeObject kobject.KomposeObject) []runtime.Object {
+	var objects []runtime.Object
+	namespaces := map[string]bool{}
+
+	sortedKeys := SortedKeys(komposeObject)
+	for _, name := range sortedKeys {
+		service := komposeObject.ServiceConfigs[name]
+		namespace := NetworkNamespace(service)
+		if namespace == "" {
+			continue
+		}
+		if !namespaces[namespace] {
+			namespaces[namespace] = true
+			objects = append(objects, &api.Namespace{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Namespace",
+					APIVersion: "v1",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: namespace,
+				},
+			})
+		}
+
+		for _, other := range sortedKeys {
+			otherService := komposeObject.ServiceConfigs[other]
+			otherNamespace := NetworkNamespace(otherService)
+			if otherNamespace == namespace || !k.PortsExist(otherService) || !shareNetwork(service, otherService) {
+				continue
+			}
+			svc := k.InitSvc(other, otherService)
+			svc.Namespace = namespace
+			svc.Spec.Selector = nil
+			svc.Spec.Type = api.ServiceTypeExternalName
+			svc.Spec.ExternalName = fmt.Sprintf("%s.%s.svc.cluster.local", other, otherNamespace)
+			objects = append(objects, svc)
+		}
+	}
+	return objects
+}
+
+// shareNetwork returns true if both services are in at least one common network
+func shareNetwork(a, b kobject.ServiceConfig) bool {
+	for _, network := range a.Network {
+		for _, other := range b.Network {
+			if network == other {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HostGatewayHosts returns the hosts of extra_hosts that are mapped to host-gateway,
+// e.g. host.docker.internal:host-gateway
+func HostGatewayHosts(service kobject.ServiceConfig) []string {
+	var hosts []string
+	for _, extraHost := range service.ExtraHosts {
+		if host := strings.SplitN(extraHost, ":", 2); len(host) == 2 && host[1] == HostGateway {
+			hosts = append(hosts, host[0])
+		}
+	}
+	return hosts
+}
+
+// CreateHostGatewayServices creates a headless Service with Endpoints pointing to --host-gateway-ip
+// for every host mapped to host-gateway in extra_hosts, so the pods can reach the machine running
+// the services, like docker does. Without --host-gateway-ip the hosts are reported.
+func (k *Kubernetes) CreateHostGatewayServices(komposeObject kobject.KomposeObject, opt kobject.ConvertOptions) []runtime.Object {
+	var objects []runtime.Object
+	created := map[string]bool{}
+
+	for _, name := range SortedKeys(komposeObject) {
+		service := komposeObject.ServiceConfigs[name]
+		for _, host := range HostGatewayHosts(service) {
+			if opt.HostGatewayIP == "" {
+				log.WithFields(log.Fields{"service": name, "category": "networking"}).Warnf("extra_hosts %q points to the host of the container, "+
+					"set --host-gateway-ip to the IP of the node or of a machine reachable from the pods to convert it", host)
+				continue
+			}
+
+			svcName := strings.Trim(strings.NewReplacer(".", "-", "_", "-").Replace(strings.ToLower(host)), "-")
+			namespace := ""
+			if opt.NamespacePerNetwork {
+				namespace = NetworkNamespace(service)
+			}
+			if created[namespace+"/"+svcName] {
+				continue
+			}
+			created[namespace+"/"+svcName] = true
+			log.Infof("Host %q of extra_hosts is converted to the Service %q pointing to %s", host, svcName, opt.HostGatewayIP)
+
+			objects = append(objects, &api.Service{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Service",
+					APIVersion: "v1",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      svcName,
+					Namespace: namespace,
+				},
+				Spec: api.ServiceSpec{
+					ClusterIP: "None",
+				},
+			}, &api.Endpoints{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Endpoints",
+					APIVersion: "v1",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      svcName,
+					Namespace: namespace,
+				},
+				Subsets: []api.EndpointSubset{{
+					Addresses: []api.EndpointAddress{{IP: opt.HostGatewayIP}},
+				}},
+			})
+		}
+	}
+	return objects
+}
+
+// ExternalLink returns the container of an external_links entry, container or container:alias,
+// and the name the container is reached by from the service
+func ExternalLink(link string) (container, name string) {
+	parts := strings.SplitN(link, ":", 2)
+	if len(parts) == 2 && parts[1] != "" {
+		return parts[0], parts[1]
+	}
+	return parts[0], parts[0]
+}
+
+// CreateExternalLinkServices creates an ExternalName Service for every name of external_links
+// whose host is given with the kompose.external-link.<name> label, so the pods reach the host by
+// the name the containers reached the linked container by. The other names are reported, as the
+// containers started outside of the compose file can't be linked to in a cluster.
+func (k *Kubernetes) CreateExternalLinkServices(komposeObject kobject.KomposeObject, opt kobject.ConvertOptions) []runtime.Object {
+	var objects []runtime.Object
+	created := map[string]bool{}
+
+	for _, name := range SortedKeys(komposeObject) {
+		service := komposeObject.ServiceConfigs[name]
+		for _, link := range service.ExternalLinks {
+			container, linkName := ExternalLink(link)
+			host, ok := service.Labels[compose.LabelExternalLinkPrefix+linkName]
+			if !ok {
+				log.WithFields(log.Fields{"service": name, "category": "networking"}).Warnf("external_links %q links to the container %s started outside of the compose file, which the pods can't reach; "+
+					"deploy it to the cluster under the name %s, or set the label %s%s to the DNS name of its host to create an ExternalName Service",
+					link, container, linkName, compose.LabelExternalLinkPrefix, linkName)
+				continue
+			}
+
+			svcName := strings.Trim(strings.NewReplacer(".", "-", "_", "-").Replace(strings.ToLower(linkName)), "-")
+			namespace := ""
+			if opt.NamespacePerNetwork {
+				namespace = NetworkNamespace(service)
+			}
+			if created[namespace+"/"+svcName] {
+				continue
+			}
+			created[namespace+"/"+svcName] = true
+			log.Infof("Container %s of external_links is converted to the Service %q pointing to %s", container, svcName, host)
+
+			objects = append(objects, &api.Service{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Service",
+					APIVersion: "v1",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      svcName,
+					Namespace: namespace,
+				},
+				Spec: api.ServiceSpec{
+					Type:         api.ServiceTypeExternalName,
+					ExternalName: host,
+				},
+			})
+		}
+	}
+	return objects
+}
+
+// CreateNetworkAliasServices copies the Services of every service for each of its network aliases,
+// so the pods can still be reached by the alias hostnames. The copies are only reachable within
+// the cluster, exposing the pods to the outside is left to the Services of the service itself.
+func (k *Kubernetes) CreateNetworkAliasServices(komposeObject kobject.KomposeObject, objects []runtime.Object) []runtime.Object {
+	var aliases []runtime.Object
+	taken := map[string]bool{}
+	for _, obj := range objects {
+		if svc, ok := obj.(*api.Service); ok {
+			taken[svc.Namespace+"/"+svc.Name] = true
+		}
+	}
+
+	for _, name := range SortedKeys(komposeObject) {
+		service := komposeObject.ServiceConfigs[name]
+		if len(service.NetworkAliases) == 0 {
+			continue
+		}
+
+		var services []*api.Service
+		for _, obj := range objects {
+			if svc, ok := obj.(*api.Service); ok && svc.Labels[transformer.Selector] == name {
+				services = append(services, svc)
+			}
+		}
+		if len(services) == 0 {
+			log.WithFields(log.Fields{"service": name, "category": "networking"}).Warnf("Network aliases %s are ignored, the service has no ports to create a Service for", strings.Join(service.NetworkAliases, ", "))
+			continue
+		}
+
+		for _, alias := range service.NetworkAliases {
+			for _, svc := range services {
+				aliasSvc := svc.DeepCopy()
+				aliasSvc.Name = alias + strings.TrimPrefix(svc.Name, name)
+				if taken[aliasSvc.Namespace+"/"+aliasSvc.Name] {
+					log.WithFields(log.Fields{"service": name, "category": "networking"}).Warnf("Network alias %q is ignored, there is already a Service named %q", alias, aliasSvc.Name)
+					continue
+				}
+				taken[aliasSvc.Namespace+"/"+aliasSvc.Name] = true
+
+				if aliasSvc.Spec.Type == api.ServiceTypeNodePort || aliasSvc.Spec.Type == api.ServiceTypeLoadBalancer {
+					aliasSvc.Spec.Type = api.ServiceTypeClusterIP
+					aliasSvc.Spec.LoadBalancerIP = ""
+					aliasSvc.Spec.ExternalTrafficPolicy = ""
+					for i := range aliasSvc.Spec.Ports {
+						aliasSvc.Spec.Ports[i].NodePort = 0
+					}
+				}
+				log.Debugf("Network alias %q of service %q is converted to the Service %q", alias, name, aliasSvc.Name)
+				aliases = append(aliases, aliasSvc)
+			}
+		}
+	}
+	return aliases
+}
+
+// ConfigDependsOnReadiness adds a readiness probe to the pod template that checks whether the
+// Service of the first depends_on service with a TCP port accepts connections. The probe is a
+// tcpSocket one, which doesn't need any tool in the image, so it can only check a single
+// dependency. Services that have a healthcheck or a readiness probe keep using it.
+func (k *Kubernetes) ConfigDependsOnReadiness(name string, service kobject.ServiceConfig, komposeObject kobject.KomposeObject, objects []runtime.Object) error {
+	if len(service.DependsOn) == 0 || !reflect.DeepEqual(service.HealthChecks, kobject.HealthCheck{}) {
+		return nil
+	}
+
+	var probe *api.Probe
+	var dependency string
+	for _, d := range service.DependsOn {
+		host, port := k.dependencyEndpoint(d, komposeObject.ServiceConfigs[d])
+		if port == 0 {
+			log.WithFields(log.Fields{"service": name, "category": "networking"}).Warnf("Readiness probe can't wait for %q because it has no TCP port", d)
+			continue
+		}
+		if probe != nil {
+			log.WithFields(log.Fields{"service": name, "category": "networking"}).Warnf("Readiness probe waits for %q only, not for %q", dependency, d)
+			continue
+		}
+		dependency = d
+		probe = &api.Probe{
+			Handler: api.Handler{
+				TCPSocket: &api.TCPSocketAction{
+					Host: host,
+					Port: intstr.FromInt(int(port)),
+				},
+			},
+		}
+	}
+	if probe == nil {
+		return nil
+	}
+
+	updateTemplate := func(template *api.PodTemplateSpec) error {
+		if template.Spec.Containers[0].ReadinessProbe != nil {
+			log.WithFields(log.Fields{"service": name, "category": "networking"}).Warnf("Readiness probe of the container is kept, it doesn't wait for %q", dependency)
+			return nil
+		}
+		template.Spec.Containers[0].ReadinessProbe = probe
+		return nil
+	}
+	for _, obj := range objects {
+		if err := k.UpdateController(obj, updateTemplate, func(*metav1.ObjectMeta) {}); err != nil {
+			return errors.Wrap(err, "k.UpdateController failed")
+		}
+	}
+	return nil
+}
+
+// dependencyEndpoint returns the Service name and the first TCP port of the given service,
+// the port is 0 if the service has no TCP port
+func (k *Kubernetes) dependencyEndpoint(name string, service kobject.ServiceConfig) (string, int32) {
+	for _, port := range service.Port {
+		if port.Protocol != "" && port.Protocol != api.ProtocolTCP {
+			continue
+		}
+		if service.ServiceType == string(api.ServiceTypeLoadBalancer) {
+			name = name + "-tcp"
+		}
+		if port.HostPort != 0 {
+			return name, port.HostPort
+		}
+		return name, port.ContainerPort
+	}
+	return name, 0
+}
+
 // Transform maps komposeObject to k8s objects
-// returns object that are already sorted in the way that Services are first
+// returns object that are sorted by dependency, see SortByDependency
 func (k *Kubernetes) Transform(komposeObject kobject.KomposeObject, opt kobject.ConvertOptions) ([]runtime.Object, error) {
 
 	// this will hold all the converted data
@@ -1150,12 +1988,42 @@ func (k *Kubernetes) Transform(komposeObject kobject.KomposeObject, opt kobject.
 		}
 	}
 
+	if opt.ZeroTrust {
+		// the pods of services in no network would be isolated by the default deny NetworkPolicy,
+		// the services are copied so the KomposeObject of the caller isn't changed
+		serviceConfigs := make(map[string]kobject.ServiceConfig, len(komposeObject.ServiceConfigs))
+		for name, service := range komposeObject.ServiceConfigs {
+			if len(service.Network) == 0 {
+				service.Network = []string{ZeroTrustNetwork}
+			}
+			serviceConfigs[name] = service
+		}
+		komposeObject.ServiceConfigs = serviceConfigs
+		allobjects = append(allobjects, k.CreateZeroTrustIssuers()...)
+	}
+
+	var extraResources []*template.Template
+	if opt.ExtraResources != "" {
+		var err error
+		if extraResources, err = LoadExtraResources(opt.ExtraResources); err != nil {
+			return nil, err
+		}
+	}
+
 	sortedKeys := SortedKeys(komposeObject)
-	for _, name := range sortedKeys {
+	for i, name := range sortedKeys {
 		service := komposeObject.ServiceConfigs[name]
 		var objects []runtime.Object
+		start := time.Now()
+		if opt.Progress {
+			log.Infof("[%d/%d] Converting service %s", i+1, len(sortedKeys), name)
+		}
 
 		service.WithKomposeAnnotation = opt.WithKomposeAnnotation
+		ingressPort := transformer.AutoIngress(name, &service, opt.AutoIngress)
+		if opt.LocalCluster {
+			ConfigLocalService(name, &service)
+		}
 
 		// Must build the images before conversion (got to add service.Image in case 'image' key isn't provided
 		// Check that --build is set to true
@@ -1184,7 +2052,7 @@ func (k *Kubernetes) Transform(komposeObject kobject.KomposeObject, opt kobject.
 			// Push the built image to the repo!
 			if opt.PushImage {
 				log.Infof("Push image enabled. Attempting to push image '%s'", service.Image)
-				err = transformer.PushDockerImage(service, name)
+				err = transformer.PushDockerImage(service, name, opt)
 				if err != nil {
 					return nil, errors.Wrapf(err, "Unable to push Docker image for service %v", name)
 				}
@@ -1194,26 +2062,58 @@ func (k *Kubernetes) Transform(komposeObject kobject.KomposeObject, opt kobject.
 		// Generate pod only and nothing more
 		if (service.Restart == "no" || service.Restart == "on-failure") && !opt.IsPodController() {
 			log.Infof("Create kubernetes pod instead of pod controller due to restart policy: %s", service.Restart)
-			pod := k.InitPod(name, service)
-			objects = append(objects, pod)
+			objects = append(objects, k.InitPodOrJob(name, service))
+			envObjects, err := k.CreateEnvFileObjects(name, service, opt)
+			if err != nil {
+				return nil, errors.Wrap(err, "Error creating the Kubernetes objects")
+			}
+			objects = append(objects, envObjects...)
 		} else {
-			objects = k.CreateKubernetesObjects(name, service, opt)
+			if service.GetJobBackoffLimit() != nil {
+				log.WithFields(log.Fields{"service": name, "category": "unsupported"}).Warn("deploy.restart_policy.max_attempts is ignored, the pods of controllers are always restarted")
+			}
+			if service.JobTTLSecondsAfterFinished != nil {
+				log.WithFields(log.Fields{"service": name, "category": "unsupported"}).Warn("kompose.job.ttl-seconds-after-finished is ignored, the service is not converted to a Job")
+			}
+			var err error
+			if objects, err = k.CreateKubernetesObjects(name, service, opt); err != nil {
+				return nil, errors.Wrap(err, "Error creating the Kubernetes objects")
+			}
 		}
 
 		if k.PortsExist(service) {
-			if service.ServiceType == "LoadBalancer" {
+			if k.SplitPorts(service) {
+				objects = append(objects, k.CreateClusterService(name, service, objects))
+				svcs := k.CreateSplitServices(name, service, objects)
+				for _, svc := range svcs {
+					objects = append(objects, svc)
+				}
+				if service.ExposeService != "" {
+					svc := SplitServiceOf(svcs, ingressPort)
+					ingress := k.initIngress(name, service, svc.Spec.Ports[0].Port)
+					for _, rule := range ingress.Spec.Rules {
+						for i := range rule.HTTP.Paths {
+							rule.HTTP.Paths[i].Backend.ServiceName = svc.Name
+						}
+					}
+					objects = append(objects, ingress)
+				}
+			} else if service.ServiceType == "LoadBalancer" {
 				svcs := k.CreateLBService(name, service, objects)
 				for _, svc := range svcs {
 					objects = append(objects, svc)
 				}
 				if len(svcs) > 1 {
-					log.Warningf("Create multiple service to avoid using mixed protocol in the same service when it's loadbalander type")
+					log.WithFields(log.Fields{"service": name, "category": "networking"}).Warning("Create multiple service to avoid using mixed protocol in the same service when it's loadbalander type")
 				}
 			} else {
 				svc := k.CreateService(name, service, objects)
 				objects = append(objects, svc)
 				if service.ExposeService != "" {
-					objects = append(objects, k.initIngress(name, service, svc.Spec.Ports[0].Port))
+					if ingressPort == 0 {
+						ingressPort = svc.Spec.Ports[0].Port
+					}
+					objects = append(objects, k.initIngress(name, service, ingressPort))
 				}
 			}
 
@@ -1222,7 +2122,7 @@ func (k *Kubernetes) Transform(komposeObject kobject.KomposeObject, opt kobject.
 				svc := k.CreateHeadlessService(name, service, objects)
 				objects = append(objects, svc)
 			} else {
-				log.Warnf("Service %q won't be created because 'ports' is not specified", name)
+				log.WithFields(log.Fields{"service": name, "category": "networking"}).Warn("Service won't be created because 'ports' is not specified")
 			}
 		}
 
@@ -1231,6 +2131,58 @@ func (k *Kubernetes) Transform(komposeObject kobject.KomposeObject, opt kobject.
 			return nil, errors.Wrap(err, "Error transforming Kubernetes objects")
 		}
 
+		if service.HPA != (kobject.HPA{}) {
+			if target, ok := ScaleTarget(objects); ok {
+				hpa, err := k.CreateHPA(name, service, opt, target)
+				if err != nil {
+					return nil, errors.Wrap(err, "Error creating the HorizontalPodAutoscaler")
+				}
+				objects = append(objects, hpa)
+			} else {
+				log.WithFields(log.Fields{"service": name, "category": "unsupported"}).Warn("The kompose.hpa labels are ignored, the service is not converted to a Deployment, StatefulSet or ReplicationController")
+			}
+		}
+
+		if opt.SingletonPDB {
+			pdb, err := k.CreateSingletonPDB(name, service, objects)
+			if err != nil {
+				return nil, errors.Wrap(err, "Error creating the PodDisruptionBudget")
+			}
+			if pdb != nil {
+				objects = append(objects, pdb)
+			}
+		}
+
+		if opt.DependsOnReadiness {
+			err = k.ConfigDependsOnReadiness(name, service, komposeObject, objects)
+			if err != nil {
+				return nil, errors.Wrap(err, "Error configuring the readiness probe")
+			}
+		}
+
+		if opt.SmartDefaults {
+			if err := k.ConfigImageProbes(name, service, objects); err != nil {
+				return nil, errors.Wrap(err, "Error configuring the probes of the image")
+			}
+		}
+
+		if opt.ZeroTrust {
+			if err := k.ConfigZeroTrustTLS(name, objects); err != nil {
+				return nil, errors.Wrap(err, "Error configuring the TLS Secret")
+			}
+			objects = append(objects, k.CreateZeroTrustCertificate(name, service, opt))
+		}
+
+		extras, err := k.CreateExtraResources(name, service, extraResources, opt)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error creating the extra resources")
+		}
+		objects = append(objects, extras...)
+
+		if opt.NamespacePerNetwork {
+			SetNamespace(objects, NetworkNamespace(service))
+		}
+
 		if len(service.Network) > 0 {
 
 			for _, net := range service.Network {
@@ -1241,6 +2193,11 @@ func (k *Kubernetes) Transform(komposeObject kobject.KomposeObject, opt kobject.
 				if err != nil {
 					return nil, errors.Wrapf(err, "Unable to create Network Policy for network %v for service %v", net, name)
 				}
+				if opt.NamespacePerNetwork {
+					// the network spans several namespaces, allow its pods from all of them
+					np.Namespace = NetworkNamespace(service)
+					np.Spec.Ingress[0].From[0].NamespaceSelector = &metav1.LabelSelector{}
+				}
 				objects = append(objects, np)
 
 			}
@@ -1248,11 +2205,46 @@ func (k *Kubernetes) Transform(komposeObject kobject.KomposeObject, opt kobject.
 		}
 
 		allobjects = append(allobjects, objects...)
+		log.Debugf("Service %s converted in %s", name, time.Since(start))
 
 	}
 
-	// sort all object so Services are first
-	k.SortServicesFirst(&allobjects)
+	allobjects = append(allobjects, k.CreateHostGatewayServices(komposeObject, opt)...)
+	allobjects = append(allobjects, k.CreateExternalLinkServices(komposeObject, opt)...)
+	allobjects = append(allobjects, k.CreateNetworkAliasServices(komposeObject, allobjects)...)
+	if opt.LocalCluster {
+		ConfigLocalNodePorts(allobjects)
+	}
+	if opt.ZeroTrust {
+		allobjects = append(allobjects, k.CreateZeroTrustPolicies(komposeObject, opt)...)
+	}
+
+	if opt.ChecksumAnnotations {
+		if err := k.AddChecksumAnnotations(allobjects); err != nil {
+			return nil, errors.Wrap(err, "Error adding the checksum annotations")
+		}
+	}
+
+	if opt.NamespacePerNetwork {
+		allobjects = append(k.CreateNamespacesPerNetwork(komposeObject), allobjects...)
+	}
+
+	if !opt.LegacyLabels {
+		if err := k.ConfigRecommendedLabels(allobjects, komposeObject, opt); err != nil {
+			return nil, errors.Wrap(err, "Error configuring the recommended labels")
+		}
+	}
+	if opt.PartOf != "" {
+		transformer.AddAppLabels(allobjects, opt.PartOf)
+	}
+
+	allobjects, err := SetInternalTrafficPolicy(allobjects)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error setting the internalTrafficPolicy of the Services")
+	}
+
+	// sort all objects so they are applied after the objects they depend on
+	k.SortByDependency(&allobjects)
 	k.RemoveDupObjects(&allobjects)
 	// k.FixWorkloadVersion(&allobjects)
 
@@ -1274,12 +2266,30 @@ func (k *Kubernetes) UpdateController(obj runtime.Object, updateTemplate func(*a
 			return errors.Wrap(err, "updateTemplate failed")
 		}
 		updateMeta(&t.ObjectMeta)
+	case *api.ReplicationController:
+		err = updateTemplate(t.Spec.Template)
+		if err != nil {
+			return errors.Wrap(err, "updateTemplate failed")
+		}
+		updateMeta(&t.ObjectMeta)
+	case *appsv1.StatefulSet:
+		err = updateTemplate(&t.Spec.Template)
+		if err != nil {
+			return errors.Wrap(err, "updateTemplate failed")
+		}
+		updateMeta(&t.ObjectMeta)
 	case *deployapi.DeploymentConfig:
 		err = updateTemplate(t.Spec.Template)
 		if err != nil {
 			return errors.Wrap(err, "updateTemplate failed")
 		}
 		updateMeta(&t.ObjectMeta)
+	case *batchv1.Job:
+		err = updateTemplate(&t.Spec.Template)
+		if err != nil {
+			return errors.Wrap(err, "updateTemplate failed")
+		}
+		updateMeta(&t.ObjectMeta)
 	case *api.Pod:
 		p := api.PodTemplateSpec{
 			ObjectMeta: t.ObjectMeta,
@@ -19,20 +19,36 @@ package kubernetes
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	batchv1 "k8s.io/api/batch/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	networkingv1beta1 "k8s.io/api/networking/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/yaml"
 
 	"reflect"
+	"runtime"
 	"testing"
+	"time"
 
+	"github.com/docker/cli/cli/compose/types"
 	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/loader/compose"
 	"github.com/kubernetes/kompose/pkg/transformer"
 	deployapi "github.com/openshift/api/apps/v1"
 
 	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	api "k8s.io/api/core/v1"
 
 	"strings"
@@ -284,13 +300,16 @@ func TestKomposeConvert(t *testing.T) {
 		opt             kobject.ConvertOptions
 		expectedNumObjs int
 	}{
-		// objects generated are deployment, service nework policies (2) and pvc
+		// objects generated are deployment, service network policies (2) and pvc
 		"Convert to Deployments (D)":                  {newKomposeObject(), kobject.ConvertOptions{CreateD: true, Replicas: replicas, IsReplicaSetFlag: true}, 5},
 		"Convert to Deployments (D) with v3 replicas": {newKomposeObject(), kobject.ConvertOptions{CreateD: true}, 5},
 		"Convert to DaemonSets (DS)":                  {newKomposeObject(), kobject.ConvertOptions{CreateDS: true}, 5},
-		// objects generated are deployment, daemonset, ReplicationController, service and pvc
-		"Convert to D, DS, and RC":                  {newKomposeObject(), kobject.ConvertOptions{CreateD: true, CreateDS: true, CreateRC: true, Replicas: replicas, IsReplicaSetFlag: true}, 6},
-		"Convert to D, DS, and RC with v3 replicas": {newKomposeObject(), kobject.ConvertOptions{CreateD: true, CreateDS: true, CreateRC: true}, 6},
+		"Convert to ReplicationControllers (RC)":      {newKomposeObject(), kobject.ConvertOptions{Controller: ReplicationController, Replicas: replicas, IsReplicaSetFlag: true}, 5},
+		"Convert to StatefulSets (SS)":                {newKomposeObject(), kobject.ConvertOptions{Controller: StatefulSetController, Replicas: replicas, IsReplicaSetFlag: true}, 5},
+		// objects generated are deployment, daemonset, ReplicationController, service network policies (2) and pvc
+		"Convert to D, DS, and RC":                  {newKomposeObject(), kobject.ConvertOptions{CreateD: true, CreateDS: true, CreateRC: true, Replicas: replicas, IsReplicaSetFlag: true}, 7},
+		"Convert to D, DS, and RC with v3 replicas": {newKomposeObject(), kobject.ConvertOptions{CreateD: true, CreateDS: true, CreateRC: true}, 7},
+		"Convert to D with the legacy labels":       {newKomposeObject(), kobject.ConvertOptions{CreateD: true, LegacyLabels: true}, 5},
 		// TODO: add more tests
 	}
 
@@ -306,11 +325,18 @@ func TestKomposeConvert(t *testing.T) {
 			t.Errorf("Expected %d objects returned, got %d", test.expectedNumObjs, len(objs))
 		}
 
-		var foundSVC, foundD, foundDS, foundDC bool
+		var foundSVC, foundD, foundDS, foundRC, foundSS, foundDC bool
 		name := "app"
 		labels := transformer.ConfigLabels(name)
 		config := test.komposeObject.ServiceConfigs[name]
 		labelsWithNetwork := transformer.ConfigLabelsWithNetwork(name, config.Network)
+		if !test.opt.LegacyLabels {
+			labels = map[string]string{transformer.LabelName: name}
+			delete(labelsWithNetwork, transformer.Selector)
+			for key, value := range RecommendedLabels(name, config, "", true) {
+				labelsWithNetwork[key] = value
+			}
+		}
 		// Check results
 		for _, obj := range objs {
 			if svc, ok := obj.(*api.Service); ok {
@@ -423,6 +449,31 @@ func TestKomposeConvert(t *testing.T) {
 
 			}
 
+			if rc, ok := obj.(*api.ReplicationController); ok {
+				if err := checkPodTemplate(config, *rc.Spec.Template, labelsWithNetwork); err != nil {
+					t.Errorf("%v", err)
+				}
+				if err := checkMeta(config, rc.ObjectMeta, name, true); err != nil {
+					t.Errorf("%v", err)
+				}
+				foundRC = true
+			}
+			if ss, ok := obj.(*appsv1.StatefulSet); ok {
+				if err := checkPodTemplate(config, ss.Spec.Template, labelsWithNetwork); err != nil {
+					t.Errorf("%v", err)
+				}
+				if err := checkMeta(config, ss.ObjectMeta, name, true); err != nil {
+					t.Errorf("%v", err)
+				}
+				if ss.Spec.ServiceName != name {
+					t.Errorf("Expected StatefulSet to be governed by Service %q, got %q", name, ss.Spec.ServiceName)
+				}
+				if (int)(*ss.Spec.Replicas) != replicas {
+					t.Errorf("Expected %d replicas, got %d", replicas, *ss.Spec.Replicas)
+				}
+				foundSS = true
+			}
+
 			// TODO: k8s & openshift transformer is now separated; either separate the test or combine the transformer
 			if test.opt.CreateDeploymentConfig {
 				if dc, ok := obj.(*deployapi.DeploymentConfig); ok {
@@ -451,6 +502,12 @@ func TestKomposeConvert(t *testing.T) {
 		if test.opt.CreateDS != foundDS {
 			t.Errorf("Expected create Daemon Set: %v, found Daemon Set: %v", test.opt.CreateDS, foundDS)
 		}
+		if createRC := test.opt.CreateRC || test.opt.Controller == ReplicationController; createRC != foundRC {
+			t.Errorf("Expected create Replication Controller: %v, found Replication Controller: %v", createRC, foundRC)
+		}
+		if createSS := test.opt.Controller == StatefulSetController; createSS != foundSS {
+			t.Errorf("Expected create Stateful Set: %v, found Stateful Set: %v", createSS, foundSS)
+		}
 
 		if test.opt.CreateDeploymentConfig != foundDC {
 			t.Errorf("Expected create Deployment Config: %v, found Deployment Config: %v", test.opt.CreateDeploymentConfig, foundDC)
@@ -562,3 +619,1979 @@ func TestConfigCapabilities(t *testing.T) {
 		}
 	}
 }
+
+func TestServiceAndPodAnnotations(t *testing.T) {
+	service := newServiceConfig()
+	service.ServiceAnnotations = map[string]string{"service.beta.kubernetes.io/aws-load-balancer-internal": "true"}
+	service.PodAnnotations = map[string]string{"sidecar.istio.io/inject": "false"}
+
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{"app": service},
+	}
+	k := Kubernetes{}
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *api.Service:
+			if o.Annotations["service.beta.kubernetes.io/aws-load-balancer-internal"] != "true" || o.Annotations["abc"] != "def" {
+				t.Errorf("Expected service annotations on the Service, got %v", o.Annotations)
+			}
+			if _, ok := o.Annotations["sidecar.istio.io/inject"]; ok {
+				t.Errorf("Expected no pod annotations on the Service, got %v", o.Annotations)
+			}
+		case *appsv1.Deployment:
+			if o.Spec.Template.Annotations["sidecar.istio.io/inject"] != "false" || o.Spec.Template.Annotations["abc"] != "def" {
+				t.Errorf("Expected pod annotations on the pod template, got %v", o.Spec.Template.Annotations)
+			}
+			if _, ok := o.Spec.Template.Annotations["service.beta.kubernetes.io/aws-load-balancer-internal"]; ok {
+				t.Errorf("Expected no service annotations on the pod template, got %v", o.Spec.Template.Annotations)
+			}
+			if _, ok := o.Annotations["sidecar.istio.io/inject"]; ok {
+				t.Errorf("Expected no pod annotations on the Deployment, got %v", o.Annotations)
+			}
+		}
+	}
+}
+
+func TestMeshInjection(t *testing.T) {
+	testCases := map[string]struct {
+		mesh        string
+		labels      map[string]string
+		annotations map[string]string
+	}{
+		"Istio":   {MeshIstio, map[string]string{"sidecar.istio.io/inject": "true"}, nil},
+		"Linkerd": {MeshLinkerd, nil, map[string]string{"linkerd.io/inject": "enabled"}},
+	}
+
+	for name, test := range testCases {
+		t.Log("Test case:", name)
+		k := Kubernetes{}
+		objects, err := k.Transform(newKomposeObject(), kobject.ConvertOptions{CreateD: true, Replicas: 1, Mesh: test.mesh})
+		if err != nil {
+			t.Fatal(errors.Wrap(err, "k.Transform failed"))
+		}
+
+		for _, obj := range objects {
+			if d, ok := obj.(*appsv1.Deployment); ok {
+				for key, value := range test.labels {
+					if d.Spec.Template.Labels[key] != value {
+						t.Errorf("Expected label %s=%s on the pod template, got %v", key, value, d.Spec.Template.Labels)
+					}
+				}
+				for key, value := range test.annotations {
+					if d.Spec.Template.Annotations[key] != value {
+						t.Errorf("Expected annotation %s=%s on the pod template, got %v", key, value, d.Spec.Template.Annotations)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestNamedPorts(t *testing.T) {
+	service := kobject.ServiceConfig{
+		ContainerName: "name",
+		Image:         "image",
+		Port: []kobject.Ports{
+			{HostPort: 80, ContainerPort: 8080, Protocol: api.ProtocolTCP, Name: "http-web"},
+			{HostPort: 53, ContainerPort: 53, Protocol: api.ProtocolUDP},
+		},
+	}
+	k := Kubernetes{Opt: kobject.ConvertOptions{NamePorts: true}}
+
+	containerPorts := k.ConfigPorts("app", service)
+	if containerPorts[0].Name != "http-web" || containerPorts[1].Name != "port-53-udp" {
+		t.Errorf("Unexpected container port names %q and %q", containerPorts[0].Name, containerPorts[1].Name)
+	}
+
+	servicePorts := k.ConfigServicePorts("app", service)
+	expected := []api.ServicePort{
+		{Name: "http-web", Port: 80, TargetPort: intstr.FromString("http-web")},
+		{Name: "port-53-udp", Port: 53, TargetPort: intstr.FromString("port-53-udp"), Protocol: api.ProtocolUDP},
+	}
+	if !reflect.DeepEqual(servicePorts, expected) {
+		t.Errorf("Expected service ports %v, got %v", expected, servicePorts)
+	}
+
+	// without --name-ports only the ports named by labels get a name
+	k = Kubernetes{}
+	containerPorts = k.ConfigPorts("app", service)
+	if containerPorts[0].Name != "http-web" || containerPorts[1].Name != "" {
+		t.Errorf("Unexpected container port names %q and %q", containerPorts[0].Name, containerPorts[1].Name)
+	}
+	servicePorts = k.ConfigServicePorts("app", service)
+	if servicePorts[1].Name != "53" || servicePorts[1].TargetPort.IntValue() != 53 {
+		t.Errorf("Expected unnamed port 53 to be targeted by number, got %v", servicePorts[1])
+	}
+}
+
+func TestConfigDependsOnReadiness(t *testing.T) {
+	db := kobject.ServiceConfig{Image: "postgres", Port: []kobject.Ports{{ContainerPort: 5432, Protocol: api.ProtocolTCP}}}
+	cache := kobject.ServiceConfig{Image: "redis", Port: []kobject.Ports{{HostPort: 6380, ContainerPort: 6379, Protocol: api.ProtocolTCP}}}
+
+	testCases := map[string]struct {
+		dependsOn   []string
+		healthCheck kobject.HealthCheck
+		probe       *api.Probe
+	}{
+		"No dependency":        {nil, kobject.HealthCheck{}, nil},
+		"One dependency":       {[]string{"db"}, kobject.HealthCheck{}, &api.Probe{Handler: api.Handler{TCPSocket: &api.TCPSocketAction{Host: "db", Port: intstr.FromInt(5432)}}}},
+		"Two dependencies":     {[]string{"db", "cache"}, kobject.HealthCheck{}, &api.Probe{Handler: api.Handler{TCPSocket: &api.TCPSocketAction{Host: "db", Port: intstr.FromInt(5432)}}}},
+		"Disabled healthcheck": {[]string{"db"}, kobject.HealthCheck{Disable: true}, nil},
+	}
+
+	for name, test := range testCases {
+		t.Log("Test case:", name)
+		web := kobject.ServiceConfig{Image: "web", DependsOn: test.dependsOn, HealthChecks: test.healthCheck}
+		komposeObject := kobject.KomposeObject{
+			ServiceConfigs: map[string]kobject.ServiceConfig{"web": web, "db": db, "cache": cache},
+		}
+		k := Kubernetes{}
+		objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1, DependsOnReadiness: true})
+		if err != nil {
+			t.Fatal(errors.Wrap(err, "k.Transform failed"))
+		}
+
+		for _, obj := range objects {
+			if d, ok := obj.(*appsv1.Deployment); ok && d.Name == "web" {
+				if probe := d.Spec.Template.Spec.Containers[0].ReadinessProbe; !reflect.DeepEqual(probe, test.probe) {
+					t.Errorf("Expected readiness probe %v, got %v", test.probe, probe)
+				}
+				if probe := d.Spec.Template.Spec.Containers[0].LivenessProbe; probe != nil {
+					t.Errorf("Expected no liveness probe, got %v", probe)
+				}
+			}
+		}
+	}
+
+	// the readiness probe of the container is kept
+	web := kobject.ServiceConfig{Image: "web", DependsOn: []string{"db"}}
+	komposeObject := kobject.KomposeObject{ServiceConfigs: map[string]kobject.ServiceConfig{"web": web, "db": db}}
+	existing := &api.Probe{Handler: api.Handler{HTTPGet: &api.HTTPGetAction{Path: "/ready", Port: intstr.FromInt(8080)}}}
+	deployment := &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Template: api.PodTemplateSpec{Spec: api.PodSpec{
+		Containers: []api.Container{{Name: "web", ReadinessProbe: existing}},
+	}}}}
+	k := Kubernetes{}
+	if err := k.ConfigDependsOnReadiness("web", web, komposeObject, []k8sruntime.Object{deployment}); err != nil {
+		t.Fatal(err)
+	}
+	if probe := deployment.Spec.Template.Spec.Containers[0].ReadinessProbe; !reflect.DeepEqual(probe, existing) {
+		t.Errorf("Expected the readiness probe %v to be kept, got %v", existing, probe)
+	}
+}
+
+func TestNamespacePerNetwork(t *testing.T) {
+	port := []kobject.Ports{{ContainerPort: 80, Protocol: api.ProtocolTCP}}
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"web": {Image: "web", Port: port, Network: []string{"frontend"}},
+			"api": {Image: "api", Port: port, Network: []string{"frontend", "backend"}},
+			"db":  {Image: "db", Port: port, Network: []string{"backend"}},
+		},
+	}
+	k := Kubernetes{}
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1, NamespacePerNetwork: true})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	found := map[string]bool{}
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *api.Namespace:
+			found["Namespace/"+o.Name] = true
+		case *appsv1.Deployment:
+			found["Deployment/"+o.Namespace+"/"+o.Name] = true
+		case *api.Service:
+			if o.Spec.Type == api.ServiceTypeExternalName {
+				found["ExternalName/"+o.Namespace+"/"+o.Name+"/"+o.Spec.ExternalName] = true
+			}
+		}
+	}
+
+	expected := []string{
+		"Namespace/backend",
+		"Namespace/frontend",
+		"Deployment/backend/api",
+		"Deployment/backend/db",
+		"Deployment/frontend/web",
+		"ExternalName/frontend/api/api.backend.svc.cluster.local",
+		"ExternalName/backend/web/web.frontend.svc.cluster.local",
+	}
+	for _, key := range expected {
+		if !found[key] {
+			t.Errorf("Expected %s, got %v", key, found)
+		}
+	}
+	if len(found) != len(expected) {
+		t.Errorf("Expected %d objects, got %v", len(expected), found)
+	}
+}
+
+func TestHostGatewayServices(t *testing.T) {
+	web := kobject.ServiceConfig{
+		Image:      "web",
+		ExtraHosts: []string{"host.docker.internal:host-gateway", "other:10.0.0.2"},
+	}
+	komposeObject := kobject.KomposeObject{ServiceConfigs: map[string]kobject.ServiceConfig{"web": web}}
+
+	k := Kubernetes{}
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1, HostGatewayIP: "10.0.0.1"})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	found := map[string]bool{}
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *appsv1.Deployment:
+			expected := []api.HostAlias{{IP: "10.0.0.1", Hostnames: []string{"host.docker.internal"}}}
+			if !reflect.DeepEqual(o.Spec.Template.Spec.HostAliases, expected) {
+				t.Errorf("Expected host aliases %v, got %v", expected, o.Spec.Template.Spec.HostAliases)
+			}
+		case *api.Service:
+			if o.Name == "host-docker-internal" && o.Spec.ClusterIP == "None" {
+				found["Service"] = true
+			}
+		case *api.Endpoints:
+			if o.Name == "host-docker-internal" && o.Subsets[0].Addresses[0].IP == "10.0.0.1" {
+				found["Endpoints"] = true
+			}
+		}
+	}
+	if !found["Service"] || !found["Endpoints"] {
+		t.Errorf("Expected a headless Service and Endpoints for host.docker.internal, got %v", found)
+	}
+
+	objects, err = k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	for _, obj := range objects {
+		if _, ok := obj.(*api.Endpoints); ok {
+			t.Errorf("Expected no Endpoints without --host-gateway-ip")
+		}
+	}
+}
+
+func TestExternalLinkServices(t *testing.T) {
+	web := kobject.ServiceConfig{
+		Image:         "web",
+		ExternalLinks: []string{"legacy_db_1:db", "redis_1"},
+		Labels:        map[string]string{"kompose.external-link.db": "db.example.com"},
+	}
+	komposeObject := kobject.KomposeObject{ServiceConfigs: map[string]kobject.ServiceConfig{"web": web}}
+
+	k := Kubernetes{}
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	var services []string
+	for _, obj := range objects {
+		if svc, ok := obj.(*api.Service); ok && svc.Spec.Type == api.ServiceTypeExternalName {
+			services = append(services, svc.Name)
+			if svc.Spec.ExternalName != "db.example.com" {
+				t.Errorf("Expected the Service %s to point to db.example.com, got %q", svc.Name, svc.Spec.ExternalName)
+			}
+		}
+	}
+	if !reflect.DeepEqual(services, []string{"db"}) {
+		t.Errorf("Expected an ExternalName Service db only, got %v", services)
+	}
+
+	web.Labels["kompose.external-link.db"] = "10.0.0.1:5432"
+	if err := ValidateServices(komposeObject, kobject.ConvertOptions{}); err == nil {
+		t.Errorf("Expected an error for a host that is not a DNS name")
+	}
+}
+
+func TestNetworkAliasServices(t *testing.T) {
+	db := kobject.ServiceConfig{
+		Image:          "postgres",
+		Port:           []kobject.Ports{{HostPort: 5432, ContainerPort: 5432, Protocol: api.ProtocolTCP}},
+		ServiceType:    string(api.ServiceTypeNodePort),
+		NetworkAliases: []string{"database", "web"},
+	}
+	web := kobject.ServiceConfig{
+		Image: "nginx",
+		Port:  []kobject.Ports{{HostPort: 80, ContainerPort: 80, Protocol: api.ProtocolTCP}},
+	}
+	komposeObject := kobject.KomposeObject{ServiceConfigs: map[string]kobject.ServiceConfig{"db": db, "web": web}}
+
+	k := Kubernetes{}
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	services := map[string]*api.Service{}
+	for _, obj := range objects {
+		if svc, ok := obj.(*api.Service); ok {
+			if services[svc.Name] != nil {
+				t.Errorf("Expected a single Service named %s", svc.Name)
+			}
+			services[svc.Name] = svc
+		}
+	}
+	alias, ok := services["database"]
+	if !ok {
+		t.Fatalf("Expected a Service for the network alias database, got %v", services)
+	}
+	if alias.Spec.Selector[transformer.LabelName] != "db" {
+		t.Errorf("Expected the alias Service to select the pods of db, got %v", alias.Spec.Selector)
+	}
+	if alias.Spec.Type != api.ServiceTypeClusterIP {
+		t.Errorf("Expected the alias Service to be a ClusterIP Service, got %s", alias.Spec.Type)
+	}
+	if services["db"].Spec.Type != api.ServiceTypeNodePort {
+		t.Errorf("Expected the Service of db to stay a NodePort Service, got %s", services["db"].Spec.Type)
+	}
+	if services["web"].Spec.Selector[transformer.LabelName] != "web" {
+		t.Errorf("Expected the alias web not to replace the Service of web")
+	}
+}
+
+func TestTLSVolumes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kompose-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certs := filepath.Join(dir, "certs")
+	if err := os.Mkdir(certs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{api.TLSCertKey, api.TLSPrivateKeyKey} {
+		content := "-----BEGIN " + file + "-----\nMIIB\n-----END " + file + "-----\n"
+		if err := ioutil.WriteFile(filepath.Join(certs, file), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ca := filepath.Join(certs, api.TLSCertKey)
+
+	service := kobject.ServiceConfig{
+		Image:  "registry",
+		Labels: map[string]string{compose.LabelVolumeTLS: certs + "," + ca},
+		Volumes: []kobject.Volumes{
+			{SvcName: "registry", Host: certs, Container: "/certs", PVCName: "registry-claim0"},
+			{SvcName: "registry", Host: ca, Container: "/etc/ca.crt", PVCName: "registry-claim1"},
+		},
+	}
+
+	k := Kubernetes{}
+	mounts, volumes, pvcs, _, secrets, err := k.ConfigVolumes("registry", service)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.ConfigVolumes failed"))
+	}
+	if len(pvcs) != 0 {
+		t.Errorf("Expected no PersistentVolumeClaims for TLS volumes, got %d", len(pvcs))
+	}
+	if len(secrets) != 2 || len(volumes) != 2 || len(mounts) != 2 {
+		t.Fatalf("Expected a Secret, volume and mount per TLS volume, got %d, %d and %d", len(secrets), len(volumes), len(mounts))
+	}
+
+	if secrets[0].Name != "registry-tls0" || secrets[0].Type != api.SecretTypeTLS || len(secrets[0].Data) != 2 {
+		t.Errorf("Expected a kubernetes.io/tls Secret registry-tls0 holding both files, got %s %s with %d keys", secrets[0].Name, secrets[0].Type, len(secrets[0].Data))
+	}
+	if volumes[0].Secret == nil || volumes[0].Secret.SecretName != "registry-tls0" {
+		t.Errorf("Expected the volume to use the Secret registry-tls0, got %v", volumes[0].VolumeSource)
+	}
+	if mounts[0].MountPath != "/certs" || !mounts[0].ReadOnly || mounts[0].SubPath != "" {
+		t.Errorf("Expected a read-only mount of the directory at /certs, got %v", mounts[0])
+	}
+
+	if secrets[1].Type != api.SecretTypeOpaque || len(secrets[1].Data) != 1 {
+		t.Errorf("Expected an Opaque Secret holding the single file, got %s with %d keys", secrets[1].Type, len(secrets[1].Data))
+	}
+	if mounts[1].MountPath != "/etc/ca.crt" || mounts[1].SubPath != "ca.crt" {
+		t.Errorf("Expected the single file to be mounted as subpath ca.crt at /etc/ca.crt, got %v", mounts[1])
+	}
+}
+
+func TestImageDefaults(t *testing.T) {
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"db":    {Image: "postgres:13"},
+			"cache": {Image: "redis", Volumes: []kobject.Volumes{{SvcName: "cache", VolumeName: "cache", Container: "/data", PVCName: "cache-claim0"}}},
+			"web":   {Image: "nginx", HealthChecks: kobject.HealthCheck{Test: []string{"true"}}, Port: []kobject.Ports{{ContainerPort: 80, Protocol: api.ProtocolTCP, Name: "http"}}},
+			"other": {Image: "example.com/library/postgres"},
+		},
+	}
+	ApplyImageDefaults(&komposeObject)
+
+	db := komposeObject.ServiceConfigs["db"]
+	if len(db.Port) != 1 || db.Port[0].ContainerPort != 5432 {
+		t.Errorf("Expected db to expose the postgres port, got %v", db.Port)
+	}
+	if len(db.Volumes) != 1 || db.Volumes[0].Container != "/var/lib/postgresql/data" || db.Volumes[0].PVCName != "db-claim0" {
+		t.Errorf("Expected db to get a volume at the postgres data directory, got %v", db.Volumes)
+	}
+	if volumes := komposeObject.ServiceConfigs["cache"].Volumes; len(volumes) != 1 {
+		t.Errorf("Expected cache to keep its own volume of the data directory, got %v", volumes)
+	}
+	if other := komposeObject.ServiceConfigs["other"]; len(other.Port) != 0 || len(other.Volumes) != 0 {
+		t.Errorf("Expected images outside of Docker Hub to be left alone, got %v", other)
+	}
+
+	k := Kubernetes{}
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1, SmartDefaults: true})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	for _, obj := range objects {
+		deployment, ok := obj.(*appsv1.Deployment)
+		if !ok {
+			continue
+		}
+		container := deployment.Spec.Template.Spec.Containers[0]
+		switch deployment.Name {
+		case "db":
+			if container.LivenessProbe == nil || container.ReadinessProbe == nil || container.ReadinessProbe.Exec.Command[0] != "pg_isready" {
+				t.Errorf("Expected the pg_isready probes on db, got %v and %v", container.LivenessProbe, container.ReadinessProbe)
+			}
+		case "web":
+			if container.LivenessProbe == nil || container.LivenessProbe.Exec == nil || container.LivenessProbe.Exec.Command[0] != "true" {
+				t.Errorf("Expected web to keep the liveness probe of its healthcheck, got %v", container.LivenessProbe)
+			}
+			if container.ReadinessProbe == nil || container.ReadinessProbe.TCPSocket == nil || container.ReadinessProbe.TCPSocket.Port != intstr.FromString("http") {
+				t.Errorf("Expected the TCP readiness probe of nginx on web to refer to the port http, got %v", container.ReadinessProbe)
+			}
+		case "other":
+			if container.LivenessProbe != nil || container.ReadinessProbe != nil {
+				t.Errorf("Expected no probes on other")
+			}
+		}
+	}
+}
+
+func TestInitSharesProcessNamespace(t *testing.T) {
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"web": {Image: "nginx", Init: true},
+			"db":  {Image: "redis"},
+		},
+	}
+	k := Kubernetes{}
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	for _, obj := range objects {
+		if deployment, ok := obj.(*appsv1.Deployment); ok {
+			share := deployment.Spec.Template.Spec.ShareProcessNamespace
+			if deployment.Name == "web" && (share == nil || !*share) {
+				t.Errorf("Expected the pod of web to share its process namespace")
+			}
+			if deployment.Name == "db" && share != nil {
+				t.Errorf("Expected the pod of db not to share its process namespace")
+			}
+		}
+	}
+}
+
+func TestTerminationMessage(t *testing.T) {
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"worker": {Image: "worker", TerminationMessagePolicy: "FallbackToLogsOnError", TerminationMessagePath: "/tmp/termination-log"},
+		},
+	}
+	k := Kubernetes{}
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	for _, obj := range objects {
+		if deployment, ok := obj.(*appsv1.Deployment); ok {
+			container := deployment.Spec.Template.Spec.Containers[0]
+			if container.TerminationMessagePolicy != api.TerminationMessageFallbackToLogsOnError || container.TerminationMessagePath != "/tmp/termination-log" {
+				t.Errorf("Expected the FallbackToLogsOnError policy and path /tmp/termination-log, got %q and %q", container.TerminationMessagePolicy, container.TerminationMessagePath)
+			}
+		}
+	}
+}
+
+func TestRuntimeClass(t *testing.T) {
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"sandbox": {Image: "sandbox", RuntimeClass: "gvisor"},
+			"web":     {Image: "web"},
+		},
+	}
+	k := Kubernetes{}
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	for _, obj := range objects {
+		if deployment, ok := obj.(*appsv1.Deployment); ok {
+			runtimeClass := deployment.Spec.Template.Spec.RuntimeClassName
+			switch deployment.Name {
+			case "sandbox":
+				if runtimeClass == nil || *runtimeClass != "gvisor" {
+					t.Errorf("Expected the runtime class gvisor, got %v", runtimeClass)
+				}
+			case "web":
+				if runtimeClass != nil {
+					t.Errorf("Expected no runtime class, got %s", *runtimeClass)
+				}
+			}
+		}
+	}
+}
+
+func TestServiceAccountToken(t *testing.T) {
+	automount := false
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"uploader": {
+				Image:                        "uploader",
+				AutomountServiceAccountToken: &automount,
+				ServiceAccountToken:          kobject.ServiceAccountToken{Audience: "sts.amazonaws.com", ExpirationSeconds: 86400},
+			},
+		},
+	}
+	k := Kubernetes{}
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	for _, obj := range objects {
+		deployment, ok := obj.(*appsv1.Deployment)
+		if !ok {
+			continue
+		}
+		spec := deployment.Spec.Template.Spec
+		if spec.AutomountServiceAccountToken == nil || *spec.AutomountServiceAccountToken {
+			t.Errorf("Expected the token of the API server not to be mounted, got %v", spec.AutomountServiceAccountToken)
+		}
+		if len(spec.Volumes) != 1 || spec.Volumes[0].Projected == nil {
+			t.Fatalf("Expected a projected volume, got %+v", spec.Volumes)
+		}
+		projection := spec.Volumes[0].Projected.Sources[0].ServiceAccountToken
+		if projection == nil || projection.Audience != "sts.amazonaws.com" || *projection.ExpirationSeconds != 86400 || projection.Path != "token" {
+			t.Errorf("Expected a token for sts.amazonaws.com valid for 86400 seconds, got %+v", projection)
+		}
+		mounts := spec.Containers[0].VolumeMounts
+		if len(mounts) != 1 || mounts[0].MountPath != defaultServiceAccountTokenPath || !mounts[0].ReadOnly {
+			t.Errorf("Expected the token to be mounted read-only at %s, got %+v", defaultServiceAccountTokenPath, mounts)
+		}
+	}
+}
+
+func TestHugePages(t *testing.T) {
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"dpdk": {
+				Image:     "dpdk",
+				MemLimit:  1073741824,
+				HugePages: map[string]string{"2Mi": "512Mi", "1Gi": "2Gi"},
+			},
+		},
+	}
+	k := Kubernetes{}
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	for _, obj := range objects {
+		deployment, ok := obj.(*appsv1.Deployment)
+		if !ok {
+			continue
+		}
+		spec := deployment.Spec.Template.Spec
+		resources := spec.Containers[0].Resources
+		for name, quantity := range map[api.ResourceName]string{"hugepages-2Mi": "512Mi", "hugepages-1Gi": "2Gi"} {
+			request, limit := resources.Requests[name], resources.Limits[name]
+			if request.String() != quantity || limit.String() != quantity {
+				t.Errorf("Expected the request and limit %s of %s, got %s and %s", quantity, name, request.String(), limit.String())
+			}
+		}
+		expectedVolumes := []api.Volume{
+			{Name: "dpdk-hugepages-1gi", VolumeSource: api.VolumeSource{EmptyDir: &api.EmptyDirVolumeSource{Medium: "HugePages-1Gi"}}},
+			{Name: "dpdk-hugepages-2mi", VolumeSource: api.VolumeSource{EmptyDir: &api.EmptyDirVolumeSource{Medium: "HugePages-2Mi"}}},
+		}
+		if !reflect.DeepEqual(spec.Volumes, expectedVolumes) {
+			t.Errorf("Expected the volumes %+v, got %+v", expectedVolumes, spec.Volumes)
+		}
+		expectedMounts := []api.VolumeMount{
+			{Name: "dpdk-hugepages-1gi", MountPath: "/dev/hugepages-1Gi"},
+			{Name: "dpdk-hugepages-2mi", MountPath: "/dev/hugepages-2Mi"},
+		}
+		if !reflect.DeepEqual(spec.Containers[0].VolumeMounts, expectedMounts) {
+			t.Errorf("Expected the volume mounts %+v, got %+v", expectedMounts, spec.Containers[0].VolumeMounts)
+		}
+	}
+}
+
+func TestSingletonPDB(t *testing.T) {
+	volume := func(name string) kobject.ServiceConfig {
+		return kobject.ServiceConfig{
+			Image:                 name,
+			VolList:               []string{"/data"},
+			Volumes:               []kobject.Volumes{{SvcName: name, MountPath: "/data", PVCName: name + "-claim0"}},
+			WithKomposeAnnotation: true,
+		}
+	}
+	cache := volume("cache")
+	cache.Replicas = 2
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{"db": volume("db"), "cache": cache, "web": {Image: "web"}},
+	}
+	k := Kubernetes{}
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1, SingletonPDB: true, WithKomposeAnnotation: true})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	var pdbs []string
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *unstructured.Unstructured:
+			pdbs = append(pdbs, o.GetName())
+			checkSingletonPDB(t, o, "db")
+		case *appsv1.Deployment:
+			if o.Name == "db" && !strings.Contains(o.Annotations[AnnotationRecreateReason], "db-claim0") {
+				t.Errorf("Expected the Deployment db to explain its Recreate strategy, got the annotations %v", o.Annotations)
+			}
+			if o.Name == "web" && o.Annotations[AnnotationRecreateReason] != "" {
+				t.Errorf("Expected no %s annotation for web, which mounts no volume", AnnotationRecreateReason)
+			}
+		}
+	}
+	if !reflect.DeepEqual(pdbs, []string{"db"}) {
+		t.Errorf("Expected a PodDisruptionBudget for db only, got %v", pdbs)
+	}
+
+	// the selector of a ReplicationController defaults to the labels of its pods
+	komposeObject.ServiceConfigs = map[string]kobject.ServiceConfig{"db": volume("db")}
+	objects, err = k.Transform(komposeObject, kobject.ConvertOptions{CreateRC: true, Controller: "replicationcontroller", Replicas: 1, SingletonPDB: true})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	pdbs = nil
+	for _, obj := range objects {
+		if o, ok := obj.(*unstructured.Unstructured); ok {
+			pdbs = append(pdbs, o.GetName())
+			checkSingletonPDB(t, o, "db")
+		}
+	}
+	if !reflect.DeepEqual(pdbs, []string{"db"}) {
+		t.Errorf("Expected a PodDisruptionBudget for the ReplicationController db, got %v", pdbs)
+	}
+}
+
+// checkSingletonPDB checks that pdb is a policy/v1 PodDisruptionBudget with maxUnavailable 0
+// selecting the pods of service name only
+func checkSingletonPDB(t *testing.T, pdb *unstructured.Unstructured, name string) {
+	t.Helper()
+	if pdb.GetAPIVersion() != "policy/v1" || pdb.GetKind() != "PodDisruptionBudget" {
+		t.Errorf("Expected a policy/v1 PodDisruptionBudget, got %s %s", pdb.GetAPIVersion(), pdb.GetKind())
+	}
+	if maxUnavailable, _, _ := unstructured.NestedInt64(pdb.Object, "spec", "maxUnavailable"); maxUnavailable != 0 {
+		t.Errorf("Expected maxUnavailable 0, got %d", maxUnavailable)
+	}
+	selector, _, _ := unstructured.NestedStringMap(pdb.Object, "spec", "selector", "matchLabels")
+	if expected := map[string]string{transformer.LabelName: name}; !reflect.DeepEqual(selector, expected) {
+		t.Errorf("Expected the PodDisruptionBudget to select %v, got %v", expected, selector)
+	}
+}
+
+func TestMigrateControllers(t *testing.T) {
+	manifests := `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: web
+- apiVersion: v1
+  kind: ReplicationController
+  metadata:
+    name: web
+    labels:
+      io.kompose.service: web
+  spec:
+    replicas: 2
+    template:
+      metadata:
+        labels:
+          io.kompose.service: web
+      spec:
+        containers:
+        - name: web
+          image: nginx
+        volumes:
+        - name: data
+          persistentVolumeClaim:
+            claimName: data
+`
+	out, migrated, err := MigrateControllers([]byte(manifests), false, 2)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "MigrateControllers failed"))
+	}
+	if migrated != 1 {
+		t.Errorf("Expected 1 migrated ReplicationController, got %d", migrated)
+	}
+
+	var list struct {
+		Items []json.RawMessage `json:"items"`
+	}
+	data, err := yaml.ToJSON(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 2 {
+		t.Fatalf("Expected 2 objects, got %d", len(list.Items))
+	}
+	deployment := appsv1.Deployment{}
+	if err := json.Unmarshal(list.Items[1], &deployment); err != nil {
+		t.Fatal(err)
+	}
+	if deployment.Kind != "Deployment" || deployment.APIVersion != "apps/v1" {
+		t.Errorf("Expected an apps/v1 Deployment, got %s %s", deployment.APIVersion, deployment.Kind)
+	}
+	if *deployment.Spec.Replicas != 2 || deployment.Spec.Selector.MatchLabels["io.kompose.service"] != "web" {
+		t.Errorf("Expected the replicas and selector of the ReplicationController, got %v", deployment.Spec)
+	}
+	if deployment.Spec.Strategy.Type != appsv1.RecreateDeploymentStrategyType {
+		t.Errorf("Expected the Recreate strategy for a pod using a PersistentVolumeClaim, got %q", deployment.Spec.Strategy.Type)
+	}
+}
+
+func TestServiceTopology(t *testing.T) {
+	service := newServiceConfig()
+	service.TrafficPolicy = "Local"
+	service.TopologyHints = "auto"
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{"app": service},
+	}
+	k := Kubernetes{}
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	found := false
+	for _, obj := range objects {
+		svc, ok := obj.(*unstructured.Unstructured)
+		if !ok || svc.GetKind() != "Service" {
+			continue
+		}
+		found = true
+		if policy, _, _ := unstructured.NestedString(svc.Object, "spec", "internalTrafficPolicy"); policy != "Local" {
+			t.Errorf("Expected the traffic to be routed to the node of the client, got the internalTrafficPolicy %q", policy)
+		}
+		if _, ok, _ := unstructured.NestedFieldNoCopy(svc.Object, "spec", "topologyKeys"); ok {
+			t.Errorf("Expected no topologyKeys, which were removed in Kubernetes 1.22")
+		}
+		annotations := svc.GetAnnotations()
+		if annotations["service.kubernetes.io/topology-aware-hints"] != "auto" || annotations["service.kubernetes.io/topology-mode"] != "Auto" {
+			t.Errorf("Expected the topology aware hints annotations, got %v", annotations)
+		}
+		if _, ok := annotations[annotationInternalTrafficPolicy]; ok {
+			t.Errorf("Expected the %s annotation to be removed", annotationInternalTrafficPolicy)
+		}
+		if ports, _, _ := unstructured.NestedSlice(svc.Object, "spec", "ports"); len(ports) == 0 {
+			t.Errorf("Expected the ports of the Service to be kept")
+		}
+	}
+	if !found {
+		t.Errorf("Expected a Service")
+	}
+}
+
+func TestZeroTrust(t *testing.T) {
+	web := newServiceConfig()
+	web.Network = nil
+	web.NetworkAliases = []string{"www"}
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{"web": web},
+	}
+	k := Kubernetes{}
+	opt := kobject.ConvertOptions{CreateD: true, Replicas: 1, ZeroTrust: true}
+	objects, err := k.Transform(komposeObject, opt)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	if len(komposeObject.ServiceConfigs["web"].Network) != 0 {
+		t.Errorf("Expected the services of the caller to be left unchanged, got the networks %v", komposeObject.ServiceConfigs["web"].Network)
+	}
+
+	certManager := map[string]*unstructured.Unstructured{}
+	policies := map[string]bool{}
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *api.Secret:
+			t.Errorf("Expected no Secret holding keys in the manifests, got %s", o.Name)
+		case *unstructured.Unstructured:
+			certManager[o.GetKind()+"/"+o.GetName()] = o
+		case *networkingv1.NetworkPolicy:
+			policies[o.Name] = true
+		case *appsv1.Deployment:
+			if o.Spec.Template.Labels["io.kompose.network/"+ZeroTrustNetwork] != "true" {
+				t.Errorf("Expected the pod of a service in no network to be in the %s network, got labels %v", ZeroTrustNetwork, o.Spec.Template.Labels)
+			}
+			container := o.Spec.Template.Spec.Containers[0]
+			if len(container.VolumeMounts) == 0 || container.VolumeMounts[len(container.VolumeMounts)-1].MountPath != ZeroTrustTLSDir {
+				t.Errorf("Expected the TLS Secret mounted at %s, got %v", ZeroTrustTLSDir, container.VolumeMounts)
+			}
+			found := false
+			for _, env := range container.Env {
+				if env.Name == "TLS_CERT_FILE" && env.Value == ZeroTrustTLSDir+"/tls.crt" {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Expected TLS_CERT_FILE pointing to the certificate, got %v", container.Env)
+			}
+		}
+	}
+	if !policies["default-deny-ingress"] || !policies[ZeroTrustNetwork] {
+		t.Errorf("Expected the default deny and %s NetworkPolicies, got %v", ZeroTrustNetwork, policies)
+	}
+
+	for _, name := range []string{"ClusterIssuer/kompose-selfsigned", "ClusterIssuer/kompose-ca", "Certificate/kompose-ca", "Certificate/web-tls"} {
+		if obj := certManager[name]; obj == nil || obj.GetAPIVersion() != "cert-manager.io/v1" {
+			t.Errorf("Expected the cert-manager.io/v1 %s, got %v", name, obj)
+		}
+	}
+	if cert := certManager["Certificate/web-tls"]; cert != nil {
+		secretName, _, _ := unstructured.NestedString(cert.Object, "spec", "secretName")
+		dnsNames, _, _ := unstructured.NestedStringSlice(cert.Object, "spec", "dnsNames")
+		issuer, _, _ := unstructured.NestedString(cert.Object, "spec", "issuerRef", "name")
+		if secretName != "web-tls" || !reflect.DeepEqual(dnsNames, []string{"web", "www"}) || issuer != "kompose-ca" {
+			t.Errorf("Expected the certificate of web and www in web-tls issued by kompose-ca, got %v", cert.Object["spec"])
+		}
+	}
+
+	// the conversions are the same, as the keys are generated in the cluster
+	again, err := k.Transform(komposeObject, opt)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	if !reflect.DeepEqual(objects, again) {
+		t.Errorf("Expected the conversions with --zero-trust to be the same")
+	}
+}
+
+func TestHostSockets(t *testing.T) {
+	service := kobject.ServiceConfig{
+		Image: "gitlab/gitlab-runner",
+		Volumes: []kobject.Volumes{{
+			SvcName:   "runner",
+			Host:      "/var/run/docker.sock",
+			Container: "/var/run/docker.sock",
+			MountPath: "/var/run/docker.sock:/var/run/docker.sock",
+			PVCName:   "runner-claim0",
+		}},
+	}
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{"runner": service},
+	}
+	opt := kobject.ConvertOptions{CreateD: true, Replicas: 1, HostSockets: true}
+	k := Kubernetes{Opt: opt}
+	objects, err := k.Transform(komposeObject, opt)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	for _, obj := range objects {
+		if _, ok := obj.(*api.PersistentVolumeClaim); ok {
+			t.Errorf("Expected no PersistentVolumeClaim for the Docker socket")
+		}
+		deployment, ok := obj.(*appsv1.Deployment)
+		if !ok {
+			continue
+		}
+		volumes := deployment.Spec.Template.Spec.Volumes
+		if len(volumes) != 1 || volumes[0].HostPath == nil || volumes[0].HostPath.Path != "/var/run/docker.sock" ||
+			volumes[0].HostPath.Type == nil || *volumes[0].HostPath.Type != api.HostPathSocket {
+			t.Errorf("Expected a hostPath volume of type Socket, got %v", volumes)
+		}
+		securityContext := deployment.Spec.Template.Spec.Containers[0].SecurityContext
+		if securityContext == nil || securityContext.SELinuxOptions == nil || securityContext.SELinuxOptions.Type != "spc_t" {
+			t.Errorf("Expected the spc_t SELinux type, got %v", securityContext)
+		}
+	}
+}
+
+func TestPinCPUs(t *testing.T) {
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"trader": {Image: "trader", CPUSet: "2-3,6", CPULimit: 500, MemLimit: 1 << 30},
+		},
+	}
+	opt := kobject.ConvertOptions{CreateD: true, Replicas: 1, PinCPUs: true}
+	k := Kubernetes{Opt: opt}
+	objects, err := k.Transform(komposeObject, opt)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	for _, obj := range objects {
+		deployment, ok := obj.(*appsv1.Deployment)
+		if !ok {
+			continue
+		}
+		resources := deployment.Spec.Template.Spec.Containers[0].Resources
+		for _, list := range []api.ResourceList{resources.Requests, resources.Limits} {
+			if cpu := list[api.ResourceCPU]; cpu.String() != "3" {
+				t.Errorf("Expected 3 whole CPUs, got %s", cpu.String())
+			}
+			if memory := list[api.ResourceMemory]; memory.Value() != 1<<30 {
+				t.Errorf("Expected 1Gi of memory, got %s", memory.String())
+			}
+		}
+	}
+
+	if err := ValidateServices(kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{"trader": {Image: "trader", CPUSet: "3-1"}},
+	}, opt); err == nil {
+		t.Errorf("Expected an error for an invalid cpuset")
+	}
+}
+
+func TestLocalCluster(t *testing.T) {
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"web": {
+				Image: "web", Build: ".",
+				Port: []kobject.Ports{{HostPort: 8080, ContainerPort: 80, Protocol: api.ProtocolTCP}},
+				Volumes: []kobject.Volumes{
+					{SvcName: "web", Host: "./html", Container: "/usr/share/nginx/html", MountPath: "./html:/usr/share/nginx/html", PVCName: "web-claim0"},
+					{SvcName: "web", Container: "/cache", MountPath: "/cache", PVCName: "web-claim1"},
+				},
+			},
+			"api":   {Image: "api", Port: []kobject.Ports{{HostPort: 10848, ContainerPort: 3000, Protocol: api.ProtocolTCP}}},
+			"cache": {Image: "redis", Port: []kobject.Ports{{ContainerPort: 6379, Protocol: api.ProtocolTCP}}},
+			"admin": {Image: "admin", Port: []kobject.Ports{{HostPort: 9000, ContainerPort: 9000, Protocol: api.ProtocolTCP}}, SplitPorts: true},
+		},
+	}
+	opt := kobject.ConvertOptions{CreateD: true, Replicas: 1, LocalCluster: true, LocalMountRoot: "/mnt/project",
+		InputFiles: []string{"/home/user/project/docker-compose.yml"}}
+	k := Kubernetes{Opt: opt}
+	objects, err := k.Transform(komposeObject, opt)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	nodePorts := map[string]int32{}
+	claims := 0
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *api.Service:
+			if o.Spec.Type == api.ServiceTypeNodePort {
+				nodePorts[o.Name] = o.Spec.Ports[0].NodePort
+			}
+		case *api.PersistentVolumeClaim:
+			claims++
+		case *appsv1.Deployment:
+			if o.Name != "web" {
+				continue
+			}
+			if policy := o.Spec.Template.Spec.Containers[0].ImagePullPolicy; policy != api.PullNever {
+				t.Errorf("Expected the image pull policy Never for a built image, got %q", policy)
+			}
+			volume := o.Spec.Template.Spec.Volumes[0]
+			if volume.HostPath == nil || volume.HostPath.Path != "/mnt/project/html" {
+				t.Errorf("Expected a hostPath volume under the mount root, got %v", volume)
+			}
+		}
+	}
+	// 10848 derives the node port of 8080, the services are converted in alphabetical order
+	if nodePorts["api"] != 32544 || nodePorts["web"] != 32545 {
+		t.Errorf("Expected the node ports 32544 of api and 32545 of web, got %v", nodePorts)
+	}
+	// the Services of split ports get node ports too, the Service of the service stays in the cluster
+	if _, ok := nodePorts["admin"]; ok || nodePorts["admin-9000"] == 0 {
+		t.Errorf("Expected a node port for the Service admin-9000 only, got %v", nodePorts)
+	}
+	if claims != 1 {
+		t.Errorf("Expected a PersistentVolumeClaim for the named volume only, got %d", claims)
+	}
+}
+
+func TestRestartPolicyMaxAttempts(t *testing.T) {
+	maxAttempts, unlimited := uint64(3), uint64(0)
+	window, longWindow := types.Duration(90*time.Second), types.Duration(15*time.Minute)
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"migrate": {Image: "migrate", Restart: "on-failure", DeployRestartPolicy: types.RestartPolicy{MaxAttempts: &maxAttempts}},
+			"web":     {Image: "nginx", DeployRestartPolicy: types.RestartPolicy{Window: &window}},
+			"slow":    {Image: "slow", DeployRestartPolicy: types.RestartPolicy{Window: &longWindow}},
+			// swarm retries without limit with max_attempts 0, like the pods
+			"retry": {Image: "retry", Restart: "on-failure", DeployRestartPolicy: types.RestartPolicy{MaxAttempts: &unlimited}},
+		},
+	}
+	opt := kobject.ConvertOptions{Replicas: 1}
+	k := Kubernetes{Opt: opt}
+	objects, err := k.Transform(komposeObject, opt)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	var job *batchv1.Job
+	var pods []string
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *batchv1.Job:
+			if o.Name != "migrate" {
+				t.Errorf("Expected a Job for migrate only, got %s", o.Name)
+				continue
+			}
+			job = o
+		case *api.Pod:
+			pods = append(pods, o.Name)
+		case *appsv1.Deployment:
+			// a window shorter than the default deadline keeps it
+			deadlines := map[string]*int32{"web": nil, "slow": func(i int32) *int32 { return &i }(900)}
+			if deadline := o.Spec.ProgressDeadlineSeconds; !reflect.DeepEqual(deadline, deadlines[o.Name]) {
+				t.Errorf("Expected the progress deadline %v of %s, got %v", deadlines[o.Name], o.Name, deadline)
+			}
+		}
+	}
+	if !reflect.DeepEqual(pods, []string{"retry"}) {
+		t.Errorf("Expected a Pod for retry only, got %v", pods)
+	}
+	if job == nil {
+		t.Fatalf("Expected a Job for the service with max_attempts")
+	}
+	if job.Spec.BackoffLimit == nil || *job.Spec.BackoffLimit != 3 {
+		t.Errorf("Expected a backoff limit of 3, got %v", job.Spec.BackoffLimit)
+	}
+	if policy := job.Spec.Template.Spec.RestartPolicy; policy != api.RestartPolicyOnFailure {
+		t.Errorf("Expected the restart policy OnFailure, got %s", policy)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	hooks := log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+	defer log.StandardLogger().ReplaceHooks(hooks)
+	hook := logtest.NewGlobal()
+
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"web":    {Image: "nginx"},
+			"db":     {Image: "postgres"},
+			"worker": {Image: "worker"},
+		},
+	}
+	testCases := map[string]struct {
+		progress bool
+		expected []string
+	}{
+		"Disabled": {false, nil},
+		"Enabled": {true, []string{
+			"[1/3] Converting service db",
+			"[2/3] Converting service web",
+			"[3/3] Converting service worker",
+		}},
+	}
+
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			hook.Reset()
+			opt := kobject.ConvertOptions{CreateD: true, Replicas: 1, Progress: test.progress}
+			k := Kubernetes{Opt: opt}
+			if _, err := k.Transform(komposeObject, opt); err != nil {
+				t.Fatal(errors.Wrap(err, "k.Transform failed"))
+			}
+			var progress []string
+			for _, entry := range hook.AllEntries() {
+				if strings.Contains(entry.Message, "Converting service") {
+					progress = append(progress, entry.Message)
+				}
+			}
+			if !reflect.DeepEqual(progress, test.expected) {
+				t.Errorf("Expected the progress %q, got %q", test.expected, progress)
+			}
+		})
+	}
+}
+
+func TestJobTTLSecondsAfterFinished(t *testing.T) {
+	ttl := int32(600)
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"seed": {Image: "seed", Restart: "no", JobTTLSecondsAfterFinished: &ttl},
+		},
+	}
+	opt := kobject.ConvertOptions{Replicas: 1}
+	k := Kubernetes{Opt: opt}
+	objects, err := k.Transform(komposeObject, opt)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	var job *batchv1.Job
+	for _, obj := range objects {
+		if o, ok := obj.(*batchv1.Job); ok {
+			job = o
+		}
+	}
+	if job == nil {
+		t.Fatalf("Expected a Job for the service with a TTL")
+	}
+	if job.Spec.TTLSecondsAfterFinished == nil || *job.Spec.TTLSecondsAfterFinished != 600 {
+		t.Errorf("Expected a TTL of 600 seconds, got %v", job.Spec.TTLSecondsAfterFinished)
+	}
+	if job.Spec.BackoffLimit != nil {
+		t.Errorf("Expected the default backoff limit, got %v", *job.Spec.BackoffLimit)
+	}
+}
+
+func TestSplitServicePorts(t *testing.T) {
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"dns": {Image: "dns", Port: []kobject.Ports{{HostPort: 53, ContainerPort: 53, Protocol: api.ProtocolTCP}, {HostPort: 53, ContainerPort: 53, Protocol: api.ProtocolUDP}}},
+			"web": {Image: "nginx", Port: []kobject.Ports{{HostPort: 8080, ContainerPort: 80, Protocol: api.ProtocolTCP}, {HostPort: 9090, ContainerPort: 9090, Protocol: api.ProtocolTCP}}, ExposeService: "web.example.org", SplitPorts: true},
+		},
+	}
+	opt := kobject.ConvertOptions{CreateD: true, Replicas: 1}
+	k := Kubernetes{Opt: opt}
+	objects, err := k.Transform(komposeObject, opt)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	services := map[string]*api.Service{}
+	var ingress *networkingv1beta1.Ingress
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *api.Service:
+			services[o.Name] = o
+		case *networkingv1beta1.Ingress:
+			ingress = o
+		}
+	}
+	// only web splits its ports, dns keeps one Service, web keeps its Service for the clients
+	// within the cluster
+	for _, name := range []string{"dns", "web", "web-8080", "web-9090"} {
+		if _, ok := services[name]; !ok {
+			t.Errorf("Expected a Service %s, got %v", name, services)
+		}
+	}
+	if len(services) != 4 {
+		t.Errorf("Expected 4 Services, got %d", len(services))
+	}
+	if web := services["web"]; web != nil && (len(web.Spec.Ports) != 2 || web.Spec.Type != api.ServiceTypeClusterIP) {
+		t.Errorf("Expected the ClusterIP Service web with both ports, got %v", web.Spec)
+	}
+	for _, name := range []string{"web-8080", "web-9090"} {
+		svc := services[name]
+		if svc == nil {
+			continue
+		}
+		if len(svc.Spec.Ports) != 1 {
+			t.Errorf("Expected a single port in Service %s, got %v", name, svc.Spec.Ports)
+		}
+		if transformer.ServiceLabel(svc.Spec.Selector) != "web" {
+			t.Errorf("Expected Service %s to select the pods of web, got %v", name, svc.Spec.Selector)
+		}
+	}
+	if ingress == nil {
+		t.Fatalf("Expected an Ingress for web")
+	}
+	if backend := ingress.Spec.Rules[0].HTTP.Paths[0].Backend; backend.ServiceName != "web-8080" || backend.ServicePort.IntVal != 8080 {
+		t.Errorf("Expected the Ingress to route to web-8080:8080, got %s:%d", backend.ServiceName, backend.ServicePort.IntVal)
+	}
+
+	// the flag splits the ports of every service, the port published with TCP and UDP gets a
+	// Service per protocol
+	opt.SplitServicePorts = true
+	k = Kubernetes{Opt: opt}
+	objects, err = k.Transform(komposeObject, opt)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	names := map[string]bool{}
+	for _, obj := range objects {
+		if svc, ok := obj.(*api.Service); ok {
+			names[svc.Name] = true
+		}
+	}
+	for _, name := range []string{"dns", "dns-53", "dns-53-udp", "web", "web-8080", "web-9090"} {
+		if !names[name] {
+			t.Errorf("Expected a Service %s with --split-service-ports, got %v", name, names)
+		}
+	}
+}
+
+func TestBrokenServiceReferences(t *testing.T) {
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"app": {Image: "app", Environment: []kobject.EnvVar{
+				{Name: "DB_HOST", Value: "postgres"},
+				{Name: "CACHE_URL", Value: "redis://my_cache:6379/0"},
+				{Name: "WORKER", Value: "worker"},
+				{Name: "DNS", Value: "dns:53"},
+				{Name: "API", Value: "http://api:8080"},
+				// a service referring to its own name doesn't need its Service
+				{Name: "DB_NAME", Value: "app"},
+			}},
+			"postgres": {Image: "postgres", Port: []kobject.Ports{{ContainerPort: 5432, Protocol: api.ProtocolTCP}}},
+			"my-cache": {Image: "redis", ComposeName: "my_cache", Port: []kobject.Ports{{ContainerPort: 6379, Protocol: api.ProtocolTCP}}},
+			"worker":   {Image: "worker"},
+			"dns":      {Image: "dns", Port: []kobject.Ports{{ContainerPort: 53, Protocol: api.ProtocolUDP}}, ServiceType: "LoadBalancer"},
+			"api":      {Image: "api", Port: []kobject.Ports{{ContainerPort: 8080, Protocol: api.ProtocolTCP}}, SplitPorts: true},
+		},
+	}
+	broken := BrokenServiceReferences(komposeObject, kobject.ConvertOptions{})
+	expected := []BrokenReference{
+		{"app", "CACHE_URL refers to service my_cache, but its Service is named my-cache"},
+		{"app", "WORKER refers to service worker, but it publishes no ports, so no Service is created for it"},
+		{"app", "DNS refers to service dns, but its LoadBalancer Services are named dns-tcp and dns-udp"},
+	}
+	if !reflect.DeepEqual(broken, expected) {
+		t.Errorf("Expected the broken references\n%v\ngot\n%v", expected, broken)
+	}
+}
+
+func TestHPA(t *testing.T) {
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"web":    {Image: "nginx", Replicas: 3, CPUReservation: 250, HPA: kobject.HPA{MaxReplicas: 10}},
+			"worker": {Image: "worker", Replicas: 3, HPA: kobject.HPA{MinReplicas: 2, MaxReplicas: 5, Memory: 70}},
+			"db":     {Image: "postgres", Replicas: 2},
+		},
+	}
+	opt := kobject.ConvertOptions{CreateD: true, Replicas: 1}
+	k := Kubernetes{Opt: opt}
+	objects, err := k.Transform(komposeObject, opt)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	hpas := map[string]*autoscalingv2beta2.HorizontalPodAutoscaler{}
+	deployments := map[string]*appsv1.Deployment{}
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *unstructured.Unstructured:
+			if o.GetAPIVersion() != "autoscaling/v2" || o.GetKind() != "HorizontalPodAutoscaler" {
+				t.Errorf("Expected an autoscaling/v2 HorizontalPodAutoscaler, got %s %s", o.GetAPIVersion(), o.GetKind())
+			}
+			if labels := o.GetLabels(); labels[transformer.LabelName] != o.GetName() || labels[transformer.Selector] != "" {
+				t.Errorf("Expected the recommended labels on the HPA %s, got %v", o.GetName(), labels)
+			}
+			hpa := &autoscalingv2beta2.HorizontalPodAutoscaler{}
+			if err := k8sruntime.DefaultUnstructuredConverter.FromUnstructured(o.Object, hpa); err != nil {
+				t.Fatal(err)
+			}
+			hpas[o.GetName()] = hpa
+		case *appsv1.Deployment:
+			deployments[o.Name] = o
+		}
+	}
+	if len(hpas) != 2 {
+		t.Fatalf("Expected HPAs for web and worker, got %d", len(hpas))
+	}
+
+	// the minimum defaults to the replicas, the target to 50% CPU
+	web := hpas["web"].Spec
+	if *web.MinReplicas != 3 || web.MaxReplicas != 10 {
+		t.Errorf("Expected web to scale between 3 and 10 replicas, got %d and %d", *web.MinReplicas, web.MaxReplicas)
+	}
+	if len(web.Metrics) != 1 || web.Metrics[0].Resource.Name != api.ResourceCPU || *web.Metrics[0].Resource.Target.AverageUtilization != 50 {
+		t.Errorf("Expected web to scale by 50%% CPU utilization, got %+v", web.Metrics)
+	}
+	if web.ScaleTargetRef.Kind != "Deployment" || web.ScaleTargetRef.Name != "web" {
+		t.Errorf("Expected the HPA to scale the Deployment web, got %+v", web.ScaleTargetRef)
+	}
+
+	worker := hpas["worker"].Spec
+	if *worker.MinReplicas != 2 || worker.MaxReplicas != 5 {
+		t.Errorf("Expected worker to scale between 2 and 5 replicas, got %d and %d", *worker.MinReplicas, worker.MaxReplicas)
+	}
+	if len(worker.Metrics) != 1 || worker.Metrics[0].Resource.Name != api.ResourceMemory {
+		t.Errorf("Expected worker to scale by memory utilization only, got %+v", worker.Metrics)
+	}
+
+	// the replicas of the scaled controllers are left to the HPA
+	for _, name := range []string{"web", "worker"} {
+		if d := deployments[name]; d == nil || d.Spec.Replicas != nil {
+			t.Errorf("Expected the Deployment %s without replicas", name)
+		}
+	}
+	if d := deployments["db"]; d == nil || d.Spec.Replicas == nil || *d.Spec.Replicas != 2 {
+		t.Errorf("Expected the Deployment db with 2 replicas")
+	}
+
+	komposeObject.ServiceConfigs["web"] = kobject.ServiceConfig{Image: "nginx", HPA: kobject.HPA{MinReplicas: 5, MaxReplicas: 2}}
+	if _, err := k.Transform(komposeObject, opt); err == nil {
+		t.Errorf("Expected an error for more min than max replicas")
+	}
+	komposeObject.ServiceConfigs["web"] = kobject.ServiceConfig{Image: "nginx", HPA: kobject.HPA{MinReplicas: 2}}
+	if _, err := k.Transform(komposeObject, opt); err == nil {
+		t.Errorf("Expected an error for an HPA without max replicas")
+	}
+}
+
+func TestVerifyManifests(t *testing.T) {
+	// the output of a conversion is consistent
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"web": {Image: "nginx", Port: []kobject.Ports{{HostPort: 80, ContainerPort: 80, Protocol: api.ProtocolTCP}}, ExposeService: "web.example.org", HPA: kobject.HPA{MaxReplicas: 3}, Network: []string{"back"}},
+			"db": {Image: "postgres", Port: []kobject.Ports{{ContainerPort: 5432, Protocol: api.ProtocolTCP}}, Environment: []kobject.EnvVar{{Name: "POSTGRES_DB", Value: "app"}}, Network: []string{"back"},
+				VolList: []string{"/data"}, Volumes: []kobject.Volumes{{SvcName: "db", MountPath: "/data", PVCName: "db-claim0"}}},
+		},
+	}
+	opt := kobject.ConvertOptions{CreateD: true, Replicas: 1, YAMLIndent: 2, ZeroTrust: true, SingletonPDB: true}
+	k := Kubernetes{Opt: opt}
+	objects, err := k.Transform(komposeObject, opt)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	data, err := MarshalList(objects, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests, err := ParseManifests(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifests) != len(objects) {
+		t.Errorf("Expected %d objects in the List, got %d", len(objects), len(manifests))
+	}
+	problems, err := VerifyManifests(manifests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) > 0 {
+		t.Errorf("Expected no problems in the converted objects, got %v", problems)
+	}
+
+	// hand edits break the references
+	edited := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: webapp
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: nginx
+        ports:
+        - containerPort: 8080
+        readinessProbe:
+          httpGet:
+            port: 80
+        env:
+        - name: A
+          valueFrom:
+            configMapKeyRef:
+              name: web-env
+              key: A
+        - name: B
+          valueFrom:
+            configMapKeyRef:
+              name: web-env
+              key: B
+        - name: C
+          valueFrom:
+            secretKeyRef:
+              name: web-secret
+              key: C
+              optional: true
+      volumes:
+      - name: data
+        persistentVolumeClaim:
+          claimName: data
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  selector:
+    app: website
+  ports:
+  - port: 8080
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+spec:
+  rules:
+  - http:
+      paths:
+      - path: /
+        backend:
+          service:
+            name: web
+            port:
+              number: 80
+---
+apiVersion: policy/v1
+kind: PodDisruptionBudget
+metadata:
+  name: web
+spec:
+  maxUnavailable: 0
+  selector:
+    matchLabels:
+      app: website
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: web
+spec:
+  podSelector:
+    matchLabels:
+      app: web
+  ingress:
+  - from:
+    - podSelector:
+        matchExpressions:
+        - key: app
+          operator: In
+          values: [frontend]
+    - namespaceSelector: {}
+      podSelector:
+        matchLabels:
+          app: frontend
+`
+	manifests, err = ParseManifests([]byte(edited))
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems, err = VerifyManifests(manifests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"Deployment web: the ConfigMap web-env doesn't exist",
+		"Deployment web: the PersistentVolumeClaim data doesn't exist",
+		"Deployment web: the readiness probe of container web uses port 80, which the container doesn't declare",
+		"Deployment web: the selector app=webapp doesn't match the labels of its pod template",
+		"Ingress web: the Service web has no port 80",
+		"NetworkPolicy web: the podSelector app in (frontend) of from matches no pods",
+		"PodDisruptionBudget web: the selector app=website matches no pods",
+		"Service web: the selector app=website matches no pods",
+	}
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("Expected the problems\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(problems, "\n"))
+	}
+}
+
+func TestEvaluatePolicies(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake opa is a shell script")
+	}
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"web": {Image: "nginx:latest"},
+			"db":  {Image: "postgres:16"},
+		},
+	}
+	k := Kubernetes{}
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	unstructuredObjects, err := ToUnstructured(objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "kompose-policy-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	policies := filepath.Join(dir, "policies")
+	if err := os.Mkdir(policies, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := EvaluatePolicies(unstructuredObjects, policies); err == nil {
+		t.Errorf("Expected an error for a directory without policies")
+	}
+
+	// the fake opa denies the latest tag, as a policy checking the image of the input would, for
+	// the objects of the input, one per line, in a single run counted in runs
+	files := map[string]string{
+		"opa": `#!/bin/sh
+echo run >> "$(dirname "$0")/runs"
+if ! grep -q "^package kompose$" "$5"/*.rego; then
+  echo '{}'
+  exit 0
+fi
+results=""
+while read -r line; do
+  case "$line" in
+  "["|"]") continue ;;
+  *'"image":"'*':latest"'*) result='{"deny":["the latest tag is not allowed"],"warn":[{"msg":"no limits"}]}' ;;
+  *) result='{"deny":[]}' ;;
+  esac
+  results="${results:+$results,}$result"
+done
+echo "{\"result\":[{\"expressions\":[{\"value\":[$results],\"text\":\"rules\"}]}]}"
+`,
+		"policies/tags.rego": "package kompose\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(binary string) { OPABinary = binary }(OPABinary)
+	OPABinary = filepath.Join(dir, "opa")
+
+	violations, err := EvaluatePolicies(unstructuredObjects, policies)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	expected := []string{"Deployment web: the latest tag is not allowed"}
+	if !reflect.DeepEqual(violations, expected) {
+		t.Errorf("Expected the violations %v, got %v", expected, violations)
+	}
+	runs, err := ioutil.ReadFile(filepath.Join(dir, "runs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(runs), "run") != 1 {
+		t.Errorf("Expected opa to run once for the %d objects, got %q", len(unstructuredObjects), runs)
+	}
+
+	// policies of another package have no result
+	if err := ioutil.WriteFile(filepath.Join(policies, "tags.rego"), []byte("package kompose.tags\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := EvaluatePolicies(unstructuredObjects, policies); err == nil || !strings.Contains(err.Error(), "no deny or warn rule") {
+		t.Errorf("Expected an error for policies without rules of the kompose package, got %v", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(policies, "limits.cel"), []byte("has(object.spec)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := EvaluatePolicies(unstructuredObjects, policies); err == nil {
+		t.Errorf("Expected an error for a CEL policy")
+	}
+}
+
+func TestAutoIngress(t *testing.T) {
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"web": {Image: "nginx", Port: []kobject.Ports{{HostPort: 8443, ContainerPort: 443, Protocol: api.ProtocolTCP}, {HostPort: 8080, ContainerPort: 80, Protocol: api.ProtocolTCP}}},
+			"api": {Image: "api", Port: []kobject.Ports{{HostPort: 3000, ContainerPort: 3000, Protocol: api.ProtocolTCP}}, ExposeService: "api.example.org"},
+			"db":  {Image: "postgres", Port: []kobject.Ports{{HostPort: 5432, ContainerPort: 5432, Protocol: api.ProtocolTCP}}},
+			"app": {Image: "app", Port: []kobject.Ports{{ContainerPort: 8080, Protocol: api.ProtocolTCP}}},
+		},
+	}
+	opt := kobject.ConvertOptions{CreateD: true, Replicas: 1, AutoIngress: "demo.example.com"}
+	k := Kubernetes{Opt: opt}
+	objects, err := k.Transform(komposeObject, opt)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	hosts := map[string]string{}
+	for _, obj := range objects {
+		if ingress, ok := obj.(*networkingv1beta1.Ingress); ok {
+			rule := ingress.Spec.Rules[0]
+			hosts[ingress.Name] = fmt.Sprintf("%s:%d", rule.Host, rule.HTTP.Paths[0].Backend.ServicePort.IntVal)
+		}
+	}
+	expected := map[string]string{"web": "web.demo.example.com:8080", "api": "api.example.org:3000"}
+	if !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("Expected the Ingresses %v, got %v", expected, hosts)
+	}
+}
+
+func TestValidateServices(t *testing.T) {
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"agent":  {Image: "agent", Replicas: 3, Labels: map[string]string{compose.LabelControllerType: "daemonset"}},
+			"logger": {Image: "logger", Replicas: 2, DeployMode: "global"},
+			"web":    {Image: "nginx", Replicas: 3},
+			"db":     {Image: "postgres", Labels: map[string]string{compose.LabelControllerType: "StatefulSets"}},
+		},
+	}
+
+	err := ValidateServices(komposeObject, kobject.ConvertOptions{})
+	if err == nil {
+		t.Fatal("Expected the services to be invalid")
+	}
+	for _, expected := range []string{"found 3 invalid service settings", "service agent: deploy.replicas 3", "service logger: deploy.replicas 2", `service db: unknown kompose.controller.type "StatefulSets"`} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected %q in the error, got %v", expected, err)
+		}
+	}
+	if strings.Contains(err.Error(), "service web") {
+		t.Errorf("Expected no violation for service web, got %v", err)
+	}
+
+	delete(komposeObject.ServiceConfigs, "db")
+	if err := ValidateServices(komposeObject, kobject.ConvertOptions{Controller: DeploymentController}); err == nil || !strings.Contains(err.Error(), "found 1 invalid") {
+		t.Errorf("Expected the controller of the options to override the global deploy mode, got %v", err)
+	}
+}
+
+func TestPartOf(t *testing.T) {
+	service := newServiceConfig()
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{"app": service},
+	}
+	opt := kobject.ConvertOptions{CreateD: true, Replicas: 1, PartOf: "shop"}
+	k := Kubernetes{Opt: opt}
+	objects, err := k.Transform(komposeObject, opt)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	for _, obj := range objects {
+		meta := obj.(metav1.Object)
+		labels := meta.GetLabels()
+		if labels[transformer.LabelPartOf] != "shop" || labels[transformer.LabelManagedBy] != "kompose" {
+			t.Errorf("Expected the app labels on %T %s, got %v", obj, meta.GetName(), labels)
+		}
+		if deployment, ok := obj.(*appsv1.Deployment); ok {
+			if _, ok := deployment.Spec.Selector.MatchLabels[transformer.LabelPartOf]; ok {
+				t.Errorf("Expected the selector of the Deployment to be kept, got %v", deployment.Spec.Selector.MatchLabels)
+			}
+		}
+		if svc, ok := obj.(*api.Service); ok {
+			if _, ok := svc.Spec.Selector[transformer.LabelPartOf]; ok {
+				t.Errorf("Expected the selector of the Service to be kept, got %v", svc.Spec.Selector)
+			}
+		}
+	}
+}
+
+func TestRecommendedLabels(t *testing.T) {
+	service := newServiceConfig()
+	service.Image = "registry.example.com/shop/frontend:1.21"
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{"web": service},
+	}
+	opt := kobject.ConvertOptions{CreateD: true, Replicas: 1, ProjectName: "shop"}
+	k := Kubernetes{Opt: opt}
+	objects, err := k.Transform(komposeObject, opt)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	expected := map[string]string{
+		transformer.LabelName:      "web",
+		transformer.LabelInstance:  "shop",
+		transformer.LabelComponent: "web",
+	}
+	selector := map[string]string{transformer.LabelName: "web", transformer.LabelInstance: "shop"}
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *api.Service:
+			if !reflect.DeepEqual(o.Labels, expected) {
+				t.Errorf("Expected the Service labels %v, got %v", expected, o.Labels)
+			}
+			if !reflect.DeepEqual(o.Spec.Selector, selector) {
+				t.Errorf("Expected the Service to select the name and instance only, got %v", o.Spec.Selector)
+			}
+		case *appsv1.Deployment:
+			if o.Labels[transformer.LabelVersion] != "1.21" || o.Spec.Template.Labels[transformer.LabelVersion] != "1.21" {
+				t.Errorf("Expected the version of the image on the Deployment and its pods, got %v and %v", o.Labels, o.Spec.Template.Labels)
+			}
+			if _, ok := o.Spec.Template.Labels[transformer.Selector]; ok {
+				t.Errorf("Expected no %s label, got %v", transformer.Selector, o.Spec.Template.Labels)
+			}
+			if !reflect.DeepEqual(o.Spec.Selector.MatchLabels, selector) {
+				t.Errorf("Expected the Deployment to select the name and instance only, got %v", o.Spec.Selector.MatchLabels)
+			}
+		}
+	}
+}
+
+func TestPreserveSelectors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kompose-selectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	old := `apiVersion: v1
+kind: List
+items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: app
+    spec:
+      selector:
+        matchLabels:
+          io.kompose.service: app
+      template:
+        metadata:
+          labels:
+            io.kompose.service: app
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "app-deployment.yaml"), []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	selectors, err := LoadSelectors(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{"app": newServiceConfig()},
+	}
+	opt := kobject.ConvertOptions{CreateD: true, Replicas: 1}
+	k := Kubernetes{Opt: opt}
+	objects, err := k.Transform(komposeObject, opt)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	preserved, err := k.PreserveSelectors(objects, selectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preserved != 1 {
+		t.Errorf("Expected the selector of 1 controller to be preserved, got %d", preserved)
+	}
+
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *appsv1.Deployment:
+			if !reflect.DeepEqual(o.Spec.Selector.MatchLabels, map[string]string{transformer.Selector: "app"}) {
+				t.Errorf("Expected the old selector of the Deployment, got %v", o.Spec.Selector.MatchLabels)
+			}
+			labels := o.Spec.Template.Labels
+			if labels[transformer.Selector] != "app" || labels[transformer.LabelName] != "app" {
+				t.Errorf("Expected the pods to have the old and new labels, got %v", labels)
+			}
+		case *api.Service:
+			if !reflect.DeepEqual(o.Spec.Selector, map[string]string{transformer.LabelName: "app"}) {
+				t.Errorf("Expected the Service selector to be kept, got %v", o.Spec.Selector)
+			}
+		}
+	}
+}
+
+func TestConfigEnvsEnvFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kompose-env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.env"), []byte("A=1\nB=1\nC=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "b.env"), []byte("B=2\nC=2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the environment holds the env_files merged with the inline environment, which sets C and D
+	service := kobject.ServiceConfig{
+		EnvFile: []string{"a.env", "b.env"},
+		Environment: []kobject.EnvVar{
+			{Name: "A", Value: "1"},
+			{Name: "B", Value: "2"},
+			{Name: "C", Value: "3"},
+			{Name: "D", Value: "4"},
+		},
+	}
+	opt := kobject.ConvertOptions{InputFiles: []string{filepath.Join(dir, "docker-compose.yml")}}
+	k := Kubernetes{Opt: opt}
+	envs, err := k.ConfigEnvs("web", service, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"A=a-env", "B=b-env", "C:3", "D:4"}
+	var got []string
+	for _, env := range envs {
+		if env.ValueFrom != nil {
+			got = append(got, env.Name+"="+env.ValueFrom.ConfigMapKeyRef.Name)
+		} else {
+			got = append(got, env.Name+":"+env.Value)
+		}
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected the environment %v, got %v", expected, got)
+	}
+}
+
+func TestEnvFilesOfPodsAndJobs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kompose-env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"migrate.env", "once.env"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("MODE=once\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the ConfigMaps of the env_files come with the Job and the Pod referencing them
+	maxAttempts := uint64(3)
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"migrate": {Image: "migrate", Restart: "on-failure", EnvFile: []string{"migrate.env"}, DeployRestartPolicy: types.RestartPolicy{MaxAttempts: &maxAttempts}},
+			"once":    {Image: "once", Restart: "no", EnvFile: []string{"once.env"}},
+		},
+	}
+	opt := kobject.ConvertOptions{Replicas: 1, InputFiles: []string{filepath.Join(dir, "docker-compose.yml")}}
+	k := Kubernetes{Opt: opt}
+	objects, err := k.Transform(komposeObject, opt)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	referenced, configMaps := map[string]bool{}, map[string]bool{}
+	for _, obj := range objects {
+		var env []api.EnvVar
+		switch o := obj.(type) {
+		case *api.ConfigMap:
+			configMaps[o.Name] = true
+		case *batchv1.Job:
+			env = o.Spec.Template.Spec.Containers[0].Env
+		case *api.Pod:
+			env = o.Spec.Containers[0].Env
+		}
+		for _, e := range env {
+			if e.ValueFrom != nil && e.ValueFrom.ConfigMapKeyRef != nil {
+				referenced[e.ValueFrom.ConfigMapKeyRef.Name] = true
+			}
+		}
+	}
+	expected := map[string]bool{"migrate-env": true, "once-env": true}
+	if !reflect.DeepEqual(referenced, expected) {
+		t.Errorf("Expected the Job and the Pod to reference the ConfigMaps %v, got %v", expected, referenced)
+	}
+	if !reflect.DeepEqual(configMaps, expected) {
+		t.Errorf("Expected the ConfigMaps %v, got %v", expected, configMaps)
+	}
+}
+
+func TestEncryptedEnvFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake sops is a shell script")
+	}
+	dir, err := ioutil.TempDir("", "kompose-env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"sops": "#!/bin/sh\nprintf 'PASSWORD=secret\\n'\n",
+		"web.env": `PASSWORD=ENC[AES256_GCM,data:c2VjcmV0,iv:aXY=,tag:dGFn,type:str]
+sops_mac=ENC[AES256_GCM,data:bWFj,iv:aXY=,tag:dGFn,type:str]
+sops_version=3.8.1
+`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	// the variables of an encrypted env_file go into a Secret rather than a ConfigMap
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"web": {Image: "nginx", EnvFile: []string{"web.env"}},
+		},
+	}
+	opt := kobject.ConvertOptions{CreateD: true, Replicas: 1, InputFiles: []string{filepath.Join(dir, "docker-compose.yml")}}
+	k := Kubernetes{Opt: opt}
+	objects, err := k.Transform(komposeObject, opt)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+	var secret *api.Secret
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *api.ConfigMap:
+			t.Errorf("Expected no ConfigMap, got %s", o.Name)
+		case *api.Secret:
+			secret = o
+		case *appsv1.Deployment:
+			env := o.Spec.Template.Spec.Containers[0].Env
+			if len(env) != 1 || env[0].ValueFrom == nil || env[0].ValueFrom.SecretKeyRef == nil || env[0].ValueFrom.SecretKeyRef.Name != "web-env" {
+				t.Errorf("Expected PASSWORD to refer to the Secret web-env, got %v", env)
+			}
+		}
+	}
+	if secret == nil || secret.Name != "web-env" || string(secret.Data["PASSWORD"]) != "secret" {
+		t.Errorf("Expected the Secret web-env holding PASSWORD, got %v", secret)
+	}
+
+	opt.DisableSOPS = true
+	k = Kubernetes{Opt: opt}
+	if _, err := k.Transform(komposeObject, opt); err == nil {
+		t.Errorf("Expected an error for an encrypted env_file with sops disabled")
+	}
+}
+
+func TestExtraResources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kompose-extra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	backup := `{{if .Labels.backup}}apiVersion: backup.example.com/v1
+kind: Schedule
+metadata:
+  name: {{.Name}}-backup
+spec:
+  image: {{.Image}}
+  cron: "{{.Labels.backup}}"
+{{end}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "backup.yaml"), []byte(backup), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dns := `{{range .Ports}}---
+apiVersion: dns.example.com/v1
+kind: Record
+metadata:
+  name: {{$.Name}}-{{.ContainerPort}}
+{{end}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "dns.yml"), []byte(dns), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	komposeObject := kobject.KomposeObject{ServiceConfigs: map[string]kobject.ServiceConfig{
+		"db": {Image: "postgres", Labels: map[string]string{"backup": "0 3 * * *"}},
+		"web": {Image: "nginx", Port: []kobject.Ports{
+			{HostPort: 80, ContainerPort: 80, Protocol: api.ProtocolTCP},
+			{HostPort: 443, ContainerPort: 443, Protocol: api.ProtocolTCP},
+		}},
+	}}
+	k := Kubernetes{}
+	objects, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1, ExtraResources: dir, LegacyLabels: true})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "k.Transform failed"))
+	}
+
+	found := map[string]string{}
+	for _, obj := range objects {
+		if us, ok := obj.(*unstructured.Unstructured); ok {
+			found[us.GetKind()+"/"+us.GetName()] = us.GetLabels()[transformer.Selector]
+		}
+	}
+	expected := map[string]string{"Schedule/db-backup": "db", "Record/web-80": "web", "Record/web-443": "web"}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Expected the extra resources %v, got %v", expected, found)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "invalid.yaml"), []byte("kind: Record\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.Transform(komposeObject, kobject.ConvertOptions{CreateD: true, Replicas: 1, ExtraResources: dir}); err == nil {
+		t.Errorf("Expected an error for an extra resource without apiVersion and name")
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/transformer"
+	"github.com/novln/docker-parser"
+	deployapi "github.com/openshift/api/apps/v1"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	api "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// SelectorLabels returns the recommended labels selecting the pods of a service: its name and the
+// project it is an instance of, so the pods of the same service of two projects in a namespace
+// aren't mixed up
+func SelectorLabels(name string, project string) map[string]string {
+	labels := map[string]string{transformer.LabelName: name}
+	if project != "" {
+		labels[transformer.LabelInstance] = project
+	}
+	return labels
+}
+
+// RecommendedLabels returns the recommended labels of the objects of a service: its selector
+// labels, and its name as the component of the project. The tag of the image is added as version
+// for the objects running the image, so the other objects don't change with the image.
+func RecommendedLabels(name string, service kobject.ServiceConfig, project string, withVersion bool) map[string]string {
+	labels := SelectorLabels(name, project)
+	labels[transformer.LabelComponent] = name
+	if ref, err := dockerparser.Parse(service.Image); err == nil {
+		if tag := ref.Tag(); withVersion && tag != "latest" && len(validation.IsValidLabelValue(tag)) == 0 {
+			labels[transformer.LabelVersion] = tag
+		}
+	}
+	return labels
+}
+
+// ConfigRecommendedLabels replaces the io.kompose.service labels and selectors of the objects with
+// the recommended app.kubernetes.io labels. The selectors only use app.kubernetes.io/name and
+// app.kubernetes.io/instance, the version changes with the image, while the selectors of
+// controllers are immutable.
+func (k *Kubernetes) ConfigRecommendedLabels(objects []runtime.Object, komposeObject kobject.KomposeObject, opt kobject.ConvertOptions) error {
+	project, err := transformer.ProjectName(opt)
+	if err != nil {
+		return errors.Wrap(err, "unable to get the project name")
+	}
+
+	relabel := func(labels map[string]string, withVersion bool) map[string]string {
+		name, ok := labels[transformer.Selector]
+		if !ok {
+			return labels
+		}
+		relabeled := SelectorLabels(name, project)
+		if service, ok := komposeObject.ServiceConfigs[name]; ok {
+			relabeled = RecommendedLabels(name, service, project, withVersion)
+		}
+		for key, value := range labels {
+			if key != transformer.Selector {
+				relabeled[key] = value
+			}
+		}
+		return relabeled
+	}
+	reselect := func(selector map[string]string) map[string]string {
+		name, ok := selector[transformer.Selector]
+		if !ok {
+			return selector
+		}
+		reselected := SelectorLabels(name, project)
+		for key, value := range selector {
+			if key != transformer.Selector {
+				reselected[key] = value
+			}
+		}
+		return reselected
+	}
+	updateTemplate := func(template *api.PodTemplateSpec) error {
+		template.Labels = relabel(template.Labels, true)
+		return nil
+	}
+
+	for _, obj := range objects {
+		if meta, ok := obj.(metav1.Object); ok {
+			meta.SetLabels(relabel(meta.GetLabels(), podSpec(obj) != nil))
+		}
+		if err := k.UpdateController(obj, updateTemplate, func(*metav1.ObjectMeta) {}); err != nil {
+			return errors.Wrap(err, "k.UpdateController failed")
+		}
+
+		switch t := obj.(type) {
+		case *api.Service:
+			t.Spec.Selector = reselect(t.Spec.Selector)
+		case *api.ReplicationController:
+			t.Spec.Selector = reselect(t.Spec.Selector)
+		case *deployapi.DeploymentConfig:
+			t.Spec.Selector = reselect(t.Spec.Selector)
+		case *appsv1.Deployment:
+			if t.Spec.Selector != nil {
+				t.Spec.Selector.MatchLabels = reselect(t.Spec.Selector.MatchLabels)
+			}
+		case *appsv1.DaemonSet:
+			if t.Spec.Selector != nil {
+				t.Spec.Selector.MatchLabels = reselect(t.Spec.Selector.MatchLabels)
+			}
+		case *appsv1.StatefulSet:
+			if t.Spec.Selector != nil {
+				t.Spec.Selector.MatchLabels = reselect(t.Spec.Selector.MatchLabels)
+			}
+		case *networkingv1.NetworkPolicy:
+			t.Spec.PodSelector.MatchLabels = reselect(t.Spec.PodSelector.MatchLabels)
+		case *unstructured.Unstructured:
+			// the objects without Go types, like the PodDisruptionBudgets, select in spec.selector
+			selector, found, err := unstructured.NestedStringMap(t.Object, "spec", "selector", "matchLabels")
+			if err != nil || !found {
+				continue
+			}
+			if err := unstructured.SetNestedStringMap(t.Object, reselect(selector), "spec", "selector", "matchLabels"); err != nil {
+				return errors.Wrap(err, "unable to set the selector")
+			}
+		}
+	}
+	return nil
+}
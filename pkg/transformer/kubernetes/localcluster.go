@@ -0,0 +1,118 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/loader/compose"
+	"github.com/kubernetes/kompose/pkg/transformer"
+	log "github.com/sirupsen/logrus"
+	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// The default node port range of the API server
+const (
+	minNodePort = 30000
+	maxNodePort = 32767
+)
+
+// LocalNodePort returns the node port a published port is given on a local cluster: the port
+// itself when it is in the node port range, else a port of the range derived from it, so the
+// port stays the same from one conversion to the next
+func LocalNodePort(port int32) int32 {
+	if port >= minNodePort && port <= maxNodePort {
+		return port
+	}
+	return minNodePort + port%(maxNodePort-minNodePort+1)
+}
+
+// ConfigLocalService adapts a service to a local cluster like kind or Docker Desktop, whose nodes
+// run on the machine of the user: the services publishing ports get a NodePort Service, unless
+// they set kompose.service.type, and the images built from the compose file are never pulled, as
+// they are only loaded into the nodes
+func ConfigLocalService(name string, service *kobject.ServiceConfig) {
+	if _, ok := service.Labels[compose.LabelServiceType]; !ok {
+		for _, port := range service.Port {
+			if port.HostPort != 0 {
+				service.ServiceType = string(api.ServiceTypeNodePort)
+				break
+			}
+		}
+	}
+	if service.Build != "" && service.ImagePullPolicy == "" {
+		log.WithField("service", name).Debugf("Never pulling the image %s built from the compose file", service.Image)
+		service.ImagePullPolicy = string(api.PullNever)
+	}
+}
+
+// ConfigLocalNodePorts gives the ports of the NodePort Services selecting the pods of a service
+// without a node port the port LocalNodePort derives from them, or the next free one when another
+// Service has it already. The Services created for network aliases are ClusterIP Services.
+func ConfigLocalNodePorts(objects []runtime.Object) {
+	var services []*api.Service
+	used := map[int32]bool{}
+	for _, obj := range objects {
+		svc, ok := obj.(*api.Service)
+		if !ok || svc.Spec.Type != api.ServiceTypeNodePort {
+			continue
+		}
+		for _, port := range svc.Spec.Ports {
+			if port.NodePort != 0 {
+				used[port.NodePort] = true
+			}
+		}
+		if transformer.ServiceLabel(svc.Spec.Selector) != "" {
+			services = append(services, svc)
+		}
+	}
+
+	for _, svc := range services {
+		for i, port := range svc.Spec.Ports {
+			if port.NodePort != 0 {
+				continue
+			}
+			nodePort := LocalNodePort(port.Port)
+			for used[nodePort] && len(used) <= maxNodePort-minNodePort {
+				if nodePort++; nodePort > maxNodePort {
+					nodePort = minNodePort
+				}
+			}
+			used[nodePort] = true
+			svc.Spec.Ports[i].NodePort = nodePort
+			log.Infof("Service %s port %d is published on node port %d", svc.Name, port.Port, nodePort)
+		}
+	}
+}
+
+// localHostPath returns the path of the nodes a host path under the directory of the compose file
+// is mounted at, under --local-mount-root, or the host path when it is outside of the directory
+func (k *Kubernetes) localHostPath(hostPath, dir string) string {
+	if k.Opt.LocalMountRoot == "" {
+		return hostPath
+	}
+	rel, err := filepath.Rel(dir, hostPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		log.WithField("category", "volumes").Warnf("Host path %q is outside of the directory of the compose file, it must be mounted into the nodes at the same path", hostPath)
+		return hostPath
+	}
+	return path.Join(k.Opt.LocalMountRoot, filepath.ToSlash(rel))
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/kubernetes/kompose/pkg/transformer"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Mapping maps the services of the compose file to the objects generated for them, and the
+// objects to their service, so tools find the objects of a service even when kompose named them
+// differently, e.g. web_app is converted to objects named web-app
+type Mapping struct {
+	// Services holds the objects of the services by their name in the compose file
+	Services map[string]*MappedService `json:"services"`
+	// Objects holds every generated object, including the objects of no service like NetworkPolicies
+	Objects []MappedObject `json:"objects"`
+}
+
+// MappedService holds the objects generated for a service of the compose file
+type MappedService struct {
+	// Name is the name the objects of the service are named and labeled after
+	Name    string         `json:"name"`
+	Objects []MappedObject `json:"objects"`
+}
+
+// MappedObject identifies a generated object and the file it was written to
+type MappedObject struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	// File is relative to the mapping file, empty when the objects are printed to stdout
+	File string `json:"file,omitempty"`
+	// Service is the name in the compose file of the service the object belongs to, if any
+	Service string `json:"service,omitempty"`
+}
+
+// NewMapping returns the mapping of the objects, written to files, to the services. serviceNames
+// maps the names the objects are labeled with to the names of the services in the compose file.
+func NewMapping(objects []runtime.Object, files []string, serviceNames map[string]string) *Mapping {
+	mapping := &Mapping{Services: map[string]*MappedService{}, Objects: []MappedObject{}}
+	for i, obj := range objects {
+		typeMeta, objectMeta := getObjectMeta(obj)
+		object := MappedObject{
+			Kind:      typeMeta.Kind,
+			Name:      objectMeta.Name,
+			Namespace: objectMeta.Namespace,
+			File:      files[i],
+		}
+
+		if name := transformer.ServiceLabel(objectMeta.Labels); name != "" {
+			object.Service = name
+			if composeName, ok := serviceNames[name]; ok {
+				object.Service = composeName
+			}
+			service, ok := mapping.Services[object.Service]
+			if !ok {
+				service = &MappedService{Name: name}
+				mapping.Services[object.Service] = service
+			}
+			service.Objects = append(service.Objects, object)
+		}
+		mapping.Objects = append(mapping.Objects, object)
+	}
+	return mapping
+}
+
+// writeMapping writes the mapping of the objects written to files to path, with the files
+// relative to the directory of path
+func writeMapping(path string, objects []runtime.Object, files []string, serviceNames map[string]string) error {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	relFiles := make([]string, len(files))
+	for i, file := range files {
+		if file == "" {
+			continue
+		}
+		relFiles[i] = file
+		if abs, err := filepath.Abs(file); err == nil {
+			if rel, err := filepath.Rel(dir, abs); err == nil {
+				relFiles[i] = filepath.ToSlash(rel)
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(NewMapping(objects, relFiles, serviceNames), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return errors.Wrap(err, "unable to write the mapping")
+	}
+	log.Infof("Mapping of the services to the objects written to %s", path)
+	return nil
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// ReplicationControllerToDeployment converts a ReplicationController to the Deployment kompose
+// generates for the same service. Pods using PersistentVolumeClaims or host paths are replaced
+// with the Recreate strategy, so the old and new pods don't use the volumes at the same time.
+func ReplicationControllerToDeployment(rc *api.ReplicationController) *appsv1.Deployment {
+	deployment := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Deployment",
+			APIVersion: "apps/v1",
+		},
+		ObjectMeta: rc.ObjectMeta,
+		Spec: appsv1.DeploymentSpec{
+			Replicas:        rc.Spec.Replicas,
+			MinReadySeconds: rc.Spec.MinReadySeconds,
+		},
+	}
+	if rc.Spec.Template != nil {
+		deployment.Spec.Template = *rc.Spec.Template
+	}
+
+	// a ReplicationController without selector selects the labels of its pods
+	selector := rc.Spec.Selector
+	if len(selector) == 0 {
+		selector = deployment.Spec.Template.Labels
+	}
+	deployment.Spec.Selector = &metav1.LabelSelector{MatchLabels: selector}
+
+	for _, volume := range deployment.Spec.Template.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil || volume.HostPath != nil {
+			deployment.Spec.Strategy.Type = appsv1.RecreateDeploymentStrategyType
+		}
+	}
+	return deployment
+}
+
+// MigrateControllers reads the YAML or JSON documents of generated manifests, which may hold
+// Lists, and converts their ReplicationControllers to Deployments. It returns the documents
+// marshalled like kompose does, separated by "---" for YAML, and the number of converted objects.
+func MigrateControllers(data []byte, jsonFormat bool, indent int) ([]byte, int, error) {
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	var documents [][]byte
+	migrated := 0
+	for {
+		var document map[string]interface{}
+		if err := decoder.Decode(&document); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, 0, errors.Wrap(err, "unable to parse the manifests")
+		}
+		if document == nil {
+			continue
+		}
+
+		document, count, err := migrateDocument(document)
+		if err != nil {
+			return nil, 0, err
+		}
+		migrated += count
+
+		var out []byte
+		if jsonFormat {
+			out, err = json.MarshalIndent(document, "", "  ")
+		} else {
+			out, err = marshalWithIndent(document, indent)
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		documents = append(documents, out)
+	}
+
+	separator := []byte("---\n")
+	if jsonFormat {
+		separator = []byte("\n")
+	}
+	return bytes.Join(documents, separator), migrated, nil
+}
+
+// migrateDocument converts a ReplicationController, or the ReplicationControllers of a List
+func migrateDocument(document map[string]interface{}) (map[string]interface{}, int, error) {
+	switch document["kind"] {
+	case "List":
+		items, _ := document["items"].([]interface{})
+		migrated := 0
+		for i, item := range items {
+			object, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			object, count, err := migrateDocument(object)
+			if err != nil {
+				return nil, 0, err
+			}
+			items[i] = object
+			migrated += count
+		}
+		return document, migrated, nil
+
+	case "ReplicationController":
+		data, err := json.Marshal(document)
+		if err != nil {
+			return nil, 0, err
+		}
+		rc := &api.ReplicationController{}
+		if err := json.Unmarshal(data, rc); err != nil {
+			return nil, 0, errors.Wrap(err, "invalid ReplicationController")
+		}
+		log.Infof("ReplicationController %s is converted to a Deployment", rc.Name)
+
+		data, err = json.Marshal(ReplicationControllerToDeployment(rc))
+		if err != nil {
+			return nil, 0, err
+		}
+		var deployment map[string]interface{}
+		if err := json.Unmarshal(data, &deployment); err != nil {
+			return nil, 0, err
+		}
+		return deployment, 1, nil
+	}
+	return document, 0, nil
+}
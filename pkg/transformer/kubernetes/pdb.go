@@ -0,0 +1,148 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/transformer"
+	deployapi "github.com/openshift/api/apps/v1"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// AnnotationRecreateReason is the annotation of the controllers explaining why they use the
+// Recreate strategy
+const AnnotationRecreateReason = "kompose.strategy.reason"
+
+// persistentVolumeClaims returns the claims of the PersistentVolumeClaims a pod mounts
+func persistentVolumeClaims(spec api.PodSpec) []string {
+	var claims []string
+	for _, volume := range spec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			claims = append(claims, volume.PersistentVolumeClaim.ClaimName)
+		}
+	}
+	return claims
+}
+
+// annotateRecreate explains with AnnotationRecreateReason why a controller whose pods mount
+// PersistentVolumeClaims uses the Recreate strategy. The annotations are copied, since the objects
+// of a service share them.
+func annotateRecreate(meta *metav1.ObjectMeta, spec api.PodSpec) {
+	claims := persistentVolumeClaims(spec)
+	if len(claims) == 0 {
+		return
+	}
+	annotations := map[string]string{}
+	for key, value := range meta.Annotations {
+		annotations[key] = value
+	}
+	annotations[AnnotationRecreateReason] = fmt.Sprintf("The pods mount the PersistentVolumeClaims %s, which a rolling update would mount in the old and the new pod at once, corrupting the data or blocking the new pod on ReadWriteOnce volumes", strings.Join(claims, ", "))
+	meta.Annotations = annotations
+}
+
+// CreateSingletonPDB creates a PodDisruptionBudget with maxUnavailable 0 for a service running a
+// single pod that mounts PersistentVolumeClaims, so voluntary disruptions like node drains don't
+// evict the pod of a stateful service without an operator stepping in. It returns nil for the
+// other services, including the services scaled by an HPA. The vendored k8s.io/api has no
+// policy/v1 types, so the PodDisruptionBudget is an unstructured object.
+func (k *Kubernetes) CreateSingletonPDB(name string, service kobject.ServiceConfig, objects []runtime.Object) (*unstructured.Unstructured, error) {
+	if service.HPA != (kobject.HPA{}) {
+		return nil, nil
+	}
+	for _, obj := range objects {
+		var replicas int32
+		var selector *metav1.LabelSelector
+		var claims []string
+		switch o := obj.(type) {
+		case *appsv1.Deployment:
+			replicas, selector, claims = replicasOrOne(o.Spec.Replicas), o.Spec.Selector.DeepCopy(), persistentVolumeClaims(o.Spec.Template.Spec)
+		case *appsv1.StatefulSet:
+			replicas, selector, claims = replicasOrOne(o.Spec.Replicas), o.Spec.Selector.DeepCopy(), persistentVolumeClaims(o.Spec.Template.Spec)
+			for _, template := range o.Spec.VolumeClaimTemplates {
+				claims = append(claims, template.Name)
+			}
+		case *api.ReplicationController:
+			if o.Spec.Template == nil {
+				continue
+			}
+			replicas, selector, claims = replicasOrOne(o.Spec.Replicas), podSelector(o.Spec.Selector, o.Spec.Template.Labels), persistentVolumeClaims(o.Spec.Template.Spec)
+		case *deployapi.DeploymentConfig:
+			if o.Spec.Template == nil {
+				continue
+			}
+			replicas, selector, claims = o.Spec.Replicas, podSelector(o.Spec.Selector, o.Spec.Template.Labels), persistentVolumeClaims(o.Spec.Template.Spec)
+		default:
+			continue
+		}
+		if replicas != 1 || len(claims) == 0 {
+			return nil, nil
+		}
+		if selector == nil || len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0 {
+			// an empty selector of policy/v1 selects every pod of the namespace
+			return nil, errors.Errorf("the %s of service %s selects no pods", obj.GetObjectKind().GroupVersionKind().Kind, name)
+		}
+		selectorData, err := runtime.DefaultUnstructuredConverter.ToUnstructured(selector)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to convert the selector of the PodDisruptionBudget")
+		}
+
+		log.Infof("The PodDisruptionBudget of the single pod of service %s blocks the node drains until the pod is deleted by hand", name)
+		pdb := &unstructured.Unstructured{}
+		pdb.SetAPIVersion("policy/v1")
+		pdb.SetKind("PodDisruptionBudget")
+		pdb.SetName(name)
+		pdb.SetLabels(transformer.ConfigLabels(name))
+		if err := unstructured.SetNestedField(pdb.Object, int64(0), "spec", "maxUnavailable"); err != nil {
+			return nil, err
+		}
+		if err := unstructured.SetNestedMap(pdb.Object, selectorData, "spec", "selector"); err != nil {
+			return nil, err
+		}
+		return pdb, nil
+	}
+	return nil, nil
+}
+
+// podSelector returns a copy of the selector of a ReplicationController or DeploymentConfig as a
+// label selector. Like the controllers, it defaults to the labels of their pod template.
+func podSelector(selector, templateLabels map[string]string) *metav1.LabelSelector {
+	if len(selector) == 0 {
+		selector = templateLabels
+	}
+	matchLabels := make(map[string]string, len(selector))
+	for key, value := range selector {
+		matchLabels[key] = value
+	}
+	return &metav1.LabelSelector{MatchLabels: matchLabels}
+}
+
+// replicasOrOne returns the replicas of a controller, which default to 1
+func replicasOrOne(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// OPABinary is the Open Policy Agent binary the Rego policies are evaluated with, looked up in the PATH
+var OPABinary = "opa"
+
+// PolicyPackage is the Rego package of the policies, whose deny rules list the violations of the
+// object given as input and whose warn rules list the warnings
+const PolicyPackage = "kompose"
+
+// policyQuery evaluates the deny and warn rules of PolicyPackage once for every object of the
+// input, an array of objects, with the object as input. The rules of an object are undefined,
+// and left out, if the package or both rules are.
+var policyQuery = fmt.Sprintf(`[rules | obj := input[_]; rules := {name: value | name := ["deny", "warn"][_]; value := data.%s[name]} with input as obj; count(rules) > 0]`, PolicyPackage)
+
+// policyRules are the messages of the deny and warn rules for an object
+type policyRules struct {
+	Deny []interface{} `json:"deny"`
+	Warn []interface{} `json:"warn"`
+}
+
+// policyResult is the output of opa eval for policyQuery
+type policyResult struct {
+	Result []struct {
+		Expressions []struct {
+			Value []policyRules `json:"value"`
+		} `json:"expressions"`
+	} `json:"result"`
+}
+
+// ToUnstructured converts the objects of a conversion to unstructured objects, the way they are
+// written
+func ToUnstructured(objects []runtime.Object) ([]*unstructured.Unstructured, error) {
+	var result []*unstructured.Unstructured
+	for _, obj := range objects {
+		data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		u := &unstructured.Unstructured{Object: data}
+		// the status belongs to the cluster
+		delete(u.Object, "status")
+		result = append(result, u)
+	}
+	return result, nil
+}
+
+// EvaluatePolicies evaluates the Rego policies of dir against every object with opa, in a single
+// evaluation of all the objects, and returns
+// the violations the deny rules of the kompose package report, prefixed by the kind and name of
+// the object. The warnings of the warn rules are logged. The other files of dir, like JSON or YAML
+// files, are the data of the policies. Like conftest, the rules get the object as input, and
+// report strings or objects with a msg. Policies defining no deny or warn rule of the kompose
+// package, like ones of a misspelled package, are an error rather than silently passing.
+func EvaluatePolicies(objects []*unstructured.Unstructured, dir string) ([]string, error) {
+	var policies []string
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch filepath.Ext(file) {
+		case ".rego":
+			policies = append(policies, file)
+		case ".cel":
+			return errors.Errorf("%s: CEL policies aren't supported, only Rego policies", file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(policies) == 0 {
+		return nil, errors.Errorf("no Rego policies (.rego files) found in %s", dir)
+	}
+	log.Debugf("Evaluating the policies %s", strings.Join(policies, ", "))
+
+	rules, err := evaluatePolicies(objects, dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to evaluate the policies")
+	}
+	var violations []string
+	for i, obj := range objects {
+		for _, message := range policyMessages(rules[i].Warn) {
+			log.WithField("category", "policy").Warnf("%s %s: %s", obj.GetKind(), obj.GetName(), message)
+		}
+		for _, message := range policyMessages(rules[i].Deny) {
+			violations = append(violations, fmt.Sprintf("%s %s: %s", obj.GetKind(), obj.GetName(), message))
+		}
+	}
+	return violations, nil
+}
+
+// evaluatePolicies evaluates the policies of dir with opa for objects, and returns their rules in
+// the order of objects
+func evaluatePolicies(objects []*unstructured.Unstructured, dir string) ([]policyRules, error) {
+	// the input array holds an object per line, which keeps the input of opa readable
+	var input bytes.Buffer
+	input.WriteString("[\n")
+	for i, obj := range objects {
+		data, err := json.Marshal(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			input.WriteString(",\n")
+		}
+		input.Write(data)
+	}
+	input.WriteString("\n]\n")
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(OPABinary, "eval", "--format", "json", "--data", dir, "--stdin-input", policyQuery)
+	cmd.Stdin = &input
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if _, ok := err.(*exec.Error); ok {
+			return nil, errors.Wrap(err, "the policies are evaluated with opa, which must be installed")
+		}
+		// opa prints the errors of the policies on stdout
+		if message := strings.TrimSpace(stderr.String() + string(out)); message != "" {
+			return nil, errors.Wrapf(err, "opa failed: %s", message)
+		}
+		return nil, errors.Wrap(err, "opa failed")
+	}
+
+	var result policyResult
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, errors.Wrap(err, "invalid output of opa")
+	}
+	var rules []policyRules
+	if len(result.Result) > 0 && len(result.Result[0].Expressions) > 0 {
+		rules = result.Result[0].Expressions[0].Value
+	}
+	// the objects without rules are left out of the result
+	if len(rules) != len(objects) {
+		return nil, errors.Errorf("the policies define no deny or warn rule of the %s package", PolicyPackage)
+	}
+	return rules, nil
+}
+
+// policyMessages returns the messages reported by a rule, strings or objects with a msg
+func policyMessages(values []interface{}) []string {
+	var messages []string
+	for _, value := range values {
+		switch v := value.(type) {
+		case string:
+			messages = append(messages, v)
+		case map[string]interface{}:
+			if msg, ok := v["msg"].(string); ok {
+				messages = append(messages, msg)
+				continue
+			}
+			data, _ := json.Marshal(v)
+			messages = append(messages, string(data))
+		default:
+			messages = append(messages, fmt.Sprint(v))
+		}
+	}
+	return messages
+}
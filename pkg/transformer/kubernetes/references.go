@@ -0,0 +1,107 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/kubernetes/kompose/pkg/kobject"
+	api "k8s.io/api/core/v1"
+)
+
+// BrokenReference is an environment variable of a service referring to another service by a name
+// that doesn't resolve to its Service
+type BrokenReference struct {
+	// Service is the name of the service of the environment variable
+	Service string
+	// Message tells the variable, the service it refers to and why its Service can't be reached
+	Message string
+}
+
+// BrokenServiceReferences returns a reference for every environment variable referring to another
+// compose service by its name, e.g. DB_HOST=postgres or DATABASE_URL=postgres://postgres:5432/app,
+// that doesn't resolve to a Service of that name after the conversion: because the service was
+// renamed by the normalization of its name or by --name-strategy, because it publishes no ports
+// and gets no Service, or because its LoadBalancer Services are split by protocol. References of a
+// service to its own name are ignored. The services of komposeObject are expected to be renamed
+// already.
+func BrokenServiceReferences(komposeObject kobject.KomposeObject, opt kobject.ConvertOptions) []BrokenReference {
+	// the services by the name they have in the compose file
+	names := map[string]string{}
+	for name, service := range komposeObject.ServiceConfigs {
+		composeName := service.ComposeName
+		if composeName == "" {
+			composeName = name
+		}
+		names[composeName] = name
+	}
+
+	var broken []BrokenReference
+	for _, name := range SortedKeys(komposeObject) {
+		service := komposeObject.ServiceConfigs[name]
+		for _, env := range service.Environment {
+			seen := map[string]bool{}
+			for _, host := range envHosts(env.Value) {
+				target, ok := names[host]
+				if !ok || target == name || seen[host] {
+					continue
+				}
+				seen[host] = true
+				if reason := serviceNameMismatch(host, target, komposeObject.ServiceConfigs[target], opt); reason != "" {
+					broken = append(broken, BrokenReference{
+						Service: name,
+						Message: fmt.Sprintf("%s refers to service %s, but %s", env.Name, host, reason),
+					})
+				}
+			}
+		}
+	}
+	return broken
+}
+
+// envHosts returns the words of an environment variable value that may be host names, i.e. the
+// parts of the value between the separators of URLs, host:port pairs and lists
+func envHosts(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' && r != '.'
+	})
+}
+
+// serviceNameMismatch returns why the Service of the service named host in the compose file,
+// converted to objects named name, can't be reached by host, or "" if it can
+func serviceNameMismatch(host, name string, service kobject.ServiceConfig, opt kobject.ConvertOptions) string {
+	if len(service.Port) == 0 {
+		if service.ServiceType == "Headless" {
+			return nameMismatch(host, name)
+		}
+		return "it publishes no ports, so no Service is created for it"
+	}
+	if service.ServiceType == string(api.ServiceTypeLoadBalancer) && !service.SplitPorts && !opt.SplitServicePorts {
+		return fmt.Sprintf("its LoadBalancer Services are named %s-tcp and %s-udp", name, name)
+	}
+	return nameMismatch(host, name)
+}
+
+// nameMismatch returns why a Service named name can't be reached by host, or "" if it can
+func nameMismatch(host, name string) string {
+	if host == name {
+		return ""
+	}
+	return fmt.Sprintf("its Service is named %s", name)
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	deployapi "github.com/openshift/api/apps/v1"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// selectorKey identifies a controller by kind, namespace and name
+func selectorKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// LoadSelectors reads the manifests of the YAML and JSON files under path, like the files of an
+// earlier conversion, and returns the selectors of their controllers by kind, namespace and name
+func LoadSelectors(path string) (map[string]map[string]string, error) {
+	selectors := map[string]map[string]string{}
+	err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch filepath.Ext(file) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := loadSelectors(data, selectors); err != nil {
+			log.WithField("category", "cluster").Warnf("Ignoring %s: %s", file, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read the manifests of %s", path)
+	}
+	log.Debugf("Found the selectors of %d controllers in %s", len(selectors), path)
+	return selectors, nil
+}
+
+// loadSelectors adds the selectors of the controllers of the documents of data, which may hold Lists
+func loadSelectors(data []byte, selectors map[string]map[string]string) error {
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		var document struct {
+			metav1.TypeMeta `json:",inline"`
+			Metadata        metav1.ObjectMeta `json:"metadata"`
+			Spec            struct {
+				Selector runtime.RawExtension `json:"selector"`
+			} `json:"spec"`
+			Items []runtime.RawExtension `json:"items"`
+		}
+		if err := decoder.Decode(&document); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		for _, item := range document.Items {
+			if err := loadSelectors(item.Raw, selectors); err != nil {
+				return err
+			}
+		}
+		if len(document.Spec.Selector.Raw) == 0 {
+			continue
+		}
+		var selector map[string]string
+		switch document.Kind {
+		case "Deployment", "DaemonSet", "StatefulSet":
+			var labelSelector metav1.LabelSelector
+			if err := json.Unmarshal(document.Spec.Selector.Raw, &labelSelector); err != nil {
+				return err
+			}
+			selector = labelSelector.MatchLabels
+		case "ReplicationController", "DeploymentConfig":
+			if err := json.Unmarshal(document.Spec.Selector.Raw, &selector); err != nil {
+				return err
+			}
+		}
+		if len(selector) > 0 {
+			selectors[selectorKey(document.Kind, document.Metadata.Namespace, document.Metadata.Name)] = selector
+		}
+	}
+}
+
+// PreserveSelectors replaces the selectors of the controllers with the selectors of the same
+// controllers in selectors, and adds their labels to the pod templates, so applying the objects
+// doesn't try to change the immutable selectors of the controllers running in a cluster. It
+// returns the number of controllers whose selector was preserved.
+func (k *Kubernetes) PreserveSelectors(objects []runtime.Object, selectors map[string]map[string]string) (int, error) {
+	preserved := 0
+	for _, obj := range objects {
+		typeMeta, objectMeta := getObjectMeta(obj)
+		selector, ok := selectors[selectorKey(typeMeta.Kind, objectMeta.Namespace, objectMeta.Name)]
+		if !ok {
+			continue
+		}
+
+		switch t := obj.(type) {
+		case *appsv1.Deployment:
+			t.Spec.Selector = &metav1.LabelSelector{MatchLabels: selector}
+		case *appsv1.DaemonSet:
+			t.Spec.Selector = &metav1.LabelSelector{MatchLabels: selector}
+		case *appsv1.StatefulSet:
+			t.Spec.Selector = &metav1.LabelSelector{MatchLabels: selector}
+		case *api.ReplicationController:
+			t.Spec.Selector = selector
+		case *deployapi.DeploymentConfig:
+			t.Spec.Selector = selector
+		default:
+			continue
+		}
+
+		updateTemplate := func(template *api.PodTemplateSpec) error {
+			if template.Labels == nil {
+				template.Labels = map[string]string{}
+			}
+			for key, value := range selector {
+				template.Labels[key] = value
+			}
+			return nil
+		}
+		if err := k.UpdateController(obj, updateTemplate, func(*metav1.ObjectMeta) {}); err != nil {
+			return 0, errors.Wrap(err, "k.UpdateController failed")
+		}
+		log.Debugf("Preserved the selector %v of %s %s", selector, typeMeta.Kind, objectMeta.Name)
+		preserved++
+	}
+	return preserved, nil
+}
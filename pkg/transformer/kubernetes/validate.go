@@ -0,0 +1,108 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/loader/compose"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// serviceControllers are the valid values of the kompose.controller.type label
+var serviceControllers = []string{DeploymentController, DaemonSetController, ReplicationController, StatefulSetController}
+
+// ServiceController returns the controller a service is converted to, the way
+// CreateKubernetesObjects chooses it: the kompose.controller.type label, else the controller of
+// the options, else a DaemonSet for the global deploy mode, else a Deployment
+func ServiceController(service kobject.ServiceConfig, opt kobject.ConvertOptions) string {
+	if controller, ok := service.Labels[compose.LabelControllerType]; ok {
+		return controller
+	}
+	switch {
+	case opt.Controller != "":
+		return opt.Controller
+	case opt.CreateDS:
+		return DaemonSetController
+	case opt.CreateRC:
+		return ReplicationController
+	case service.DeployMode == "global":
+		return DaemonSetController
+	}
+	return DeploymentController
+}
+
+// ValidateServices checks the settings of every service against each other and against the
+// options, and returns an error listing all the violations found, or nil
+func ValidateServices(komposeObject kobject.KomposeObject, opt kobject.ConvertOptions) error {
+	var violations []string
+	for _, name := range SortedKeys(komposeObject) {
+		service := komposeObject.ServiceConfigs[name]
+		controller := ServiceController(service, opt)
+
+		if !isServiceController(controller) {
+			violations = append(violations, fmt.Sprintf("service %s: unknown %s %q, possible values are %s",
+				name, compose.LabelControllerType, controller, strings.Join(serviceControllers, ", ")))
+			continue
+		}
+
+		if controller == DaemonSetController && service.Replicas > 1 {
+			violations = append(violations, fmt.Sprintf("service %s: deploy.replicas %d can't be used with a DaemonSet, which runs one pod per node, "+
+				"remove deploy.replicas or set the %s label to %s", name, service.Replicas, compose.LabelControllerType, DeploymentController))
+		}
+
+		if controller == DaemonSetController && service.HPA != (kobject.HPA{}) {
+			violations = append(violations, fmt.Sprintf("service %s: the kompose.hpa labels can't be used with a DaemonSet, which runs one pod per node, "+
+				"set the %s label to %s", name, compose.LabelControllerType, DeploymentController))
+		}
+
+		if opt.PinCPUs && service.CPUSet != "" {
+			if _, err := CPUSetSize(service.CPUSet); err != nil {
+				violations = append(violations, fmt.Sprintf("service %s: %s", name, err))
+			}
+		}
+
+		for _, link := range service.ExternalLinks {
+			_, linkName := ExternalLink(link)
+			host, ok := service.Labels[compose.LabelExternalLinkPrefix+linkName]
+			if !ok {
+				continue
+			}
+			if errs := validation.IsDNS1123Subdomain(host); len(errs) > 0 {
+				violations = append(violations, fmt.Sprintf("service %s: %s%s %q is not the DNS name of a host: %s",
+					name, compose.LabelExternalLinkPrefix, linkName, host, strings.Join(errs, ", ")))
+			}
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	return fmt.Errorf("found %d invalid service settings:\n  - %s", len(violations), strings.Join(violations, "\n  - "))
+}
+
+// isServiceController returns true if controller is a valid value of the kompose.controller.type label
+func isServiceController(controller string) bool {
+	for _, c := range serviceControllers {
+		if c == controller {
+			return true
+		}
+	}
+	return false
+}
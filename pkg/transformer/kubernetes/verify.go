@@ -0,0 +1,436 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// podTemplatePaths are the fields of the pod templates of the kinds running pods
+var podTemplatePaths = map[string][]string{
+	"Deployment":            {"spec", "template"},
+	"DaemonSet":             {"spec", "template"},
+	"StatefulSet":           {"spec", "template"},
+	"ReplicaSet":            {"spec", "template"},
+	"ReplicationController": {"spec", "template"},
+	"DeploymentConfig":      {"spec", "template"},
+	"Job":                   {"spec", "template"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template"},
+}
+
+// ParseManifests returns the objects of the YAML or JSON documents of manifests, with the items of
+// Lists as objects of their own. Documents that are not objects, like the Chart.yaml of a Helm
+// chart, are left out.
+func ParseManifests(data []byte) ([]*unstructured.Unstructured, error) {
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	var objects []*unstructured.Unstructured
+	for {
+		var document map[string]interface{}
+		if err := decoder.Decode(&document); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, errors.Wrap(err, "unable to parse the manifests")
+		}
+		obj := &unstructured.Unstructured{Object: document}
+		if obj.GetKind() == "" || obj.GetAPIVersion() == "" {
+			continue
+		}
+		if obj.IsList() {
+			err := obj.EachListItem(func(item runtime.Object) error {
+				objects = append(objects, item.(*unstructured.Unstructured))
+				return nil
+			})
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid List")
+			}
+			continue
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// manifestSet indexes a set of objects by kind, namespace and name
+type manifestSet map[string]*unstructured.Unstructured
+
+func (s manifestSet) key(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+func (s manifestSet) get(kind, namespace, name string) *unstructured.Unstructured {
+	return s[s.key(kind, namespace, name)]
+}
+
+// VerifyManifests checks that a set of objects, like the output of an earlier conversion that may
+// have been edited by hand, is consistent: the ConfigMaps, Secrets and PersistentVolumeClaims the
+// pods use exist, the controllers select the pods of their template, the Services,
+// PodDisruptionBudgets and NetworkPolicies select pods and the Ingresses, Routes and
+// HorizontalPodAutoscalers refer to existing objects and ports, as do the probes of the
+// containers. It returns the problems found, sorted by object. References to optional ConfigMaps
+// and Secrets are ignored, as are the empty selectors, which select every pod.
+func VerifyManifests(objects []*unstructured.Unstructured) ([]string, error) {
+	set := manifestSet{}
+	for _, obj := range objects {
+		set[set.key(obj.GetKind(), obj.GetNamespace(), obj.GetName())] = obj
+		// cert-manager writes the Secrets of the Certificates, like the ones of --zero-trust
+		if obj.GetKind() == "Certificate" {
+			if secretName, ok, _ := unstructured.NestedString(obj.Object, "spec", "secretName"); ok {
+				set[set.key("Secret", obj.GetNamespace(), secretName)] = obj
+			}
+		}
+	}
+
+	// a missing ConfigMap is reported once for all the variables of a container taken from it
+	found := map[string]bool{}
+	var problems []string
+	report := func(obj *unstructured.Unstructured, format string, args ...interface{}) {
+		problem := fmt.Sprintf("%s %s: %s", obj.GetKind(), obj.GetName(), fmt.Sprintf(format, args...))
+		if !found[problem] {
+			found[problem] = true
+			problems = append(problems, problem)
+		}
+	}
+
+	// selects reports the selector of obj at path if it matches none of the sets of labels
+	selects := func(obj *unstructured.Unstructured, sets []labels.Set, path ...string) {
+		selector, err := labelSelectorAt(obj.Object, path...)
+		if err != nil {
+			report(obj, "invalid %s: %v", path[len(path)-1], err)
+		} else if !selector.Empty() && !selectsAny(selector, sets) {
+			report(obj, "the %s %s matches no pods", path[len(path)-1], selector)
+		}
+	}
+
+	// the labels of the pods of every namespace, for the selectors of the Services
+	podLabels := map[string][]labels.Set{}
+	for _, obj := range objects {
+		template, err := PodTemplateOf(obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pod template of %s %s", obj.GetKind(), obj.GetName())
+		}
+		if template == nil {
+			continue
+		}
+		podLabels[obj.GetNamespace()] = append(podLabels[obj.GetNamespace()], template.Labels)
+		verifyPodSpec(obj, template.Spec, set, report)
+
+		// the pods of a controller are the ones of its template
+		switch obj.GetKind() {
+		case "ReplicationController", "DeploymentConfig":
+			matchLabels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "selector")
+			if selector := labels.SelectorFromSet(matchLabels); !selector.Matches(labels.Set(template.Labels)) {
+				report(obj, "the selector %s doesn't match the labels of its pod template", selector)
+			}
+		case "Deployment", "DaemonSet", "StatefulSet", "ReplicaSet", "Job":
+			selector, err := labelSelectorAt(obj.Object, "spec", "selector")
+			if err != nil {
+				report(obj, "invalid selector: %v", err)
+			} else if !selector.Empty() && !selector.Matches(labels.Set(template.Labels)) {
+				report(obj, "the selector %s doesn't match the labels of its pod template", selector)
+			}
+		}
+	}
+
+	for _, obj := range objects {
+		namespace := obj.GetNamespace()
+		switch obj.GetKind() {
+		case "Service":
+			matchLabels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "selector")
+			if selector := labels.SelectorFromSet(matchLabels); len(matchLabels) > 0 && !selectsAny(selector, podLabels[namespace]) {
+				report(obj, "the selector %s matches no pods", selector)
+			}
+
+		case "PodDisruptionBudget":
+			selects(obj, podLabels[namespace], "spec", "selector")
+
+		case "NetworkPolicy":
+			selects(obj, podLabels[namespace], "spec", "podSelector")
+			// the peers without a namespaceSelector are pods of the namespace of the NetworkPolicy
+			for _, rules := range [][]string{{"ingress", "from"}, {"egress", "to"}} {
+				ruleList, _, _ := unstructured.NestedSlice(obj.Object, "spec", rules[0])
+				for _, rule := range ruleList {
+					r, _ := rule.(map[string]interface{})
+					peers, _, _ := unstructured.NestedSlice(r, rules[1])
+					for _, peer := range peers {
+						p, _ := peer.(map[string]interface{})
+						if _, ok := p["namespaceSelector"]; ok || p == nil {
+							continue
+						}
+						selector, err := labelSelectorAt(p, "podSelector")
+						if err != nil {
+							report(obj, "invalid podSelector of %s: %v", rules[1], err)
+						} else if !selector.Empty() && !selectsAny(selector, podLabels[namespace]) {
+							report(obj, "the podSelector %s of %s matches no pods", selector, rules[1])
+						}
+					}
+				}
+			}
+
+		case "Ingress":
+			for _, backend := range ingressBackends(obj) {
+				verifyServicePort(obj, set, namespace, backend.name, backend.port, report)
+			}
+
+		case "Route":
+			name, _, _ := unstructured.NestedString(obj.Object, "spec", "to", "name")
+			port, _, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", "port", "targetPort")
+			verifyServicePort(obj, set, namespace, name, port, report)
+
+		case "HorizontalPodAutoscaler":
+			kind, _, _ := unstructured.NestedString(obj.Object, "spec", "scaleTargetRef", "kind")
+			name, _, _ := unstructured.NestedString(obj.Object, "spec", "scaleTargetRef", "name")
+			if set.get(kind, namespace, name) == nil {
+				report(obj, "the %s %s it scales doesn't exist", kind, name)
+			}
+		}
+	}
+
+	sort.Strings(problems)
+	return problems, nil
+}
+
+// PodTemplateOf returns the pod template of an object running pods, the spec of a Pod, or nil for
+// other objects
+func PodTemplateOf(obj *unstructured.Unstructured) (*api.PodTemplateSpec, error) {
+	var fields map[string]interface{}
+	var found bool
+	var err error
+	if obj.GetKind() == "Pod" {
+		fields = map[string]interface{}{"metadata": obj.Object["metadata"], "spec": obj.Object["spec"]}
+		found = true
+	} else if path, ok := podTemplatePaths[obj.GetKind()]; ok {
+		fields, found, err = unstructured.NestedMap(obj.Object, path...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+	template := &api.PodTemplateSpec{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(fields, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// verifyPodSpec checks the references of the pods of obj to ConfigMaps, Secrets and
+// PersistentVolumeClaims, and the ports of the probes of their containers
+func verifyPodSpec(obj *unstructured.Unstructured, spec api.PodSpec, set manifestSet, report func(*unstructured.Unstructured, string, ...interface{})) {
+	namespace := obj.GetNamespace()
+	exists := func(kind, name string, optional *bool) {
+		if (optional == nil || !*optional) && set.get(kind, namespace, name) == nil {
+			report(obj, "the %s %s doesn't exist", kind, name)
+		}
+	}
+
+	// the claims of the volumeClaimTemplates of a StatefulSet are created with its pods
+	claimTemplates := map[string]bool{}
+	if templates, ok, _ := unstructured.NestedSlice(obj.Object, "spec", "volumeClaimTemplates"); ok {
+		for _, t := range templates {
+			if m, ok := t.(map[string]interface{}); ok {
+				name, _, _ := unstructured.NestedString(m, "metadata", "name")
+				claimTemplates[name] = true
+			}
+		}
+	}
+
+	for _, volume := range spec.Volumes {
+		switch {
+		case volume.ConfigMap != nil:
+			exists("ConfigMap", volume.ConfigMap.Name, volume.ConfigMap.Optional)
+		case volume.Secret != nil:
+			exists("Secret", volume.Secret.SecretName, volume.Secret.Optional)
+		case volume.PersistentVolumeClaim != nil:
+			if !claimTemplates[volume.PersistentVolumeClaim.ClaimName] {
+				exists("PersistentVolumeClaim", volume.PersistentVolumeClaim.ClaimName, nil)
+			}
+		case volume.Projected != nil:
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					exists("ConfigMap", source.ConfigMap.Name, source.ConfigMap.Optional)
+				}
+				if source.Secret != nil {
+					exists("Secret", source.Secret.Name, source.Secret.Optional)
+				}
+			}
+		}
+	}
+
+	containers := append(append([]api.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, container := range containers {
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+				exists("ConfigMap", ref.Name, ref.Optional)
+			}
+			if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+				exists("Secret", ref.Name, ref.Optional)
+			}
+		}
+		for _, envFrom := range container.EnvFrom {
+			if ref := envFrom.ConfigMapRef; ref != nil {
+				exists("ConfigMap", ref.Name, ref.Optional)
+			}
+			if ref := envFrom.SecretRef; ref != nil {
+				exists("Secret", ref.Name, ref.Optional)
+			}
+		}
+
+		for probeName, probe := range map[string]*api.Probe{"liveness": container.LivenessProbe, "readiness": container.ReadinessProbe, "startup": container.StartupProbe} {
+			if probe == nil {
+				continue
+			}
+			var port *intstr.IntOrString
+			if probe.HTTPGet != nil {
+				port = &probe.HTTPGet.Port
+			} else if probe.TCPSocket != nil {
+				port = &probe.TCPSocket.Port
+			}
+			if port != nil && !containerHasPort(container, *port) {
+				report(obj, "the %s probe of container %s uses port %s, which the container doesn't declare", probeName, container.Name, port.String())
+			}
+		}
+	}
+}
+
+// containerHasPort returns true if port is the number or name of a port of container
+func containerHasPort(container api.Container, port intstr.IntOrString) bool {
+	for _, p := range container.Ports {
+		if (port.Type == intstr.Int && p.ContainerPort == port.IntVal) || (port.Type == intstr.String && p.Name == port.StrVal) {
+			return true
+		}
+	}
+	return false
+}
+
+// labelSelectorAt returns the metav1.LabelSelector at path of fields as a labels.Selector, which is
+// empty if there is none
+func labelSelectorAt(fields map[string]interface{}, path ...string) (labels.Selector, error) {
+	m, found, err := unstructured.NestedMap(fields, path...)
+	if err != nil || !found {
+		return labels.Everything(), err
+	}
+	labelSelector := &metav1.LabelSelector{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, labelSelector); err != nil {
+		return nil, err
+	}
+	return metav1.LabelSelectorAsSelector(labelSelector)
+}
+
+// selectsAny returns true if selector matches any of the sets of labels
+func selectsAny(selector labels.Selector, sets []labels.Set) bool {
+	for _, set := range sets {
+		if selector.Matches(set) {
+			return true
+		}
+	}
+	return false
+}
+
+// ingressBackend is a Service and port an Ingress routes to
+type ingressBackend struct {
+	name string
+	port interface{}
+}
+
+// ingressBackends returns the backends of the default backend and the rules of an Ingress, of
+// extensions/v1beta1 and networking.k8s.io/v1beta1 as well as networking.k8s.io/v1
+func ingressBackends(obj *unstructured.Unstructured) []ingressBackend {
+	var backends []ingressBackend
+	add := func(backend map[string]interface{}) {
+		if name, ok, _ := unstructured.NestedString(backend, "serviceName"); ok {
+			port, _, _ := unstructured.NestedFieldNoCopy(backend, "servicePort")
+			backends = append(backends, ingressBackend{name, port})
+		} else if name, ok, _ := unstructured.NestedString(backend, "service", "name"); ok {
+			port, ok, _ := unstructured.NestedFieldNoCopy(backend, "service", "port", "number")
+			if !ok {
+				port, _, _ = unstructured.NestedFieldNoCopy(backend, "service", "port", "name")
+			}
+			backends = append(backends, ingressBackend{name, port})
+		}
+	}
+
+	if backend, ok, _ := unstructured.NestedMap(obj.Object, "spec", "backend"); ok {
+		add(backend)
+	}
+	if backend, ok, _ := unstructured.NestedMap(obj.Object, "spec", "defaultBackend"); ok {
+		add(backend)
+	}
+	rules, _, _ := unstructured.NestedSlice(obj.Object, "spec", "rules")
+	for _, rule := range rules {
+		r, ok := rule.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		paths, _, _ := unstructured.NestedSlice(r, "http", "paths")
+		for _, path := range paths {
+			if p, ok := path.(map[string]interface{}); ok {
+				if backend, ok, _ := unstructured.NestedMap(p, "backend"); ok {
+					add(backend)
+				}
+			}
+		}
+	}
+	return backends
+}
+
+// verifyServicePort checks that the Service name exists and has port, a port number or name, or
+// nil for any port
+func verifyServicePort(obj *unstructured.Unstructured, set manifestSet, namespace, name string, port interface{}, report func(*unstructured.Unstructured, string, ...interface{})) {
+	svc := set.get("Service", namespace, name)
+	if svc == nil {
+		report(obj, "the Service %s doesn't exist", name)
+		return
+	}
+	if port == nil || port == "" {
+		return
+	}
+	ports, _, _ := unstructured.NestedSlice(svc.Object, "spec", "ports")
+	for _, p := range ports {
+		m, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch value := port.(type) {
+		case string:
+			if m["name"] == value {
+				return
+			}
+		default:
+			if fmt.Sprint(m["port"]) == fmt.Sprint(value) || fmt.Sprint(m["targetPort"]) == fmt.Sprint(value) {
+				return
+			}
+		}
+	}
+	report(obj, "the Service %s has no port %v", name, port)
+}
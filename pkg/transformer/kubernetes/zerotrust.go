@@ -0,0 +1,220 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"fmt"
+
+	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/transformer"
+	"github.com/pkg/errors"
+	api "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// ZeroTrustNetwork is the network of the services that are in no network with --zero-trust
+	ZeroTrustNetwork = "default"
+	// ZeroTrustTLSDir is the directory the TLS Secret of a service is mounted at with --zero-trust
+	ZeroTrustTLSDir = "/etc/kompose/tls"
+)
+
+// zeroTrustEnv maps the environment variables set to the paths of the TLS files to their key in the Secret
+var zeroTrustEnv = []struct{ name, key string }{
+	{"TLS_CERT_FILE", api.TLSCertKey},
+	{"TLS_KEY_FILE", api.TLSPrivateKeyKey},
+	{"TLS_CA_FILE", api.ServiceAccountRootCAKey},
+}
+
+// zeroTrustCA is the name of the cert-manager ClusterIssuer issuing the certificates of the
+// services, of its Certificate and of the Secret holding its key
+const zeroTrustCA = "kompose-ca"
+
+// zeroTrustCANamespace is the cluster resource namespace of cert-manager, the Secret of the key of
+// a CA ClusterIssuer is read from
+const zeroTrustCANamespace = "cert-manager"
+
+// CreateZeroTrustIssuers creates the cert-manager ClusterIssuers of --zero-trust: a self-signed one
+// issuing the certificate of the CA, and the CA issuing the certificates of the services. The keys
+// are generated by cert-manager in the cluster, so they are never written in the manifests and
+// every conversion generates the same objects.
+func (k *Kubernetes) CreateZeroTrustIssuers() []runtime.Object {
+	selfSigned := newCertManagerObject("ClusterIssuer", "kompose-selfsigned", map[string]interface{}{
+		"selfSigned": map[string]interface{}{},
+	})
+	caCert := newCertManagerObject("Certificate", zeroTrustCA, map[string]interface{}{
+		"isCA":       true,
+		"commonName": zeroTrustCA,
+		"secretName": zeroTrustCA,
+		"duration":   "87600h",
+		"privateKey": map[string]interface{}{"algorithm": "ECDSA", "size": int64(256)},
+		"issuerRef":  map[string]interface{}{"name": "kompose-selfsigned", "kind": "ClusterIssuer", "group": "cert-manager.io"},
+	})
+	caCert.SetNamespace(zeroTrustCANamespace)
+	ca := newCertManagerObject("ClusterIssuer", zeroTrustCA, map[string]interface{}{
+		"ca": map[string]interface{}{"secretName": zeroTrustCA},
+	})
+	return []runtime.Object{selfSigned, caCert, ca}
+}
+
+// newCertManagerObject returns a cert-manager.io/v1 object, cert-manager has no types vendored
+func newCertManagerObject(kind, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	obj.SetAPIVersion("cert-manager.io/v1")
+	obj.SetKind(kind)
+	obj.SetName(name)
+	return obj
+}
+
+// zeroTrustHosts returns the names a service is reached by: its name, its network aliases and,
+// when its namespace is known, its fully qualified names
+func zeroTrustHosts(name string, service kobject.ServiceConfig, opt kobject.ConvertOptions) []string {
+	hosts := []string{name}
+	hosts = append(hosts, service.NetworkAliases...)
+	if opt.NamespacePerNetwork {
+		if namespace := NetworkNamespace(service); namespace != "" {
+			hosts = append(hosts,
+				fmt.Sprintf("%s.%s", name, namespace),
+				fmt.Sprintf("%s.%s.svc", name, namespace),
+				fmt.Sprintf("%s.%s.svc.cluster.local", name, namespace))
+		}
+	}
+	return hosts
+}
+
+// CreateZeroTrustCertificate creates the cert-manager Certificate of a service, for its names and
+// valid for serving and as client certificate. cert-manager writes its key, its certificate and
+// the certificate of the CA to verify the other services with to the TLS Secret of the service.
+func (k *Kubernetes) CreateZeroTrustCertificate(name string, service kobject.ServiceConfig, opt kobject.ConvertOptions) *unstructured.Unstructured {
+	var dnsNames []interface{}
+	for _, host := range zeroTrustHosts(name, service, opt) {
+		dnsNames = append(dnsNames, host)
+	}
+	cert := newCertManagerObject("Certificate", name+"-tls", map[string]interface{}{
+		"secretName": name + "-tls",
+		"commonName": name,
+		"dnsNames":   dnsNames,
+		"duration":   "8760h",
+		"privateKey": map[string]interface{}{"algorithm": "ECDSA", "size": int64(256)},
+		"usages":     []interface{}{"digital signature", "key encipherment", "server auth", "client auth"},
+		"issuerRef":  map[string]interface{}{"name": zeroTrustCA, "kind": "ClusterIssuer", "group": "cert-manager.io"},
+	})
+	cert.SetLabels(transformer.ConfigLabels(name))
+	return cert
+}
+
+// ConfigZeroTrustTLS mounts the TLS Secret of a service into its containers and sets the
+// environment variables pointing to the TLS files, unless the service sets them itself
+func (k *Kubernetes) ConfigZeroTrustTLS(name string, objects []runtime.Object) error {
+	secretName := name + "-tls"
+	updateTemplate := func(template *api.PodTemplateSpec) error {
+		template.Spec.Volumes = append(template.Spec.Volumes, api.Volume{
+			Name: secretName,
+			VolumeSource: api.VolumeSource{
+				Secret: &api.SecretVolumeSource{SecretName: secretName},
+			},
+		})
+		for i := range template.Spec.Containers {
+			container := &template.Spec.Containers[i]
+			container.VolumeMounts = append(container.VolumeMounts, api.VolumeMount{
+				Name:      secretName,
+				MountPath: ZeroTrustTLSDir,
+				ReadOnly:  true,
+			})
+		env:
+			for _, tlsEnv := range zeroTrustEnv {
+				for _, env := range container.Env {
+					if env.Name == tlsEnv.name {
+						continue env
+					}
+				}
+				container.Env = append(container.Env, api.EnvVar{Name: tlsEnv.name, Value: ZeroTrustTLSDir + "/" + tlsEnv.key})
+			}
+		}
+		return nil
+	}
+	for _, obj := range objects {
+		if err := k.UpdateController(obj, updateTemplate, func(*metav1.ObjectMeta) {}); err != nil {
+			return errors.Wrap(err, "k.UpdateController failed")
+		}
+	}
+	return nil
+}
+
+// CreateZeroTrustPolicies creates a NetworkPolicy denying the ingress traffic of all the pods of
+// every namespace, leaving only the traffic allowed by the NetworkPolicies of the networks, and
+// a NetworkPolicy allowing the traffic from anywhere to the ports of the exposed services
+func (k *Kubernetes) CreateZeroTrustPolicies(komposeObject kobject.KomposeObject, opt kobject.ConvertOptions) []runtime.Object {
+	var objects []runtime.Object
+	namespaces := map[string]bool{}
+	for _, name := range SortedKeys(komposeObject) {
+		service := komposeObject.ServiceConfigs[name]
+		namespace := ""
+		if opt.NamespacePerNetwork {
+			namespace = NetworkNamespace(service)
+		}
+		if !namespaces[namespace] {
+			namespaces[namespace] = true
+			objects = append(objects, &networkingv1.NetworkPolicy{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "NetworkPolicy",
+					APIVersion: "networking.k8s.io/v1",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "default-deny-ingress",
+					Namespace: namespace,
+				},
+				Spec: networkingv1.NetworkPolicySpec{
+					PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				},
+			})
+		}
+
+		exposed := service.ExposeService != "" || service.ServiceType == string(api.ServiceTypeNodePort) || service.ServiceType == string(api.ServiceTypeLoadBalancer)
+		if !exposed || !k.PortsExist(service) {
+			continue
+		}
+		var ports []networkingv1.NetworkPolicyPort
+		for _, port := range k.ConfigPorts(name, service) {
+			protocol, containerPort := port.Protocol, intstr.FromInt(int(port.ContainerPort))
+			ports = append(ports, networkingv1.NetworkPolicyPort{
+				Protocol: &protocol,
+				Port:     &containerPort,
+			})
+		}
+		objects = append(objects, &networkingv1.NetworkPolicy{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "NetworkPolicy",
+				APIVersion: "networking.k8s.io/v1",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name + "-external",
+				Namespace: namespace,
+				Labels:    transformer.ConfigLabels(name),
+			},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: transformer.ConfigLabels(name)},
+				Ingress:     []networkingv1.NetworkPolicyIngressRule{{Ports: ports}},
+			},
+		})
+	}
+	return objects
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transformer
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// NameStrategyService names the objects after the compose service
+	NameStrategyService = "service"
+	// NameStrategyProject prefixes the compose service name with the project name
+	NameStrategyProject = "project"
+
+	// MaxNameLength is the maximum length of a label value, which the object names are used as
+	MaxNameLength = 63
+)
+
+// NameData is passed to the --name-strategy template
+type NameData struct {
+	Project string
+	Service string
+}
+
+// IsValidNameStrategy returns true if strategy is a known naming strategy or a template
+func IsValidNameStrategy(strategy string) bool {
+	return strategy == NameStrategyService || strategy == NameStrategyProject || strings.Contains(strategy, "{{")
+}
+
+// ObjectName maps a compose service name to the name of its objects using the given strategy,
+// which is either one of the NameStrategy constants or a template like "{{.Project}}-{{.Service}}".
+// The project strategy leaves the names of a project without name unprefixed. Names longer than
+// MaxNameLength are truncated and suffixed with a hash of the full name.
+func ObjectName(strategy, project, service string) (string, error) {
+	var name string
+	switch strategy {
+	case "", NameStrategyService:
+		name = service
+	case NameStrategyProject:
+		name = service
+		if project != "" {
+			name = project + "-" + service
+		}
+	default:
+		t, err := template.New("name").Parse(strategy)
+		if err != nil {
+			return "", errors.Wrap(err, "invalid --name-strategy template")
+		}
+		var b bytes.Buffer
+		if err := t.Execute(&b, NameData{Project: project, Service: service}); err != nil {
+			return "", errors.Wrap(err, "invalid --name-strategy template")
+		}
+		name = normalizeName(b.String())
+	}
+	return TruncateName(name), nil
+}
+
+// TruncateName shortens names longer than MaxNameLength, keeping them unique by
+// replacing the end of the name with a hash of the full name
+func TruncateName(name string) string {
+	if len(name) <= MaxNameLength {
+		return name
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	hash := fmt.Sprintf("%08x", h.Sum32())
+	return strings.TrimRight(name[:MaxNameLength-len(hash)-1], "-") + "-" + hash
+}
+
+// normalizeName turns name into a valid DNS-1123 label
+func normalizeName(name string) string {
+	name = regexp.MustCompile("[^a-z0-9-]+").ReplaceAllString(strings.ToLower(name), "-")
+	return strings.Trim(name, "-")
+}
+
+// ProjectName returns the project name given by --project-name or COMPOSE_PROJECT_NAME,
+// or the name of the directory of the compose file like docker-compose does
+func ProjectName(opt kobject.ConvertOptions) (string, error) {
+	if opt.ProjectName != "" {
+		return normalizeName(opt.ProjectName), nil
+	}
+	if len(opt.InputFiles) == 0 {
+		return "", nil
+	}
+	dir, err := GetComposeFileDir(opt.InputFiles)
+	if err != nil {
+		return "", err
+	}
+	return normalizeName(filepath.Base(dir)), nil
+}
+
+// RenameServices renames the services of komposeObject according to opt.NameStrategy,
+// along with the references between the services. The containers reach each other by the
+// names of their Services, so it warns that the renamed services no longer answer to their
+// compose name.
+func RenameServices(komposeObject *kobject.KomposeObject, opt kobject.ConvertOptions) error {
+	project := ""
+	if opt.NameStrategy != "" && opt.NameStrategy != NameStrategyService {
+		var err error
+		project, err = ProjectName(opt)
+		if err != nil {
+			return errors.Wrap(err, "unable to get the project name")
+		}
+	}
+
+	names := make(map[string]string)
+	for service := range komposeObject.ServiceConfigs {
+		name, err := ObjectName(opt.NameStrategy, project, service)
+		if err != nil {
+			return err
+		}
+		if name != service {
+			log.WithFields(log.Fields{"service": service, "category": "networking"}).Warnf("Renamed to %q by the --name-strategy, the other services have to reach it by this name instead of its compose name", name)
+		}
+		names[service] = name
+	}
+
+	rename := func(service string) string {
+		if name, ok := names[service]; ok {
+			return name
+		}
+		return service
+	}
+
+	serviceConfigs := make(map[string]kobject.ServiceConfig)
+	for service, serviceConfig := range komposeObject.ServiceConfigs {
+		for i, dependency := range serviceConfig.DependsOn {
+			serviceConfig.DependsOn[i] = rename(dependency)
+		}
+		for i, volume := range serviceConfig.Volumes {
+			if strings.HasPrefix(volume.PVCName, volume.SvcName+"-") {
+				serviceConfig.Volumes[i].PVCName = rename(volume.SvcName) + strings.TrimPrefix(volume.PVCName, volume.SvcName)
+			}
+			serviceConfig.Volumes[i].SvcName = rename(volume.SvcName)
+			if volume.VFrom != "" {
+				serviceConfig.Volumes[i].VFrom = rename(volume.VFrom)
+			}
+		}
+		serviceConfigs[rename(service)] = serviceConfig
+	}
+	if len(serviceConfigs) != len(komposeObject.ServiceConfigs) {
+		return errors.New("the --name-strategy maps several services to the same name")
+	}
+	komposeObject.ServiceConfigs = serviceConfigs
+	return nil
+}
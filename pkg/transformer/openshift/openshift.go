@@ -21,8 +21,12 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"os"
+	"strings"
+	"text/template"
+	"time"
 
 	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/loader/compose"
 	"github.com/kubernetes/kompose/pkg/transformer/kubernetes"
 
 	log "github.com/sirupsen/logrus"
@@ -31,6 +35,7 @@ import (
 	buildapi "github.com/openshift/api/build/v1"
 	imageapi "github.com/openshift/api/image/v1"
 	routeapi "github.com/openshift/api/route/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
 	corev1 "k8s.io/api/core/v1"
 	kapi "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -232,6 +237,112 @@ func (o *OpenShift) initDeploymentConfig(name string, service kobject.ServiceCon
 	return dc
 }
 
+// scaleTarget returns the reference of an HPA to the controller among the objects of a service, see
+// kubernetes.ScaleTarget. The replicas of a DeploymentConfig can't be left out, so applying it
+// again resets the replicas the HPA scaled it to, until the HPA scales it again.
+func scaleTarget(objects []runtime.Object) (autoscalingv2beta2.CrossVersionObjectReference, bool) {
+	for _, obj := range objects {
+		if dc, ok := obj.(*deployapi.DeploymentConfig); ok {
+			return autoscalingv2beta2.CrossVersionObjectReference{
+				Kind:       "DeploymentConfig",
+				APIVersion: "apps.openshift.io/v1",
+				Name:       dc.Name,
+			}, true
+		}
+	}
+	return kubernetes.ScaleTarget(objects)
+}
+
+// configDeploymentStrategy sets the strategy and lifecycle hooks of the DeploymentConfig of a service
+// from its kompose.deploymentconfig.* labels. Hooks run their shell command in a new pod of the service.
+func configDeploymentStrategy(name string, service kobject.ServiceConfig, objects []runtime.Object) error {
+	strategy, hasStrategy := service.Labels[compose.LabelDeploymentConfigStrategy]
+	pre := service.Labels[compose.LabelDeploymentConfigPreHook]
+	mid := service.Labels[compose.LabelDeploymentConfigMidHook]
+	post := service.Labels[compose.LabelDeploymentConfigPostHook]
+	if !hasStrategy && pre == "" && mid == "" && post == "" {
+		return nil
+	}
+
+	var failurePolicy deployapi.LifecycleHookFailurePolicy
+	switch policy := service.Labels[compose.LabelDeploymentConfigHookFailurePolicy]; strings.ToLower(policy) {
+	case "", "abort":
+		failurePolicy = deployapi.LifecycleHookFailurePolicyAbort
+	case "retry":
+		failurePolicy = deployapi.LifecycleHookFailurePolicyRetry
+	case "ignore":
+		failurePolicy = deployapi.LifecycleHookFailurePolicyIgnore
+	default:
+		return fmt.Errorf("unknown %s %q of service %s, supported values are abort, retry and ignore", compose.LabelDeploymentConfigHookFailurePolicy, policy, name)
+	}
+
+	for _, obj := range objects {
+		dc, ok := obj.(*deployapi.DeploymentConfig)
+		if !ok {
+			continue
+		}
+
+		hook := func(command string) *deployapi.LifecycleHook {
+			if command == "" {
+				return nil
+			}
+			return &deployapi.LifecycleHook{
+				FailurePolicy: failurePolicy,
+				ExecNewPod: &deployapi.ExecNewPodHook{
+					Command:       []string{"/bin/sh", "-c", command},
+					ContainerName: dc.Spec.Template.Spec.Containers[0].Name,
+				},
+			}
+		}
+
+		if hasStrategy {
+			switch strings.ToLower(strategy) {
+			case "rolling":
+				dc.Spec.Strategy.Type = deployapi.DeploymentStrategyTypeRolling
+			case "recreate":
+				dc.Spec.Strategy.Type = deployapi.DeploymentStrategyTypeRecreate
+			case "custom":
+				image := service.Labels[compose.LabelDeploymentConfigStrategyImage]
+				if image == "" {
+					return fmt.Errorf("%s custom of service %s requires %s", compose.LabelDeploymentConfigStrategy, name, compose.LabelDeploymentConfigStrategyImage)
+				}
+				dc.Spec.Strategy.Type = deployapi.DeploymentStrategyTypeCustom
+				dc.Spec.Strategy.CustomParams = &deployapi.CustomDeploymentStrategyParams{Image: image}
+			default:
+				return fmt.Errorf("unknown %s %q of service %s, supported values are rolling, recreate and custom", compose.LabelDeploymentConfigStrategy, strategy, name)
+			}
+		}
+
+		switch dc.Spec.Strategy.Type {
+		case deployapi.DeploymentStrategyTypeRecreate:
+			dc.Spec.Strategy.RollingParams = nil
+			dc.Spec.Strategy.RecreateParams = &deployapi.RecreateDeploymentStrategyParams{
+				Pre:  hook(pre),
+				Mid:  hook(mid),
+				Post: hook(post),
+			}
+		case deployapi.DeploymentStrategyTypeCustom:
+			if pre != "" || mid != "" || post != "" {
+				return fmt.Errorf("the custom strategy of service %s doesn't support hooks", name)
+			}
+			dc.Spec.Strategy.RollingParams = nil
+		default:
+			if mid != "" {
+				return fmt.Errorf("%s of service %s requires %s recreate", compose.LabelDeploymentConfigMidHook, name, compose.LabelDeploymentConfigStrategy)
+			}
+			delete(dc.Annotations, kubernetes.AnnotationRecreateReason)
+			dc.Spec.Strategy.Type = deployapi.DeploymentStrategyTypeRolling
+			dc.Spec.Strategy.RecreateParams = nil
+			if dc.Spec.Strategy.RollingParams == nil {
+				dc.Spec.Strategy.RollingParams = &deployapi.RollingDeploymentStrategyParams{}
+			}
+			dc.Spec.Strategy.RollingParams.Pre = hook(pre)
+			dc.Spec.Strategy.RollingParams.Post = hook(post)
+		}
+	}
+	return nil
+}
+
 func (o *OpenShift) initRoute(name string, service kobject.ServiceConfig, port int32) *routeapi.Route {
 	route := &routeapi.Route{
 		TypeMeta: kapi.TypeMeta{
@@ -262,11 +373,11 @@ func (o *OpenShift) initRoute(name string, service kobject.ServiceConfig, port i
 }
 
 // Transform maps komposeObject to openshift objects
-// returns objects that are already sorted in the way that Services are first
+// returns objects that are sorted by dependency, see SortByDependency
 func (o *OpenShift) Transform(komposeObject kobject.KomposeObject, opt kobject.ConvertOptions) ([]runtime.Object, error) {
 	noSupKeys := o.Kubernetes.CheckUnsupportedKey(&komposeObject, unsupportedKey)
 	for _, keyName := range noSupKeys {
-		log.Warningf("OpenShift provider doesn't support %s key - ignoring", keyName)
+		log.WithField("category", "unsupported").Warningf("OpenShift provider doesn't support %s key - ignoring", keyName)
 	}
 	// this will hold all the converted data
 	var allobjects []runtime.Object
@@ -285,10 +396,22 @@ func (o *OpenShift) Transform(komposeObject kobject.KomposeObject, opt kobject.C
 		}
 	}
 
+	var extraResources []*template.Template
+	if opt.ExtraResources != "" {
+		if extraResources, err = kubernetes.LoadExtraResources(opt.ExtraResources); err != nil {
+			return nil, err
+		}
+	}
+
 	sortedKeys := kubernetes.SortedKeys(komposeObject)
-	for _, name := range sortedKeys {
+	for i, name := range sortedKeys {
 		service := komposeObject.ServiceConfigs[name]
 		var objects []runtime.Object
+		start := time.Now()
+		if opt.Progress {
+			log.Infof("[%d/%d] Converting service %s", i+1, len(sortedKeys), name)
+		}
+		routePort := transformer.AutoIngress(name, &service, opt.AutoIngress)
 
 		//replicas
 		var replica int
@@ -326,7 +449,7 @@ func (o *OpenShift) Transform(komposeObject kobject.KomposeObject, opt kobject.C
 
 			// Push the built container to the repo!
 			if opt.PushImage {
-				err = transformer.PushDockerImage(service, name)
+				err = transformer.PushDockerImage(service, name, opt)
 				if err != nil {
 					log.Fatalf("Unable to push Docker image for service %v: %v", name, err)
 				}
@@ -340,10 +463,22 @@ func (o *OpenShift) Transform(komposeObject kobject.KomposeObject, opt kobject.C
 			if opt.IsDeploymentConfigFlag {
 				return nil, errors.New("Controller object cannot be specified with restart: 'on-failure'")
 			}
-			pod := o.InitPod(name, service)
-			objects = append(objects, pod)
+			objects = append(objects, o.InitPodOrJob(name, service))
+			envObjects, err := o.CreateEnvFileObjects(name, service, opt)
+			if err != nil {
+				return nil, errors.Wrap(err, "Error creating the Kubernetes objects")
+			}
+			objects = append(objects, envObjects...)
 		} else {
-			objects = o.CreateKubernetesObjects(name, service, opt)
+			if service.GetJobBackoffLimit() != nil {
+				log.WithFields(log.Fields{"service": name, "category": "unsupported"}).Warn("deploy.restart_policy.max_attempts is ignored, the pods of controllers are always restarted")
+			}
+			if service.JobTTLSecondsAfterFinished != nil {
+				log.WithFields(log.Fields{"service": name, "category": "unsupported"}).Warn("kompose.job.ttl-seconds-after-finished is ignored, the service is not converted to a Job")
+			}
+			if objects, err = o.CreateKubernetesObjects(name, service, opt); err != nil {
+				return nil, errors.Wrap(err, "Error creating the Kubernetes objects")
+			}
 
 			if opt.CreateDeploymentConfig {
 				objects = append(objects, o.initDeploymentConfig(name, service, replica)) // OpenShift DeploymentConfigs
@@ -358,7 +493,7 @@ func (o *OpenShift) Transform(komposeObject kobject.KomposeObject, opt kobject.C
 				// Get the compose file directory
 				composeFileDir, err = transformer.GetComposeFileDir(opt.InputFiles)
 				if err != nil {
-					log.Warningf("Error %v in detecting compose file's directory.", err)
+					log.WithField("category", "images").Warningf("Error %v in detecting compose file's directory.", err)
 					continue
 				}
 
@@ -400,20 +535,34 @@ func (o *OpenShift) Transform(komposeObject kobject.KomposeObject, opt kobject.C
 		}
 
 		if o.PortsExist(service) {
-			if service.ServiceType == "LoadBalancer" {
+			if o.SplitPorts(service) {
+				svcs := o.CreateSplitServices(name, service, objects)
+				for _, svc := range svcs {
+					objects = append(objects, svc)
+				}
+				if service.ExposeService != "" {
+					svc := kubernetes.SplitServiceOf(svcs, routePort)
+					route := o.initRoute(name, service, svc.Spec.Ports[0].Port)
+					route.Spec.To.Name = svc.Name
+					objects = append(objects, route)
+				}
+			} else if service.ServiceType == "LoadBalancer" {
 				svcs := o.CreateLBService(name, service, objects)
 				for _, svc := range svcs {
 					objects = append(objects, svc)
 				}
 				if len(svcs) > 1 {
-					log.Warningf("Create multiple service to avoid using mixed protocol in the same service when it's loadbalander type")
+					log.WithFields(log.Fields{"service": name, "category": "networking"}).Warning("Create multiple service to avoid using mixed protocol in the same service when it's loadbalander type")
 				}
 			} else {
 				svc := o.CreateService(name, service, objects)
 				objects = append(objects, svc)
 
 				if service.ExposeService != "" {
-					objects = append(objects, o.initRoute(name, service, svc.Spec.Ports[0].Port))
+					if routePort == 0 {
+						routePort = svc.Spec.Ports[0].Port
+					}
+					objects = append(objects, o.initRoute(name, service, routePort))
 				}
 			}
 
@@ -427,11 +576,85 @@ func (o *OpenShift) Transform(komposeObject kobject.KomposeObject, opt kobject.C
 			return nil, errors.Wrap(err, "Error transforming Kubernetes objects")
 		}
 
+		if err := o.ConfigSCC(name, opt, &objects); err != nil {
+			return nil, errors.Wrap(err, "Error configuring the SecurityContextConstraints")
+		}
+
+		if err := configDeploymentStrategy(name, service, objects); err != nil {
+			return nil, errors.Wrap(err, "Error configuring the DeploymentConfig strategy")
+		}
+
+		if service.HPA != (kobject.HPA{}) {
+			if target, ok := scaleTarget(objects); ok {
+				hpa, err := o.CreateHPA(name, service, opt, target)
+				if err != nil {
+					return nil, errors.Wrap(err, "Error creating the HorizontalPodAutoscaler")
+				}
+				objects = append(objects, hpa)
+			} else {
+				log.WithFields(log.Fields{"service": name, "category": "unsupported"}).Warn("The kompose.hpa labels are ignored, the service is not converted to a DeploymentConfig, Deployment, StatefulSet or ReplicationController")
+			}
+		}
+
+		if opt.SingletonPDB {
+			pdb, err := o.CreateSingletonPDB(name, service, objects)
+			if err != nil {
+				return nil, errors.Wrap(err, "Error creating the PodDisruptionBudget")
+			}
+			if pdb != nil {
+				objects = append(objects, pdb)
+			}
+		}
+
+		if opt.DependsOnReadiness {
+			err = o.ConfigDependsOnReadiness(name, service, komposeObject, objects)
+			if err != nil {
+				return nil, errors.Wrap(err, "Error configuring the readiness probe")
+			}
+		}
+
+		if opt.SmartDefaults {
+			if err := o.ConfigImageProbes(name, service, objects); err != nil {
+				return nil, errors.Wrap(err, "Error configuring the probes of the image")
+			}
+		}
+
+		extras, err := o.CreateExtraResources(name, service, extraResources, opt)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error creating the extra resources")
+		}
+		objects = append(objects, extras...)
+
 		allobjects = append(allobjects, objects...)
+		log.Debugf("Service %s converted in %s", name, time.Since(start))
+	}
+
+	allobjects = append(allobjects, o.CreateHostGatewayServices(komposeObject, opt)...)
+	allobjects = append(allobjects, o.CreateExternalLinkServices(komposeObject, opt)...)
+	allobjects = append(allobjects, o.CreateNetworkAliasServices(komposeObject, allobjects)...)
+
+	if opt.ChecksumAnnotations {
+		if err := o.AddChecksumAnnotations(allobjects); err != nil {
+			return nil, errors.Wrap(err, "Error adding the checksum annotations")
+		}
+	}
+
+	if !opt.LegacyLabels {
+		if err := o.ConfigRecommendedLabels(allobjects, komposeObject, opt); err != nil {
+			return nil, errors.Wrap(err, "Error configuring the recommended labels")
+		}
+	}
+	if opt.PartOf != "" {
+		transformer.AddAppLabels(allobjects, opt.PartOf)
+	}
+
+	allobjects, err = kubernetes.SetInternalTrafficPolicy(allobjects)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error setting the internalTrafficPolicy of the Services")
 	}
 
-	// sort all object so Services are first
-	o.SortServicesFirst(&allobjects)
+	// sort all objects so they are applied after the objects they depend on
+	o.SortByDependency(&allobjects)
 	o.RemoveDupObjects(&allobjects)
 	// o.FixWorkloadVersion(&allobjects)
 
@@ -19,6 +19,7 @@ package openshift
 import (
 	deployapi "github.com/openshift/api/apps/v1"
 
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"os"
 	"path/filepath"
@@ -26,12 +27,14 @@ import (
 	"testing"
 
 	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/loader/compose"
 	"github.com/kubernetes/kompose/pkg/testutils"
 	"github.com/kubernetes/kompose/pkg/transformer"
 	"github.com/kubernetes/kompose/pkg/transformer/kubernetes"
 	"github.com/pkg/errors"
 
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 )
 
 func newServiceConfig() kobject.ServiceConfig {
@@ -432,3 +435,156 @@ func TestRecreateStrategyWithVolumesPresent(t *testing.T) {
 		}
 	}
 }
+
+func TestSingletonPDB(t *testing.T) {
+	service := kobject.ServiceConfig{
+		Image:   "image",
+		VolList: []string{"/data"},
+		Volumes: []kobject.Volumes{{SvcName: "app", MountPath: "/data", PVCName: "app-claim0"}},
+	}
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{"app": service},
+	}
+
+	o := OpenShift{Kubernetes: kubernetes.Kubernetes{}}
+	objects, err := o.Transform(komposeObject, kobject.ConvertOptions{CreateDeploymentConfig: true, Replicas: 1, SingletonPDB: true})
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "o.Transform failed"))
+	}
+	var pdb *unstructured.Unstructured
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *unstructured.Unstructured:
+			pdb = o
+		case *deployapi.DeploymentConfig:
+			if selector := map[string]string{transformer.LabelName: "app"}; !reflect.DeepEqual(o.Spec.Selector, selector) {
+				t.Errorf("Expected the DeploymentConfig to select %v, got %v", selector, o.Spec.Selector)
+			}
+		}
+	}
+	if pdb == nil || pdb.GetKind() != "PodDisruptionBudget" {
+		t.Fatalf("Expected a PodDisruptionBudget, got %v", pdb)
+	}
+	selector, _, _ := unstructured.NestedStringMap(pdb.Object, "spec", "selector", "matchLabels")
+	if expected := map[string]string{transformer.LabelName: "app"}; !reflect.DeepEqual(selector, expected) {
+		t.Errorf("Expected the PodDisruptionBudget to select %v, got %v", expected, selector)
+	}
+}
+
+func TestDeploymentConfigStrategyLabels(t *testing.T) {
+	testCases := map[string]struct {
+		labels       map[string]string
+		volumes      bool
+		strategyType deployapi.DeploymentStrategyType
+		hooks        []string
+		expectError  bool
+	}{
+		"Rolling with hooks": {
+			map[string]string{compose.LabelDeploymentConfigPreHook: "./migrate.sh", compose.LabelDeploymentConfigPostHook: "./notify.sh"},
+			false, deployapi.DeploymentStrategyTypeRolling, []string{"pre", "post"}, false,
+		},
+		"Recreate of volumes with mid hook": {
+			map[string]string{compose.LabelDeploymentConfigMidHook: "./migrate.sh"},
+			true, deployapi.DeploymentStrategyTypeRecreate, []string{"mid"}, false,
+		},
+		"Rolling overrides the recreate of volumes": {
+			map[string]string{compose.LabelDeploymentConfigStrategy: "Rolling"},
+			true, deployapi.DeploymentStrategyTypeRolling, nil, false,
+		},
+		"Custom": {
+			map[string]string{compose.LabelDeploymentConfigStrategy: "custom", compose.LabelDeploymentConfigStrategyImage: "deployer"},
+			false, deployapi.DeploymentStrategyTypeCustom, nil, false,
+		},
+		"Custom without image":     {map[string]string{compose.LabelDeploymentConfigStrategy: "custom"}, false, "", nil, true},
+		"Mid hook of rolling":      {map[string]string{compose.LabelDeploymentConfigMidHook: "./migrate.sh"}, false, "", nil, true},
+		"Unknown strategy":         {map[string]string{compose.LabelDeploymentConfigStrategy: "canary"}, false, "", nil, true},
+		"Unknown failure policy":   {map[string]string{compose.LabelDeploymentConfigPreHook: "true", compose.LabelDeploymentConfigHookFailurePolicy: "skip"}, false, "", nil, true},
+		"Hooks of custom strategy": {map[string]string{compose.LabelDeploymentConfigStrategy: "custom", compose.LabelDeploymentConfigStrategyImage: "deployer", compose.LabelDeploymentConfigPreHook: "true"}, false, "", nil, true},
+	}
+
+	for name, test := range testCases {
+		t.Log("Test case:", name)
+		service := kobject.ServiceConfig{
+			ContainerName: "name",
+			Image:         "image",
+			Labels:        test.labels,
+		}
+		if test.volumes {
+			service.VolList = []string{"/tmp/volume"}
+			service.Volumes = []kobject.Volumes{{SvcName: "app", MountPath: "/tmp/volume", PVCName: "app-claim0"}}
+		}
+		komposeObject := kobject.KomposeObject{
+			ServiceConfigs: map[string]kobject.ServiceConfig{"app": service},
+		}
+
+		o := OpenShift{Kubernetes: kubernetes.Kubernetes{}}
+		objects, err := o.Transform(komposeObject, kobject.ConvertOptions{CreateDeploymentConfig: true, Replicas: 1})
+		if test.expectError {
+			if err == nil {
+				t.Errorf("Expected an error")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(errors.Wrap(err, "o.Transform failed"))
+		}
+
+		for _, obj := range objects {
+			dc, ok := obj.(*deployapi.DeploymentConfig)
+			if !ok {
+				continue
+			}
+			strategy := dc.Spec.Strategy
+			if strategy.Type != test.strategyType {
+				t.Errorf("Expected strategy %s, got %s", test.strategyType, strategy.Type)
+			}
+			hooks := map[string]*deployapi.LifecycleHook{}
+			if strategy.RollingParams != nil {
+				hooks["pre"], hooks["post"] = strategy.RollingParams.Pre, strategy.RollingParams.Post
+			}
+			if strategy.RecreateParams != nil {
+				hooks["pre"], hooks["mid"], hooks["post"] = strategy.RecreateParams.Pre, strategy.RecreateParams.Mid, strategy.RecreateParams.Post
+			}
+			for _, hook := range test.hooks {
+				if hooks[hook] == nil || hooks[hook].ExecNewPod.ContainerName != "name" || hooks[hook].FailurePolicy != deployapi.LifecycleHookFailurePolicyAbort {
+					t.Errorf("Expected a %s hook aborting on failure, got %#v", hook, hooks[hook])
+				}
+			}
+		}
+	}
+}
+
+func TestConfigSCC(t *testing.T) {
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"agent": {Image: "agent", Privileged: true},
+			"web":   {Image: "nginx"},
+		},
+	}
+	opt := kobject.ConvertOptions{CreateDeploymentConfig: true, Replicas: 1, SCCBindings: true}
+	o := OpenShift{Kubernetes: kubernetes.Kubernetes{Opt: opt}}
+	objects, err := o.Transform(komposeObject, opt)
+	if err != nil {
+		t.Fatal(errors.Wrap(err, "o.Transform failed"))
+	}
+
+	var bindings []string
+	for _, obj := range objects {
+		switch object := obj.(type) {
+		case *deployapi.DeploymentConfig:
+			serviceAccount := object.Spec.Template.Spec.ServiceAccountName
+			if object.Name == "agent" && serviceAccount != "agent" || object.Name == "web" && serviceAccount != "" {
+				t.Errorf("Unexpected ServiceAccount %q for DeploymentConfig %s", serviceAccount, object.Name)
+			}
+		case *rbacv1.RoleBinding:
+			bindings = append(bindings, object.Name+"="+object.RoleRef.Name)
+		}
+	}
+	if !reflect.DeepEqual(bindings, []string{"agent-scc-privileged=system:openshift:scc:privileged"}) {
+		t.Errorf("Unexpected RoleBindings %v", bindings)
+	}
+
+	if scc := RequiredSCC(&corev1.PodSpec{Volumes: []corev1.Volume{{VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/data"}}}}}); scc != SCCHostMountAnyUID {
+		t.Errorf("Expected %s for a hostPath volume, got %q", SCCHostMountAnyUID, scc)
+	}
+}
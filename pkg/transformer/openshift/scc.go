@@ -0,0 +1,143 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openshift
+
+import (
+	"github.com/kubernetes/kompose/pkg/kobject"
+	"github.com/kubernetes/kompose/pkg/transformer"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kapi "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// The default SecurityContextConstraints of OpenShift the pods may need instead of restricted
+const (
+	// SCCPrivileged allows privileged containers, added capabilities, host namespaces and any SELinux context
+	SCCPrivileged = "privileged"
+	// SCCHostMountAnyUID allows hostPath volumes and running as any user
+	SCCHostMountAnyUID = "hostmount-anyuid"
+	// SCCAnyUID allows running as any user, like root
+	SCCAnyUID = "anyuid"
+)
+
+// RequiredSCC returns the SecurityContextConstraints the pods of spec need, as the restricted SCC
+// the pods get by default refuses them, or "" when restricted admits them
+func RequiredSCC(spec *corev1.PodSpec) string {
+	if spec.HostNetwork || spec.HostPID || spec.HostIPC {
+		return SCCPrivileged
+	}
+	anyUID := spec.SecurityContext != nil && spec.SecurityContext.RunAsUser != nil
+	for _, container := range spec.Containers {
+		context := container.SecurityContext
+		if context == nil {
+			continue
+		}
+		if (context.Privileged != nil && *context.Privileged) || context.SELinuxOptions != nil ||
+			(context.Capabilities != nil && len(context.Capabilities.Add) > 0) {
+			return SCCPrivileged
+		}
+		if context.RunAsUser != nil {
+			anyUID = true
+		}
+	}
+	for _, volume := range spec.Volumes {
+		if volume.HostPath != nil {
+			return SCCHostMountAnyUID
+		}
+	}
+	if anyUID {
+		return SCCAnyUID
+	}
+	return ""
+}
+
+// ConfigSCC finds the SecurityContextConstraints the pods of a service need. Without
+// --scc-bindings it warns that the pods will fail admission, else it runs the pods with a
+// ServiceAccount of the service and appends the ServiceAccount and a RoleBinding granting it the
+// use of the SCC to objects.
+func (o *OpenShift) ConfigSCC(name string, opt kobject.ConvertOptions, objects *[]runtime.Object) error {
+	var scc string
+	updateTemplate := func(template *corev1.PodTemplateSpec) error {
+		required := RequiredSCC(&template.Spec)
+		if required == "" {
+			return nil
+		}
+		scc = required
+		if opt.SCCBindings {
+			template.Spec.ServiceAccountName = name
+		}
+		return nil
+	}
+	for _, obj := range *objects {
+		if err := o.UpdateController(obj, updateTemplate, func(*kapi.ObjectMeta) {}); err != nil {
+			return errors.Wrap(err, "o.UpdateController failed")
+		}
+	}
+	if scc == "" {
+		return nil
+	}
+
+	if !opt.SCCBindings {
+		log.WithFields(log.Fields{"service": name, "category": "security"}).Warnf("The pods need the %s SecurityContextConstraints and will fail admission with the default restricted one, "+
+			"use --scc-bindings or run 'oc adm policy add-scc-to-user %s -z default'", scc, scc)
+		return nil
+	}
+	log.WithField("service", name).Infof("Granting the %s SecurityContextConstraints to the ServiceAccount %s", scc, name)
+	*objects = append(*objects, initServiceAccount(name), initSCCRoleBinding(name, scc))
+	return nil
+}
+
+// initServiceAccount initializes the ServiceAccount the pods of a service run with
+func initServiceAccount(name string) *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{
+		TypeMeta: kapi.TypeMeta{
+			Kind:       "ServiceAccount",
+			APIVersion: "v1",
+		},
+		ObjectMeta: kapi.ObjectMeta{
+			Name:   name,
+			Labels: transformer.ConfigLabels(name),
+		},
+	}
+}
+
+// initSCCRoleBinding initializes the RoleBinding granting the ServiceAccount of a service the
+// use of scc, through the system:openshift:scc:<scc> ClusterRole of OpenShift 4
+func initSCCRoleBinding(name, scc string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		TypeMeta: kapi.TypeMeta{
+			Kind:       "RoleBinding",
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: kapi.ObjectMeta{
+			Name:   name + "-scc-" + scc,
+			Labels: transformer.ConfigLabels(name),
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     "system:openshift:scc:" + scc,
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind: rbacv1.ServiceAccountKind,
+			Name: name,
+		}},
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package transformer
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -32,11 +33,30 @@ import (
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // Selector used as labels and selector
 const Selector = "io.kompose.service"
 
+const (
+	// LabelName is the recommended label of the name of the service, selecting its pods
+	LabelName = "app.kubernetes.io/name"
+	// LabelInstance is the recommended label of the instance of the application, the project
+	LabelInstance = "app.kubernetes.io/instance"
+	// LabelVersion is the recommended label of the version of the service, the tag of its image
+	LabelVersion = "app.kubernetes.io/version"
+	// LabelComponent is the recommended label of the component of the project, the service
+	LabelComponent = "app.kubernetes.io/component"
+	// LabelPartOf is the label of the application the objects are part of
+	LabelPartOf = "app.kubernetes.io/part-of"
+	// LabelManagedBy is the label of the tool managing the objects
+	LabelManagedBy = "app.kubernetes.io/managed-by"
+	// ManagedBy is the value of LabelManagedBy
+	ManagedBy = "kompose"
+)
+
 // Exists returns true if a file path exists.
 // Otherwise, returns false.
 func Exists(p string) bool {
@@ -67,7 +87,7 @@ func ParseVolume(volume string) (name, host, container, mode string, err error)
 	separator := ":"
 
 	// Parse based on ":"
-	volumeStrings := strings.Split(volume, separator)
+	volumeStrings := joinWindowsDrives(strings.Split(volume, separator))
 	if len(volumeStrings) == 0 {
 		return
 	}
@@ -84,19 +104,22 @@ func ParseVolume(volume string) (name, host, container, mode string, err error)
 		return
 	}
 
-	// Get the last ":" passed which is presumingly the "access mode"
-	possibleAccessMode := volumeStrings[len(volumeStrings)-1]
-
-	// Check to see if :Z or :z exists. We do not support SELinux relabeling at the moment.
-	// See https://github.com/kubernetes/kompose/issues/176
-	// Otherwise, check to see if "rw" or "ro" has been passed
-	if possibleAccessMode == "z" || possibleAccessMode == "Z" {
-		log.Warnf("Volume mount \"%s\" will be mounted without labeling support. :z or :Z not supported", volume)
-		mode = ""
-		volumeStrings = volumeStrings[:len(volumeStrings)-1]
-	} else if possibleAccessMode == "rw" || possibleAccessMode == "ro" {
-		mode = possibleAccessMode
+	// Get the last ":" passed which is presumingly the options, like "ro" or "ro,Z"
+	if options, ok := volumeOptions(volumeStrings[len(volumeStrings)-1]); ok {
 		volumeStrings = volumeStrings[:len(volumeStrings)-1]
+		for _, option := range options {
+			switch option {
+			case "rw", "ro":
+				mode = option
+			case "z", "Z":
+				// We do not support SELinux relabeling at the moment.
+				// See https://github.com/kubernetes/kompose/issues/176
+				log.WithField("category", "volumes").Warnf("Volume mount \"%s\" will be mounted without labeling support. :z or :Z not supported", volume)
+			case "U":
+				// podman changes the owner of the volume to the user of the container
+				log.WithField("category", "volumes").Warnf("Volume mount \"%s\" will be mounted without changing its owner. :U not supported, see kompose.fsgroup", volume)
+			}
+		}
 	}
 
 	// Check the volume format as well as host
@@ -112,6 +135,21 @@ func ParseVolume(volume string) (name, host, container, mode string, err error)
 	return
 }
 
+// volumeOptions splits the options of a volume, like "ro,Z", and returns false if they aren't
+// options of docker or podman
+func volumeOptions(s string) ([]string, bool) {
+	options := strings.Split(s, ",")
+	for _, option := range options {
+		switch option {
+		case "rw", "ro", "z", "Z", "U", "O", "nocopy", "cached", "delegated", "consistent",
+			"shared", "rshared", "slave", "rslave", "private", "rprivate":
+		default:
+			return nil, false
+		}
+	}
+	return options, true
+}
+
 // ParseIngressPath parse path for ingress.
 // eg. example.com/org -> example.com org
 func ParseIngressPath(url string) (string, string) {
@@ -122,8 +160,65 @@ func ParseIngressPath(url string) (string, string) {
 	return url, ""
 }
 
+// webPorts are the container ports HTTP servers usually listen on
+var webPorts = map[int32]bool{80: true, 3000: true, 5000: true, 8000: true, 8080: true, 8888: true, 9000: true}
+
+// AutoIngress exposes a service at <name>.<domain> when it publishes a web port and doesn't set
+// kompose.service.expose itself. It returns the published port to send the traffic of the host
+// to, or 0 when the service isn't exposed automatically.
+func AutoIngress(name string, service *kobject.ServiceConfig, domain string) int32 {
+	if domain == "" || service.ExposeService != "" {
+		return 0
+	}
+	for _, port := range service.Port {
+		if port.HostPort != 0 && port.Protocol != api.ProtocolUDP && webPorts[port.ContainerPort] {
+			service.ExposeService = name + "." + domain
+			log.Debugf("Service %s is exposed at %s", name, service.ExposeService)
+			return port.HostPort
+		}
+	}
+	return 0
+}
+
 func isPath(substring string) bool {
-	return strings.Contains(substring, "/") || substring == "."
+	return strings.ContainsAny(substring, "/\\") || substring == "."
+}
+
+// IsWindowsPath returns true if the path starts with a drive letter, such as C:\data or C:/data
+func IsWindowsPath(path string) bool {
+	return len(path) > 2 && isDriveLetter(path[:1]) && path[1] == ':' && (path[2] == '\\' || path[2] == '/')
+}
+
+func isDriveLetter(s string) bool {
+	return len(s) == 1 && (s[0] >= 'a' && s[0] <= 'z' || s[0] >= 'A' && s[0] <= 'Z')
+}
+
+// joinWindowsDrives joins the drive letters split off Windows paths back to their path,
+// e.g. ["C", "\data", "/data"] becomes ["C:\data", "/data"]. A drive letter followed by a
+// forward slash path is only joined when a container path follows, since "c:/data" is
+// a volume named "c" mounted at "/data".
+func joinWindowsDrives(volumeStrings []string) []string {
+	var result []string
+	for i := 0; i < len(volumeStrings); i++ {
+		if i+1 < len(volumeStrings) && isDriveLetter(volumeStrings[i]) {
+			next := volumeStrings[i+1]
+			if strings.HasPrefix(next, "\\") || (strings.HasPrefix(next, "/") && i+2 < len(volumeStrings) && isPath(volumeStrings[i+2])) {
+				result = append(result, volumeStrings[i]+":"+next)
+				i++
+				continue
+			}
+		}
+		result = append(result, volumeStrings[i])
+	}
+	return result
+}
+
+// TranslateWindowsPath converts a Windows drive path into a Linux path below prefix,
+// e.g. C:\data becomes <prefix>/c/data
+func TranslateWindowsPath(path, prefix string) string {
+	drive := strings.ToLower(path[:1])
+	rest := strings.Replace(path[2:], "\\", "/", -1)
+	return strings.TrimSuffix(prefix, "/") + "/" + drive + rest
 }
 
 // ConfigLabels configures label name alone
@@ -131,6 +226,33 @@ func ConfigLabels(name string) map[string]string {
 	return map[string]string{Selector: name}
 }
 
+// ServiceLabel returns the service the labels of an object refer to, by the io.kompose.service
+// label or by the recommended app.kubernetes.io/name label
+func ServiceLabel(labels map[string]string) string {
+	if service, ok := labels[Selector]; ok {
+		return service
+	}
+	return labels[LabelName]
+}
+
+// AddAppLabels labels every object as part of the application partOf and managed by kompose, so
+// the whole converted set can be selected, e.g. by kubectl apply --prune -l
+func AddAppLabels(objects []runtime.Object, partOf string) {
+	for _, obj := range objects {
+		meta, ok := obj.(metav1.Object)
+		if !ok {
+			continue
+		}
+		labels := meta.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[LabelPartOf] = partOf
+		labels[LabelManagedBy] = ManagedBy
+		meta.SetLabels(labels)
+	}
+}
+
 // ConfigLabelsWithNetwork configures label and add Network Information in labels
 func ConfigLabelsWithNetwork(name string, net []string) map[string]string {
 
@@ -156,6 +278,15 @@ func ConfigAllLabels(name string, service *kobject.ServiceConfig) map[string]str
 
 }
 
+// ConfigServiceAnnotations configures the annotations of the Service objects
+func ConfigServiceAnnotations(service kobject.ServiceConfig) map[string]string {
+	annotations := ConfigAnnotations(service)
+	for key, value := range service.ServiceAnnotations {
+		annotations[key] = value
+	}
+	return annotations
+}
+
 // ConfigAnnotations configures annotations
 func ConfigAnnotations(service kobject.ServiceConfig) map[string]string {
 
@@ -186,31 +317,72 @@ func ConfigAnnotations(service kobject.ServiceConfig) map[string]string {
 }
 
 // Print either prints to stdout or to file/s
-func Print(name, path string, trailing string, data []byte, toStdout, generateJSON bool, f *os.File, provider string) (string, error) {
+func Print(name, path string, trailing string, data []byte, toStdout, generateJSON bool, f *os.File, provider string) (string, FileChange, error) {
+	// files holding objects of several kinds have no trailing kind
+	if trailing != "" {
+		name = fmt.Sprintf("%s-%s", name, trailing)
+	}
 	file := ""
 	if generateJSON {
-		file = fmt.Sprintf("%s-%s.json", name, trailing)
+		file = fmt.Sprintf("%s.json", name)
 	} else {
-		file = fmt.Sprintf("%s-%s.yaml", name, trailing)
+		file = fmt.Sprintf("%s.yaml", name)
 	}
 	if toStdout {
 		fmt.Fprintf(os.Stdout, "%s\n", string(data))
-		return "", nil
+		return "", "", nil
 	} else if f != nil {
 		// Write all content to a single file f
 		if _, err := f.WriteString(fmt.Sprintf("%s\n", string(data))); err != nil {
-			return "", errors.Wrap(err, "f.WriteString failed, Failed to write %s to file: "+trailing)
+			return "", "", errors.Wrap(err, "f.WriteString failed, Failed to write %s to file: "+trailing)
 		}
 		f.Sync()
-	} else {
-		// Write content separately to each file
-		file = filepath.Join(path, file)
-		if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
-			return "", errors.Wrap(err, "Failed to write %s: "+trailing)
-		}
+		return file, "", nil
+	}
+
+	// Write content separately to each file
+	file = filepath.Join(path, file)
+	change, err := WriteFileIfChanged(file, data)
+	if err != nil {
+		return "", "", errors.Wrap(err, "Failed to write %s: "+trailing)
+	}
+	switch change {
+	case FileAdded:
 		log.Printf("%s file %q created", formatProviderName(provider), file)
+	case FileChanged:
+		log.Printf("%s file %q updated", formatProviderName(provider), file)
+	default:
+		log.Debugf("%s file %q unchanged", formatProviderName(provider), file)
 	}
-	return file, nil
+	return file, change, nil
+}
+
+// FileChange is how writing a file of the output directory changed it
+type FileChange string
+
+const (
+	// FileAdded is a file that didn't exist
+	FileAdded FileChange = "added"
+	// FileChanged is a file whose content changed
+	FileChanged FileChange = "changed"
+	// FileUnchanged is a file that already had the content
+	FileUnchanged FileChange = "unchanged"
+)
+
+// WriteFileIfChanged writes data to file unless the file already holds data, so the modification
+// time of unchanged files is kept and GitOps diffs and build caches only see the changed files
+func WriteFileIfChanged(file string, data []byte) (FileChange, error) {
+	change := FileChanged
+	existing, err := ioutil.ReadFile(file)
+	switch {
+	case err == nil && bytes.Equal(existing, data):
+		return FileUnchanged, nil
+	case os.IsNotExist(err):
+		change = FileAdded
+	case err != nil:
+		return "", err
+	}
+	return change, ioutil.WriteFile(file, data, 0644)
 }
 
 // If Openshift, change to OpenShift!
@@ -242,6 +414,33 @@ func (env EnvSort) Swap(i, j int) {
 	env[i], env[j] = env[j], env[i]
 }
 
+// MatchEnv returns true if the name of an environment variable matches one of the glob patterns,
+// like DOCKER_*. The patterns are checked by the validation of the options, a malformed one
+// matches nothing.
+func MatchEnv(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterEnv returns the value of an environment variable for the manifests: ok is false if the
+// variable matches opt.EnvExclude, and the value is replaced by the placeholder ${name} if it
+// matches opt.EnvMask
+func FilterEnv(name, value string, opt kobject.ConvertOptions) (string, bool) {
+	if MatchEnv(name, opt.EnvExclude) {
+		log.Debugf("Environment variable %s is excluded", name)
+		return "", false
+	}
+	if MatchEnv(name, opt.EnvMask) {
+		log.Debugf("Environment variable %s is masked", name)
+		return "${" + name + "}", true
+	}
+	return value, true
+}
+
 // GetComposeFileDir returns compose file directory
 func GetComposeFileDir(inputFiles []string) (string, error) {
 	// Lets assume all the docker-compose files are in the same directory
@@ -311,15 +510,28 @@ func BuildDockerImage(service kobject.ServiceConfig, name string) error {
 	return nil
 }
 
-// PushDockerImage pushes docker image
-func PushDockerImage(service kobject.ServiceConfig, serviceName string) error {
+// PushCredentials returns the registry credentials given by the --push-registry flags, or nil to use
+// the credentials of the Docker config file
+func PushCredentials(opt kobject.ConvertOptions) *dockerlib.AuthConfiguration {
+	if opt.PushRegistryUsername == "" && opt.PushRegistryToken == "" {
+		return nil
+	}
+	return &dockerlib.AuthConfiguration{
+		Username:      opt.PushRegistryUsername,
+		Password:      opt.PushRegistryPassword,
+		RegistryToken: opt.PushRegistryToken,
+	}
+}
+
+// PushDockerImage pushes docker image, with the registry credentials and settings of opt
+func PushDockerImage(service kobject.ServiceConfig, serviceName string, opt kobject.ConvertOptions) error {
 
 	log.Debugf("Pushing Docker image '%s'", service.Image)
 
 	// Don't do anything if service.Image is blank, but at least WARN about it
 	// lse, let's push the image
 	if service.Image == "" {
-		log.Warnf("No image name has been passed for service %s, skipping pushing to repository", serviceName)
+		log.WithFields(log.Fields{"service": serviceName, "category": "images"}).Warn("No image name has been passed, skipping pushing to repository")
 		return nil
 	}
 
@@ -329,7 +541,7 @@ func PushDockerImage(service kobject.ServiceConfig, serviceName string) error {
 		return err
 	}
 
-	push := docker.Push{Client: *client}
+	push := docker.Push{Client: *client, Auth: PushCredentials(opt), Insecure: opt.PushInsecureRegistry}
 	err = push.PushImage(service.Image)
 
 	if err != nil {
@@ -338,3 +550,27 @@ func PushDockerImage(service kobject.ServiceConfig, serviceName string) error {
 
 	return nil
 }
+
+// ReportUnused warns about the top-level volumes, networks, configs and secrets of komposeObject
+// that no service refers to. Only secrets are converted regardless of their use, so prune removes
+// the unused secrets from komposeObject, which leaves out their Secret objects.
+func ReportUnused(komposeObject *kobject.KomposeObject, prune bool) {
+	unused := komposeObject.Unused
+	for _, name := range unused.Volumes {
+		log.WithField("category", "unused").Warnf("Volume %q is declared but not used by any service", name)
+	}
+	for _, name := range unused.Networks {
+		log.WithField("category", "unused").Warnf("Network %q is declared but not used by any service", name)
+	}
+	for _, name := range unused.Configs {
+		log.WithField("category", "unused").Warnf("Config %q is declared but not used by any service", name)
+	}
+	for _, name := range unused.Secrets {
+		if !prune {
+			log.WithField("category", "unused").Warnf("Secret %q is declared but not used by any service, --prune-unused leaves out its Secret object", name)
+			continue
+		}
+		log.Infof("Secret %q is declared but not used by any service - leaving it out", name)
+		delete(komposeObject.Secrets, name)
+	}
+}
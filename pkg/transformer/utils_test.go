@@ -20,6 +20,9 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+
+	dockerCliTypes "github.com/docker/cli/cli/compose/types"
+	"github.com/kubernetes/kompose/pkg/kobject"
 )
 
 func TestFormatProviderName(t *testing.T) {
@@ -118,6 +121,46 @@ func TestParseVolume(t *testing.T) {
 			container2,
 			"",
 		},
+		{
+			"name:container:podman options",
+			fmt.Sprintf("%s:%s:%s", name1, container1, "ro,Z,U"),
+			name1,
+			"",
+			container1,
+			"ro",
+		},
+		{
+			"host:container:options without mode",
+			fmt.Sprintf("%s:%s:%s", host1, container1, "z,nocopy"),
+			"",
+			host1,
+			container1,
+			"",
+		},
+		{
+			"windows host:container:mode",
+			fmt.Sprintf("%s:%s:%s", `C:\data`, container1, mode),
+			"",
+			`C:\data`,
+			container1,
+			mode,
+		},
+		{
+			"windows host with forward slashes:container",
+			fmt.Sprintf("%s:%s", "c:/data", container1),
+			"",
+			"c:/data",
+			container1,
+			"",
+		},
+		{
+			"name:windows host:container",
+			fmt.Sprintf("%s:%s:%s", name1, `D:\cache`, container1),
+			name1,
+			`D:\cache`,
+			container1,
+			"",
+		},
 	}
 
 	for _, test := range tests {
@@ -149,3 +192,155 @@ func TestGetComposeFileDir(t *testing.T) {
 		t.Errorf("Expected $PWD/foobar, got %v", output)
 	}
 }
+
+func TestTranslateWindowsPath(t *testing.T) {
+	tests := []struct {
+		path, prefix, expected string
+	}{
+		{`C:\data`, "/mnt", "/mnt/c/data"},
+		{`d:\some\dir`, "/host/", "/host/d/some/dir"},
+		{"C:/data", "/mnt", "/mnt/c/data"},
+	}
+
+	for _, test := range tests {
+		if !IsWindowsPath(test.path) {
+			t.Errorf("Expected %q to be a Windows path", test.path)
+		}
+		if result := TranslateWindowsPath(test.path, test.prefix); result != test.expected {
+			t.Errorf("Expected %q, got %q", test.expected, result)
+		}
+	}
+
+	if IsWindowsPath("/data") || IsWindowsPath("c:data") {
+		t.Errorf("Expected Linux and drive relative paths not to be Windows paths")
+	}
+}
+
+func TestObjectName(t *testing.T) {
+	long := strings.Repeat("a", 70)
+	tests := []struct {
+		strategy, service, expected string
+	}{
+		{"service", "web", "web"},
+		{"project", "web", "shop-web"},
+		{"{{.Service}}-{{.Project}}", "web", "web-shop"},
+		{"{{.Project}}_{{.Service}}", "web", "shop-web"},
+		{"service", long, TruncateName(long)},
+	}
+
+	for _, test := range tests {
+		name, err := ObjectName(test.strategy, "shop", test.service)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", test.strategy, err)
+		}
+		if name != test.expected {
+			t.Errorf("Expected %q, got %q", test.expected, name)
+		}
+	}
+
+	truncated := TruncateName(long)
+	if len(truncated) != MaxNameLength {
+		t.Errorf("Expected %q to be truncated to %d characters", truncated, MaxNameLength)
+	}
+	if truncated == TruncateName(long+"b") {
+		t.Errorf("Expected different names to stay different after truncation")
+	}
+
+	if name, _ := ObjectName("project", "", "web"); name != "web" {
+		t.Errorf("Expected no prefix without a project name, got %q", name)
+	}
+
+	if _, err := ObjectName("{{.Unknown}}", "shop", "web"); err == nil {
+		t.Errorf("Expected an error for an invalid template")
+	}
+}
+
+func TestRenameServices(t *testing.T) {
+	komposeObject := kobject.KomposeObject{
+		ServiceConfigs: map[string]kobject.ServiceConfig{
+			"web": {
+				DependsOn: []string{"db"},
+				Volumes:   []kobject.Volumes{{SvcName: "web", VFrom: "db", PVCName: "web-claim0"}},
+			},
+			"db": {},
+		},
+	}
+
+	opt := kobject.ConvertOptions{NameStrategy: NameStrategyProject, ProjectName: "Shop"}
+	if err := RenameServices(&komposeObject, opt); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	web, ok := komposeObject.ServiceConfigs["shop-web"]
+	if !ok {
+		t.Fatalf("Expected service web to be renamed to shop-web, got %v", komposeObject.ServiceConfigs)
+	}
+	if _, ok := komposeObject.ServiceConfigs["shop-db"]; !ok {
+		t.Errorf("Expected service db to be renamed to shop-db")
+	}
+	if web.DependsOn[0] != "shop-db" {
+		t.Errorf("Expected depends_on to reference shop-db, got %s", web.DependsOn[0])
+	}
+	volume := web.Volumes[0]
+	if volume.SvcName != "shop-web" || volume.VFrom != "shop-db" || volume.PVCName != "shop-web-claim0" {
+		t.Errorf("Expected the volume to reference the renamed services, got %+v", volume)
+	}
+
+	// a project without name adds no prefix
+	komposeObject = kobject.KomposeObject{ServiceConfigs: map[string]kobject.ServiceConfig{"web": {}}}
+	if err := RenameServices(&komposeObject, kobject.ConvertOptions{NameStrategy: NameStrategyProject}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := komposeObject.ServiceConfigs["web"]; !ok {
+		t.Errorf("Expected service web to keep its name without a project name, got %v", komposeObject.ServiceConfigs)
+	}
+}
+
+func TestReportUnused(t *testing.T) {
+	newKomposeObject := func() kobject.KomposeObject {
+		return kobject.KomposeObject{
+			Secrets: map[string]dockerCliTypes.SecretConfig{
+				"token": {},
+				"stale": {},
+			},
+			Unused: kobject.UnusedResources{Volumes: []string{"cache"}, Secrets: []string{"stale"}},
+		}
+	}
+
+	komposeObject := newKomposeObject()
+	ReportUnused(&komposeObject, false)
+	if len(komposeObject.Secrets) != 2 {
+		t.Errorf("Expected the unused secret to be kept without pruning, got %v", komposeObject.Secrets)
+	}
+
+	komposeObject = newKomposeObject()
+	ReportUnused(&komposeObject, true)
+	if _, ok := komposeObject.Secrets["stale"]; ok {
+		t.Errorf("Expected the unused secret to be pruned")
+	}
+	if _, ok := komposeObject.Secrets["token"]; !ok {
+		t.Errorf("Expected the used secret to be kept")
+	}
+}
+
+func TestFilterEnv(t *testing.T) {
+	opt := kobject.ConvertOptions{EnvExclude: []string{"DOCKER_*", "DEBUG"}, EnvMask: []string{"*_PASSWORD"}}
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+		ok       bool
+	}{
+		{"DOCKER_HOST", "tcp://localhost", "", false},
+		{"DEBUG", "1", "", false},
+		{"DEBUGGER", "1", "1", true},
+		{"DB_PASSWORD", "secret", "${DB_PASSWORD}", true},
+		{"PORT", "80", "80", true},
+	}
+	for _, test := range tests {
+		value, ok := FilterEnv(test.name, test.value, opt)
+		if value != test.expected || ok != test.ok {
+			t.Errorf("FilterEnv(%q) = %q, %v, expected %q, %v", test.name, value, ok, test.expected, test.ok)
+		}
+	}
+}
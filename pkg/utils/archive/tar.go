@@ -18,10 +18,13 @@ package archive
 
 import (
 	"archive/tar"
+	"compress/gzip"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/pkg/errors"
 )
 
 /*
@@ -36,12 +39,39 @@ func CreateTarball(source, target string) error {
 	}
 	defer tarfile.Close()
 
-	tarball := tar.NewWriter(tarfile)
-	defer tarball.Close()
+	return writeTarball(source, tarfile)
+}
+
+// CreateGzipTarball creates a gzip compressed tarball for source and dumps it to target path. Closing
+// the gzip writer and the file flushes the end of the archive, so their errors are returned too.
+func CreateGzipTarball(source, target string) error {
+	tarfile, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(tarfile)
+
+	err = writeTarball(source, gz)
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := tarfile.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeTarball writes a tarball of source to w, returning the error of closing the tar writer,
+// which writes the end of the archive
+func writeTarball(source string, w io.Writer) error {
+	tarball := tar.NewWriter(w)
 
 	info, err := os.Stat(source)
 	if err != nil {
-		return nil
+		if closeErr := tarball.Close(); closeErr != nil {
+			return errors.Wrapf(err, "unable to close the tarball (%v)", closeErr)
+		}
+		return err
 	}
 
 	var baseDir string
@@ -49,9 +79,9 @@ func CreateTarball(source, target string) error {
 		baseDir = filepath.Base(source)
 	}
 
-	return filepath.Walk(source,
+	err = filepath.Walk(source,
 		func(path string, info os.FileInfo, err error) error {
-			if baseDir == path {
+			if baseDir == path || (baseDir != "" && path == source) {
 				return nil
 			}
 			if err != nil {
@@ -87,4 +117,8 @@ func CreateTarball(source, target string) error {
 			_, err = io.Copy(tarball, file)
 			return err
 		})
+	if closeErr := tarball.Close(); err == nil {
+		err = closeErr
+	}
+	return err
 }
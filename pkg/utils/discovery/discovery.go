@@ -0,0 +1,339 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Timeout limits the discovery requests, so an unreachable cluster doesn't block the conversion
+var Timeout = 10 * time.Second
+
+// ServiceAccountDir is the directory the token and certificate authority of the service account
+// of a pod are mounted at
+var ServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// kubeconfig holds the part of a kubeconfig file needed to reach the cluster of the current context
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+			Username              string `yaml:"username"`
+			Password              string `yaml:"password"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// Cluster is the API server of the current context of a kubeconfig file
+type Cluster struct {
+	Server string
+
+	client   *http.Client
+	token    string
+	username string
+	password string
+
+	// resources caches the resources of the group/versions, see Resource
+	resources map[string][]metav1.APIResource
+}
+
+// KubeconfigPath returns the kubeconfig file to use: path if set, else the first file of
+// $KUBECONFIG, else ~/.kube/config. It returns an empty string when no such file exists.
+func KubeconfigPath(path string) string {
+	if path != "" {
+		return path
+	}
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		path = filepath.SplitList(env)[0]
+	} else if home, err := os.UserHomeDir(); err == nil {
+		path = filepath.Join(home, ".kube", "config")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// NewCluster reads the cluster and user of the current context from the kubeconfig file at path
+func NewCluster(path string) (*Cluster, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the kubeconfig")
+	}
+	var config kubeconfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse the kubeconfig %s", path)
+	}
+	dir := filepath.Dir(path)
+
+	var clusterName, userName string
+	found := false
+	for _, context := range config.Contexts {
+		if context.Name == config.CurrentContext {
+			clusterName, userName, found = context.Context.Cluster, context.Context.User, true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("the current context %q of the kubeconfig %s doesn't exist", config.CurrentContext, path)
+	}
+
+	c := &Cluster{}
+	tlsConfig := &tls.Config{}
+	for _, cluster := range config.Clusters {
+		if cluster.Name != clusterName {
+			continue
+		}
+		c.Server = strings.TrimSuffix(cluster.Cluster.Server, "/")
+		tlsConfig.InsecureSkipVerify = cluster.Cluster.InsecureSkipTLSVerify
+		ca, err := fileOrData(dir, cluster.Cluster.CertificateAuthority, cluster.Cluster.CertificateAuthorityData)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read the certificate authority")
+		}
+		if ca != nil {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("invalid certificate authority of cluster %s", clusterName)
+			}
+		}
+	}
+	if c.Server == "" {
+		return nil, fmt.Errorf("the cluster %q of the kubeconfig %s has no server", clusterName, path)
+	}
+
+	for _, user := range config.Users {
+		if user.Name != userName {
+			continue
+		}
+		c.token, c.username, c.password = user.User.Token, user.User.Username, user.User.Password
+		if user.User.TokenFile != "" {
+			token, err := ioutil.ReadFile(resolve(dir, user.User.TokenFile))
+			if err != nil {
+				return nil, errors.Wrap(err, "unable to read the token file")
+			}
+			c.token = strings.TrimSpace(string(token))
+		}
+		cert, err := fileOrData(dir, user.User.ClientCertificate, user.User.ClientCertificateData)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read the client certificate")
+		}
+		key, err := fileOrData(dir, user.User.ClientKey, user.User.ClientKeyData)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read the client key")
+		}
+		if cert != nil && key != nil {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid client certificate")
+			}
+			tlsConfig.Certificates = []tls.Certificate{pair}
+		}
+	}
+
+	c.client = newClient(tlsConfig)
+	return c, nil
+}
+
+// InCluster returns true when kompose runs in a pod, where the API server is reachable with the
+// service account of the pod
+func InCluster() bool {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" || os.Getenv("KUBERNETES_SERVICE_PORT") == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(ServiceAccountDir, "token"))
+	return err == nil
+}
+
+// NewInCluster returns the cluster kompose runs in, authenticated with the service account of
+// its pod, the way in-cluster clients of client-go are configured
+func NewInCluster() (*Cluster, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set to run in the cluster")
+	}
+	token, err := ioutil.ReadFile(filepath.Join(ServiceAccountDir, "token"))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the token of the service account")
+	}
+	return NewTokenCluster("https://"+net.JoinHostPort(host, port), strings.TrimSpace(string(token)), filepath.Join(ServiceAccountDir, "ca.crt"))
+}
+
+// NewTokenCluster returns the cluster of the API server at server, authenticated with the bearer
+// token if set and verified with the certificate authority of the file caFile if set
+func NewTokenCluster(server, token, caFile string) (*Cluster, error) {
+	tlsConfig := &tls.Config{}
+	if caFile != "" {
+		ca, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read the certificate authority")
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("invalid certificate authority %s", caFile)
+		}
+	}
+	return &Cluster{
+		Server: strings.TrimSuffix(server, "/"),
+		client: newClient(tlsConfig),
+		token:  token,
+	}, nil
+}
+
+// newClient returns the HTTP client of the discovery requests
+func newClient(tlsConfig *tls.Config) *http.Client {
+	return &http.Client{
+		Timeout:   Timeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+	}
+}
+
+// fileOrData returns the base64 encoded data, or else the content of file relative to dir
+func fileOrData(dir, file, data string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if file != "" {
+		return ioutil.ReadFile(resolve(dir, file))
+	}
+	return nil, nil
+}
+
+// resolve returns path relative to dir, as kubectl resolves the paths of a kubeconfig
+func resolve(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// get decodes the JSON response of the API server to path into v
+func (c *Cluster) get(path string, v interface{}) error {
+	return c.do(http.MethodGet, path, "", nil, v)
+}
+
+// do sends a request with body of contentType to the API server and decodes the JSON response
+// into v, if v is not nil
+func (c *Cluster) do(method, path, contentType string, body []byte, v interface{}) error {
+	req, err := http.NewRequest(method, c.Server+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{Method: method, URL: c.Server + path, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// StatusError is the error of a request the API server answered with an error status
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s returned %s", e.Method, e.URL, e.Status)
+}
+
+// IsNotFound returns true if err is a StatusError of a missing object
+func IsNotFound(err error) bool {
+	statusErr, ok := errors.Cause(err).(*StatusError)
+	return ok && statusErr.StatusCode == http.StatusNotFound
+}
+
+// ServedGroupVersions returns the group/versions served by the cluster, like "v1" or "apps/v1",
+// the way they are written in the apiVersion of the objects
+func (c *Cluster) ServedGroupVersions() (map[string]bool, error) {
+	served := make(map[string]bool)
+
+	var core metav1.APIVersions
+	if err := c.get("/api", &core); err != nil {
+		return nil, errors.Wrap(err, "unable to discover the core API versions")
+	}
+	for _, version := range core.Versions {
+		served[version] = true
+	}
+
+	var groups metav1.APIGroupList
+	if err := c.get("/apis", &groups); err != nil {
+		return nil, errors.Wrap(err, "unable to discover the API groups")
+	}
+	for _, group := range groups.Groups {
+		for _, version := range group.Versions {
+			served[version.GroupVersion] = true
+		}
+	}
+	log.Debugf("Cluster %s serves %d group versions", c.Server, len(served))
+	return served, nil
+}
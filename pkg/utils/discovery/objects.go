@@ -0,0 +1,119 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Get decodes the object or list of the API path, like /api/v1/namespaces/default/services, into v
+func (c *Cluster) Get(path string, v interface{}) error {
+	return c.get(path, v)
+}
+
+// groupVersionPath returns the API path of a group/version, /api/v1 for the core group
+func groupVersionPath(apiVersion string) string {
+	if strings.Contains(apiVersion, "/") {
+		return "/apis/" + apiVersion
+	}
+	return "/api/" + apiVersion
+}
+
+// Resource returns the resource of a kind, like deployments for Deployment, and whether it is
+// namespaced. The resources of a group/version are discovered once.
+func (c *Cluster) Resource(apiVersion, kind string) (string, bool, error) {
+	resources, ok := c.resources[apiVersion]
+	if !ok {
+		var list metav1.APIResourceList
+		if err := c.get(groupVersionPath(apiVersion), &list); err != nil {
+			return "", false, errors.Wrapf(err, "unable to discover the resources of %s", apiVersion)
+		}
+		if c.resources == nil {
+			c.resources = map[string][]metav1.APIResource{}
+		}
+		resources = list.APIResources
+		c.resources[apiVersion] = resources
+	}
+	for _, resource := range resources {
+		// subresources like deployments/scale have the kind of their object too
+		if resource.Kind == kind && !strings.Contains(resource.Name, "/") {
+			return resource.Name, resource.Namespaced, nil
+		}
+	}
+	return "", false, fmt.Errorf("the cluster serves no %s of %s", kind, apiVersion)
+}
+
+// ObjectPath returns the API path of an object, the namespace is ignored for the objects that
+// aren't namespaced
+func (c *Cluster) ObjectPath(apiVersion, kind, namespace, name string) (string, error) {
+	resource, namespaced, err := c.Resource(apiVersion, kind)
+	if err != nil {
+		return "", err
+	}
+	path := groupVersionPath(apiVersion)
+	if namespaced {
+		path += "/namespaces/" + url.PathEscape(namespace)
+	}
+	return path + "/" + resource + "/" + url.PathEscape(name), nil
+}
+
+// Apply creates or updates an object with a server-side apply by fieldManager, taking over the
+// fields other managers set, the way kubectl apply --server-side --force-conflicts does
+func (c *Cluster) Apply(obj *unstructured.Unstructured, fieldManager string) error {
+	path, err := c.ObjectPath(obj.GetAPIVersion(), obj.GetKind(), obj.GetNamespace(), obj.GetName())
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return err
+	}
+	query := url.Values{"fieldManager": {fieldManager}, "force": {"true"}}
+	// JSON is YAML, which apply patches are written in
+	return c.do(http.MethodPatch, path+"?"+query.Encode(), "application/apply-patch+yaml", data, nil)
+}
+
+// MergePatch patches the object or subresource of the API path with a JSON merge patch
+func (c *Cluster) MergePatch(path string, patch interface{}) error {
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	return c.do(http.MethodPatch, path, "application/merge-patch+json", data, nil)
+}
+
+// Delete deletes an object and, in the background, the objects it owns. Objects that don't exist
+// are not an error.
+func (c *Cluster) Delete(apiVersion, kind, namespace, name string) error {
+	path, err := c.ObjectPath(apiVersion, kind, namespace, name)
+	if err != nil {
+		return err
+	}
+	err = c.do(http.MethodDelete, path+"?propagationPolicy=Background", "", nil, nil)
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
+}
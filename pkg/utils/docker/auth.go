@@ -0,0 +1,149 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	dockerlib "github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// dockerHubServer is the key of Docker Hub in the Docker config file and the credential helpers
+const dockerHubServer = "https://index.docker.io/v1/"
+
+// credentialHelpers holds the credential helpers set in the Docker config file
+type credentialHelpers struct {
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// helperCredentials is the output of "docker-credential-<helper> get"
+type helperCredentials struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+// dockerConfigFile returns the path of the Docker config file, as the docker CLI finds it
+func dockerConfigFile() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// HelperCredentials returns the credentials of registry stored by the credential helper set in
+// the credHelpers or credsStore of the Docker config file, or nil when there is no helper or
+// the helper has no credentials for registry
+func HelperCredentials(registry string) (*dockerlib.AuthConfiguration, error) {
+	path := dockerConfigFile()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) || path == "" {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "unable to read the Docker config file")
+	}
+	var config credentialHelpers
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse the Docker config file %s", path)
+	}
+
+	server := registry
+	if registry == "docker.io" {
+		server = dockerHubServer
+	}
+	helper, ok := config.CredHelpers[server]
+	if !ok {
+		helper, ok = config.CredHelpers[registry]
+	}
+	if !ok {
+		helper = config.CredsStore
+	}
+	if helper == "" {
+		return nil, nil
+	}
+
+	log.Debugf("Retrieving the credentials of registry '%s' from credential helper '%s'", registry, helper)
+	var stderr bytes.Buffer
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// helpers print this message, on stdout, for registries they have no credentials of
+		if strings.Contains(string(out), "credentials not found") {
+			return nil, nil
+		}
+		if message := strings.TrimSpace(stderr.String() + string(out)); message != "" {
+			return nil, errors.Wrapf(err, "credential helper '%s' failed: %s", helper, message)
+		}
+		return nil, errors.Wrapf(err, "credential helper '%s' failed", helper)
+	}
+
+	var credentials helperCredentials
+	if err := json.Unmarshal(out, &credentials); err != nil {
+		return nil, errors.Wrapf(err, "invalid output of credential helper '%s'", helper)
+	}
+	auth := &dockerlib.AuthConfiguration{ServerAddress: server}
+	// identity tokens are stored with the "<token>" user name
+	if credentials.Username == "<token>" {
+		auth.IdentityToken = credentials.Secret
+	} else {
+		auth.Username, auth.Password = credentials.Username, credentials.Secret
+	}
+	return auth, nil
+}
+
+// RegistryCredentials returns the credentials of registry from its credential helper, else from
+// the auths of the Docker config files, or nil when there are none
+func RegistryCredentials(registry string) *dockerlib.AuthConfiguration {
+	auth, err := HelperCredentials(registry)
+	if err != nil {
+		log.WithField("category", "images").Warn(errors.Wrap(err, "Unable to retrieve the credentials from the Docker credential helper"))
+	}
+	if auth != nil {
+		return auth
+	}
+
+	configs, err := dockerlib.NewAuthConfigurationsFromDockerCfg()
+	if err != nil {
+		return nil
+	}
+	for server, config := range configs.Configs {
+		// the keys are host names, or URLs like https://index.docker.io/v1/
+		host := strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+		host = strings.SplitN(host, "/", 2)[0]
+		if host == registry || (registry == "docker.io" && server == dockerHubServer) {
+			config.ServerAddress = server
+			return &config
+		}
+	}
+	return nil
+}
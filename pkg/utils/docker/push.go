@@ -18,6 +18,9 @@ package docker
 
 import (
 	"bytes"
+	"fmt"
+	"net"
+
 	dockerlib "github.com/fsouza/go-dockerclient"
 	"github.com/novln/docker-parser"
 	"github.com/pkg/errors"
@@ -27,6 +30,10 @@ import (
 // Push will provide methods for interaction with API regarding pushing images
 type Push struct {
 	Client dockerlib.Client
+	// Auth, if set, is the only credentials the image is pushed with
+	Auth *dockerlib.AuthConfiguration
+	// Insecure expects the registry to be an insecure registry of the Docker daemon
+	Insecure bool
 }
 
 /*
@@ -54,34 +61,24 @@ func (c *Push) PushImage(fullImageName string) error {
 		OutputStream: outputBuffer,
 	}
 
-	// Retrieve the authentication configuration file
-	// Files checked as per https://godoc.org/github.com/fsouza/go-dockerclient#NewAuthConfigurationsFromFile
-	// $DOCKER_CONFIG/config.json, $HOME/.docker/config.json , $HOME/.dockercfg
-	credentials, err := dockerlib.NewAuthConfigurationsFromDockerCfg()
-	if err != nil {
-		log.Warn(errors.Wrap(err, "Unable to retrieve .docker/config.json authentication details. Check that 'docker login' works successfully on the command line."))
-	}
-
-	// Fallback to unauthenticated access in case if no auth credentials are retrieved
-	if credentials == nil || len(credentials.Configs) == 0 {
-		log.Info("Authentication credentials are not detected. Will try push without authentication.")
-		credentials = &dockerlib.AuthConfigurations{
-			Configs: map[string]dockerlib.AuthConfiguration{
-				registry: {},
-			},
+	if c.Insecure {
+		if err := c.checkInsecure(registry); err != nil {
+			return err
 		}
 	}
 
+	credentials := c.credentials(registry)
+
 	// Push the image to the repository (based on the URL)
 	// We will iterate through all available authentication configurations until we find one that pushes successfully
 	// and then return nil.
-	if len(credentials.Configs) > 1 {
+	if len(credentials) > 1 {
 		log.Info("Multiple authentication credentials detected. Will try each configuration.")
 	}
 
-	for k, v := range credentials.Configs {
-		log.Infof("Attempting authentication credentials '%s", k)
-		err = c.Client.PushImage(options, v)
+	for _, credential := range credentials {
+		log.Infof("Attempting authentication credentials '%s'", credential.ServerAddress)
+		err = c.Client.PushImage(options, credential)
 		if err != nil {
 			log.Errorf("Unable to push image '%s' to registry '%s'. Error: %s", image, registry, err)
 		} else {
@@ -93,3 +90,76 @@ func (c *Push) PushImage(fullImageName string) error {
 
 	return errors.New("unable to push docker image(s). Check that `docker login` works successfully on the command line")
 }
+
+// credentials returns the credentials to try pushing to registry with, in order: the credentials
+// given by Auth, else those of the credential helper of the registry followed by those of the
+// Docker config files, else none
+func (c *Push) credentials(registry string) []dockerlib.AuthConfiguration {
+	if c.Auth != nil {
+		auth := *c.Auth
+		auth.ServerAddress = registry
+		return []dockerlib.AuthConfiguration{auth}
+	}
+
+	var credentials []dockerlib.AuthConfiguration
+	helper, err := HelperCredentials(registry)
+	if err != nil {
+		log.WithField("category", "images").Warn(errors.Wrap(err, "Unable to retrieve the credentials from the Docker credential helper"))
+	}
+	if helper != nil {
+		credentials = append(credentials, *helper)
+	}
+
+	// Retrieve the authentication configuration file
+	// Files checked as per https://godoc.org/github.com/fsouza/go-dockerclient#NewAuthConfigurationsFromFile
+	// $DOCKER_CONFIG/config.json, $HOME/.docker/config.json , $HOME/.dockercfg
+	configs, err := dockerlib.NewAuthConfigurationsFromDockerCfg()
+	if err != nil && helper == nil {
+		log.WithField("category", "images").Warn(errors.Wrap(err, "Unable to retrieve .docker/config.json authentication details. Check that 'docker login' works successfully on the command line."))
+	}
+	if configs != nil {
+		for server, config := range configs.Configs {
+			config.ServerAddress = server
+			credentials = append(credentials, config)
+		}
+	}
+
+	// Fallback to unauthenticated access in case if no auth credentials are retrieved
+	if len(credentials) == 0 {
+		log.Info("Authentication credentials are not detected. Will try push without authentication.")
+		credentials = append(credentials, dockerlib.AuthConfiguration{ServerAddress: registry})
+	}
+	return credentials
+}
+
+// checkInsecure returns an error if the Docker daemon, which pushes the image, doesn't treat
+// registry as an insecure registry, since kompose can't change the registries the daemon trusts
+func (c *Push) checkInsecure(registry string) error {
+	info, err := c.Client.Info()
+	if err != nil {
+		return errors.Wrap(err, "unable to retrieve the configuration of the Docker daemon")
+	}
+	if info.RegistryConfig == nil {
+		log.WithField("category", "images").Warnf("Unable to check that the Docker daemon treats registry '%s' as insecure", registry)
+		return nil
+	}
+
+	if index, ok := info.RegistryConfig.IndexConfigs[registry]; ok {
+		if !index.Secure {
+			return nil
+		}
+	} else {
+		host := registry
+		if h, _, err := net.SplitHostPort(registry); err == nil {
+			host = h
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			for _, cidr := range info.RegistryConfig.InsecureRegistryCIDRs {
+				if (*net.IPNet)(cidr).Contains(ip) {
+					return nil
+				}
+			}
+		}
+	}
+	return fmt.Errorf("the Docker daemon doesn't treat registry '%s' as insecure, add it to the \"insecure-registries\" of its daemon.json", registry)
+}
@@ -0,0 +1,292 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	dockerlib "github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// HelmConfigMediaType is the media type of the config of a Helm chart, its Chart.yaml as JSON
+	HelmConfigMediaType = "application/vnd.cncf.helm.config.v1+json"
+	// HelmChartMediaType is the media type of the packaged Helm chart
+	HelmChartMediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+
+	manifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+)
+
+// Timeout limits every request to the registry
+var Timeout = 5 * time.Minute
+
+// descriptor references a blob of an OCI manifest
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int    `json:"size"`
+}
+
+// manifest is an OCI image manifest
+type manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	Config        descriptor        `json:"config"`
+	Layers        []descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Repository is a repository of an OCI registry, like oci://registry.example.com/charts/web
+type Repository struct {
+	// Host is the host and port of the registry
+	Host string
+	// Name is the path of the repository in the registry
+	Name string
+	// PlainHTTP reaches the registry over HTTP instead of HTTPS
+	PlainHTTP bool
+	// Auth holds the credentials, nil for anonymous access
+	Auth *dockerlib.AuthConfiguration
+
+	client *http.Client
+	token  string
+}
+
+// ParseReference splits a reference like oci://registry.example.com/charts into the host of the
+// registry and the path of the repository
+func ParseReference(ref string) (string, string, error) {
+	if !strings.HasPrefix(ref, "oci://") {
+		return "", "", fmt.Errorf("invalid OCI reference %q, expected oci://registry/repository", ref)
+	}
+	parts := strings.SplitN(strings.Trim(strings.TrimPrefix(ref, "oci://"), "/"), "/", 2)
+	if parts[0] == "" {
+		return "", "", fmt.Errorf("invalid OCI reference %q, the registry is missing", ref)
+	}
+	if len(parts) == 1 {
+		return parts[0], "", nil
+	}
+	return parts[0], parts[1], nil
+}
+
+// PushHelmChart pushes a packaged chart and its config as the tag of the repository, the way
+// "helm push" does, and returns the digest of the manifest
+func (r *Repository) PushHelmChart(chart, config []byte, tag string, annotations map[string]string) (string, error) {
+	if r.client == nil {
+		r.client = &http.Client{Timeout: Timeout}
+	}
+
+	configDescriptor, err := r.pushBlob(HelmConfigMediaType, config)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to push the chart config")
+	}
+	chartDescriptor, err := r.pushBlob(HelmChartMediaType, chart)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to push the chart")
+	}
+
+	data, err := json.Marshal(manifest{
+		SchemaVersion: 2,
+		MediaType:     manifestMediaType,
+		Config:        configDescriptor,
+		Layers:        []descriptor{chartDescriptor},
+		Annotations:   annotations,
+	})
+	if err != nil {
+		return "", err
+	}
+	resp, err := r.do(http.MethodPut, r.url("/manifests/"+tag), manifestMediaType, data)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to push the manifest")
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to push the manifest: %s", resp.Status)
+	}
+	return digest(data), nil
+}
+
+// pushBlob uploads data in a single request, unless the registry has it already
+func (r *Repository) pushBlob(mediaType string, data []byte) (descriptor, error) {
+	d := descriptor{MediaType: mediaType, Digest: digest(data), Size: len(data)}
+
+	resp, err := r.do(http.MethodHead, r.url("/blobs/"+d.Digest), "", nil)
+	if err != nil {
+		return d, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		log.Debugf("Blob %s exists in %s/%s", d.Digest, r.Host, r.Name)
+		return d, nil
+	}
+
+	resp, err = r.do(http.MethodPost, r.url("/blobs/uploads/"), "", nil)
+	if err != nil {
+		return d, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return d, fmt.Errorf("unable to start the upload: %s", resp.Status)
+	}
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return d, errors.Wrap(err, "invalid upload location")
+	}
+	query := location.Query()
+	query.Set("digest", d.Digest)
+	location.RawQuery = query.Encode()
+
+	resp, err = r.do(http.MethodPut, location.String(), "application/octet-stream", data)
+	if err != nil {
+		return d, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return d, fmt.Errorf("unable to upload the blob: %s", resp.Status)
+	}
+	return d, nil
+}
+
+// url returns the URL of path in the repository
+func (r *Repository) url(path string) string {
+	scheme := "https"
+	if r.PlainHTTP {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/v2/%s%s", scheme, r.Host, r.Name, path)
+}
+
+// do sends a request, authenticating and retrying once if the registry asks for it
+func (r *Repository) do(method, target, contentType string, body []byte) (*http.Response, error) {
+	send := func() (*http.Response, error) {
+		req, err := http.NewRequest(method, target, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if r.token != "" {
+			req.Header.Set("Authorization", "Bearer "+r.token)
+		} else if r.Auth != nil && r.Auth.Username != "" {
+			req.SetBasicAuth(r.Auth.Username, r.Auth.Password)
+		}
+		return r.client.Do(req)
+	}
+
+	resp, err := send()
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	resp.Body.Close()
+	if err := r.authenticate(resp.Header.Get("WWW-Authenticate")); err != nil {
+		return nil, err
+	}
+	return send()
+}
+
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// authenticate gets a token from the authorization server of a Bearer challenge. Basic challenges
+// are answered by sending the credentials with every request.
+func (r *Repository) authenticate(challenge string) error {
+	if r.Auth != nil && r.Auth.RegistryToken != "" {
+		r.token = r.Auth.RegistryToken
+		return nil
+	}
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		if r.Auth == nil || r.Auth.Username == "" {
+			return errors.New("the registry requires credentials")
+		}
+		return nil
+	}
+
+	params := map[string]string{}
+	for _, match := range challengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[match[1]] = match[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return fmt.Errorf("invalid authentication challenge %q", challenge)
+	}
+	scope := fmt.Sprintf("repository:%s:pull,push", r.Name)
+
+	var req *http.Request
+	if r.Auth != nil && r.Auth.IdentityToken != "" {
+		form := url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {r.Auth.IdentityToken},
+			"service":       {params["service"]},
+			"scope":         {scope},
+			"client_id":     {"kompose"},
+		}
+		req, err = http.NewRequest(http.MethodPost, realm.String(), strings.NewReader(form.Encode()))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		query := realm.Query()
+		query.Set("service", params["service"])
+		query.Set("scope", scope)
+		realm.RawQuery = query.Encode()
+		req, err = http.NewRequest(http.MethodGet, realm.String(), nil)
+		if err != nil {
+			return err
+		}
+		if r.Auth != nil && r.Auth.Username != "" {
+			req.SetBasicAuth(r.Auth.Username, r.Auth.Password)
+		}
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "unable to authenticate to the registry")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unable to authenticate to the registry: %s %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return errors.Wrap(err, "invalid response of the authorization server")
+	}
+	r.token = token.Token
+	if r.token == "" {
+		r.token = token.AccessToken
+	}
+	return nil
+}
+
+// digest returns the sha256 digest of data
+func digest(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2017 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sops decrypts the compose and env files encrypted with SOPS, https://github.com/getsops/sops,
+// with the sops binary. The decrypted content is only kept in memory, for the time of a read.
+package sops
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultBinary is the sops binary files are decrypted with, looked up in the PATH
+const DefaultBinary = "sops"
+
+// Options are the options of the decryption of the files of a conversion. The zero value decrypts
+// with the sops binary of the PATH and the keys sops finds in its environment, like SOPS_AGE_KEY
+// or the credentials of the cloud KMS.
+type Options struct {
+	// Binary is the sops binary, DefaultBinary when empty
+	Binary string
+	// AgeKeyFile is the file of the age keys files are decrypted with, passed to sops as
+	// SOPS_AGE_KEY_FILE
+	AgeKeyFile string
+	// Disabled refuses the encrypted files instead of decrypting them, for the conversions of the
+	// files of other users, who mustn't decrypt files with the keys of the machine converting them
+	Disabled bool
+}
+
+// dotenvMAC matches the MAC sops adds to the dotenv files it encrypts
+var dotenvMAC = regexp.MustCompile(`(?m)^sops_mac=`)
+
+// format returns the sops input type of data, yaml, json or dotenv, if sops encrypted it, else ""
+func format(data []byte) string {
+	var file struct {
+		SOPS struct {
+			MAC string `yaml:"mac"`
+		} `yaml:"sops"`
+	}
+	if err := yaml.Unmarshal(data, &file); err == nil && file.SOPS.MAC != "" {
+		// JSON is valid YAML
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+			return "json"
+		}
+		return "yaml"
+	}
+	if dotenvMAC.Match(data) {
+		return "dotenv"
+	}
+	return ""
+}
+
+// IsEncrypted returns whether data is a YAML, JSON or dotenv file encrypted by sops
+func IsEncrypted(data []byte) bool {
+	return format(data) != ""
+}
+
+// Decrypt returns data decrypted by sops if it is encrypted, else data. name is only used in the
+// messages. sops reads files rather than stdin on every platform, so the encrypted data is
+// written to a temporary file first.
+func (o Options) Decrypt(name string, data []byte) ([]byte, error) {
+	inputType := format(data)
+	if inputType == "" {
+		return data, nil
+	}
+	if o.Disabled {
+		return nil, errors.Errorf("%s is encrypted with sops, which can't be decrypted by this conversion", name)
+	}
+	f, err := ioutil.TempFile("", "kompose-sops-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return o.decrypt(name, f.Name(), inputType)
+}
+
+// decrypt runs sops to decrypt file of inputType
+func (o Options) decrypt(name, file, inputType string) ([]byte, error) {
+	binary := o.Binary
+	if binary == "" {
+		binary = DefaultBinary
+	}
+	log.Debugf("Decrypting %s with %s", name, binary)
+	var stderr bytes.Buffer
+	cmd := exec.Command(binary, "--decrypt", "--input-type", inputType, "--output-type", inputType, file)
+	cmd.Stderr = &stderr
+	cmd.Env = os.Environ()
+	if o.AgeKeyFile != "" {
+		cmd.Env = append(cmd.Env, "SOPS_AGE_KEY_FILE="+o.AgeKeyFile)
+	}
+	plain, err := cmd.Output()
+	if err != nil {
+		if _, ok := err.(*exec.Error); ok {
+			return nil, errors.Wrapf(err, "%s is encrypted with sops, which must be installed to decrypt it", name)
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, errors.Wrapf(err, "unable to decrypt %s: %s", name, message)
+		}
+		return nil, errors.Wrapf(err, "unable to decrypt %s", name)
+	}
+	return plain, nil
+}
+
+// ReadFile reads file, decrypted if sops encrypted it
+func (o Options) ReadFile(file string) ([]byte, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	inputType := format(data)
+	if inputType == "" {
+		return data, nil
+	}
+	if o.Disabled {
+		return nil, errors.Errorf("%s is encrypted with sops, which can't be decrypted by this conversion", file)
+	}
+	return o.decrypt(file, file, inputType)
+}
+
+// ReadEnvFile reads the variables of an env_file, decrypted if sops encrypted it
+func (o Options) ReadEnvFile(file string) (map[string]string, error) {
+	data, err := o.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return godotenv.Unmarshal(string(data))
+}